@@ -1,72 +1,667 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/hgsgtk/mygit/commands"
 )
 
+// jsonOutput is set by --json: commands that support it print JSON, and
+// errors are reported as JSON objects
+var jsonOutput bool
+
 func main() {
-	if len(os.Args) < 2 {
+	args := os.Args[1:]
+
+	// Global options come before the command. Like git -C, -C runs as if
+	// started in another directory; several -C options are applied in order.
+	// --wait sets how long to wait for locks held by other processes.
+	// --json (or --format=json) selects JSON output.
+global:
+	for len(args) > 0 {
+		switch {
+		case args[0] == "-C":
+			if len(args) < 2 {
+				usage("-C requires a directory")
+			}
+			if err := os.Chdir(args[1]); err != nil {
+				fail(fmt.Errorf("cannot change to '%s': %w", args[1], err))
+			}
+			args = args[2:]
+		case args[0] == "--wait" || strings.HasPrefix(args[0], "--wait="):
+			timeout, ok := strings.CutPrefix(args[0], "--wait=")
+			args = args[1:]
+			if !ok {
+				if len(args) < 1 {
+					usage("--wait requires a duration")
+				}
+				timeout, args = args[0], args[1:]
+			}
+			if d, err := time.ParseDuration(timeout); err != nil || d < 0 {
+				usage("invalid --wait duration '%s'", timeout)
+			}
+			os.Setenv(commands.LockTimeoutEnv, timeout)
+		case args[0] == "--json" || args[0] == "--format=json":
+			jsonOutput = true
+			args = args[1:]
+		default:
+			break global
+		}
+	}
+
+	if len(args) < 1 {
 		printUsage()
-		os.Exit(1)
+		os.Exit(commands.ExitStatus(commands.CodeUsage))
 	}
 
-	command := os.Args[1]
-	args := os.Args[2:]
+	command := args[0]
+	args = args[1:]
 
 	switch command {
 	case "init":
 		if err := commands.Init(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			fail(err)
 		}
 	case "add":
-		if len(args) == 0 {
-			fmt.Fprintf(os.Stderr, "Error: add command requires file path(s)\n")
-			os.Exit(1)
+		addCmd := flag.NewFlagSet("add", flag.ContinueOnError)
+		all := addCmd.Bool("all", false, "stage new, modified and deleted files")
+		addCmd.BoolVar(all, "A", false, "stage new, modified and deleted files (shorthand)")
+		update := addCmd.Bool("update", false, "stage modified and deleted tracked files")
+		addCmd.BoolVar(update, "u", false, "stage modified and deleted tracked files (shorthand)")
+		force := addCmd.Bool("force", false, "add ignored files")
+		addCmd.BoolVar(force, "f", false, "add ignored files (shorthand)")
+		parseFlags(addCmd, args)
+
+		if addCmd.NArg() == 0 && !*all && !*update {
+			usage("add command requires file path(s)")
 		}
-		if err := commands.Add(args); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		if err := commands.AddWithOptions(addCmd.Args(), commands.AddOptions{All: *all, Update: *update, Force: *force}); err != nil {
+			fail(err)
 		}
 	case "commit":
-		commitCmd := flag.NewFlagSet("commit", flag.ExitOnError)
+		commitCmd := flag.NewFlagSet("commit", flag.ContinueOnError)
 		message := commitCmd.String("m", "", "commit message")
-		commitCmd.Parse(args)
-		
-		if *message == "" {
-			fmt.Fprintf(os.Stderr, "Error: commit message is required (-m flag)\n")
-			os.Exit(1)
+		author := commitCmd.String("author", "", "override the author, as 'Name <email>'")
+		date := commitCmd.String("date", "", "override the author date")
+		parseFlags(commitCmd, args)
+
+		// A merge in progress supplies its own message
+		if *message == "" && !commands.Merging() {
+			usage("commit message is required (-m flag)")
 		}
-		
-		if err := commands.Commit(*message); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+
+		var opts commands.CommitOptions
+		if *author != "" {
+			sig, err := commands.ParseSignature(*author)
+			if err != nil {
+				usage("%v", err)
+			}
+			opts.Author = sig
+		}
+		if *date != "" {
+			t, err := commands.ParseDate(*date)
+			if err != nil {
+				usage("%v", err)
+			}
+			opts.Date = t
+		}
+		commit := commands.CommitWithOptions
+		if jsonOutput {
+			commit = commands.CommitJSON
+		}
+		if err := commit(*message, opts); err != nil {
+			fail(err)
 		}
 	case "log":
-		if err := commands.Log(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		logCmd := flag.NewFlagSet("log", flag.ContinueOnError)
+		date := logCmd.String("date", "", "date format: default, local, iso, relative or raw")
+		maxCount := logCmd.Int("max-count", 0, "show at most this many commits")
+		logCmd.IntVar(maxCount, "n", 0, "show at most this many commits (shorthand)")
+		since := logCmd.String("since", "", "show commits after a date, e.g. \"2 weeks ago\"")
+		until := logCmd.String("until", "", "show commits before a date")
+		author := logCmd.String("author", "", "show commits whose author matches a regular expression")
+		grep := logCmd.String("grep", "", "show commits whose message matches a regular expression")
+		firstParent := logCmd.Bool("first-parent", false, "follow only the first parent of merges")
+		oneline := logCmd.Bool("oneline", false, "show each commit on one line")
+		format := logCmd.String("format", "", "oneline, short, medium, full or a template such as \"%h %s\"")
+		stat := logCmd.Bool("stat", false, "show the number of changed lines per file")
+		nameStatus := logCmd.Bool("name-status", false, "show the changed files and how they changed")
+		patch := logCmd.Bool("p", false, "show the changes as a diff")
+		logCmd.BoolVar(patch, "patch", false, "show the changes as a diff")
+		graph := logCmd.Bool("graph", false, "draw the history of branches and merges")
+		// Like Git, -<n> is short for -n <n>
+		for i, arg := range args {
+			if len(arg) > 1 && arg[0] == '-' && strings.Trim(arg[1:], "0123456789") == "" {
+				args[i] = "-n=" + arg[1:]
+			}
+		}
+		attachNumbers(args, "n")
+		parseFlags(logCmd, args)
+
+		opts := commands.LogOptions{
+			MaxCount:    *maxCount,
+			Since:       *since,
+			Until:       *until,
+			Author:      *author,
+			Grep:        *grep,
+			FirstParent: *firstParent,
+			Format:      *format,
+			DateFormat:  *date,
+			Stat:        *stat,
+			NameStatus:  *nameStatus,
+			Patch:       *patch,
+			Graph:       *graph,
+		}
+		if *oneline {
+			opts.Format = commands.FormatOneline
+		}
+		// Arguments after -- are paths
+		rest := logCmd.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			opts.Paths = rest
+		} else if i := slices.Index(rest, "--"); i >= 0 {
+			opts.Revisions, opts.Paths = rest[:i], rest[i+1:]
+		} else {
+			opts.Revisions = rest
+		}
+		log := commands.LogWithOptions
+		if jsonOutput {
+			log = commands.LogJSON
+		}
+		if err := log(opts); err != nil {
+			fail(err)
+		}
+	case "show":
+		showCmd := flag.NewFlagSet("show", flag.ContinueOnError)
+		date := showCmd.String("date", "", "date format: default, local, iso, relative or raw")
+		oneline := showCmd.Bool("oneline", false, "show commits on one line")
+		format := showCmd.String("format", "", "oneline, short, medium, full or a template such as \"%h %s\"")
+		noPatch := showCmd.Bool("s", false, "do not show the changes of commits")
+		showCmd.BoolVar(noPatch, "no-patch", false, "do not show the changes of commits")
+		parseFlags(showCmd, args)
+
+		opts := commands.ShowOptions{Format: *format, DateFormat: *date, NoPatch: *noPatch}
+		if *oneline {
+			opts.Format = commands.FormatOneline
+		}
+		show := commands.Show
+		if jsonOutput {
+			show = commands.ShowJSON
+		}
+		if err := show(showCmd.Args(), opts); err != nil {
+			fail(err)
+		}
+	case "status":
+		statusCmd := flag.NewFlagSet("status", flag.ContinueOnError)
+		short := statusCmd.Bool("short", false, "show status in short format")
+		statusCmd.BoolVar(short, "s", false, "show status in short format (shorthand)")
+		porcelain := statusCmd.Bool("porcelain", false, "show status in a stable, machine-readable format")
+		parseFlags(statusCmd, args)
+
+		var err error
+		if jsonOutput {
+			err = commands.StatusJSON()
+		} else {
+			err = commands.Status(commands.StatusOptions{Short: *short, Porcelain: *porcelain})
+		}
+		if err != nil {
+			fail(err)
+		}
+	case "diff":
+		diffCmd := flag.NewFlagSet("diff", flag.ContinueOnError)
+		staged := diffCmd.Bool("staged", false, "compare the staging area with HEAD")
+		diffCmd.BoolVar(staged, "cached", false, "synonym for --staged")
+		context := diffCmd.Int("unified", commands.DefaultContextLines, "number of context lines")
+		diffCmd.IntVar(context, "U", commands.DefaultContextLines, "number of context lines (shorthand)")
+		stat := diffCmd.Bool("stat", false, "show the number of changed lines per file")
+		attachNumbers(args, "U")
+		parseFlags(diffCmd, args)
+
+		opts := commands.DiffOptions{Staged: *staged, Commits: diffCmd.Args(), Context: *context, Stat: *stat}
+		diff := commands.Diff
+		if jsonOutput {
+			diff = commands.DiffJSON
+		}
+		if err := diff(opts); err != nil {
+			fail(err)
+		}
+	case "checkout":
+		checkoutCmd := flag.NewFlagSet("checkout", flag.ContinueOnError)
+		force := checkoutCmd.Bool("force", false, "discard local modifications")
+		checkoutCmd.BoolVar(force, "f", false, "discard local modifications (shorthand)")
+		parseFlags(checkoutCmd, args)
+
+		if checkoutCmd.NArg() != 1 {
+			usage("checkout command requires a commit")
+		}
+		if err := commands.Checkout(checkoutCmd.Arg(0), commands.CheckoutOptions{Force: *force}); err != nil {
+			fail(err)
+		}
+	case "restore":
+		restoreCmd := flag.NewFlagSet("restore", flag.ContinueOnError)
+		source := restoreCmd.String("source", "", "commit to restore from")
+		staged := restoreCmd.Bool("staged", false, "restore the staging area")
+		worktree := restoreCmd.Bool("worktree", false, "restore the working tree (default)")
+		force := restoreCmd.Bool("force", false, "discard local modifications")
+		restoreCmd.BoolVar(force, "f", false, "discard local modifications (shorthand)")
+		parseFlags(restoreCmd, args)
+
+		if restoreCmd.NArg() == 0 {
+			usage("restore command requires file path(s)")
+		}
+		opts := commands.RestoreOptions{Source: *source, Staged: *staged, Worktree: *worktree, Force: *force}
+		if err := commands.Restore(restoreCmd.Args(), opts); err != nil {
+			fail(err)
+		}
+	case "branch":
+		branchCmd := flag.NewFlagSet("branch", flag.ContinueOnError)
+		del := branchCmd.Bool("d", false, "delete a fully merged branch")
+		forceDel := branchCmd.Bool("D", false, "delete a branch even if it is not merged")
+		rename := branchCmd.Bool("m", false, "rename a branch")
+		parseFlags(branchCmd, args)
+
+		var err error
+		switch {
+		case jsonOutput && (*del || *forceDel || *rename || branchCmd.NArg() > 0):
+			usage("--json can only be used to list branches")
+		case jsonOutput:
+			err = commands.ListBranchesJSON()
+		case *del || *forceDel:
+			if branchCmd.NArg() != 1 {
+				usage("branch -d requires a branch name")
+			}
+			err = commands.DeleteBranch(branchCmd.Arg(0), *forceDel)
+		case *rename:
+			switch branchCmd.NArg() {
+			case 1:
+				err = commands.RenameBranch("", branchCmd.Arg(0))
+			case 2:
+				err = commands.RenameBranch(branchCmd.Arg(0), branchCmd.Arg(1))
+			default:
+				usage("branch -m requires [<old>] <new>")
+			}
+		case branchCmd.NArg() == 0:
+			err = commands.ListBranches()
+		case branchCmd.NArg() <= 2:
+			err = commands.CreateBranch(branchCmd.Arg(0), branchCmd.Arg(1))
+		default:
+			usage("too many arguments to branch")
+		}
+		if err != nil {
+			fail(err)
+		}
+	case "rev-parse":
+		revParseCmd := flag.NewFlagSet("rev-parse", flag.ContinueOnError)
+		verify := revParseCmd.Bool("verify", false, "require exactly one revision naming an object")
+		parseFlags(revParseCmd, args)
+
+		if revParseCmd.NArg() == 0 {
+			usage("rev-parse requires a revision")
+		}
+		if err := commands.RevParse(revParseCmd.Args(), commands.RevParseOptions{Verify: *verify}); err != nil {
+			fail(err)
+		}
+	case "tag":
+		tagCmd := flag.NewFlagSet("tag", flag.ContinueOnError)
+		annotate := tagCmd.Bool("a", false, "make an annotated tag object")
+		message := tagCmd.String("m", "", "message of an annotated tag")
+		list := tagCmd.Bool("l", false, "list the tags matching the patterns")
+		del := tagCmd.Bool("d", false, "delete tags")
+		force := tagCmd.Bool("f", false, "replace an existing tag")
+		parseFlags(tagCmd, args)
+
+		var err error
+		switch {
+		case *del:
+			if tagCmd.NArg() == 0 {
+				usage("tag -d requires a tag name")
+			}
+			err = commands.DeleteTag(tagCmd.Args())
+		case *list || tagCmd.NArg() == 0:
+			err = commands.ListTags(tagCmd.Args())
+		case tagCmd.NArg() <= 2:
+			opts := commands.TagOptions{Annotate: *annotate, Message: *message, Force: *force}
+			err = commands.CreateTag(tagCmd.Arg(0), tagCmd.Arg(1), opts)
+		default:
+			usage("too many arguments to tag")
+		}
+		if err != nil {
+			fail(err)
+		}
+	case "switch":
+		switchCmd := flag.NewFlagSet("switch", flag.ContinueOnError)
+		create := switchCmd.Bool("c", false, "create the branch before switching to it")
+		force := switchCmd.Bool("force", false, "discard local modifications")
+		switchCmd.BoolVar(force, "f", false, "discard local modifications (shorthand)")
+		parseFlags(switchCmd, args)
+
+		if switchCmd.NArg() != 1 {
+			usage("switch command requires a branch name")
+		}
+		if err := commands.Switch(switchCmd.Arg(0), commands.SwitchOptions{Create: *create, Force: *force}); err != nil {
+			fail(err)
+		}
+	case "merge":
+		mergeCmd := flag.NewFlagSet("merge", flag.ContinueOnError)
+		noFF := mergeCmd.Bool("no-ff", false, "create a merge commit even when a fast-forward is possible")
+		message := mergeCmd.String("m", "", "merge commit message")
+		parseFlags(mergeCmd, args)
+
+		if mergeCmd.NArg() != 1 {
+			usage("merge command requires a branch or commit")
+		}
+		if err := commands.Merge(mergeCmd.Arg(0), commands.MergeOptions{NoFastForward: *noFF, Message: *message}); err != nil {
+			fail(err)
+		}
+	case "reset":
+		resetCmd := flag.NewFlagSet("reset", flag.ContinueOnError)
+		soft := resetCmd.Bool("soft", false, "move HEAD only, keeping the index and working tree")
+		mixed := resetCmd.Bool("mixed", false, "move HEAD and reset the index (default)")
+		hard := resetCmd.Bool("hard", false, "move HEAD and reset the index and working tree")
+		parseFlags(resetCmd, args)
+
+		opts := commands.ResetOptions{}
+		modes := 0
+		for mode, set := range map[string]bool{commands.ResetSoft: *soft, commands.ResetMixed: *mixed, commands.ResetHard: *hard} {
+			if set {
+				opts.Mode = mode
+				modes++
+			}
+		}
+		if modes > 1 {
+			usage("--soft, --mixed and --hard cannot be combined")
+		}
+		if resetCmd.NArg() > 1 {
+			usage("reset accepts at most one commit")
+		}
+		if err := commands.Reset(resetCmd.Arg(0), opts); err != nil {
+			fail(err)
+		}
+	case "rm":
+		rmCmd := flag.NewFlagSet("rm", flag.ContinueOnError)
+		cached := rmCmd.Bool("cached", false, "only remove from the index")
+		recursive := rmCmd.Bool("r", false, "allow recursive removal of directories")
+		force := rmCmd.Bool("force", false, "remove files with local or staged changes")
+		rmCmd.BoolVar(force, "f", false, "remove files with local or staged changes (shorthand)")
+		parseFlags(rmCmd, args)
+
+		if rmCmd.NArg() == 0 {
+			usage("rm command requires file path(s)")
+		}
+		if err := commands.Remove(rmCmd.Args(), commands.RemoveOptions{Cached: *cached, Recursive: *recursive, Force: *force}); err != nil {
+			fail(err)
+		}
+	case "mv":
+		mvCmd := flag.NewFlagSet("mv", flag.ContinueOnError)
+		force := mvCmd.Bool("force", false, "overwrite existing destination files")
+		mvCmd.BoolVar(force, "f", false, "overwrite existing destination files (shorthand)")
+		parseFlags(mvCmd, args)
+
+		if mvCmd.NArg() < 2 {
+			usage("mv command requires a source and a destination")
+		}
+		sources := mvCmd.Args()[:mvCmd.NArg()-1]
+		if err := commands.Move(sources, mvCmd.Arg(mvCmd.NArg()-1), commands.MoveOptions{Force: *force}); err != nil {
+			fail(err)
+		}
+	case "clean":
+		cleanCmd := flag.NewFlagSet("clean", flag.ContinueOnError)
+		dryRun := cleanCmd.Bool("n", false, "only show what would be removed")
+		force := cleanCmd.Bool("force", false, "remove untracked files")
+		cleanCmd.BoolVar(force, "f", false, "remove untracked files (shorthand)")
+		dirs := cleanCmd.Bool("d", false, "also remove untracked directories")
+		includeIgnored := cleanCmd.Bool("x", false, "also remove ignored files")
+		onlyIgnored := cleanCmd.Bool("X", false, "remove only ignored files")
+		parseFlags(cleanCmd, args)
+
+		opts := commands.CleanOptions{
+			DryRun:         *dryRun,
+			Force:          *force,
+			Directories:    *dirs,
+			IncludeIgnored: *includeIgnored,
+			OnlyIgnored:    *onlyIgnored,
+		}
+		if err := commands.Clean(opts); err != nil {
+			fail(err)
+		}
+	case "check-ignore":
+		checkCmd := flag.NewFlagSet("check-ignore", flag.ContinueOnError)
+		verbose := checkCmd.Bool("v", false, "show the rule that matched each path")
+		parseFlags(checkCmd, args)
+
+		if checkCmd.NArg() == 0 {
+			usage("check-ignore command requires path(s)")
+		}
+		// Like Git, exit with 1 when no path is ignored, so errors use 128
+		ignored, err := commands.CheckIgnore(checkCmd.Args(), commands.CheckIgnoreOptions{Verbose: *verbose})
+		if err != nil {
+			fail(&commands.StatusError{Err: err, Status: 128})
+		}
+		if !ignored {
 			os.Exit(1)
 		}
+	case "config":
+		configCmd := flag.NewFlagSet("config", flag.ContinueOnError)
+		global := configCmd.Bool("global", false, "use the user config file")
+		local := configCmd.Bool("local", false, "use the repository config file")
+		list := configCmd.Bool("list", false, "list all variables")
+		configCmd.BoolVar(list, "l", false, "list all variables (shorthand)")
+		unset := configCmd.Bool("unset", false, "remove a variable")
+		parseFlags(configCmd, args)
+
+		opts := commands.ConfigOptions{Global: *global, Local: *local}
+		var err error
+		switch {
+		case *list && configCmd.NArg() == 0:
+			err = commands.ListConfig(opts)
+		case *unset && configCmd.NArg() == 1:
+			err = commands.UnsetConfig(configCmd.Arg(0), opts)
+		case !*list && !*unset && configCmd.NArg() == 1:
+			err = commands.GetConfig(configCmd.Arg(0), opts)
+		case !*list && !*unset && configCmd.NArg() == 2:
+			err = commands.SetConfig(configCmd.Arg(0), configCmd.Arg(1), opts)
+		default:
+			usage("usage: mygit config [--global|--local] (<key> [<value>] | --unset <key> | --list)")
+		}
+		// Like Git, a variable that is not set only changes the exit status
+		var notFound *commands.ConfigNotFoundError
+		if errors.As(err, &notFound) {
+			os.Exit(1)
+		}
+		if err != nil {
+			fail(err)
+		}
+	case "export-git":
+		if len(args) > 1 {
+			usage("usage: mygit export-git [<git-dir>]")
+		}
+		gitDir := ""
+		if len(args) == 1 {
+			gitDir = args[0]
+		}
+		if err := commands.ExportGit(gitDir); err != nil {
+			fail(err)
+		}
+	case "import-git":
+		if len(args) != 1 {
+			usage("usage: mygit import-git <git-dir>")
+		}
+		if err := commands.ImportGit(args[0]); err != nil {
+			fail(err)
+		}
+	case "fast-export":
+		exportCmd := flag.NewFlagSet("fast-export", flag.ContinueOnError)
+		all := exportCmd.Bool("all", false, "export every branch and tag (the default)")
+		parseFlags(exportCmd, args)
+
+		refs := exportCmd.Args()
+		if *all {
+			refs = nil
+		}
+		if err := commands.FastExport(refs); err != nil {
+			fail(err)
+		}
+	case "fast-import":
+		importCmd := flag.NewFlagSet("fast-import", flag.ContinueOnError)
+		force := importCmd.Bool("force", false, "move branches even when their commits would be lost")
+		parseFlags(importCmd, args)
+
+		if err := commands.FastImport(commands.FastImportOptions{Force: *force}); err != nil {
+			fail(err)
+		}
+	case "recover":
+		if err := commands.Recover(); err != nil {
+			fail(err)
+		}
 	case "help", "-h", "--help":
 		printUsage()
 	default:
+		if jsonOutput {
+			usage("unknown command: %s", command)
+		}
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		printUsage()
-		os.Exit(1)
+		os.Exit(commands.ExitStatus(commands.CodeUsage))
+	}
+}
+
+// fail reports err and exits with the status of its error code. With --json
+// the error is written as a JSON object instead of a message.
+func fail(err error) {
+	if jsonOutput {
+		commands.WriteJSONError(os.Stderr, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	os.Exit(commands.ErrorExitStatus(err))
+}
+
+// attachNumbers rewrites short options given with a number attached, such as
+// -n1 or -U1, to the -n=1 form the flag package accepts
+func attachNumbers(args []string, names ...string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return
+		}
+		for _, name := range names {
+			value, ok := strings.CutPrefix(arg, "-"+name)
+			if ok && value != "" && strings.Trim(value, "0123456789") == "" {
+				args[i] = "-" + name + "=" + value
+			}
+		}
 	}
 }
 
+// parseFlags parses the options of a command. Unknown or malformed options
+// are usage errors, reported like other errors; without --json the options
+// of the command are listed first. -h lists them and exits.
+func parseFlags(flags *flag.FlagSet, args []string) {
+	flags.SetOutput(io.Discard)
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		flags.SetOutput(os.Stdout)
+		flags.Usage()
+		os.Exit(0)
+	}
+	if err != nil {
+		if !jsonOutput {
+			flags.SetOutput(os.Stderr)
+			flags.Usage()
+		}
+		usage("%s: %v", flags.Name(), err)
+	}
+}
+
+// usage reports a command line that cannot be run as given
+func usage(format string, args ...any) {
+	fail(&commands.UsageError{Message: fmt.Sprintf(format, args...)})
+}
+
 func printUsage() {
-	fmt.Println("Usage: mygit <command> [args]")
+	fmt.Println("Usage: mygit [-C <dir>] [--wait <duration>] [--json] <command> [args]")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  -C <dir>                Run as if mygit was started in <dir>")
+	fmt.Println("  --wait <duration>       Wait up to <duration> (e.g. 5s) for locks held by")
+	fmt.Println("                          other mygit processes")
+	fmt.Println("  --json, --format=json   Print the output of status, log, show, diff, branch")
+	fmt.Println("                          and commit, and errors, as JSON")
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  init                    Initialize a new repository")
 	fmt.Println("  add <file>...           Add file(s) to staging area")
-	fmt.Println("  commit -m <message>     Commit staged changes")
-	fmt.Println("  log                     Show commit history")
+	fmt.Println("  add -A|-u [<path>...]   Stage all changes, or only changes to tracked files")
+	fmt.Println("  add -f <file>...        Add files even if they are ignored")
+	fmt.Println("  commit -m <message> [--author <name <email>>] [--date <date>]")
+	fmt.Println("                          Commit staged changes")
+	fmt.Println("  log [<options>] [<revision-range>...] [[--] <path>...]")
+	fmt.Println("                          Show commit history; options are -n <count>,")
+	fmt.Println("                          --since/--until <date>, --author/--grep <regexp>,")
+	fmt.Println("                          --first-parent, --oneline, --format=<format>,")
+	fmt.Println("                          --date=<format>, --stat, --name-status, -p, --graph")
+	fmt.Println("  show [<options>] [<object>...]")
+	fmt.Println("                          Show a commit with its changes, a tree, a file such")
+	fmt.Println("                          as HEAD:README.md or a tag; options are --oneline,")
+	fmt.Println("                          --format=<format>, --date=<format>, -s")
+	fmt.Println("  status [-s|--porcelain] Show staged, unstaged and untracked changes")
+	fmt.Println("  diff [--staged] [--stat] [<commit> [<commit>] | <commit>..<commit>]")
+	fmt.Println("                          Show changes between working tree, index and commits")
+	fmt.Println("  checkout [-f] <commit>  Rebuild the working tree from a commit or branch")
+	fmt.Println("  restore [--source <commit>] [--staged] [--worktree] [-f] <path>...")
+	fmt.Println("                          Restore files from the index or a commit")
+	fmt.Println("  branch [<name> [<start>]]")
+	fmt.Println("                          List or create branches")
+	fmt.Println("  branch -d|-D <name>     Delete a branch")
+	fmt.Println("  branch -m [<old>] <new> Rename a branch")
+	fmt.Println("  tag [-l] [<pattern>...] List tags, optionally matching glob patterns")
+	fmt.Println("  tag [-f] [-a -m <message>] <name> [<commit>]")
+	fmt.Println("                          Create a lightweight or annotated tag")
+	fmt.Println("  tag -d <name>...        Delete tags")
+	fmt.Println("  switch [-c] [-f] <name> Switch to a branch")
+	fmt.Println("  merge [--no-ff] [-m <message>] <branch>")
+	fmt.Println("                          Merge another branch into the current branch")
+	fmt.Println("  reset [--soft|--mixed|--hard] [<commit>]")
+	fmt.Println("                          Move the current branch to a commit, e.g. HEAD~1")
+	fmt.Println("  rm [--cached] [-r] [-f] <path>...")
+	fmt.Println("                          Remove files from the index and working tree")
+	fmt.Println("  mv [-f] <source>... <destination>")
+	fmt.Println("                          Move or rename tracked files")
+	fmt.Println("  clean -f|-n [-d] [-x|-X]")
+	fmt.Println("                          Remove untracked files from the working tree")
+	fmt.Println("  check-ignore [-v] <path>...")
+	fmt.Println("                          Show which paths are ignored and why")
+	fmt.Println("  config [--global|--local] <key> [<value>]")
+	fmt.Println("                          Get or set a config variable, e.g. user.name")
+	fmt.Println("  config [--global|--local] --unset <key> | --list")
+	fmt.Println("                          Remove a config variable, or list them all")
+	fmt.Println("  rev-parse [--verify] <rev>...")
+	fmt.Println("                          Print the object IDs of revisions such as HEAD~2,")
+	fmt.Println("                          main@{1}, v1.0..main or HEAD:README.md")
+	fmt.Println("  export-git [<git-dir>]  Write the history as a Git repository, by default .git")
+	fmt.Println("  import-git <git-dir>    Add the branches, tags and commits of a Git repository")
+	fmt.Println("  fast-export [--all | <ref>...]")
+	fmt.Println("                          Write the history as a fast-export stream")
+	fmt.Println("  fast-import [--force]   Read a fast-export stream from standard input")
+	fmt.Println("  recover                 Repair the repository after a crash, restoring a")
+	fmt.Println("                          damaged metadata.json from its copy in metadata.json.bak")
 	fmt.Println("  help                    Show this help message")
-} 
\ No newline at end of file
+	fmt.Println()
+	fmt.Println("Environment:")
+	fmt.Println("  MYGIT_DIR               Path of the repository directory, skipping discovery")
+	fmt.Println("  MYGIT_WORK_TREE         Root of the working tree")
+	fmt.Println("  MYGIT_LOCK_TIMEOUT      Default for --wait")
+	fmt.Println("  MYGIT_CONFIG_GLOBAL     Path of the user config file (default ~/.mygitconfig)")
+	fmt.Println("  MYGIT_AUTHOR_NAME, MYGIT_AUTHOR_EMAIL")
+	fmt.Println("                          Override user.name and user.email for the author")
+	fmt.Println("  MYGIT_COMMITTER_NAME, MYGIT_COMMITTER_EMAIL")
+	fmt.Println("                          Override user.name and user.email for the committer")
+	fmt.Println("  MYGIT_AUTHOR_DATE, MYGIT_COMMITTER_DATE")
+	fmt.Println("                          Override the author and committer dates")
+}
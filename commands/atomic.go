@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempInfix marks the temporary files written next to a file being
+// replaced, such as "index_tmp_123"
+const tempInfix = "_tmp_"
+
+// writeFileAtomic replaces a file so that it holds either its old or its new
+// content, even after a crash. The data is written to a temporary file in the
+// same directory, synced to disk and renamed over the file, and the directory
+// is synced so the rename itself survives a crash.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(name)
+	tmp, err := os.CreateTemp(dir, filepath.Base(name)+tempInfix)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, name); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes a directory entry change such as a rename to disk. Not
+// every platform can sync a directory, so failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// isTempFile reports whether a file name belongs to a temporary file left
+// behind by an interrupted write
+func isTempFile(name string) bool {
+	return strings.Contains(name, tempInfix) || strings.HasPrefix(name, "tmp_obj_")
+}
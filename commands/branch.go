@@ -0,0 +1,326 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// SwitchOptions controls Switch
+type SwitchOptions struct {
+	// Create creates the branch at HEAD before switching to it
+	Create bool
+	// Force discards local modifications that would otherwise block the switch
+	Force bool
+}
+
+// ListBranches prints all branches, marking the current one with "*"
+func ListBranches() error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	branches, err := r.listBranches()
+	if err != nil {
+		return err
+	}
+	current, attached, err := r.currentBranch()
+	if err != nil {
+		return err
+	}
+
+	if !attached {
+		_, commitID, err := r.readHead()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("* (HEAD detached at %s)\n", commitID[:7])
+	}
+	for _, branch := range branches {
+		if attached && branch == current {
+			fmt.Printf("* %s\n", branch)
+		} else {
+			fmt.Printf("  %s\n", branch)
+		}
+	}
+	return nil
+}
+
+// Branch is a branch and the commit it points to
+type Branch struct {
+	Name string `json:"name"`
+	// Commit is empty for the current branch before its first commit
+	Commit string `json:"commit"`
+	// Current is set for the branch HEAD is attached to
+	Current bool `json:"current"`
+}
+
+// Branches returns all branches sorted by name. A current branch without
+// commits, which only exists in HEAD, is included.
+func (r *Repository) Branches() ([]Branch, error) {
+	names, err := r.listBranches()
+	if err != nil {
+		return nil, err
+	}
+	current, attached, err := r.currentBranch()
+	if err != nil {
+		return nil, err
+	}
+	if attached && !slices.Contains(names, current) {
+		names = append(names, current)
+		slices.Sort(names)
+	}
+
+	branches := make([]Branch, 0, len(names))
+	for _, name := range names {
+		commitID, err := r.readRef(BranchPrefix + name)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, Branch{Name: name, Commit: commitID, Current: attached && name == current})
+	}
+	return branches, nil
+}
+
+// CreateBranch creates a branch pointing at startPoint, or at HEAD when
+// startPoint is empty
+func CreateBranch(name, startPoint string) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	unlock, err := r.lock(HeadFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkBranchName(name); err != nil {
+		return err
+	}
+	if existing, err := r.readRef(BranchPrefix + name); err != nil {
+		return err
+	} else if existing != "" {
+		return fmt.Errorf("a branch named '%s' already exists", name)
+	}
+
+	if startPoint == "" {
+		startPoint = "HEAD"
+	}
+	commit, err := r.ResolveCommit(startPoint)
+	if err != nil {
+		return fmt.Errorf("cannot create branch '%s': %w", name, err)
+	}
+	commitID := commit.ID
+	if err := r.writeRef(BranchPrefix+name, commitID); err != nil {
+		return err
+	}
+	if err := r.logRefUpdate(BranchPrefix+name, "", commitID, "branch: Created from "+startPoint); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created branch %s at %s\n", name, commitID[:7])
+	return nil
+}
+
+// DeleteBranch deletes a branch. Unless force is set, the branch must be
+// merged into HEAD so no commits become unreachable.
+func DeleteBranch(name string, force bool) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	unlock, err := r.lock(HeadFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	commitID, err := r.readRef(BranchPrefix + name)
+	if err != nil {
+		return err
+	}
+	if commitID == "" || checkBranchName(name) != nil {
+		return fmt.Errorf("branch '%s' not found", name)
+	}
+	if current, attached, err := r.currentBranch(); err != nil {
+		return err
+	} else if attached && current == name {
+		return fmt.Errorf("cannot delete branch '%s' which you are currently on", name)
+	}
+
+	if !force {
+		_, headID, err := r.readHead()
+		if err != nil {
+			return err
+		}
+		metadata, err := r.readMetadata()
+		if err != nil {
+			return err
+		}
+		if !metadata.isAncestor(commitID, headID) {
+			return fmt.Errorf("the branch '%s' is not fully merged; use -D to delete it anyway", name)
+		}
+	}
+
+	if err := r.deleteRef(BranchPrefix + name); err != nil {
+		return err
+	}
+	if err := r.deleteReflog(BranchPrefix + name); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted branch %s (was %s)\n", name, commitID[:7])
+	return nil
+}
+
+// RenameBranch renames a branch, or the current branch when oldName is empty
+func RenameBranch(oldName, newName string) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	unlock, err := r.lock(HeadFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, attached, err := r.currentBranch()
+	if err != nil {
+		return err
+	}
+	if oldName == "" {
+		if !attached {
+			return errors.New("cannot rename the current branch while HEAD is detached")
+		}
+		oldName = current
+	}
+	if err := checkBranchName(newName); err != nil {
+		return err
+	}
+	if existing, err := r.readRef(BranchPrefix + newName); err != nil {
+		return err
+	} else if existing != "" {
+		return fmt.Errorf("a branch named '%s' already exists", newName)
+	}
+
+	commitID, err := r.readRef(BranchPrefix + oldName)
+	if err != nil {
+		return err
+	}
+	isCurrent := attached && current == oldName
+	if commitID == "" && !isCurrent {
+		return fmt.Errorf("branch '%s' not found", oldName)
+	}
+
+	// A current branch without commits only exists in HEAD
+	if commitID != "" {
+		if err := r.writeRef(BranchPrefix+newName, commitID); err != nil {
+			return err
+		}
+		if err := r.deleteRef(BranchPrefix + oldName); err != nil {
+			return err
+		}
+		if err := r.renameReflog(BranchPrefix+oldName, BranchPrefix+newName); err != nil {
+			return err
+		}
+	}
+	if isCurrent {
+		if err := r.attachHead(newName); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Renamed branch %s to %s\n", oldName, newName)
+	return nil
+}
+
+// Switch checks out a branch and points HEAD at it, so new commits are
+// added to that branch
+func Switch(name string, opts SwitchOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	unlock, err := r.lock(IndexFile, HeadFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkBranchName(name); err != nil {
+		return err
+	}
+
+	_, headID, err := r.readHead()
+	if err != nil {
+		return err
+	}
+	from, err := r.headName()
+	if err != nil {
+		return err
+	}
+
+	if opts.Create {
+		if existing, err := r.readRef(BranchPrefix + name); err != nil {
+			return err
+		} else if existing != "" {
+			return fmt.Errorf("a branch named '%s' already exists", name)
+		}
+		// The new branch starts at HEAD, so the working tree stays as it is
+		if headID != "" {
+			if err := r.writeRef(BranchPrefix+name, headID); err != nil {
+				return err
+			}
+			if err := r.logRefUpdate(BranchPrefix+name, "", headID, "branch: Created from HEAD"); err != nil {
+				return err
+			}
+		}
+		if err := r.attachHead(name); err != nil {
+			return err
+		}
+		if headID != "" {
+			if err := r.logRefUpdate(HeadFile, headID, headID, "checkout: moving from "+from+" to "+name); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Switched to a new branch '%s'\n", name)
+		return nil
+	}
+
+	commitID, err := r.readRef(BranchPrefix + name)
+	if err != nil {
+		return err
+	}
+	if commitID == "" {
+		return fmt.Errorf("branch '%s' not found", name)
+	}
+
+	// A forced switch discards local modifications even on the same commit
+	if commitID != headID || opts.Force {
+		metadata, err := r.readMetadata()
+		if err != nil {
+			return err
+		}
+		commit := metadata.find(commitID)
+		if commit == nil {
+			return fmt.Errorf("branch '%s' points to unknown commit %s", name, commitID)
+		}
+		if err := r.checkoutCommit(commit, opts.Force); err != nil {
+			return err
+		}
+	}
+	if err := r.attachHead(name); err != nil {
+		return err
+	}
+	if err := r.logRefUpdate(HeadFile, headID, commitID, "checkout: moving from "+from+" to "+name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Switched to branch '%s'\n", name)
+	return nil
+}
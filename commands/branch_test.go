@@ -0,0 +1,243 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// readBranch returns the commit a branch points at, or an empty string if it does not exist
+func readBranch(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(commands.MyGitDir, "refs", "heads", name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// TestCreateBranch tests creating branches
+func TestCreateBranch(t *testing.T) {
+	tests := []struct {
+		name          string
+		branch        string
+		startPoint    string
+		expectedError bool
+	}{
+		{name: "branch at HEAD", branch: "feature"},
+		{name: "nested branch name", branch: "feature/login"},
+		{name: "branch at another branch", branch: "copy", startPoint: "main"},
+		{name: "existing branch", branch: "main", expectedError: true},
+		{name: "invalid name", branch: "bad..name", expectedError: true},
+		{name: "unknown start point", branch: "feature", startPoint: "missing", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := setupTwoCommits(t)
+
+			err := commands.CreateBranch(tt.branch, tt.startPoint)
+
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.expectedError && readBranch(t, tt.branch) != ids[1] {
+				t.Errorf("expected branch %s to point at %s", tt.branch, ids[1])
+			}
+		})
+	}
+}
+
+// TestSwitchAndCommit tests that commits advance only the current branch
+func TestSwitchAndCommit(t *testing.T) {
+	ids := setupTwoCommits(t)
+
+	if err := commands.Switch("feature", commands.SwitchOptions{Create: true}); err != nil {
+		t.Fatalf("failed to create branch: %v", err)
+	}
+	os.WriteFile("feature.txt", []byte("feature\n"), 0644)
+	commands.Add([]string{"feature.txt"})
+	if err := commands.Commit("Feature commit"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	featureTip := readBranch(t, "feature")
+	if featureTip == ids[1] {
+		t.Errorf("feature branch did not advance")
+	}
+	if readBranch(t, "main") != ids[1] {
+		t.Errorf("main branch should not move")
+	}
+
+	if err := commands.Switch("main", commands.SwitchOptions{}); err != nil {
+		t.Fatalf("failed to switch: %v", err)
+	}
+	if _, err := os.Stat("feature.txt"); !os.IsNotExist(err) {
+		t.Errorf("feature.txt should not exist on main")
+	}
+	head, _ := os.ReadFile(filepath.Join(commands.MyGitDir, commands.HeadFile))
+	if strings.TrimSpace(string(head)) != "ref: refs/heads/main" {
+		t.Errorf("expected HEAD to point at main, got %q", head)
+	}
+
+	if err := commands.Checkout("feature", commands.CheckoutOptions{}); err != nil {
+		t.Fatalf("failed to checkout branch: %v", err)
+	}
+	if _, err := os.Stat("feature.txt"); err != nil {
+		t.Errorf("feature.txt should exist on feature")
+	}
+}
+
+// TestForceSwitchCurrentBranch tests that a forced switch to the current
+// branch discards local modifications, with switch and with checkout
+func TestForceSwitchCurrentBranch(t *testing.T) {
+	tests := []struct {
+		name       string
+		switchFunc func() error
+	}{
+		{name: "switch", switchFunc: func() error { return commands.Switch("main", commands.SwitchOptions{Force: true}) }},
+		{name: "checkout", switchFunc: func() error { return commands.Checkout("main", commands.CheckoutOptions{Force: true}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTwoCommits(t)
+			os.WriteFile("file.txt", []byte("local edit\n"), 0644)
+			os.WriteFile(filepath.Join("dir", "new.txt"), []byte("staged edit\n"), 0644)
+			commands.Add([]string{"dir/new.txt"})
+
+			if err := tt.switchFunc(); err != nil {
+				t.Fatalf("failed to switch: %v", err)
+			}
+			if content, _ := os.ReadFile("file.txt"); string(content) != "version 2\n" {
+				t.Errorf("expected file.txt to be restored, got %q", content)
+			}
+			result, err := commands.GetStatus()
+			if err != nil {
+				t.Fatalf("failed to get status: %v", err)
+			}
+			if !result.Clean() {
+				t.Errorf("expected a clean status, got %+v", result)
+			}
+		})
+	}
+}
+
+// TestDeleteBranch tests deleting branches
+func TestDeleteBranch(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupFunc     func()
+		branch        string
+		force         bool
+		expectedError bool
+	}{
+		{
+			name:      "merged branch",
+			setupFunc: func() { commands.CreateBranch("merged", "") },
+			branch:    "merged",
+		},
+		{
+			name: "unmerged branch",
+			setupFunc: func() {
+				commands.Switch("unmerged", commands.SwitchOptions{Create: true})
+				os.WriteFile("extra.txt", []byte("extra\n"), 0644)
+				commands.Add([]string{"extra.txt"})
+				commands.Commit("Unmerged commit")
+				commands.Switch("main", commands.SwitchOptions{})
+			},
+			branch:        "unmerged",
+			expectedError: true,
+		},
+		{
+			name: "force delete unmerged branch",
+			setupFunc: func() {
+				commands.Switch("unmerged", commands.SwitchOptions{Create: true})
+				os.WriteFile("extra.txt", []byte("extra\n"), 0644)
+				commands.Add([]string{"extra.txt"})
+				commands.Commit("Unmerged commit")
+				commands.Switch("main", commands.SwitchOptions{})
+			},
+			branch: "unmerged",
+			force:  true,
+		},
+		{
+			name:          "current branch",
+			setupFunc:     func() {},
+			branch:        "main",
+			expectedError: true,
+		},
+		{
+			name:          "missing branch",
+			setupFunc:     func() {},
+			branch:        "missing",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTwoCommits(t)
+			tt.setupFunc()
+
+			err := commands.DeleteBranch(tt.branch, tt.force)
+
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.expectedError && readBranch(t, tt.branch) != "" {
+				t.Errorf("branch %s still exists", tt.branch)
+			}
+		})
+	}
+}
+
+// TestRenameBranch tests renaming the current branch
+func TestRenameBranch(t *testing.T) {
+	ids := setupTwoCommits(t)
+
+	if err := commands.RenameBranch("", "trunk"); err != nil {
+		t.Fatalf("failed to rename branch: %v", err)
+	}
+	if readBranch(t, "main") != "" || readBranch(t, "trunk") != ids[1] {
+		t.Errorf("branch was not renamed")
+	}
+	head, _ := os.ReadFile(filepath.Join(commands.MyGitDir, commands.HeadFile))
+	if strings.TrimSpace(string(head)) != "ref: refs/heads/trunk" {
+		t.Errorf("expected HEAD to follow the renamed branch, got %q", head)
+	}
+	if err := commands.ListBranches(); err != nil {
+		t.Errorf("failed to list branches: %v", err)
+	}
+}
+
+// TestLegacyHistoryBecomesMainBranch tests migration of repositories created before branches existed
+func TestLegacyHistoryBecomesMainBranch(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	os.Mkdir(commands.MyGitDir, 0755)
+	metadata := map[string]any{
+		"commit_history": []map[string]any{
+			{"commit_id": "1111111111111111111111111111111111111111", "commit_message": "Old commit", "parent_commit_id": ""},
+		},
+	}
+	file, _ := os.Create(filepath.Join(commands.MyGitDir, commands.MetadataFile))
+	json.NewEncoder(file).Encode(metadata)
+	file.Close()
+
+	if err := commands.ListBranches(); err != nil {
+		t.Fatalf("failed to list branches: %v", err)
+	}
+	if readBranch(t, "main") != "1111111111111111111111111111111111111111" {
+		t.Errorf("legacy history was not migrated to the main branch")
+	}
+}
@@ -0,0 +1,478 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CheckoutOptions controls Checkout
+type CheckoutOptions struct {
+	// Force discards local modifications that would otherwise block the checkout
+	Force bool
+}
+
+// RestoreOptions controls Restore
+type RestoreOptions struct {
+	// Source is the commit to restore from. By default the working tree is
+	// restored from the index and the index from HEAD.
+	Source string
+	// Staged restores the index
+	Staged bool
+	// Worktree restores the working tree; it is implied when Staged is not set
+	Worktree bool
+	// Force discards local modifications that would otherwise be overwritten
+	Force bool
+}
+
+// Checkout switches to a branch, or rewrites the working tree and index to
+// match a commit and detaches HEAD at it
+func Checkout(rev string, opts CheckoutOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	// A branch name attaches HEAD to the branch
+	if checkBranchName(rev) == nil {
+		if commitID, err := r.readRef(BranchPrefix + rev); err == nil && commitID != "" {
+			return Switch(rev, SwitchOptions{Force: opts.Force})
+		}
+	}
+	unlock, err := r.lock(IndexFile, HeadFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	commit, err := r.ResolveCommit(rev)
+	if err != nil {
+		return err
+	}
+	_, oldHeadID, err := r.readHead()
+	if err != nil {
+		return err
+	}
+	from, err := r.headName()
+	if err != nil {
+		return err
+	}
+	if err := r.checkoutCommit(commit, opts.Force); err != nil {
+		return err
+	}
+
+	commitID := commit.ID
+	if err := r.detachHead(commitID); err != nil {
+		return err
+	}
+	if err := r.logRefUpdate(HeadFile, oldHeadID, commitID, "checkout: moving from "+from+" to "+rev); err != nil {
+		return err
+	}
+
+	message := commit.Message
+	fmt.Printf("HEAD is now at %s %s\n", commitID[:7], firstLine(message))
+	return nil
+}
+
+// checkoutCommit moves the index and working tree from HEAD to a commit
+// without updating HEAD itself. A merge with unresolved conflicts blocks the
+// checkout unless force is set, which abandons the merge.
+func (r *Repository) checkoutCommit(commit *CommitRecord, force bool) error {
+	targetFiles, err := r.commitFiles(commit)
+	if err != nil {
+		return fmt.Errorf("failed to read commit: %w", err)
+	}
+	headSnapshot, err := r.headFiles()
+	if err != nil {
+		return err
+	}
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	if len(idx.Conflicts) > 0 && !force {
+		return kindErrorf(ErrConflict, "you need to resolve your current index first (use --force to abandon the merge)")
+	}
+
+	if err := r.checkoutSnapshot(idx, headSnapshot, targetFiles, force); err != nil {
+		return err
+	}
+	if err := idx.Write(); err != nil {
+		return err
+	}
+	return r.clearMergeState()
+}
+
+// Restore rewrites working tree files and optionally index entries for the
+// given paths from the index or a commit
+func Restore(paths []string, opts RestoreOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	unlock, err := r.lock(IndexFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if len(paths) == 0 {
+		return errors.New("restore requires at least one path")
+	}
+	specs, err := r.repoPaths(paths)
+	if err != nil {
+		return err
+	}
+	if !opts.Staged {
+		opts.Worktree = true
+	}
+
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+
+	var sourceFiles []FileEntry
+	switch {
+	case opts.Source != "":
+		commit, err := r.ResolveCommit(opts.Source)
+		if err != nil {
+			return err
+		}
+		if sourceFiles, err = r.commitFiles(commit); err != nil {
+			return fmt.Errorf("failed to read commit %s: %w", opts.Source, err)
+		}
+	case opts.Staged:
+		if sourceFiles, err = r.headFiles(); err != nil {
+			return err
+		}
+	default:
+		sourceFiles = idx.Files()
+	}
+	source := make(map[string]FileEntry, len(sourceFiles))
+	for _, file := range sourceFiles {
+		source[file.Path] = file
+	}
+
+	// Expand each path to the files it names in the source or the index
+	known := make([]string, 0, len(sourceFiles)+len(idx.Entries))
+	for _, file := range sourceFiles {
+		known = append(known, file.Path)
+	}
+	for _, entry := range idx.Entries {
+		known = append(known, entry.Path)
+	}
+	matched := make(map[string]bool)
+	for i, spec := range specs {
+		found := false
+		for _, candidate := range known {
+			if spec == "." || candidate == spec || strings.HasPrefix(candidate, spec+"/") {
+				matched[candidate] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("pathspec '%s' did not match any file(s) known to mygit", paths[i])
+		}
+	}
+	targets := make([]string, 0, len(matched))
+	for path := range matched {
+		targets = append(targets, path)
+	}
+	sort.Strings(targets)
+
+	// Refuse to overwrite working tree content that is not stored anywhere
+	if opts.Worktree && !opts.Force {
+		var dirty []string
+		for _, path := range targets {
+			file, inSource := source[path]
+			clean, err := r.workTreeSafe(idx, path, file, inSource)
+			if err != nil {
+				return err
+			}
+			if !clean {
+				dirty = append(dirty, path)
+			}
+		}
+		if len(dirty) > 0 {
+			return kindErrorf(ErrLocalChanges, "restore would overwrite local modifications to:\n\t%s\nuse --force to discard them", strings.Join(dirty, "\n\t"))
+		}
+	}
+
+	for _, path := range targets {
+		file, inSource := source[path]
+		var info os.FileInfo
+		if opts.Worktree {
+			switch {
+			case !inSource:
+				if err := r.removeWorkTreeFile(path); err != nil {
+					return err
+				}
+			case file.Mode != ModeGitlink:
+				if info, err = r.writeWorkTreeFile(file); err != nil {
+					return err
+				}
+			}
+		}
+
+		switch {
+		case opts.Staged && !inSource:
+			idx.Remove(path)
+		case opts.Staged && info != nil:
+			idx.Set(newIndexEntry(path, file.Hash, info))
+		case opts.Staged:
+			idx.Set(IndexEntry{Path: path, Hash: file.Hash, Mode: file.Mode})
+		case info != nil:
+			// Refresh stat data when the index already holds this content
+			if entry, ok := idx.Entry(path); ok && entry.Hash == file.Hash && entry.Mode == file.Mode {
+				idx.Set(newIndexEntry(path, file.Hash, info))
+			}
+		}
+		fmt.Printf("Restored: %s\n", path)
+	}
+
+	return idx.Write()
+}
+
+// checkoutSnapshot moves the index and working tree from one snapshot to
+// another. Paths that are the same in both snapshots keep any local changes.
+// Unless force is set, it fails without touching anything if a path that
+// differs between the snapshots has staged or unstaged changes, or if an
+// untracked file would be overwritten.
+func (r *Repository) checkoutSnapshot(idx *Index, from, to []FileEntry, force bool) error {
+	fromFiles := make(map[string]FileEntry, len(from))
+	for _, file := range from {
+		fromFiles[file.Path] = file
+	}
+	toFiles := make(map[string]FileEntry, len(to))
+	for _, file := range to {
+		toFiles[file.Path] = file
+	}
+
+	// Collect the paths that need to change
+	changed := make(map[string]bool)
+	for _, change := range diffSnapshots(from, to) {
+		changed[change.Path] = true
+	}
+	if force {
+		// Discard everything that differs from the target snapshot
+		for _, file := range to {
+			changed[file.Path] = true
+		}
+		for _, entry := range idx.Entries {
+			changed[entry.Path] = true
+		}
+		for _, conflict := range idx.Conflicts {
+			changed[conflict.Path] = true
+		}
+		idx.Conflicts = nil
+	}
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	if !force {
+		var dirty []string
+		for _, path := range paths {
+			entry, tracked := idx.Entry(path)
+			old, inFrom := fromFiles[path]
+			if tracked != inFrom || (tracked && (entry.Hash != old.Hash || entry.Mode != old.Mode)) {
+				// The index has staged changes for this path
+				dirty = append(dirty, path)
+				continue
+			}
+			target, inTarget := toFiles[path]
+			clean, err := r.workTreeSafe(idx, path, target, inTarget)
+			if err != nil {
+				return err
+			}
+			if !clean {
+				dirty = append(dirty, path)
+			}
+		}
+		if len(dirty) > 0 {
+			return kindErrorf(ErrLocalChanges, "your local changes to the following files would be overwritten:\n\t%s\ncommit them or use --force to discard them", strings.Join(dirty, "\n\t"))
+		}
+	}
+
+	// Remove files first so a directory can replace a file of the same name
+	for _, path := range paths {
+		if _, ok := toFiles[path]; !ok {
+			if err := r.removeWorkTreeFile(path); err != nil {
+				return err
+			}
+			idx.Remove(path)
+		}
+	}
+	for _, path := range paths {
+		file, ok := toFiles[path]
+		if !ok {
+			continue
+		}
+		entry, err := r.checkoutFile(file)
+		if err != nil {
+			return err
+		}
+		idx.Set(entry)
+	}
+
+	return nil
+}
+
+// workTreeSafe reports whether the working tree file at path can be replaced
+// by target (or removed when inTarget is false) without losing content.
+// Tracked files are safe when they match the index; untracked files are safe
+// when they do not exist or already match the target.
+func (r *Repository) workTreeSafe(idx *Index, path string, target FileEntry, inTarget bool) (bool, error) {
+	// Submodules are not checked out, so their directories are left alone
+	if idx.isGitlink(path) || (inTarget && target.Mode == ModeGitlink) {
+		return true, nil
+	}
+	info, err := os.Lstat(r.workPath(path))
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !isSnapshotFile(info) {
+		return false, nil
+	}
+
+	entry, tracked := idx.Entry(path)
+	if tracked && idx.StatClean(entry, info) {
+		return true, nil
+	}
+
+	content, err := r.readWorkTreeFile(path, info)
+	if err != nil {
+		return false, err
+	}
+	hash := HashObject(BlobObject, content)
+	if tracked && hash == entry.Hash && fileMode(info) == entry.Mode {
+		return true, nil
+	}
+	return inTarget && hash == target.Hash && fileMode(info) == target.Mode, nil
+}
+
+// checkoutFile writes a file of a snapshot to the working tree and returns
+// its index entry. A submodule is not checked out; its entry only records
+// the commit.
+func (r *Repository) checkoutFile(file FileEntry) (IndexEntry, error) {
+	if file.Mode == ModeGitlink {
+		return IndexEntry{Path: file.Path, Hash: file.Hash, Mode: ModeGitlink}, nil
+	}
+	info, err := r.writeWorkTreeFile(file)
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	return newIndexEntry(file.Path, file.Hash, info), nil
+}
+
+// readWorkTreeFile reads a working tree file as its blob holds it: the
+// target of a symbolic link, or else the content of the file
+func (r *Repository) readWorkTreeFile(path string, info os.FileInfo) ([]byte, error) {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(r.workPath(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read link %s: %w", path, err)
+		}
+		return []byte(target), nil
+	}
+	content, err := os.ReadFile(r.workPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// writeWorkTreeFile writes a file's blob content to the working tree, as a
+// symbolic link for ModeSymlink, and returns the resulting file info
+func (r *Repository) writeWorkTreeFile(file FileEntry) (os.FileInfo, error) {
+	objType, content, err := r.ReadObject(file.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content of %s: %w", file.Path, err)
+	}
+	if objType != BlobObject {
+		return nil, fmt.Errorf("object %s for %s is a %s, not a blob", file.Hash, file.Path, objType)
+	}
+	// Paths come from objects, which may be corrupted
+	if !r.inWorkTree(file.Path) {
+		return nil, fmt.Errorf("refusing to write %s outside the working tree", file.Path)
+	}
+
+	// A symbolic link in place of a directory would lead the file elsewhere
+	if r.linkInPath(file.Path) {
+		return nil, fmt.Errorf("refusing to write %s through a symbolic link", file.Path)
+	}
+
+	path := r.workPath(file.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", file.Path, err)
+	}
+	// Links are replaced rather than written through
+	if info, err := os.Lstat(path); err == nil && (file.Mode == ModeSymlink || info.Mode()&os.ModeSymlink != 0) {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to replace %s: %w", file.Path, err)
+		}
+	}
+	if file.Mode == ModeSymlink {
+		if err := os.Symlink(string(content), path); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+		return os.Lstat(path)
+	}
+	perm := os.FileMode(0644)
+	if file.Mode == ModeExecutable {
+		perm = 0755
+	}
+	if err := os.WriteFile(path, content, perm); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", file.Path, err)
+	}
+	// WriteFile keeps the permissions of an existing file
+	if err := os.Chmod(path, perm); err != nil {
+		return nil, fmt.Errorf("failed to set mode of %s: %w", file.Path, err)
+	}
+	return os.Lstat(path)
+}
+
+// removeWorkTreeFile deletes a file from the working tree along with any
+// parent directories left empty. A directory, such as that of a submodule,
+// is left alone, and so is a path through a symbolic link, which leads
+// somewhere else.
+func (r *Repository) removeWorkTreeFile(path string) error {
+	if !r.inWorkTree(path) {
+		return fmt.Errorf("refusing to remove %s outside the working tree", path)
+	}
+	if info, err := os.Lstat(r.workPath(path)); (err == nil && info.IsDir()) || r.linkInPath(path) {
+		return nil
+	}
+	if err := os.Remove(r.workPath(path)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	r.removeEmptyParents(path)
+	return nil
+}
+
+// removeEmptyParents removes the parent directories of a working tree path
+// that are left empty, stopping at the root of the working tree
+func (r *Repository) removeEmptyParents(name string) {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if err := os.Remove(r.workPath(dir)); err != nil {
+			break
+		}
+	}
+}
+
+// firstLine returns the first line of a commit message
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return line
+}
@@ -0,0 +1,277 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// commitIDs returns the IDs of all commits in the order they were created
+func commitIDs(t *testing.T) []string {
+	t.Helper()
+	metadataPath := filepath.Join(commands.MyGitDir, commands.MetadataFile)
+	file, err := os.Open(metadataPath)
+	if err != nil {
+		t.Fatalf("failed to open metadata: %v", err)
+	}
+	defer file.Close()
+	var metadata struct {
+		CommitHistory []struct {
+			CommitID string `json:"commit_id"`
+		} `json:"commit_history"`
+	}
+	json.NewDecoder(file).Decode(&metadata)
+	var ids []string
+	for _, commit := range metadata.CommitHistory {
+		ids = append(ids, commit.CommitID)
+	}
+	return ids
+}
+
+// setupTwoCommits creates a repository where file.txt changes and new.txt is added in the second commit
+func setupTwoCommits(t *testing.T) []string {
+	t.Helper()
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+	os.WriteFile("file.txt", []byte("version 1\n"), 0644)
+	commands.Add([]string{"file.txt"})
+	commands.Commit("First commit")
+	os.WriteFile("file.txt", []byte("version 2\n"), 0644)
+	os.Mkdir("dir", 0755)
+	os.WriteFile(filepath.Join("dir", "new.txt"), []byte("new\n"), 0644)
+	commands.Add([]string{"file.txt", "dir"})
+	commands.Commit("Second commit")
+	return commitIDs(t)
+}
+
+// TestCheckout tests rebuilding the working tree from a commit
+func TestCheckout(t *testing.T) {
+	tests := []struct {
+		name            string
+		setupFunc       func()
+		force           bool
+		expectedError   bool
+		expectedContent string
+	}{
+		{
+			name:            "clean working tree",
+			setupFunc:       func() {},
+			expectedContent: "version 1\n",
+		},
+		{
+			name: "local modification blocks checkout",
+			setupFunc: func() {
+				os.WriteFile("file.txt", []byte("local edit\n"), 0644)
+			},
+			expectedError:   true,
+			expectedContent: "local edit\n",
+		},
+		{
+			name: "force discards local modification",
+			setupFunc: func() {
+				os.WriteFile("file.txt", []byte("local edit\n"), 0644)
+			},
+			force:           true,
+			expectedContent: "version 1\n",
+		},
+		{
+			name: "untracked file is kept",
+			setupFunc: func() {
+				os.WriteFile("untracked.txt", []byte("untracked\n"), 0644)
+			},
+			expectedContent: "version 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := setupTwoCommits(t)
+			tt.setupFunc()
+
+			err := commands.Checkout(ids[0], commands.CheckoutOptions{Force: tt.force})
+
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			content, _ := os.ReadFile("file.txt")
+			if string(content) != tt.expectedContent {
+				t.Errorf("expected file.txt to contain %q, got %q", tt.expectedContent, content)
+			}
+			if tt.expectedError {
+				return
+			}
+			if _, err := os.Stat(filepath.Join("dir", "new.txt")); !os.IsNotExist(err) {
+				t.Errorf("dir/new.txt should have been removed")
+			}
+			result, err := commands.GetStatus()
+			if err != nil {
+				t.Fatalf("failed to get status: %v", err)
+			}
+			if len(result.Staged) != 0 || len(result.Unstaged) != 0 {
+				t.Errorf("expected clean status after checkout, got %+v", result)
+			}
+		})
+	}
+}
+
+// TestCheckoutThenCommit tests that commits made after a checkout use the checked-out commit as parent
+func TestCheckoutThenCommit(t *testing.T) {
+	ids := setupTwoCommits(t)
+
+	if err := commands.Checkout(ids[0], commands.CheckoutOptions{}); err != nil {
+		t.Fatalf("failed to checkout: %v", err)
+	}
+	os.WriteFile("file.txt", []byte("version 3\n"), 0644)
+	commands.Add([]string{"file.txt"})
+	if err := commands.Commit("Third commit"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	metadataPath := filepath.Join(commands.MyGitDir, commands.MetadataFile)
+	file, _ := os.Open(metadataPath)
+	defer file.Close()
+	var metadata struct {
+		CommitHistory []map[string]any `json:"commit_history"`
+	}
+	json.NewDecoder(file).Decode(&metadata)
+	if parents, _ := metadata.CommitHistory[2]["parent_commit_ids"].([]any); len(parents) != 1 || parents[0] != ids[0] {
+		t.Errorf("expected parents [%s], got %v", ids[0], metadata.CommitHistory[2]["parent_commit_ids"])
+	}
+}
+
+// TestRestore tests restoring individual paths
+func TestRestore(t *testing.T) {
+	tests := []struct {
+		name            string
+		setupFunc       func()
+		paths           []string
+		opts            func(ids []string) commands.RestoreOptions
+		expectedError   bool
+		expectedContent string
+	}{
+		{
+			name: "restore deleted file from index",
+			setupFunc: func() {
+				os.Remove("file.txt")
+			},
+			paths:           []string{"file.txt"},
+			opts:            func([]string) commands.RestoreOptions { return commands.RestoreOptions{} },
+			expectedContent: "version 2\n",
+		},
+		{
+			name:            "restore from an older commit",
+			setupFunc:       func() {},
+			paths:           []string{"file.txt"},
+			opts:            func(ids []string) commands.RestoreOptions { return commands.RestoreOptions{Source: ids[0]} },
+			expectedContent: "version 1\n",
+		},
+		{
+			name: "local modification blocks restore",
+			setupFunc: func() {
+				os.WriteFile("file.txt", []byte("local edit\n"), 0644)
+			},
+			paths:           []string{"file.txt"},
+			opts:            func(ids []string) commands.RestoreOptions { return commands.RestoreOptions{Source: ids[0]} },
+			expectedError:   true,
+			expectedContent: "local edit\n",
+		},
+		{
+			name: "force discards local modification",
+			setupFunc: func() {
+				os.WriteFile("file.txt", []byte("local edit\n"), 0644)
+			},
+			paths:           []string{"file.txt"},
+			opts:            func([]string) commands.RestoreOptions { return commands.RestoreOptions{Force: true} },
+			expectedContent: "version 2\n",
+		},
+		{
+			name:            "unknown path",
+			setupFunc:       func() {},
+			paths:           []string{"missing.txt"},
+			opts:            func([]string) commands.RestoreOptions { return commands.RestoreOptions{} },
+			expectedError:   true,
+			expectedContent: "version 2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := setupTwoCommits(t)
+			tt.setupFunc()
+
+			err := commands.Restore(tt.paths, tt.opts(ids))
+
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			content, _ := os.ReadFile("file.txt")
+			if string(content) != tt.expectedContent {
+				t.Errorf("expected file.txt to contain %q, got %q", tt.expectedContent, content)
+			}
+		})
+	}
+}
+
+// TestRestoreStaged tests restoring index entries without touching the working tree
+func TestRestoreStaged(t *testing.T) {
+	ids := setupTwoCommits(t)
+
+	err := commands.Restore([]string{"file.txt"}, commands.RestoreOptions{Source: ids[0], Staged: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, _ := os.ReadFile("file.txt")
+	if string(content) != "version 2\n" {
+		t.Errorf("working tree should be untouched, got %q", content)
+	}
+	result, err := commands.GetStatus()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if len(result.Staged) != 1 || len(result.Unstaged) != 1 {
+		t.Errorf("expected file.txt to be staged and unstaged, got %+v", result)
+	}
+}
+
+// TestRestoreOutsideWorkTree tests that a path leading out of the working
+// tree in a damaged index is not written
+func TestRestoreOutsideWorkTree(t *testing.T) {
+	setupTwoCommits(t)
+	idx, err := commands.ReadIndex()
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
+	}
+	entry, _ := idx.Entry("file.txt")
+	for _, path := range []string{"../outside.txt", ".mygit/HEAD", "dir/.git/config"} {
+		entry.Path = path
+		idx.Set(entry)
+	}
+	if err := idx.Write(); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+
+	if err := commands.Restore([]string{"."}, commands.RestoreOptions{Force: true}); err == nil {
+		t.Errorf("expected restore to fail")
+	}
+	if _, err := os.Stat(filepath.Join("..", "outside.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected no file outside the working tree, got %v", err)
+	}
+	if head, _ := os.ReadFile(filepath.Join(commands.MyGitDir, "HEAD")); string(head) == "version 2\n" {
+		t.Errorf("expected HEAD to be left alone")
+	}
+	if _, err := os.Stat(filepath.Join("dir", ".git")); !os.IsNotExist(err) {
+		t.Errorf("expected no repository directory to be written, got %v", err)
+	}
+}
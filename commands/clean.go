@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// CleanOptions controls Clean
+type CleanOptions struct {
+	// DryRun only prints what would be removed
+	DryRun bool
+	// Force must be set to remove anything, guarding against accidents
+	Force bool
+	// Directories also removes untracked directories
+	Directories bool
+	// IncludeIgnored also removes ignored files
+	IncludeIgnored bool
+	// OnlyIgnored removes only ignored files, keeping other untracked files
+	OnlyIgnored bool
+}
+
+// Clean removes untracked files from the working tree. Ignored files are kept
+// unless IncludeIgnored or OnlyIgnored is set, and untracked directories are
+// left alone unless Directories is set.
+func Clean(opts CleanOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	if !opts.Force && !opts.DryRun {
+		return errors.New("refusing to clean without -f or -n")
+	}
+	if opts.IncludeIgnored && opts.OnlyIgnored {
+		return errors.New("-x and -X cannot be used together")
+	}
+
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	ignore, err := r.newIgnoreMatcher()
+	if err != nil {
+		return err
+	}
+	dirs := trackedDirs(idx)
+
+	// removable reports whether an untracked path should be cleaned given
+	// whether it is ignored
+	removable := func(ignored bool) bool {
+		if opts.OnlyIgnored {
+			return ignored
+		}
+		return !ignored || opts.IncludeIgnored
+	}
+
+	// Collect paths to remove in walk order, along with the untracked
+	// directories looked into; directories are listed with a trailing slash.
+	// kept holds the directories with something inside that stays.
+	var found []string
+	kept := make(map[string]bool)
+	keep := func(name string) {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			kept[dir] = true
+		}
+	}
+	err = fs.WalkDir(r.workTreeFS(), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name == "." || dirs[name] {
+				return nil
+			}
+			if r.isGitDir(name) || idx.isGitlink(name) || !opts.Directories {
+				keep(name)
+				return fs.SkipDir
+			}
+			ignored, err := ignore.ignored(name, true)
+			if err != nil {
+				return err
+			}
+			switch {
+			case ignored && removable(true):
+				found = append(found, name+"/")
+				return fs.SkipDir
+			case ignored:
+				keep(name)
+				return fs.SkipDir
+			}
+			// Look inside for files that should be kept or removed
+			found = append(found, name+"/")
+			return nil
+		}
+
+		_, tracked := idx.Entry(name)
+		_, unmerged := idx.Conflict(name)
+		if tracked || unmerged {
+			return nil
+		}
+		ignored, err := ignore.ignored(name, false)
+		if err != nil {
+			return err
+		}
+		if removable(ignored) {
+			found = append(found, name)
+		} else {
+			keep(name)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan working tree: %w", err)
+	}
+
+	// Like Git, an untracked directory with nothing inside to keep is
+	// removed whole and listed once. The walk lists everything inside a
+	// directory right after it.
+	var targets []string
+	for _, name := range found {
+		if n := len(targets); n > 0 && strings.HasSuffix(targets[n-1], "/") && strings.HasPrefix(name, targets[n-1]) {
+			continue
+		}
+		if dir, ok := strings.CutSuffix(name, "/"); ok && kept[dir] {
+			continue
+		}
+		targets = append(targets, name)
+	}
+
+	for _, target := range targets {
+		if opts.DryRun {
+			fmt.Printf("Would remove %s\n", target)
+			continue
+		}
+		if err := os.RemoveAll(r.workPath(target)); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", target, err)
+		}
+		fmt.Printf("Removing %s\n", target)
+	}
+
+	return nil
+}
@@ -0,0 +1,500 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+// combinedLine is a line of the result of a merge in a combined diff,
+// together with the lines the parents had before it
+type combinedLine struct {
+	text string
+	// flag has bit i set when the line is not in parent i. The bit after
+	// the parents marks lines shown in a hunk, and the next one context
+	// lines before a hunk, which do not show their lost lines.
+	flag uint
+	// lost holds the lines removed from parents before this line
+	lost []lostLine
+	// parentLine holds, for each parent, the line number in that parent
+	// where a hunk starting at this line starts
+	parentLine []int
+}
+
+// lostLine is a line some parents had that the result does not
+type lostLine struct {
+	text string
+	// parents has bit i set for the parents that had the line
+	parents uint
+}
+
+// formatCombinedDiff formats the changes of a merge as a combined diff,
+// like git diff --cc. Only files that differ from every parent are shown,
+// and only the hunks where the result does not simply take the lines of one
+// parent.
+func formatCombinedDiff(parents []diffSource, result diffSource, context int) (string, error) {
+	resultFiles := make(map[string]FileEntry, len(result.files))
+	for _, file := range result.files {
+		resultFiles[file.Path] = file
+	}
+	parentFiles := make([]map[string]FileEntry, len(parents))
+	changed := make([]map[string]bool, len(parents))
+	for i, parent := range parents {
+		parentFiles[i] = make(map[string]FileEntry, len(parent.files))
+		for _, file := range parent.files {
+			parentFiles[i][file.Path] = file
+		}
+		changed[i] = make(map[string]bool)
+		for _, change := range diffSnapshots(parent.files, result.files) {
+			changed[i][change.Path] = true
+		}
+	}
+
+	var out strings.Builder
+	for _, change := range diffSnapshots(parents[0].files, result.files) {
+		path := change.Path
+		differsFromAll := true
+		for i := range parents {
+			differsFromAll = differsFromAll && changed[i][path]
+		}
+		if !differsFromAll {
+			continue
+		}
+
+		resultFile, inResult := resultFiles[path]
+		var resultContent []byte
+		var err error
+		if inResult {
+			if resultContent, err = result.read(resultFile); err != nil {
+				return "", fmt.Errorf("failed to read %s: %w", path, err)
+			}
+		}
+		binary := IsBinary(resultContent)
+		files := make([]FileEntry, len(parents))
+		contents := make([][]byte, len(parents))
+		for i, parent := range parents {
+			file, ok := parentFiles[i][path]
+			if !ok {
+				continue
+			}
+			files[i] = file
+			if contents[i], err = parent.read(file); err != nil {
+				return "", fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			binary = binary || IsBinary(contents[i])
+		}
+
+		modeDiffers := false
+		for _, file := range files {
+			modeDiffers = modeDiffers || file.Mode != resultFile.Mode
+		}
+		if binary {
+			out.WriteString(combinedHeader(path, files, resultFile, modeDiffers, false))
+			out.WriteString("Binary files differ\n")
+			continue
+		}
+
+		lines := combineLines(contents, resultContent, inResult)
+		cnt := len(lines) - 2
+		shown := makeCombinedHunks(lines, cnt, len(parents), context)
+		if !shown && !modeDiffers {
+			continue
+		}
+		out.WriteString(combinedHeader(path, files, resultFile, modeDiffers, true))
+		if inResult {
+			writeCombinedHunks(&out, lines, cnt, len(parents))
+		}
+	}
+	return out.String(), nil
+}
+
+// combinedHeader formats the header of a file in a combined diff. Files
+// missing from a side have no mode.
+func combinedHeader(path string, parents []FileEntry, result FileEntry, modeDiffers, fileHeader bool) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "diff --cc %s\nindex ", path)
+	for i, parent := range parents {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		out.WriteString(shortID(hashOrZero(parent.Hash)))
+	}
+	fmt.Fprintf(&out, "..%s\n", shortID(hashOrZero(result.Hash)))
+
+	deleted := result.Mode == ""
+	added := !deleted
+	for _, parent := range parents {
+		added = added && parent.Mode == ""
+	}
+	if modeDiffers {
+		if added {
+			fmt.Fprintf(&out, "new file mode %s\n", result.Mode)
+		} else {
+			if deleted {
+				out.WriteString("deleted file ")
+			}
+			out.WriteString("mode ")
+			for i, parent := range parents {
+				if i > 0 {
+					out.WriteString(",")
+				}
+				out.WriteString(modeOrZero(parent.Mode))
+			}
+			if !deleted {
+				out.WriteString(".." + result.Mode)
+			}
+			out.WriteString("\n")
+		}
+	}
+	if !fileHeader {
+		return out.String()
+	}
+	if added {
+		out.WriteString("--- /dev/null\n")
+	} else {
+		fmt.Fprintf(&out, "--- a/%s\n", path)
+	}
+	if deleted {
+		out.WriteString("+++ /dev/null\n")
+	} else {
+		fmt.Fprintf(&out, "+++ b/%s\n", path)
+	}
+	return out.String()
+}
+
+func hashOrZero(hash string) string {
+	if hash == "" {
+		return zeroHash
+	}
+	return hash
+}
+
+func modeOrZero(mode string) string {
+	if mode == "" {
+		return "000000"
+	}
+	return mode
+}
+
+// combineLines compares the result of a merge with each parent. It returns
+// a line per line of the result, then one holding the lines lost at the
+// end, then one holding the line numbers of the parents after their last
+// line.
+func combineLines(parents [][]byte, result []byte, inResult bool) []combinedLine {
+	resultLines := SplitLines(result)
+	cnt := len(resultLines)
+	lines := make([]combinedLine, cnt+2)
+	for i := range lines {
+		lines[i].parentLine = make([]int, len(parents))
+		if i < cnt {
+			lines[i].text = strings.TrimSuffix(resultLines[i], "\n")
+		}
+	}
+	if !inResult {
+		return lines
+	}
+
+	for n, parent := range parents {
+		mask := uint(1) << n
+		// Lines removed from the parent hang on the line after them
+		lost := make(map[int][]lostLine)
+		edits := DiffLines(SplitLines(parent), resultLines)
+		next := 0
+		for i := 0; i < len(edits); {
+			if edits[i].Op == EditEqual {
+				next++
+				i++
+				continue
+			}
+			bucket := next
+			for ; i < len(edits) && edits[i].Op != EditEqual; i++ {
+				if edits[i].Op == EditInsert {
+					lines[edits[i].NewLine].flag |= mask
+					next++
+				} else {
+					lost[bucket] = append(lost[bucket], lostLine{text: strings.TrimSuffix(edits[i].Text, "\n"), parents: mask})
+				}
+			}
+		}
+
+		parentLine := 1
+		for lno := 0; lno <= cnt; lno++ {
+			lines[lno].parentLine[n] = parentLine
+			if len(lost[lno]) > 0 {
+				lines[lno].lost = coalesceLost(lines[lno].lost, lost[lno], mask)
+			}
+			for _, line := range lines[lno].lost {
+				if line.parents&mask != 0 {
+					parentLine++
+				}
+			}
+			if lno < cnt && lines[lno].flag&mask == 0 {
+				parentLine++
+			}
+		}
+		lines[cnt+1].parentLine[n] = parentLine
+	}
+	return lines
+}
+
+// coalesceLost merges the lines a parent lost into the lines other parents
+// lost at the same place. Lines of their longest common subsequence are
+// shown once, marked as lost by both.
+func coalesceLost(base, lost []lostLine, mask uint) []lostLine {
+	if len(base) == 0 {
+		return lost
+	}
+	// length[i][j] is the length of the longest common subsequence of the
+	// first i lines of base and the first j lines of lost
+	length := make([][]int, len(base)+1)
+	for i := range length {
+		length[i] = make([]int, len(lost)+1)
+	}
+	for i := 1; i <= len(base); i++ {
+		for j := 1; j <= len(lost); j++ {
+			if base[i-1].text == lost[j-1].text {
+				length[i][j] = length[i-1][j-1] + 1
+			} else {
+				length[i][j] = max(length[i][j-1], length[i-1][j])
+			}
+		}
+	}
+
+	// Walk back from the end, preferring lost lines over base lines, as Git does
+	var merged []lostLine
+	i, j := len(base), len(lost)
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && base[i-1].text == lost[j-1].text:
+			line := base[i-1]
+			line.parents |= mask
+			merged = append(merged, line)
+			i--
+			j--
+		case j > 0 && (i == 0 || length[i][j-1] >= length[i-1][j]):
+			merged = append(merged, lost[j-1])
+			j--
+		default:
+			merged = append(merged, base[i-1])
+			i--
+		}
+	}
+	for a, b := 0, len(merged)-1; a < b; a, b = a+1, b-1 {
+		merged[a], merged[b] = merged[b], merged[a]
+	}
+	return merged
+}
+
+// interestingLine reports whether the result differs from some parent at
+// a line
+func interestingLine(line combinedLine, allMask uint) bool {
+	return line.flag&allMask != 0 || len(line.lost) > 0
+}
+
+// makeCombinedHunks marks the lines of the hunks to show and reports
+// whether there are any. A hunk is left out when the result only takes the
+// changes of one parent, that is when all its changes are against the same
+// parents but not all of them.
+func makeCombinedHunks(lines []combinedLine, cnt, parents, context int) bool {
+	allMask := uint(1)<<parents - 1
+	mark := uint(1) << parents
+	for i := 0; i <= cnt; i++ {
+		if interestingLine(lines[i], allMask) {
+			lines[i].flag |= mark
+		} else {
+			lines[i].flag &^= mark
+		}
+	}
+
+	for i := 0; i <= cnt; {
+		for i <= cnt && lines[i].flag&mark == 0 {
+			i++
+		}
+		if i > cnt {
+			break
+		}
+		hunkBegin := i
+		j := i + 1
+		for ; j <= cnt; j++ {
+			if lines[j].flag&mark != 0 {
+				continue
+			}
+			// Continue past uninteresting lines when an interesting line
+			// follows within the context
+			la := adjustHunkTail(lines, allMask, hunkBegin, j)
+			la = min(la+context, cnt+1)
+			continued := false
+			for la > 0 {
+				la--
+				if la < j {
+					break
+				}
+				if lines[la].flag&mark != 0 {
+					continued = true
+					break
+				}
+			}
+			if !continued {
+				break
+			}
+			j = la
+		}
+		hunkEnd := j
+
+		// The hunk is interesting when its changes are against different
+		// sets of parents, or against all of them
+		var sameDiff uint
+		interesting := false
+		for j := i; j < hunkEnd && !interesting; j++ {
+			if diff := lines[j].flag & allMask; diff != 0 {
+				if sameDiff == 0 {
+					sameDiff = diff
+				} else if sameDiff != diff {
+					interesting = true
+					break
+				}
+			}
+			for _, line := range lines[j].lost {
+				if sameDiff == 0 {
+					sameDiff = line.parents
+				} else if sameDiff != line.parents {
+					interesting = true
+					break
+				}
+			}
+		}
+		if !interesting && sameDiff != allMask {
+			for j := hunkBegin; j < hunkEnd; j++ {
+				lines[j].flag &^= mark
+			}
+		}
+		i = hunkEnd
+	}
+	return giveCombinedContext(lines, cnt, parents, context)
+}
+
+// adjustHunkTail returns where the trailing context of a hunk ending before
+// line i starts. A last line that only has lost lines already shows itself
+// after them, as a line of context.
+func adjustHunkTail(lines []combinedLine, allMask uint, hunkBegin, i int) int {
+	if hunkBegin+1 <= i && lines[i-1].flag&allMask == 0 {
+		i--
+	}
+	return i
+}
+
+// nextCombinedLine returns the first line from i that is marked, or that is
+// not marked when unmarked is set, or cnt+1 when there is none
+func nextCombinedLine(lines []combinedLine, mark uint, i, cnt int, unmarked bool) int {
+	for ; i <= cnt; i++ {
+		if (lines[i].flag&mark == 0) == unmarked {
+			return i
+		}
+	}
+	return i
+}
+
+// giveCombinedContext marks the context lines around the marked lines,
+// joining hunks separated by less than the context, and reports whether
+// there is any hunk
+func giveCombinedContext(lines []combinedLine, cnt, parents, context int) bool {
+	allMask := uint(1)<<parents - 1
+	mark := uint(1) << parents
+	noPreDelete := uint(2) << parents
+
+	i := nextCombinedLine(lines, mark, 0, cnt, false)
+	if i > cnt {
+		return false
+	}
+	for i <= cnt {
+		// Context before the hunk does not show its lost lines
+		for j := max(i-context, 0); j < i; j++ {
+			if lines[j].flag&mark == 0 {
+				lines[j].flag |= noPreDelete
+			}
+			lines[j].flag |= mark
+		}
+
+		for {
+			j := nextCombinedLine(lines, mark, i, cnt, true)
+			if j > cnt {
+				return true
+			}
+			k := nextCombinedLine(lines, mark, j, cnt, false)
+			j = adjustHunkTail(lines, allMask, i, j)
+			if k < j+context {
+				// The gap to the next hunk is small enough to join them
+				for ; j < k; j++ {
+					lines[j].flag |= mark
+				}
+				i = k
+				continue
+			}
+			i = k
+			for end := min(j+context, cnt+1); j < end; j++ {
+				lines[j].flag |= mark
+			}
+			break
+		}
+	}
+	return true
+}
+
+// writeCombinedHunks writes the marked lines as hunks. Each line has a
+// column per parent: "-" for lines removed from that parent, and "+" for
+// lines the parent did not have.
+func writeCombinedHunks(out *strings.Builder, lines []combinedLine, cnt, parents int) {
+	mark := uint(1) << parents
+	noPreDelete := uint(2) << parents
+	markers := strings.Repeat("@", parents+1)
+	for lno := 0; ; {
+		for lno <= cnt && lines[lno].flag&mark == 0 {
+			lno++
+		}
+		if lno > cnt {
+			return
+		}
+		hunkEnd := lno + 1
+		for hunkEnd <= cnt && lines[hunkEnd].flag&mark != 0 {
+			hunkEnd++
+		}
+		count := hunkEnd - lno
+		if hunkEnd > cnt {
+			// The last line only holds the lines lost at the end
+			count--
+		}
+
+		out.WriteString(markers)
+		for n := 0; n < parents; n++ {
+			start, end := lines[lno].parentLine[n], lines[hunkEnd].parentLine[n]
+			fmt.Fprintf(out, " -%d,%d", start, end-start)
+		}
+		fmt.Fprintf(out, " +%d,%d %s\n", lno+1, count, markers)
+
+		for ; lno < hunkEnd; lno++ {
+			line := lines[lno]
+			if line.flag&noPreDelete == 0 {
+				for _, lost := range line.lost {
+					for n := 0; n < parents; n++ {
+						out.WriteByte(combinedMarker(lost.parents, n, '-'))
+					}
+					out.WriteString(lost.text + "\n")
+				}
+			}
+			if lno == cnt {
+				continue
+			}
+			for n := 0; n < parents; n++ {
+				out.WriteByte(combinedMarker(line.flag, n, '+'))
+			}
+			out.WriteString(line.text + "\n")
+		}
+	}
+}
+
+// combinedMarker returns op when bit n of mask is set, and a space otherwise
+func combinedMarker(mask uint, n int, op byte) byte {
+	if mask&(1<<n) != 0 {
+		return op
+	}
+	return ' '
+}
@@ -1,346 +1,707 @@
 package commands
 
 import (
-	"crypto/sha1"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
 
 const (
-	MyGitDir = ".mygit"
+	MyGitDir     = ".mygit"
 	MetadataFile = "metadata.json"
+	HeadFile     = "HEAD"
 )
 
-// Init initializes a new repository
+// Init initializes a new repository in the current directory, or in
+// MYGIT_DIR when it is set
 func Init() error {
+	gitDir := MyGitDir
+	if dir := os.Getenv(GitDirEnv); dir != "" {
+		gitDir = dir
+	}
+
 	// Check if .mygit directory already exists
-	if _, err := os.Stat(MyGitDir); err == nil {
+	if _, err := os.Stat(gitDir); err == nil {
 		fmt.Println("Repository already initialized")
 		return nil
 	}
 
+	if err := initGitDir(gitDir); err != nil {
+		return err
+	}
+
+	fmt.Println("Repository initialized successfully")
+	return nil
+}
+
+// InitRepository creates a repository in dir, which must exist, and opens
+// it. An existing repository is opened as it is.
+func InitRepository(dir string) (*Repository, error) {
+	gitDir := filepath.Join(dir, MyGitDir)
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		if err := initGitDir(gitDir); err != nil {
+			return nil, err
+		}
+	}
+	return Open(dir)
+}
+
+// initGitDir creates a repository directory with an empty history
+func initGitDir(gitDir string) error {
 	// Create .mygit directory
-	if err := os.Mkdir(MyGitDir, 0755); err != nil {
+	if err := os.MkdirAll(gitDir, 0755); err != nil {
 		return fmt.Errorf("failed to create .mygit directory: %w", err)
 	}
+	r := &Repository{gitDir: gitDir}
 
-	// Create metadata.json with empty JSON object
-	metadata := map[string]any{}
-	metadataPath := filepath.Join(MyGitDir, MetadataFile)
-	
-	file, err := os.Create(metadataPath)
-	if err != nil {
-		return fmt.Errorf("failed to create metadata.json: %w", err)
+	// Create objects directory for the content-addressable store
+	if err := os.Mkdir(r.gitPath(ObjectsDir), 0755); err != nil {
+		return fmt.Errorf("failed to create objects directory: %w", err)
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(metadata); err != nil {
-		return fmt.Errorf("failed to write metadata.json: %w", err)
+	// Create refs directory and point HEAD at the default branch
+	if err := os.MkdirAll(r.gitPath(filepath.FromSlash(BranchPrefix)), 0755); err != nil {
+		return fmt.Errorf("failed to create refs directory: %w", err)
+	}
+	if err := r.attachHead(DefaultBranch); err != nil {
+		return err
 	}
 
-	fmt.Println("Repository initialized successfully")
-	return nil
+	// Create metadata.json with an empty history
+	return r.writeMetadata(&metadata{})
+}
+
+// AddOptions controls AddWithOptions
+type AddOptions struct {
+	// All stages new, modified and deleted files. Without paths it covers the
+	// whole working tree.
+	All bool
+	// Update stages modified and deleted tracked files without adding new
+	// ones. Without paths it covers the whole working tree.
+	Update bool
+	// Force adds files even if they are ignored
+	Force bool
+}
+
+// AddResult lists the paths whose index entries were changed by Add, each
+// sorted by path
+type AddResult struct {
+	// Added lists files that were not tracked before
+	Added []string
+	// Updated lists tracked files whose content or mode changed
+	Updated []string
+	// Removed lists tracked files that no longer exist in the working tree
+	Removed []string
+	// Resolved lists paths whose merge conflicts were marked as resolved
+	Resolved []string
+}
+
+// Empty reports whether Add left the index unchanged
+func (a *AddResult) Empty() bool {
+	return len(a.Added) == 0 && len(a.Updated) == 0 && len(a.Removed) == 0 && len(a.Resolved) == 0
+}
+
+// IgnoredPathsError is returned by Add when paths named explicitly are
+// ignored. The other paths are still added.
+type IgnoredPathsError struct {
+	Paths []string
+}
+
+func (e *IgnoredPathsError) Error() string {
+	return fmt.Sprintf("the following paths are ignored by one of your %s files:\n\t%s\nuse -f if you really want to add them", IgnoreFile, strings.Join(e.Paths, "\n\t"))
 }
 
 // Add adds files to the staging area
 func Add(args []string) error {
-	// Check if .mygit exists
-	if _, err := os.Stat(MyGitDir); os.IsNotExist(err) {
-		return errors.New("not a mygit repository (run 'mygit init' first)")
+	return AddWithOptions(args, AddOptions{})
+}
+
+// AddWithOptions adds files to the staging area. Tracked files under the
+// given paths that no longer exist are removed from it.
+func AddWithOptions(args []string, opts AddOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	result, err := r.Add(args, opts)
+	if result == nil {
+		return err
+	}
+	if result.Empty() && err == nil {
+		fmt.Println("No files to add.")
+		return nil
+	}
+	for _, path := range result.Resolved {
+		fmt.Printf("Resolved: %s\n", path)
+	}
+	for _, path := range result.Added {
+		fmt.Printf("Added: %s\n", path)
+	}
+	for _, path := range result.Updated {
+		fmt.Printf("Updated: %s\n", path)
+	}
+	for _, path := range result.Removed {
+		fmt.Printf("Removed: %s\n", path)
+	}
+	return err
+}
+
+// Add stages the files under the given paths and the deletion of tracked
+// files under them that no longer exist. When some of the paths are ignored
+// the others are still staged, and the result is returned together with an
+// *IgnoredPathsError.
+func (r *Repository) Add(args []string, opts AddOptions) (*AddResult, error) {
+	if opts.All && opts.Update {
+		return nil, errors.New("-A and -u cannot be used together")
+	}
+	args, err := r.repoPaths(args)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := r.lock(IndexFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if len(args) == 0 && (opts.All || opts.Update) {
+		// Cover the whole working tree, even from a subdirectory
+		args = []string{"."}
+	}
+
+	// Load the index
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	ignore, err := r.newIgnoreMatcher()
+	if err != nil {
+		return nil, err
+	}
+	dirs := trackedDirs(idx)
+	isTracked := func(name string) bool {
+		_, inIndex := idx.Entry(name)
+		_, unmerged := idx.Conflict(name)
+		return inIndex || unmerged
+	}
+
+	// Expand a path to the files it names. Ignored files are skipped when
+	// found in a directory and reported when named directly, unless forced.
+	fsys := r.workTreeFS()
+	var filesToAdd, ignoredPaths []string
+	expand := func(name string) error {
+		// A symbolic link is added as a link, even one to a directory
+		info, err := os.Lstat(r.workPath(name))
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			if !opts.Force && !isTracked(name) {
+				if ignored, err := ignore.ignored(name, false); err != nil {
+					return err
+				} else if ignored {
+					ignoredPaths = append(ignoredPaths, name)
+					return nil
+				}
+			}
+			filesToAdd = append(filesToAdd, name)
+			return nil
+		}
+		return fs.WalkDir(fsys, name, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if r.isGitDir(path) || idx.isGitlink(path) {
+					return fs.SkipDir
+				}
+				if path == "." || opts.Force || dirs[path] {
+					return nil
+				}
+				ignored, err := ignore.ignored(path, true)
+				if err != nil {
+					return err
+				}
+				if ignored {
+					if path == name {
+						ignoredPaths = append(ignoredPaths, name)
+					}
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !opts.Force && !isTracked(path) {
+				if ignored, err := ignore.ignored(path, false); err != nil || ignored {
+					return err
+				}
+			}
+			filesToAdd = append(filesToAdd, path)
+			return nil
+		})
 	}
 
 	// Expand all arguments to file paths
-	var filesToAdd []string
 	for _, arg := range args {
-		matches, err := filepath.Glob(arg)
+		matches, err := fs.Glob(fsys, arg)
 		if err != nil || matches == nil {
-			// If not a glob, check if it's a file or directory
-			info, statErr := os.Stat(arg)
-			if statErr == nil {
-				if info.IsDir() {
-					filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
-						if err == nil && !info.IsDir() {
-							filesToAdd = append(filesToAdd, path)
-						}
-						return nil
-					})
-				} else {
-					filesToAdd = append(filesToAdd, arg)
-				}
-			}
-			continue
+			// If not a glob, it may be a file or directory
+			matches = []string{arg}
 		}
 		for _, match := range matches {
-			info, statErr := os.Stat(match)
-			if statErr == nil {
-				if info.IsDir() {
-					filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
-						if err == nil && !info.IsDir() {
-							filesToAdd = append(filesToAdd, path)
-						}
-						return nil
-					})
-				} else {
-					filesToAdd = append(filesToAdd, match)
-				}
+			if err := expand(match); err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	if len(filesToAdd) == 0 {
-		fmt.Println("No files to add.")
-		return nil
+	// Collect tracked files under the given paths that have been deleted
+	tracked := conflictPaths(idx)
+	for _, entry := range idx.Entries {
+		tracked = append(tracked, entry.Path)
 	}
+	var removals []string
+	for _, path := range tracked {
+		if !matchPathspec(args, path) || idx.isGitlink(path) {
+			continue
+		}
+		// Symbolic links are stored as links, so they are not followed
+		if info, err := os.Lstat(r.workPath(path)); err != nil || !isSnapshotFile(info) {
+			removals = append(removals, path)
+		}
+	}
+	sort.Strings(removals)
 
-	// Remove duplicates
+	// Remove duplicates, keeping only tracked files when updating
 	fileSet := make(map[string]struct{})
 	for _, f := range filesToAdd {
+		if opts.Update && !isTracked(f) {
+			continue
+		}
 		fileSet[f] = struct{}{}
 	}
 	uniqueFiles := make([]string, 0, len(fileSet))
 	for f := range fileSet {
 		uniqueFiles = append(uniqueFiles, f)
 	}
+	sort.Strings(uniqueFiles)
 
-	// Read metadata.json
-	metadataPath := filepath.Join(MyGitDir, MetadataFile)
-	metadata := map[string]any{}
-	if file, err := os.Open(metadataPath); err == nil {
-		defer file.Close()
-		json.NewDecoder(file).Decode(&metadata)
-	}
-
-	// Load or create staging_area
-	stagingArea := []map[string]string{}
-	if sa, ok := metadata["staging_area"]; ok {
-		if arr, ok := sa.([]any); ok {
-			for _, v := range arr {
-				if m, ok := v.(map[string]any); ok {
-					entry := map[string]string{}
-					for k, val := range m {
-						if s, ok := val.(string); ok {
-							entry[k] = s
-						}
-					}
-					stagingArea = append(stagingArea, entry)
-				}
-			}
+	// Add/update files
+	result := &AddResult{}
+	for _, indexPath := range uniqueFiles {
+		info, err := os.Lstat(r.workPath(indexPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", indexPath, err)
 		}
-	}
 
-	// Index for quick lookup
-	stagedIndex := make(map[string]int)
-	for i, entry := range stagingArea {
-		stagedIndex[entry["file_path"]] = i
-	}
-
-	// Add/update files
-	for _, filePath := range uniqueFiles {
-		// Skip files in .mygit
-		if strings.HasPrefix(filePath, MyGitDir+string(os.PathSeparator)) || filePath == MyGitDir {
+		existing, tracked := idx.Entry(indexPath)
+		if tracked && idx.StatClean(existing, info) {
 			continue
 		}
-		f, err := os.Open(filePath)
+
+		content, err := r.readWorkTreeFile(indexPath, info)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: could not open %s: %v\n", filePath, err)
-			continue
+			return nil, err
 		}
-		sha := sha1.New()
-		if _, err := io.Copy(sha, f); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: could not hash %s: %v\n", filePath, err)
-			f.Close()
-			continue
+
+		// Store the content as a blob so it can be restored later
+		hash, err := r.WriteObject(BlobObject, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", indexPath, err)
 		}
-		f.Close()
-		hash := fmt.Sprintf("%x", sha.Sum(nil))
 
-		entry := map[string]string{"file_path": filePath, "file_hash": hash}
-		if idx, ok := stagedIndex[filePath]; ok {
-			stagingArea[idx] = entry
-			fmt.Printf("Updated: %s\n", filePath)
-		} else {
-			stagingArea = append(stagingArea, entry)
-			fmt.Printf("Added: %s\n", filePath)
+		entry := newIndexEntry(indexPath, hash, info)
+		idx.Set(entry)
+		switch {
+		case idx.ResolveConflict(indexPath):
+			result.Resolved = append(result.Resolved, indexPath)
+		case !tracked:
+			result.Added = append(result.Added, indexPath)
+		case existing.Hash != entry.Hash || existing.Mode != entry.Mode:
+			result.Updated = append(result.Updated, indexPath)
 		}
 	}
 
-	metadata["staging_area"] = stagingArea
+	// Stage deletions
+	for _, path := range removals {
+		idx.Remove(path)
+		idx.ResolveConflict(path)
+		result.Removed = append(result.Removed, path)
+	}
+
+	if err := idx.Write(); err != nil {
+		return nil, err
+	}
 
-	// Write back to metadata.json
-	file, err := os.Create(metadataPath)
+	if len(ignoredPaths) > 0 {
+		return result, &IgnoredPathsError{Paths: ignoredPaths}
+	}
+	return result, nil
+}
+
+// matchPathspec reports whether a slash-separated path is named by any of
+// the given command-line paths: the path itself, a directory containing it,
+// "." or a glob pattern
+func matchPathspec(specs []string, name string) bool {
+	for _, spec := range specs {
+		clean := filepath.ToSlash(filepath.Clean(spec))
+		if clean == "." || name == clean || strings.HasPrefix(name, clean+"/") {
+			return true
+		}
+		if matched, _ := path.Match(clean, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Commit commits the staged changes. While a merge is in progress the commit
+// records the merged commit as a second parent, and an empty message falls
+// back to the prepared merge message.
+func Commit(message string) error {
+	return CommitWithOptions(message, CommitOptions{})
+}
+
+// CommitOptions controls how a commit is recorded
+type CommitOptions struct {
+	// Author overrides the author, which defaults to the committer. Its date
+	// is used when set.
+	Author Signature
+	// Date overrides the author date
+	Date time.Time
+}
+
+// CommitWithOptions commits the staged changes with the given options
+func CommitWithOptions(message string, opts CommitOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
 	if err != nil {
-		return fmt.Errorf("failed to update metadata.json: %w", err)
+		return err
 	}
-	defer file.Close()
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(metadata); err != nil {
-		return fmt.Errorf("failed to write metadata.json: %w", err)
+
+	commit, err := r.CommitWithOptions(message, opts)
+	if err != nil {
+		return err
+	}
+	changes, err := r.CommitChanges(commit)
+	if err != nil {
+		return err
 	}
 
+	// Print success message
+	fmt.Printf("Committed %d files\n", len(changes))
+	fmt.Printf("Commit ID: %s\n", commit.ID)
+	fmt.Printf("Message: %s\n", commit.Message)
+
 	return nil
 }
 
-// Commit commits the staged changes
-func Commit(message string) error {
-	// Check if .mygit exists
-	if _, err := os.Stat(MyGitDir); os.IsNotExist(err) {
-		return errors.New("not a mygit repository (run 'mygit init' first)")
+// Commit records the index as a new commit on the current branch, or on a
+// detached HEAD, and returns it. The author and committer are taken from
+// user.name and user.email.
+func (r *Repository) Commit(message string) (*CommitRecord, error) {
+	return r.CommitWithOptions(message, CommitOptions{})
+}
+
+// CommitWithOptions is Commit with options
+func (r *Repository) CommitWithOptions(message string, opts CommitOptions) (*CommitRecord, error) {
+	unlock, err := r.lock(IndexFile, HeadFile, MetadataFile)
+	if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
 	// Read metadata.json
-	metadataPath := filepath.Join(MyGitDir, MetadataFile)
-	metadata := map[string]any{}
-	if file, err := os.Open(metadataPath); err == nil {
-		defer file.Close()
-		json.NewDecoder(file).Decode(&metadata)
-	}
-
-	// Load staging area
-	stagingArea := []map[string]string{}
-	if sa, ok := metadata["staging_area"]; ok {
-		if arr, ok := sa.([]any); ok {
-			for _, v := range arr {
-				if m, ok := v.(map[string]any); ok {
-					entry := map[string]string{}
-					for k, val := range m {
-						if s, ok := val.(string); ok {
-							entry[k] = s
-						}
-					}
-					stagingArea = append(stagingArea, entry)
-				}
-			}
-		}
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
 	}
 
-	// Check if there are files in staging area
-	if len(stagingArea) == 0 {
-		return errors.New("no files staged for commit")
+	// Load the index, which holds the complete next snapshot
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	if len(idx.Conflicts) > 0 {
+		return nil, kindErrorf(ErrConflict, "cannot commit with unmerged paths:\n\t%s\nresolve them and run 'mygit add' first", strings.Join(conflictPaths(idx), "\n\t"))
 	}
 
-	// Get current timestamp
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-
-	// Get parent commit ID
-	parentCommitID := ""
-	if commitHistory, ok := metadata["commit_history"]; ok {
-		if arr, ok := commitHistory.([]any); ok && len(arr) > 0 {
-			if lastCommit, ok := arr[len(arr)-1].(map[string]any); ok {
-				if id, ok := lastCommit["commit_id"].(string); ok {
-					parentCommitID = id
-				}
-			}
+	// Get parent commits
+	var parents []string
+	var parentFiles []FileEntry
+	if parentCommit := r.headCommit(metadata); parentCommit != nil {
+		parents = append(parents, parentCommit.ID)
+		if parentFiles, err = r.commitFiles(parentCommit); err != nil {
+			return nil, fmt.Errorf("failed to read parent commit %s: %w", parentCommit.ID, err)
 		}
 	}
+	mergeHead, mergeMessage, err := r.readMergeState()
+	if err != nil {
+		return nil, err
+	}
+	if mergeHead != "" {
+		parents = append(parents, mergeHead)
+		if message == "" {
+			message = mergeMessage
+		}
+	}
+	if message == "" {
+		return nil, errors.New("commit message is required")
+	}
 
-	// Create commit content for hashing
-	commitContent := fmt.Sprintf("%s%s%s", timestamp, message, parentCommitID)
-	for _, file := range stagingArea {
-		commitContent += file["file_path"] + file["file_hash"]
+	// Check that the index differs from the parent snapshot. A merge commit
+	// is recorded even when the merge left the tree unchanged.
+	if len(diffSnapshots(parentFiles, idx.Files())) == 0 && mergeHead == "" {
+		return nil, ErrNothingToCommit
 	}
 
-	// Generate commit ID
-	sha := sha1.New()
-	sha.Write([]byte(commitContent))
-	commitID := fmt.Sprintf("%x", sha.Sum(nil))
+	// Write the full snapshot as tree objects
+	treeHash, err := r.WriteTree(idx.Files())
+	if err != nil {
+		return nil, fmt.Errorf("failed to write tree: %w", err)
+	}
+
+	commit, err := r.writeCommit(metadata, message, treeHash, parents, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Persist the index so it keeps mirroring the new commit
+	if err := idx.Write(); err != nil {
+		return nil, err
+	}
 
-	// Create commit object
-	commit := map[string]any{
-		"commit_id":        commitID,
-		"commit_message":   message,
-		"commit_timestamp": timestamp,
-		"files":            stagingArea,
-		"parent_commit_id": parentCommitID,
+	// Move the current branch (or a detached HEAD) to the new commit
+	action := "commit"
+	switch {
+	case mergeHead != "":
+		action = "commit (merge)"
+	case len(parents) == 0:
+		action = "commit (initial)"
 	}
+	if err := r.updateHead(commit.ID, action+": "+firstLine(message)); err != nil {
+		return nil, err
+	}
+	if err := r.clearMergeState(); err != nil {
+		return nil, err
+	}
+
+	return commit, nil
+}
 
-	// Add to commit history
-	var commitHistory []any
-	if ch, ok := metadata["commit_history"]; ok {
-		if arr, ok := ch.([]any); ok {
-			commitHistory = arr
+// writeCommit records a new commit in the history and returns it. HEAD is
+// left for the caller to update.
+func (r *Repository) writeCommit(metadata *metadata, message, treeHash string, parents []string, opts CommitOptions) (*CommitRecord, error) {
+	committer, err := r.identity(CommitterNameEnv, CommitterEmailEnv)
+	if err != nil {
+		return nil, err
+	}
+	author := opts.Author
+	if author.IsZero() {
+		if author, err = r.identity(AuthorNameEnv, AuthorEmailEnv); err != nil {
+			return nil, err
 		}
+	} else if err := author.validate(); err != nil {
+		return nil, err
 	}
-	commitHistory = append(commitHistory, commit)
-	metadata["commit_history"] = commitHistory
 
-	// Clear staging area
-	metadata["staging_area"] = []any{}
+	// Dates keep the time zone they were made in, to the second
+	now := time.Now().Truncate(time.Second)
+	if committer.When, err = commitDate(CommitterDateEnv, now); err != nil {
+		return nil, err
+	}
+	switch {
+	case !opts.Date.IsZero():
+		author.When = opts.Date
+	case author.When.IsZero():
+		if author.When, err = commitDate(AuthorDateEnv, now); err != nil {
+			return nil, err
+		}
+	}
 
-	// Write back to metadata.json
-	file, err := os.Create(metadataPath)
+	// Store the commit as a Git commit object, whose ID is the commit ID
+	commitID, err := r.WriteObject(CommitObject, encodeCommit(treeHash, parents, author, committer, objectMessage(message)))
 	if err != nil {
-		return fmt.Errorf("failed to update metadata.json: %w", err)
+		return nil, fmt.Errorf("failed to write commit: %w", err)
 	}
-	defer file.Close()
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(metadata); err != nil {
-		return fmt.Errorf("failed to write metadata.json: %w", err)
+	commit := CommitRecord{
+		ID:        commitID,
+		Tree:      treeHash,
+		Parents:   parents,
+		Message:   message,
+		Author:    author,
+		Committer: committer,
+		Time:      committer.When,
 	}
 
-	// Print success message
-	fmt.Printf("Committed %d files\n", len(stagingArea))
-	fmt.Printf("Commit ID: %s\n", commitID)
-	fmt.Printf("Message: %s\n", message)
+	// Add to commit history; the staging area now lives in the index
+	metadata.CommitHistory = append(metadata.CommitHistory, commit)
+	metadata.StagingArea = nil
 
-	return nil
+	if err := r.writeMetadata(metadata); err != nil {
+		return nil, err
+	}
+	return &commit, nil
 }
 
-// Log shows the commit history
-func Log() error {
-	// Check if .mygit exists
-	if _, err := os.Stat(MyGitDir); os.IsNotExist(err) {
-		return errors.New("not a mygit repository (run 'mygit init' first)")
+// CommitChanges returns the files a commit changed compared with its first
+// parent. Every file of a root commit is added.
+func (r *Repository) CommitChanges(commit *CommitRecord) ([]FileChange, error) {
+	files, err := r.commitFiles(commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", commit.ID, err)
 	}
+	var parentFiles []FileEntry
+	if len(commit.Parents) > 0 {
+		metadata, err := r.readMetadata()
+		if err != nil {
+			return nil, err
+		}
+		parent := metadata.find(commit.Parents[0])
+		if parent == nil {
+			return nil, fmt.Errorf("unknown commit: %s", commit.Parents[0])
+		}
+		if parentFiles, err = r.commitFiles(parent); err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", parent.ID, err)
+		}
+	}
+	return diffSnapshots(parentFiles, files), nil
+}
 
-	// Read metadata.json
-	metadataPath := filepath.Join(MyGitDir, MetadataFile)
-	metadata := map[string]any{}
-	if file, err := os.Open(metadataPath); err == nil {
-		defer file.Close()
-		json.NewDecoder(file).Decode(&metadata)
+// commitFiles returns the snapshot recorded by a commit.
+// Commits made before tree objects existed only list the files staged for
+// them, so that list is used as the best available snapshot.
+func (r *Repository) commitFiles(commit *CommitRecord) ([]FileEntry, error) {
+	if commit.Tree != "" {
+		return r.FlattenTree(commit.Tree)
+	}
+	return commit.files, nil
+}
+
+// headCommit returns the commit checked out in the working tree, or nil if
+// the current branch has no commits yet
+func (r *Repository) headCommit(metadata *metadata) *CommitRecord {
+	_, commitID, err := r.readHead()
+	if err != nil {
+		return nil
 	}
+	return metadata.find(commitID)
+}
 
-	// Load commit history
-	var commitHistory []any
-	if ch, ok := metadata["commit_history"]; ok {
-		if arr, ok := ch.([]any); ok {
-			commitHistory = arr
+// headFiles returns the snapshot of the HEAD commit
+func (r *Repository) headFiles() ([]FileEntry, error) {
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	commit := r.headCommit(metadata)
+	if commit == nil {
+		return nil, nil
+	}
+	files, err := r.commitFiles(commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+	return files, nil
+}
+
+// isAncestor reports whether ancestorID is reachable from commitID by
+// following parents. A commit is its own ancestor.
+func (m *metadata) isAncestor(ancestorID, commitID string) bool {
+	seen := make(map[string]bool)
+	queue := []string{commitID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == ancestorID {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if commit := m.find(id); commit != nil {
+			queue = append(queue, commit.Parents...)
 		}
 	}
+	return false
+}
 
-	// Check if there are any commits
-	if len(commitHistory) == 0 {
-		fmt.Println("No commits yet")
-		return nil
+// mergeBase returns the best common ancestor of two commits: one that is not
+// an ancestor of any other common ancestor. When there are several, the most
+// recently created one is used. An empty string means the histories are
+// unrelated.
+func (m *metadata) mergeBase(a, b string) string {
+	// Collect everything reachable from a
+	reachable := make(map[string]bool)
+	queue := []string{a}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if reachable[id] {
+			continue
+		}
+		reachable[id] = true
+		if commit := m.find(id); commit != nil {
+			queue = append(queue, commit.Parents...)
+		}
 	}
 
-	// Display commits in reverse chronological order (newest first)
-	for i := len(commitHistory) - 1; i >= 0; i-- {
-		commit := commitHistory[i]
-		if commitMap, ok := commit.(map[string]any); ok {
-			// Extract commit information
-			commitID, _ := commitMap["commit_id"].(string)
-			commitMessage, _ := commitMap["commit_message"].(string)
-			commitTimestamp, _ := commitMap["commit_timestamp"].(string)
+	// Walk back from b, stopping at the first common commits on each path
+	var candidates []string
+	seen := make(map[string]bool)
+	queue = []string{b}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if reachable[id] {
+			candidates = append(candidates, id)
+			continue
+		}
+		if commit := m.find(id); commit != nil {
+			queue = append(queue, commit.Parents...)
+		}
+	}
 
-			// Display commit
-			fmt.Printf("commit %s\n", commitID)
-			fmt.Printf("Date: %s\n", commitTimestamp)
-			fmt.Println()
-			fmt.Printf("    %s\n", commitMessage)
-			fmt.Println()
+	// Drop candidates that are ancestors of other candidates
+	var best []string
+	for _, candidate := range candidates {
+		redundant := false
+		for _, other := range candidates {
+			if other != candidate && m.isAncestor(candidate, other) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			best = append(best, candidate)
 		}
 	}
+	if len(best) == 0 {
+		return ""
+	}
 
-	return nil
-} 
\ No newline at end of file
+	// Prefer the most recent commit in the history
+	order := make(map[string]int)
+	for i, commit := range m.CommitHistory {
+		order[commit.ID] = i
+	}
+	base := best[0]
+	for _, id := range best[1:] {
+		if order[id] > order[base] {
+			base = id
+		}
+	}
+	return base
+}
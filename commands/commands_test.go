@@ -149,19 +149,16 @@ func TestAdd(t *testing.T) {
 				t.Errorf("unexpected error: %v", err)
 			}
 
-			// Check if files were added to staging area
+			// Check if existing files were added to the index
 			if !tt.expectedError {
-				metadataPath := filepath.Join(commands.MyGitDir, commands.MetadataFile)
-				file, _ := os.Open(metadataPath)
-				defer file.Close()
-				var metadata map[string]any
-				json.NewDecoder(file).Decode(&metadata)
-
-				if stagingArea, ok := metadata["staging_area"]; ok {
-					if arr, ok := stagingArea.([]any); ok {
-						if len(arr) == 0 {
-							t.Errorf("staging area is empty, expected files to be added")
-						}
+				idx, err := commands.ReadIndex()
+				if err != nil {
+					t.Fatalf("failed to read index: %v", err)
+				}
+				for _, f := range tt.files {
+					_, statErr := os.Stat(f)
+					if _, ok := idx.Entry(f); ok != (statErr == nil) {
+						t.Errorf("index entry for %s: got %v, expected %v", f, ok, statErr == nil)
 					}
 				}
 			}
@@ -243,13 +240,16 @@ func TestCommit(t *testing.T) {
 					}
 				}
 
-				// Check if staging area was cleared
-				if stagingArea, ok := metadata["staging_area"]; ok {
-					if arr, ok := stagingArea.([]any); ok {
-						if len(arr) != 0 {
-							t.Errorf("staging area was not cleared after commit")
-						}
-					}
+				// Check that the index still mirrors the committed snapshot
+				idx, err := commands.ReadIndex()
+				if err != nil {
+					t.Fatalf("failed to read index: %v", err)
+				}
+				if len(idx.Entries) != 1 {
+					t.Errorf("expected index to keep 1 entry after commit, got %d", len(idx.Entries))
+				}
+				if err := commands.Commit("nothing changed"); err == nil {
+					t.Errorf("expected error when committing an unchanged index")
 				}
 			}
 		})
@@ -375,24 +375,16 @@ func TestFileHashing(t *testing.T) {
 	// Add the file
 	commands.Add([]string{"test.txt"})
 
-	// Read metadata to check hash
-	metadataPath := filepath.Join(commands.MyGitDir, commands.MetadataFile)
-	file, _ := os.Open(metadataPath)
-	defer file.Close()
-	var metadata map[string]any
-	json.NewDecoder(file).Decode(&metadata)
-
-	if stagingArea, ok := metadata["staging_area"]; ok {
-		if arr, ok := stagingArea.([]any); ok {
-			if len(arr) > 0 {
-				if fileEntry, ok := arr[0].(map[string]any); ok {
-					if hash, ok := fileEntry["file_hash"].(string); ok {
-						if len(hash) != 40 { // SHA-1 is 40 characters
-							t.Errorf("hash length is %d, expected 40", len(hash))
-						}
-					}
-				}
-			}
-		}
+	// Read the index to check hash
+	idx, err := commands.ReadIndex()
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
 	}
-}
\ No newline at end of file
+	entry, ok := idx.Entry("test.txt")
+	if !ok {
+		t.Fatalf("test.txt was not staged")
+	}
+	if len(entry.Hash) != 40 { // SHA-1 is 40 characters
+		t.Errorf("hash length is %d, expected 40", len(entry.Hash))
+	}
+}
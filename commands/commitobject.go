@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// encodeCommit serializes a commit in the format of Git commit objects, so
+// that its object ID is the commit ID Git computes:
+//
+//	tree <tree ID>
+//	parent <commit ID>
+//	author <name> <<email>> <Unix time> <UTC offset>
+//	committer <name> <<email>> <Unix time> <UTC offset>
+//
+//	<message>
+//
+// There is a parent line per parent. The message is written as it is; see
+// objectMessage.
+func encodeCommit(tree string, parents []string, author, committer Signature, message string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "tree %s\n", tree)
+	for _, parent := range parents {
+		fmt.Fprintf(&buf, "parent %s\n", parent)
+	}
+	fmt.Fprintf(&buf, "author %s %s\n", author, rawDate(author.When))
+	fmt.Fprintf(&buf, "committer %s %s\n", committer, rawDate(committer.When))
+	buf.WriteString("\n")
+	buf.WriteString(message)
+	return buf.Bytes()
+}
+
+// objectMessage returns a commit message as Git stores it, ending with a
+// newline
+func objectMessage(message string) string {
+	if strings.HasSuffix(message, "\n") {
+		return message
+	}
+	return message + "\n"
+}
+
+// gitCommit is a decoded Git commit object
+type gitCommit struct {
+	Tree      string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+	// Message is the message exactly as stored, usually ending with a newline
+	Message string
+}
+
+// decodeCommit parses a Git commit object. Headers other than tree, parent,
+// author and committer, such as signatures and encodings, are skipped.
+func decodeCommit(data []byte) (*gitCommit, error) {
+	header, message, _ := strings.Cut(string(data), "\n\n")
+	commit := &gitCommit{Message: message}
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		var err error
+		switch key {
+		case "tree":
+			commit.Tree = value
+		case "parent":
+			commit.Parents = append(commit.Parents, value)
+		case "author":
+			commit.Author, err = parseSignatureLine(value)
+		case "committer":
+			commit.Committer, err = parseSignatureLine(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed commit object: %w", err)
+		}
+	}
+	if !isObjectID(commit.Tree) {
+		return nil, fmt.Errorf("malformed commit object: missing tree")
+	}
+	for _, parent := range commit.Parents {
+		if !isObjectID(parent) {
+			return nil, fmt.Errorf("malformed commit object: invalid parent %q", parent)
+		}
+	}
+	return commit, nil
+}
+
+// parseSignatureLine parses the identity and date of an author, committer
+// or tagger line: "Name <email> <Unix time> <UTC offset>"
+func parseSignatureLine(text string) (Signature, error) {
+	end := strings.LastIndex(text, ">")
+	start := strings.LastIndex(text[:max(end, 0)], "<")
+	if start < 0 || end < 0 {
+		return Signature{}, fmt.Errorf("invalid identity '%s'", text)
+	}
+	sig := Signature{
+		Name:  strings.TrimSpace(text[:start]),
+		Email: text[start+1 : end],
+	}
+	if date := strings.TrimSpace(text[end+1:]); date != "" {
+		when, err := ParseDate(date)
+		if err != nil {
+			return Signature{}, err
+		}
+		sig.When = when
+	}
+	return sig, nil
+}
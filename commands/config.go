@@ -0,0 +1,550 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ConfigFile is the repository config file inside .mygit
+	ConfigFile = "config"
+	// UserConfigFile is the user config file in the home directory, shared by
+	// every repository of the user
+	UserConfigFile = ".mygitconfig"
+	// ConfigGlobalEnv overrides the path of the user config file
+	ConfigGlobalEnv = "MYGIT_CONFIG_GLOBAL"
+)
+
+// Config scopes, naming the file a variable comes from
+const (
+	ScopeGlobal = "global"
+	ScopeLocal  = "local"
+)
+
+// ConfigEntry is a variable set in a config file
+type ConfigEntry struct {
+	// Key is the name of the variable, such as "user.name"
+	Key string
+	// Value is the value of the variable
+	Value string
+	// Scope is the config file setting it, ScopeGlobal or ScopeLocal
+	Scope string
+}
+
+// Config holds the variables of the config files, lowest precedence first:
+// the user config file, then the repository config file
+type Config struct {
+	Entries []ConfigEntry
+}
+
+// Get returns the value of a variable and whether it is set. When several
+// files set it the last value wins.
+func (c *Config) Get(key string) (string, bool) {
+	section, name, _, err := splitConfigKey(key)
+	if err != nil {
+		return "", false
+	}
+	key = section + "." + name
+	for i := len(c.Entries) - 1; i >= 0; i-- {
+		if c.Entries[i].Key == key {
+			return c.Entries[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// ConfigOptions selects the config file used by the config command
+type ConfigOptions struct {
+	// Global uses the user config file
+	Global bool
+	// Local uses the repository config file
+	Local bool
+}
+
+// Config reads the user and repository config files
+func (r *Repository) Config() (*Config, error) {
+	return readConfig(r, ConfigOptions{})
+}
+
+// readConfig reads the config files selected by opts; without Global or
+// Local both the user file and, when r is not nil, the repository file
+func readConfig(r *Repository, opts ConfigOptions) (*Config, error) {
+	config := &Config{}
+	if !opts.Local {
+		userPath, err := userConfigPath()
+		if err != nil && opts.Global {
+			return nil, err
+		}
+		if err == nil {
+			file, err := readConfigFile(userPath)
+			if err != nil {
+				return nil, err
+			}
+			config.Entries = append(config.Entries, file.entries(ScopeGlobal)...)
+		}
+	}
+	if !opts.Global && r != nil {
+		file, err := readConfigFile(r.gitPath(ConfigFile))
+		if err != nil {
+			return nil, err
+		}
+		config.Entries = append(config.Entries, file.entries(ScopeLocal)...)
+	}
+	return config, nil
+}
+
+// userConfigPath returns the path of the user config file
+func userConfigPath() (string, error) {
+	if p := os.Getenv(ConfigGlobalEnv); p != "" {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find the user config file: %w", err)
+	}
+	return filepath.Join(home, UserConfigFile), nil
+}
+
+// configTarget returns the repository the config command runs in and the
+// path of the file it writes. Outside of a repository only the user config
+// file can be used, and the repository is nil.
+func configTarget(opts ConfigOptions) (*Repository, string, error) {
+	if opts.Global && opts.Local {
+		return nil, "", errors.New("--global and --local cannot be used together")
+	}
+	r, err := openRepository()
+	if errors.Is(err, ErrNotRepository) && !opts.Local {
+		r, err = nil, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if opts.Global || r == nil {
+		userPath, err := userConfigPath()
+		return r, userPath, err
+	}
+	return r, r.gitPath(ConfigFile), nil
+}
+
+// GetConfig prints the value of a variable. Unless opts selects a file, the
+// repository config file overrides the user config file.
+func GetConfig(key string, opts ConfigOptions) error {
+	r, _, err := configTarget(opts)
+	if err != nil {
+		return err
+	}
+	if _, _, _, err := splitConfigKey(key); err != nil {
+		return err
+	}
+	config, err := readConfig(r, opts)
+	if err != nil {
+		return err
+	}
+	value, ok := config.Get(key)
+	if !ok {
+		return &ConfigNotFoundError{Key: key}
+	}
+	fmt.Println(value)
+	return nil
+}
+
+// ConfigNotFoundError is returned by GetConfig when a variable is not set
+type ConfigNotFoundError struct {
+	Key string
+}
+
+func (e *ConfigNotFoundError) Error() string {
+	return fmt.Sprintf("config variable '%s' is not set", e.Key)
+}
+
+// ListConfig prints every variable as key=value, lowest precedence first
+func ListConfig(opts ConfigOptions) error {
+	r, _, err := configTarget(opts)
+	if err != nil {
+		return err
+	}
+	config, err := readConfig(r, opts)
+	if err != nil {
+		return err
+	}
+	for _, entry := range config.Entries {
+		fmt.Printf("%s=%s\n", entry.Key, entry.Value)
+	}
+	return nil
+}
+
+// SetConfig sets a variable in the repository config file, or in the user
+// config file with opts.Global
+func SetConfig(key, value string, opts ConfigOptions) error {
+	r, configPath, err := configTarget(opts)
+	if err != nil {
+		return err
+	}
+	if r == nil && !opts.Global {
+		return ErrNotRepository
+	}
+	return updateConfigFile(configPath, func(file *configFile) error {
+		return file.set(key, value)
+	})
+}
+
+// UnsetConfig removes a variable from the repository config file, or from
+// the user config file with opts.Global
+func UnsetConfig(key string, opts ConfigOptions) error {
+	r, configPath, err := configTarget(opts)
+	if err != nil {
+		return err
+	}
+	if r == nil && !opts.Global {
+		return ErrNotRepository
+	}
+	return updateConfigFile(configPath, func(file *configFile) error {
+		removed, err := file.unset(key)
+		if err == nil && !removed {
+			err = &ConfigNotFoundError{Key: key}
+		}
+		return err
+	})
+}
+
+// updateConfigFile applies a change to a config file while holding its lock
+func updateConfigFile(configPath string, change func(*configFile) error) error {
+	timeout, err := lockTimeoutFromEnv()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockPaths(timeout, configPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := readConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	if err := change(file); err != nil {
+		return err
+	}
+	return file.write()
+}
+
+// configFile is a config file in INI format:
+//
+//	# comment
+//	[user]
+//		name = Alice
+//		email = alice@example.com
+//	[branch "main"]
+//		description = "Main line; always releasable"
+//
+// Section and variable names are case-insensitive, subsection names are
+// not. The file is kept as lines so that changes preserve its comments and
+// layout.
+type configFile struct {
+	path  string
+	lines []configLine
+}
+
+// configLine is a line of a config file
+type configLine struct {
+	text string
+	// section is the canonical name of the section the line is in, such as
+	// "user" or "branch.main"
+	section string
+	// name is the lowercase name of the variable set on the line; it is
+	// empty for section headers, comments and blank lines
+	name   string
+	value  string
+	header bool
+}
+
+// readConfigFile loads a config file; a missing file is empty
+func readConfigFile(configPath string) (*configFile, error) {
+	file := &configFile{path: configPath}
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", configPath, err)
+	}
+	if len(data) == 0 {
+		return file, nil
+	}
+
+	section := ""
+	for i, text := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		text = strings.TrimSuffix(text, "\r")
+		line := configLine{text: text, section: section}
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';':
+		case trimmed[0] == '[':
+			if section, err = parseConfigHeader(trimmed); err != nil {
+				return nil, fmt.Errorf("bad config line %d in %s: %w", i+1, configPath, err)
+			}
+			line.section = section
+			line.header = true
+		default:
+			if section == "" {
+				return nil, fmt.Errorf("bad config line %d in %s: variable outside of a section", i+1, configPath)
+			}
+			if line.name, line.value, err = parseConfigVariable(trimmed); err != nil {
+				return nil, fmt.Errorf("bad config line %d in %s: %w", i+1, configPath, err)
+			}
+		}
+		file.lines = append(file.lines, line)
+	}
+	return file, nil
+}
+
+// parseConfigHeader parses a section header, [section] or
+// [section "subsection"], and returns the canonical section name
+func parseConfigHeader(text string) (string, error) {
+	end := strings.LastIndex(text, "]")
+	if end < 0 {
+		return "", errors.New("unterminated section header")
+	}
+	if rest := strings.TrimSpace(text[end+1:]); rest != "" && rest[0] != '#' && rest[0] != ';' {
+		return "", errors.New("unexpected text after section header")
+	}
+	inner := text[1:end]
+
+	name, sub, quoted := strings.Cut(inner, " ")
+	if !validConfigName(name, true) {
+		return "", fmt.Errorf("invalid section name '%s'", name)
+	}
+	name = strings.ToLower(name)
+	if !quoted {
+		// The older [section.subsection] form has a case-insensitive subsection
+		return name, nil
+	}
+
+	sub = strings.TrimSpace(sub)
+	if len(sub) < 2 || sub[0] != '"' || sub[len(sub)-1] != '"' {
+		return "", errors.New("subsection names must be quoted")
+	}
+	var b strings.Builder
+	for i := 1; i < len(sub)-1; i++ {
+		c := sub[i]
+		if c == '\\' && i+1 < len(sub)-1 {
+			i++
+			c = sub[i]
+		} else if c == '"' {
+			return "", errors.New("unescaped quote in subsection name")
+		}
+		b.WriteByte(c)
+	}
+	return name + "." + b.String(), nil
+}
+
+// parseConfigVariable parses a "name = value" line. A name without a value
+// is a boolean set to true. Values may be quoted to keep leading or
+// trailing spaces and comment characters, and support the escapes \", \\,
+// \n and \t.
+func parseConfigVariable(text string) (string, string, error) {
+	name, raw, hasValue := strings.Cut(text, "=")
+	name = strings.TrimSpace(name)
+	if !validConfigName(name, false) {
+		return "", "", fmt.Errorf("invalid variable name '%s'", name)
+	}
+	name = strings.ToLower(name)
+	if !hasValue {
+		return name, "true", nil
+	}
+
+	var b strings.Builder
+	inQuotes := false
+	// pending holds unquoted whitespace, which is dropped at the end of the value
+	pending := ""
+	raw = strings.TrimLeft(raw, " \t")
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			b.WriteString(pending)
+			pending = ""
+			continue
+		case !inQuotes && (c == '#' || c == ';'):
+			i = len(raw)
+			continue
+		case !inQuotes && (c == ' ' || c == '\t'):
+			pending += string(c)
+			continue
+		case c == '\\':
+			if i+1 >= len(raw) {
+				return "", "", errors.New("line continuations are not supported")
+			}
+			i++
+			switch raw[i] {
+			case '"', '\\':
+				c = raw[i]
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			default:
+				return "", "", fmt.Errorf("invalid escape '\\%c'", raw[i])
+			}
+		}
+		b.WriteString(pending)
+		pending = ""
+		b.WriteByte(c)
+	}
+	if inQuotes {
+		return "", "", errors.New("unterminated quote")
+	}
+	return name, b.String(), nil
+}
+
+// validConfigName reports whether name is a valid variable name: letters,
+// digits and '-', starting with a letter. Section names may also contain
+// '.' for the older [section.subsection] form.
+func validConfigName(name string, section bool) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '-'):
+		case section && i > 0 && c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// splitConfigKey splits a key such as "user.name" or "branch.main.remote"
+// into its canonical section, its lowercase variable name and the variable
+// name as written
+func splitConfigKey(key string) (string, string, string, error) {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first <= 0 || last == len(key)-1 {
+		return "", "", "", fmt.Errorf("key does not contain a section: %s", key)
+	}
+	section, name := key[:first], key[last+1:]
+	if !validConfigName(section, false) || !validConfigName(name, false) {
+		return "", "", "", fmt.Errorf("invalid key: %s", key)
+	}
+	canonical := strings.ToLower(section)
+	if first != last {
+		canonical += key[first:last]
+	}
+	return canonical, strings.ToLower(name), name, nil
+}
+
+// entries returns the variables set in the file
+func (f *configFile) entries(scope string) []ConfigEntry {
+	var entries []ConfigEntry
+	for _, line := range f.lines {
+		if line.name != "" {
+			entries = append(entries, ConfigEntry{Key: line.section + "." + line.name, Value: line.value, Scope: scope})
+		}
+	}
+	return entries
+}
+
+// set sets a variable, replacing its last value in the file or adding it to
+// the end of its section, which is created when missing
+func (f *configFile) set(key, value string) error {
+	section, name, written, err := splitConfigKey(key)
+	if err != nil {
+		return err
+	}
+	line := configLine{
+		text:    "\t" + written + " = " + quoteConfigValue(value),
+		section: section,
+		name:    name,
+		value:   value,
+	}
+
+	last := -1
+	for i, l := range f.lines {
+		if l.section != section {
+			continue
+		}
+		if l.name == name {
+			last = i
+		}
+	}
+	if last >= 0 {
+		f.lines[last] = line
+		return nil
+	}
+
+	// Add the variable after the last header or variable of its section
+	end := -1
+	for i, l := range f.lines {
+		if l.section == section && (l.header || l.name != "") {
+			end = i
+		}
+	}
+	if end < 0 {
+		header := configLine{text: configHeader(section), section: section, header: true}
+		f.lines = append(f.lines, header, line)
+		return nil
+	}
+	f.lines = append(f.lines[:end+1], append([]configLine{line}, f.lines[end+1:]...)...)
+	return nil
+}
+
+// unset removes every value of a variable and reports whether there were any
+func (f *configFile) unset(key string) (bool, error) {
+	section, name, _, err := splitConfigKey(key)
+	if err != nil {
+		return false, err
+	}
+	removed := false
+	lines := f.lines[:0]
+	for _, line := range f.lines {
+		if line.section == section && line.name == name {
+			removed = true
+			continue
+		}
+		lines = append(lines, line)
+	}
+	f.lines = lines
+	return removed, nil
+}
+
+// write replaces the file atomically
+func (f *configFile) write() error {
+	var b strings.Builder
+	for _, line := range f.lines {
+		b.WriteString(line.text)
+		b.WriteByte('\n')
+	}
+	if err := writeFileAtomic(f.path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// configHeader returns the header line of a section
+func configHeader(section string) string {
+	name, sub, hasSub := strings.Cut(section, ".")
+	if !hasSub {
+		return "[" + name + "]"
+	}
+	sub = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(sub)
+	return fmt.Sprintf("[%s \"%s\"]", name, sub)
+}
+
+// quoteConfigValue encodes a value for a config file, quoting it when it
+// has leading or trailing spaces or comment characters
+func quoteConfigValue(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(value)
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;") {
+		return `"` + escaped + `"`
+	}
+	return escaped
+}
@@ -0,0 +1,224 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// setupConfigRepo creates a repository whose user config file lives in the
+// temporary directory instead of the home directory
+func setupConfigRepo(t *testing.T) string {
+	t.Helper()
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+	userConfig := filepath.Join(tempDir, "user.mygitconfig")
+	t.Setenv(commands.ConfigGlobalEnv, userConfig)
+	for _, env := range []string{commands.AuthorNameEnv, commands.AuthorEmailEnv, commands.CommitterNameEnv, commands.CommitterEmailEnv} {
+		t.Setenv(env, "")
+	}
+	return userConfig
+}
+
+// readConfig returns the merged config of the repository in the current directory
+func readConfig(t *testing.T) *commands.Config {
+	t.Helper()
+	repo, err := commands.Open(".")
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	config, err := repo.Config()
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	return config
+}
+
+// TestConfigFile tests parsing config files
+func TestConfigFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		key           string
+		expectedValue string
+		expectedError bool
+	}{
+		{name: "simple", content: "[user]\n\tname = Alice\n", key: "user.name", expectedValue: "Alice"},
+		{name: "case-insensitive names", content: "[User]\nName=Alice\n", key: "user.NAME", expectedValue: "Alice"},
+		{name: "comments", content: "# header\n[user]\n; note\nname = Alice Smith # trailing\n", key: "user.name", expectedValue: "Alice Smith"},
+		{name: "quoted value", content: "[core]\nprompt = \"  a # b \"\n", key: "core.prompt", expectedValue: "  a # b "},
+		{name: "escapes", content: "[core]\nmessage = \"say \\\"hi\\\"\\n\"\n", key: "core.message", expectedValue: "say \"hi\"\n"},
+		{name: "boolean without value", content: "[core]\n\tbare\n", key: "core.bare", expectedValue: "true"},
+		{name: "subsection", content: "[branch \"Main\"]\nremote = origin\n", key: "branch.Main.remote", expectedValue: "origin"},
+		{name: "last value wins", content: "[user]\nname = A\nname = B\n", key: "user.name", expectedValue: "B"},
+		{name: "variable outside a section", content: "name = Alice\n", key: "user.name", expectedError: true},
+		{name: "unterminated header", content: "[user\n", key: "user.name", expectedError: true},
+		{name: "unterminated quote", content: "[user]\nname = \"Alice\n", key: "user.name", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupConfigRepo(t)
+			os.WriteFile(filepath.Join(commands.MyGitDir, commands.ConfigFile), []byte(tt.content), 0644)
+
+			repo, err := commands.Open(".")
+			if err != nil {
+				t.Fatalf("failed to open repository: %v", err)
+			}
+			config, err := repo.Config()
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value, ok := config.Get(tt.key); !ok || value != tt.expectedValue {
+				t.Errorf("expected %s to be %q, got %q (set: %v)", tt.key, tt.expectedValue, value, ok)
+			}
+		})
+	}
+}
+
+// TestSetConfig tests changing config files
+func TestSetConfig(t *testing.T) {
+	userConfig := setupConfigRepo(t)
+	repoConfig := filepath.Join(commands.MyGitDir, commands.ConfigFile)
+	os.WriteFile(repoConfig, []byte("# keep me\n[user]\n\tname = Old\n[core]\n\tbare = false\n"), 0644)
+
+	if err := commands.SetConfig("user.name", "Alice", commands.ConfigOptions{}); err != nil {
+		t.Fatalf("failed to set user.name: %v", err)
+	}
+	if err := commands.SetConfig("user.email", "alice@example.com", commands.ConfigOptions{}); err != nil {
+		t.Fatalf("failed to set user.email: %v", err)
+	}
+	if err := commands.SetConfig("branch.main.description", " trailing # space ", commands.ConfigOptions{}); err != nil {
+		t.Fatalf("failed to set a subsection variable: %v", err)
+	}
+	if err := commands.UnsetConfig("core.bare", commands.ConfigOptions{}); err != nil {
+		t.Fatalf("failed to unset core.bare: %v", err)
+	}
+	if err := commands.UnsetConfig("core.bare", commands.ConfigOptions{}); err == nil {
+		t.Errorf("expected unsetting a missing variable to fail")
+	}
+	if err := commands.SetConfig("nosection", "x", commands.ConfigOptions{}); err == nil {
+		t.Errorf("expected a key without a section to be rejected")
+	}
+
+	data, _ := os.ReadFile(repoConfig)
+	expected := "# keep me\n[user]\n\tname = Alice\n\temail = alice@example.com\n[core]\n[branch \"main\"]\n\tdescription = \" trailing # space \"\n"
+	if string(data) != expected {
+		t.Errorf("unexpected config file:\n%s\nexpected:\n%s", data, expected)
+	}
+	config := readConfig(t)
+	if value, _ := config.Get("branch.main.description"); value != " trailing # space " {
+		t.Errorf("expected the quoted value to round-trip, got %q", value)
+	}
+
+	// The repository file overrides the user file
+	if err := commands.SetConfig("user.name", "Global", commands.ConfigOptions{Global: true}); err != nil {
+		t.Fatalf("failed to set the global user.name: %v", err)
+	}
+	if err := commands.SetConfig("user.signingkey", "ABC", commands.ConfigOptions{Global: true}); err != nil {
+		t.Fatalf("failed to set the global user.signingkey: %v", err)
+	}
+	if data, _ := os.ReadFile(userConfig); !strings.Contains(string(data), "name = Global") {
+		t.Errorf("expected the user config file to be written, got %s", data)
+	}
+	config = readConfig(t)
+	if value, _ := config.Get("user.name"); value != "Alice" {
+		t.Errorf("expected the repository user.name to win, got %s", value)
+	}
+	if value, _ := config.Get("user.signingkey"); value != "ABC" {
+		t.Errorf("expected the user file to be read, got %s", value)
+	}
+}
+
+// TestCommitIdentity tests recording the author and committer of commits
+func TestCommitIdentity(t *testing.T) {
+	tests := []struct {
+		name              string
+		env               map[string]string
+		author            string
+		expectedAuthor    string
+		expectedCommitter string
+		expectedError     bool
+	}{
+		{
+			name:              "from config",
+			expectedAuthor:    "Alice <alice@example.com>",
+			expectedCommitter: "Alice <alice@example.com>",
+		},
+		{
+			name:              "environment overrides",
+			env:               map[string]string{commands.AuthorNameEnv: "Bob", commands.CommitterEmailEnv: "ci@example.com"},
+			expectedAuthor:    "Bob <alice@example.com>",
+			expectedCommitter: "Alice <ci@example.com>",
+		},
+		{
+			name:              "author option",
+			author:            "Carol <carol@example.com>",
+			expectedAuthor:    "Carol <carol@example.com>",
+			expectedCommitter: "Alice <alice@example.com>",
+		},
+		{
+			name:          "invalid author",
+			author:        "Carol",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupConfigRepo(t)
+			commands.SetConfig("user.name", "Alice", commands.ConfigOptions{})
+			commands.SetConfig("user.email", "alice@example.com", commands.ConfigOptions{})
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			os.WriteFile("file.txt", []byte("content\n"), 0644)
+			commands.Add([]string{"file.txt"})
+
+			var opts commands.CommitOptions
+			if tt.author != "" {
+				author, err := commands.ParseSignature(tt.author)
+				if tt.expectedError {
+					if err == nil {
+						t.Errorf("expected error but got none")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				opts.Author = author
+			}
+
+			repo, err := commands.Open(".")
+			if err != nil {
+				t.Fatalf("failed to open repository: %v", err)
+			}
+			if _, err := repo.CommitWithOptions("Add file", opts); err != nil {
+				t.Fatalf("failed to commit: %v", err)
+			}
+
+			// Read the commit back from metadata.json
+			history, err := repo.Log()
+			if err != nil {
+				t.Fatalf("failed to read the history: %v", err)
+			}
+			if got := history[0].Author.String(); got != tt.expectedAuthor {
+				t.Errorf("expected author %s, got %s", tt.expectedAuthor, got)
+			}
+			if got := history[0].Committer.String(); got != tt.expectedCommitter {
+				t.Errorf("expected committer %s, got %s", tt.expectedCommitter, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// AuthorDateEnv overrides the author date of new commits
+	AuthorDateEnv = "MYGIT_AUTHOR_DATE"
+	// CommitterDateEnv overrides the committer date of new commits
+	CommitterDateEnv = "MYGIT_COMMITTER_DATE"
+)
+
+// Date formats of log
+const (
+	// DateDefault shows dates in the time zone they were recorded in, as
+	// "Mon Jan 2 15:04:05 2006 -0700"
+	DateDefault = "default"
+	// DateLocal shows dates in the local time zone, as "Mon Jan 2 15:04:05 2006"
+	DateLocal = "local"
+	// DateISO shows dates as "2006-01-02 15:04:05 -0700"
+	DateISO = "iso"
+	// DateRelative shows dates relative to now, as "3 hours ago"
+	DateRelative = "relative"
+	// DateRaw shows dates as stored: Unix time and UTC offset, as
+	// "1136239445 -0700"
+	DateRaw = "raw"
+)
+
+// dateLayouts are the layouts ParseDate accepts besides Unix time. Those
+// without an offset are in the local time zone.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"Mon Jan 2 15:04:05 2006 -0700",
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"2006-01-02",
+}
+
+// ParseDate parses a commit date. It accepts Unix time with an optional UTC
+// offset ("1136239445 -0700" or "@1136239445"), ISO 8601 dates
+// ("2006-01-02T15:04:05-07:00" or "2006-01-02 15:04:05") and RFC 2822 dates
+// ("Mon, 02 Jan 2006 15:04:05 -0700"). Dates without an offset are in the
+// local time zone.
+func ParseDate(text string) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	fields := strings.Fields(strings.TrimPrefix(text, "@"))
+	if len(fields) == 1 || len(fields) == 2 {
+		if seconds, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			t := time.Unix(seconds, 0)
+			if len(fields) == 1 {
+				return t, nil
+			}
+			offset, err := parseOffset(fields[1])
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid date '%s': %w", text, err)
+			}
+			return t.In(offset), nil
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t.Truncate(time.Second), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date '%s': use a Unix time, an ISO 8601 or an RFC 2822 date", text)
+}
+
+// approxUnits are the units of relative dates such as "2 weeks ago"
+var approxUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// parseApproxDate parses the dates given to log --since and --until: any
+// date ParseDate accepts, "now", "yesterday", or a time before now such as
+// "2 weeks ago" or "3.days.ago"
+func parseApproxDate(text string, now time.Time) (time.Time, error) {
+	switch strings.TrimSpace(text) {
+	case "now":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+	fields := strings.Fields(strings.ReplaceAll(text, ".", " "))
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		unit := strings.TrimSuffix(fields[1], "s")
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid date '%s'", text)
+		}
+		switch unit {
+		case "month":
+			return now.AddDate(0, -n, 0), nil
+		case "year":
+			return now.AddDate(-n, 0, 0), nil
+		}
+		if d, ok := approxUnits[unit]; ok {
+			return now.Add(-time.Duration(n) * d), nil
+		}
+		return time.Time{}, fmt.Errorf("invalid date '%s': unknown unit '%s'", text, fields[1])
+	}
+	return ParseDate(text)
+}
+
+// parseOffset parses a UTC offset such as "+0100" into a time zone
+func parseOffset(text string) (*time.Location, error) {
+	if len(text) != 5 || (text[0] != '+' && text[0] != '-') {
+		return nil, fmt.Errorf("invalid UTC offset '%s'", text)
+	}
+	hours, err1 := strconv.Atoi(text[1:3])
+	minutes, err2 := strconv.Atoi(text[3:5])
+	if err1 != nil || err2 != nil || minutes >= 60 {
+		return nil, fmt.Errorf("invalid UTC offset '%s'", text)
+	}
+	seconds := (hours*60 + minutes) * 60
+	if text[0] == '-' {
+		seconds = -seconds
+	}
+	return time.FixedZone("", seconds), nil
+}
+
+// formatOffset returns the UTC offset of t, such as "+0100"
+func formatOffset(t time.Time) string {
+	return t.Format("-0700")
+}
+
+// rawDate formats t as Unix time and UTC offset
+func rawDate(t time.Time) string {
+	return fmt.Sprintf("%d %s", t.Unix(), formatOffset(t))
+}
+
+// commitDate returns the date of a new commit: the date set by the given
+// environment variable, or now
+func commitDate(env string, now time.Time) (time.Time, error) {
+	value := os.Getenv(env)
+	if value == "" {
+		return now, nil
+	}
+	t, err := ParseDate(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %w", env, err)
+	}
+	return t, nil
+}
+
+// CheckDateFormat returns an error unless format is one of the date formats
+// of log
+func CheckDateFormat(format string) error {
+	switch format {
+	case "", DateDefault, DateLocal, DateISO, DateRelative, DateRaw:
+		return nil
+	}
+	return usageErrorf("unknown date format '%s': use default, local, iso, relative or raw", format)
+}
+
+// FormatDate formats a commit date in one of the date formats of log
+func FormatDate(t time.Time, format string) string {
+	switch format {
+	case DateLocal:
+		return t.Local().Format("Mon Jan 2 15:04:05 2006")
+	case DateISO:
+		return t.Format("2006-01-02 15:04:05 -0700")
+	case DateRelative:
+		return relativeDate(t, time.Now())
+	case DateRaw:
+		return rawDate(t)
+	default:
+		return t.Format("Mon Jan 2 15:04:05 2006 -0700")
+	}
+}
+
+// relativeDate describes how long before now t is, with the precision
+// getting coarser the further back it is
+func relativeDate(t, now time.Time) string {
+	seconds := int64(now.Sub(t) / time.Second)
+	if seconds < 0 {
+		return "in the future"
+	}
+	ago := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+
+	if seconds < 90 {
+		return ago(seconds, "second")
+	}
+	minutes := (seconds + 30) / 60
+	if minutes < 90 {
+		return ago(minutes, "minute")
+	}
+	hours := (minutes + 30) / 60
+	if hours < 36 {
+		return ago(hours, "hour")
+	}
+	days := (hours + 12) / 24
+	if days < 14 {
+		return ago(days, "day")
+	}
+	if days < 70 {
+		return ago((days+3)/7, "week")
+	}
+	if days < 365 {
+		return ago((days+15)/30, "month")
+	}
+	years := days / 365
+	months := (days%365 + 15) / 30
+	if months == 12 {
+		years, months = years+1, 0
+	}
+	if months == 0 || years >= 5 {
+		return ago(years, "year")
+	}
+	yearText := fmt.Sprintf("%d years", years)
+	if years == 1 {
+		yearText = "1 year"
+	}
+	return yearText + ", " + ago(months, "month")
+}
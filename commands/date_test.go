@@ -0,0 +1,179 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestParseDate tests the date formats accepted for commit dates
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		name           string
+		date           string
+		expectedUnix   int64
+		expectedOffset string
+		expectedError  bool
+	}{
+		{name: "raw", date: "1700000000 +0530", expectedUnix: 1700000000, expectedOffset: "+0530"},
+		{name: "raw with negative offset", date: "1700000000 -0800", expectedUnix: 1700000000, expectedOffset: "-0800"},
+		{name: "unix time", date: "@1700000000", expectedUnix: 1700000000},
+		{name: "ISO 8601 in UTC", date: "2023-11-14T22:13:20Z", expectedUnix: 1700000000, expectedOffset: "+0000"},
+		{name: "ISO 8601 with offset", date: "2023-11-15T00:13:20+02:00", expectedUnix: 1700000000, expectedOffset: "+0200"},
+		{name: "ISO-like with offset", date: "2023-11-14 14:13:20 -0800", expectedUnix: 1700000000, expectedOffset: "-0800"},
+		{name: "RFC 2822", date: "Tue, 14 Nov 2023 22:13:20 +0000", expectedUnix: 1700000000, expectedOffset: "+0000"},
+		{name: "default log format", date: "Tue Nov 14 22:13:20 2023 +0000", expectedUnix: 1700000000, expectedOffset: "+0000"},
+		{name: "invalid offset", date: "1700000000 +05", expectedError: true},
+		{name: "invalid date", date: "yesterday", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, err := commands.ParseDate(tt.date)
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if date.Unix() != tt.expectedUnix {
+				t.Errorf("expected Unix time %d, got %d", tt.expectedUnix, date.Unix())
+			}
+			if tt.expectedOffset != "" && date.Format("-0700") != tt.expectedOffset {
+				t.Errorf("expected offset %s, got %s", tt.expectedOffset, date.Format("-0700"))
+			}
+		})
+	}
+}
+
+// TestFormatDate tests the date formats of log
+func TestFormatDate(t *testing.T) {
+	date := time.Unix(1700000000, 0).In(time.FixedZone("", -8*3600))
+	tests := []struct {
+		name     string
+		date     time.Time
+		format   string
+		expected string
+	}{
+		{name: "default", date: date, format: commands.DateDefault, expected: "Tue Nov 14 14:13:20 2023 -0800"},
+		{name: "empty is default", date: date, format: "", expected: "Tue Nov 14 14:13:20 2023 -0800"},
+		{name: "iso", date: date, format: commands.DateISO, expected: "2023-11-14 14:13:20 -0800"},
+		{name: "raw", date: date, format: commands.DateRaw, expected: "1700000000 -0800"},
+		{name: "local", date: date, format: commands.DateLocal, expected: date.Local().Format("Mon Jan 2 15:04:05 2006")},
+		{name: "seconds ago", date: time.Now().Add(-10 * time.Second), format: commands.DateRelative, expected: "10 seconds ago"},
+		{name: "hours ago", date: time.Now().Add(-3 * time.Hour), format: commands.DateRelative, expected: "3 hours ago"},
+		{name: "days ago", date: time.Now().Add(-50 * time.Hour), format: commands.DateRelative, expected: "2 days ago"},
+		{name: "weeks ago", date: time.Now().Add(-21 * 24 * time.Hour), format: commands.DateRelative, expected: "3 weeks ago"},
+		{name: "years ago", date: time.Now().Add(-(365 + 60) * 24 * time.Hour), format: commands.DateRelative, expected: "1 year, 2 months ago"},
+		{name: "future", date: time.Now().Add(time.Hour), format: commands.DateRelative, expected: "in the future"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commands.FormatDate(tt.date, tt.format); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if err := commands.CheckDateFormat("short"); err == nil {
+		t.Errorf("expected an unknown date format to be rejected")
+	}
+}
+
+// TestCommitDates tests recording author and committer dates
+func TestCommitDates(t *testing.T) {
+	commit := func(opts commands.CommitOptions) commands.CommitRecord {
+		t.Helper()
+		setupConfigRepo(t)
+		commands.SetConfig("user.name", "Alice", commands.ConfigOptions{})
+		commands.SetConfig("user.email", "alice@example.com", commands.ConfigOptions{})
+		os.WriteFile("file.txt", []byte("content\n"), 0644)
+		commands.Add([]string{"file.txt"})
+		repo, err := commands.Open(".")
+		if err != nil {
+			t.Fatalf("failed to open repository: %v", err)
+		}
+		if _, err := repo.CommitWithOptions("Add file", opts); err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+		// Read the commit back from metadata.json
+		history, err := repo.Log()
+		if err != nil {
+			t.Fatalf("failed to read the history: %v", err)
+		}
+		return history[0]
+	}
+
+	t.Setenv(commands.AuthorDateEnv, "1700000000 +0530")
+	t.Setenv(commands.CommitterDateEnv, "2023-11-15T00:00:00-08:00")
+	first := commit(commands.CommitOptions{})
+	if got := first.Author.When.Format("2006-01-02 15:04:05 -0700"); got != "2023-11-15 03:43:20 +0530" {
+		t.Errorf("unexpected author date %s", got)
+	}
+	if got := first.Committer.When.Format("2006-01-02 15:04:05 -0700"); got != "2023-11-15 00:00:00 -0800" {
+		t.Errorf("unexpected committer date %s", got)
+	}
+	if !first.Time.Equal(first.Committer.When) {
+		t.Errorf("expected the commit time to be the committer date")
+	}
+
+	// Fixed identities and dates make commit IDs reproducible
+	if second := commit(commands.CommitOptions{}); second.ID != first.ID {
+		t.Errorf("expected the same commit ID, got %s and %s", first.ID, second.ID)
+	}
+
+	// The date option overrides the author date only
+	date, _ := commands.ParseDate("2020-01-01T12:00:00+01:00")
+	third := commit(commands.CommitOptions{Date: date})
+	if !third.Author.When.Equal(date) || third.Author.When.Format("-0700") != "+0100" {
+		t.Errorf("expected author date %v, got %v", date, third.Author.When)
+	}
+	if !third.Committer.When.Equal(first.Committer.When) {
+		t.Errorf("expected the committer date to come from %s", commands.CommitterDateEnv)
+	}
+	if third.ID == first.ID {
+		t.Errorf("expected a different author date to change the commit ID")
+	}
+
+	t.Setenv(commands.AuthorDateEnv, "soon")
+	setupConfigRepo(t)
+	os.WriteFile("file.txt", []byte("content\n"), 0644)
+	commands.Add([]string{"file.txt"})
+	if err := commands.Commit("Add file"); err == nil {
+		t.Errorf("expected an invalid %s to be rejected", commands.AuthorDateEnv)
+	}
+}
+
+// TestLegacyCommitDate tests reading commits whose timestamp was stored in
+// local time without a time zone
+func TestLegacyCommitDate(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+	legacy := `{"commit_history": [{"commit_id": "abc", "commit_message": "Old", "commit_timestamp": "2021-01-01 09:30:00", "parent_commit_ids": []}]}`
+	os.WriteFile(filepath.Join(commands.MyGitDir, commands.MetadataFile), []byte(legacy), 0644)
+	os.WriteFile(filepath.Join(commands.MyGitDir, "refs", "heads", "main"), []byte("abc\n"), 0644)
+
+	repo, err := commands.Open(".")
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	history, err := repo.Log()
+	if err != nil {
+		t.Fatalf("failed to read the history: %v", err)
+	}
+	expected := time.Date(2021, 1, 1, 9, 30, 0, 0, time.Local)
+	if len(history) != 1 || !history[0].Time.Equal(expected) {
+		t.Fatalf("expected a commit at %v, got %+v", expected, history)
+	}
+	if !history[0].Author.IsZero() || !history[0].Committer.When.IsZero() {
+		t.Errorf("expected no author or committer, got %+v", history[0])
+	}
+}
@@ -0,0 +1,431 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultContextLines is the number of unchanged lines shown around each change
+const DefaultContextLines = 3
+
+// DiffOptions selects what Diff compares
+type DiffOptions struct {
+	// Staged compares the index with HEAD (or with the single given commit)
+	Staged bool
+	// Commits holds zero, one or two revisions. With none, the working tree
+	// is compared with the index; with one, the working tree (or index when
+	// Staged is set) is compared with that commit; with two, or a single
+	// range such as "A..B", the commits are compared with each other.
+	Commits []string
+	// Context is the number of context lines around each change
+	Context int
+	// Stat shows the number of lines added and removed in each file instead
+	// of the changes
+	Stat bool
+}
+
+// diffSource is one side of a diff: a snapshot plus the contents of files
+// that are read from the working tree rather than the object store
+type diffSource struct {
+	repo     *Repository
+	files    []FileEntry
+	contents map[string][]byte
+}
+
+func (s diffSource) read(file FileEntry) ([]byte, error) {
+	// Like Git, a submodule reads as the commit it records
+	if file.Mode == ModeGitlink {
+		return []byte("Subproject commit " + file.Hash + "\n"), nil
+	}
+	if content, ok := s.contents[file.Path]; ok {
+		return content, nil
+	}
+	_, data, err := s.repo.ReadObject(file.Hash)
+	return data, err
+}
+
+// Diff shows line-by-line changes between the working tree, the index and commits
+func Diff(opts DiffOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	oldSide, newSide, err := r.diffSides(opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.Stat {
+		stats, err := diffStats(oldSide, newSide)
+		if err != nil {
+			return err
+		}
+		fmt.Print(formatStat(stats, statWidth))
+		return nil
+	}
+	patch, err := formatDiff(oldSide, newSide, opts.Context)
+	if err != nil {
+		return err
+	}
+	fmt.Print(patch)
+	return nil
+}
+
+// DiffStat counts the lines added and removed in each file that differs
+// between the sides opts selects, as diff --stat shows them
+func (r *Repository) DiffStat(opts DiffOptions) ([]FileStat, error) {
+	oldSide, newSide, err := r.diffSides(opts)
+	if err != nil {
+		return nil, err
+	}
+	return diffStats(oldSide, newSide)
+}
+
+// diffSides returns the two sides opts selects for Diff
+func (r *Repository) diffSides(opts DiffOptions) (diffSource, diffSource, error) {
+	oldSide, newSide := diffSource{repo: r}, diffSource{repo: r}
+	if len(opts.Commits) > 2 {
+		return oldSide, newSide, errors.New("diff accepts at most two commits")
+	}
+	// A range compares its ends; "A...B" compares B with the merge base
+	if len(opts.Commits) == 1 {
+		if from, to, symmetric, ok := splitRange(opts.Commits[0]); ok {
+			if symmetric {
+				var err error
+				if from, err = r.mergeBaseOf(from, to); err != nil {
+					return oldSide, newSide, err
+				}
+			}
+			opts.Commits = []string{from, to}
+		}
+	}
+	if opts.Staged && len(opts.Commits) == 2 {
+		return oldSide, newSide, errors.New("--staged cannot be used with two commits")
+	}
+
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return oldSide, newSide, err
+	}
+
+	switch {
+	case len(opts.Commits) == 2:
+		if oldSide, err = r.commitSource(opts.Commits[0]); err != nil {
+			return oldSide, newSide, err
+		}
+		if newSide, err = r.commitSource(opts.Commits[1]); err != nil {
+			return oldSide, newSide, err
+		}
+	case opts.Staged:
+		if len(opts.Commits) == 1 {
+			if oldSide, err = r.commitSource(opts.Commits[0]); err != nil {
+				return oldSide, newSide, err
+			}
+		} else {
+			// Before the first commit everything in the index is new
+			if oldSide.files, err = r.headFiles(); err != nil {
+				return oldSide, newSide, err
+			}
+		}
+		newSide.files = idx.Files()
+	case len(opts.Commits) == 1:
+		if oldSide, err = r.commitSource(opts.Commits[0]); err != nil {
+			return oldSide, newSide, err
+		}
+		// Compare against every file tracked by either the commit or the index
+		paths := make(map[string]bool)
+		for _, file := range oldSide.files {
+			paths[file.Path] = true
+		}
+		for _, entry := range idx.Entries {
+			paths[entry.Path] = true
+		}
+		if newSide, err = r.workTreeSource(idx, paths); err != nil {
+			return oldSide, newSide, err
+		}
+	default:
+		oldSide.files = idx.Files()
+		paths := make(map[string]bool)
+		for _, entry := range idx.Entries {
+			paths[entry.Path] = true
+		}
+		if newSide, err = r.workTreeSource(idx, paths); err != nil {
+			return oldSide, newSide, err
+		}
+	}
+	return oldSide, newSide, nil
+}
+
+// diffFiles pairs up the two versions of each changed file and reads
+// their contents
+func diffFiles(oldSide, newSide diffSource, each func(change FileChange, oldFile, newFile FileEntry, oldContent, newContent []byte) error) error {
+	oldFiles := make(map[string]FileEntry, len(oldSide.files))
+	for _, file := range oldSide.files {
+		oldFiles[file.Path] = file
+	}
+	newFiles := make(map[string]FileEntry, len(newSide.files))
+	for _, file := range newSide.files {
+		newFiles[file.Path] = file
+	}
+
+	for _, change := range diffSnapshots(oldSide.files, newSide.files) {
+		var oldContent, newContent []byte
+		var err error
+		oldFile, hasOld := oldFiles[change.Path]
+		newFile, hasNew := newFiles[change.Path]
+		if hasOld {
+			if oldContent, err = oldSide.read(oldFile); err != nil {
+				return fmt.Errorf("failed to read %s: %w", change.Path, err)
+			}
+		}
+		if hasNew {
+			if newContent, err = newSide.read(newFile); err != nil {
+				return fmt.Errorf("failed to read %s: %w", change.Path, err)
+			}
+		}
+		if err := each(change, oldFile, newFile, oldContent, newContent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatDiff formats the changes between two sides as a patch
+func formatDiff(oldSide, newSide diffSource, context int) (string, error) {
+	var out strings.Builder
+	err := diffFiles(oldSide, newSide, func(change FileChange, oldFile, newFile FileEntry, oldContent, newContent []byte) error {
+		out.WriteString(formatFileDiff(change, oldFile, newFile, oldContent, newContent, context))
+		return nil
+	})
+	return out.String(), err
+}
+
+// formatFileDiff formats the extended header and hunks for a single changed file
+func formatFileDiff(change FileChange, oldFile, newFile FileEntry, oldContent, newContent []byte, context int) string {
+	// Like Git, a file replaced by a symbolic link or the other way round
+	// shows as deleted and added again
+	if change.Change == ChangeModified && (oldFile.Mode == ModeSymlink) != (newFile.Mode == ModeSymlink) {
+		deleted := FileChange{Path: change.Path, Change: ChangeDeleted}
+		added := FileChange{Path: change.Path, Change: ChangeAdded}
+		return formatFileDiff(deleted, oldFile, FileEntry{}, oldContent, nil, context) +
+			formatFileDiff(added, FileEntry{}, newFile, nil, newContent, context)
+	}
+	oldName, newName := "a/"+change.Path, "b/"+change.Path
+	header := fmt.Sprintf("diff --git %s %s\n", oldName, newName)
+
+	oldHash, newHash := zeroHash, zeroHash
+	switch change.Change {
+	case ChangeAdded:
+		header += fmt.Sprintf("new file mode %s\n", newFile.Mode)
+		oldName = "/dev/null"
+		newHash = newFile.Hash
+	case ChangeDeleted:
+		header += fmt.Sprintf("deleted file mode %s\n", oldFile.Mode)
+		newName = "/dev/null"
+		oldHash = oldFile.Hash
+	default:
+		oldHash, newHash = oldFile.Hash, newFile.Hash
+		if oldFile.Mode != newFile.Mode {
+			header += fmt.Sprintf("old mode %s\nnew mode %s\n", oldFile.Mode, newFile.Mode)
+		}
+	}
+
+	// Mode-only changes have no content to show
+	if oldHash == newHash {
+		return header
+	}
+
+	header += fmt.Sprintf("index %s..%s", oldHash[:7], newHash[:7])
+	if change.Change == ChangeModified && oldFile.Mode == newFile.Mode {
+		header += " " + oldFile.Mode
+	}
+	header += "\n"
+
+	if IsBinary(oldContent) || IsBinary(newContent) {
+		return header + fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+	}
+	return header + UnifiedDiff(oldName, newName, SplitLines(oldContent), SplitLines(newContent), context)
+}
+
+// mergeBaseOf returns the merge base of two revisions, for "A...B"
+func (r *Repository) mergeBaseOf(a, b string) (string, error) {
+	revs, err := r.ResolveRange(a + "..." + b)
+	if err != nil {
+		return "", err
+	}
+	if len(revs.Exclude) == 0 {
+		return "", fmt.Errorf("%s and %s have no common ancestor", a, b)
+	}
+	return revs.Exclude[0], nil
+}
+
+// commitSource returns the snapshot of a commit as a diff side
+func (r *Repository) commitSource(rev string) (diffSource, error) {
+	commit, err := r.ResolveCommit(rev)
+	if err != nil {
+		return diffSource{}, err
+	}
+	files, err := r.commitFiles(commit)
+	if err != nil {
+		return diffSource{}, fmt.Errorf("failed to read commit %s: %w", rev, err)
+	}
+	return diffSource{repo: r, files: files}, nil
+}
+
+// workTreeSource reads the given paths from the working tree as a diff side.
+// Paths that no longer exist are left out, so they show up as deletions.
+// Submodules are not checked out, so they are taken from the index.
+func (r *Repository) workTreeSource(idx *Index, paths map[string]bool) (diffSource, error) {
+	source := diffSource{repo: r, contents: make(map[string][]byte)}
+	for path := range paths {
+		if entry, ok := idx.Entry(path); ok && entry.Mode == ModeGitlink {
+			source.files = append(source.files, FileEntry{Path: path, Mode: entry.Mode, Hash: entry.Hash})
+			continue
+		}
+		info, err := os.Lstat(r.workPath(path))
+		if err != nil || !isSnapshotFile(info) {
+			continue
+		}
+		content, err := r.readWorkTreeFile(path, info)
+		if err != nil {
+			return diffSource{}, err
+		}
+		source.files = append(source.files, FileEntry{
+			Path: path,
+			Mode: fileMode(info),
+			Hash: HashObject(BlobObject, content),
+		})
+		source.contents[path] = content
+	}
+	return source, nil
+}
+
+// statWidth is the width of the lines printed by --stat, as Git uses when
+// the output is not a terminal
+const statWidth = 80
+
+// FileStat counts the lines a change added and removed in one file
+type FileStat struct {
+	Path string `json:"path"`
+	// Change is ChangeAdded, ChangeModified or ChangeDeleted
+	Change     string `json:"status"`
+	Insertions int    `json:"insertions"`
+	Deletions  int    `json:"deletions"`
+	// Binary is set for files that are not text, which are compared by size
+	// instead of counting lines
+	Binary  bool `json:"binary"`
+	OldSize int  `json:"old_size,omitempty"`
+	NewSize int  `json:"new_size,omitempty"`
+}
+
+// diffStats counts the lines added and removed in each changed file
+func diffStats(oldSide, newSide diffSource) ([]FileStat, error) {
+	var stats []FileStat
+	err := diffFiles(oldSide, newSide, func(change FileChange, oldFile, newFile FileEntry, oldContent, newContent []byte) error {
+		stat := FileStat{Path: change.Path, Change: change.Change}
+		if IsBinary(oldContent) || IsBinary(newContent) {
+			stat.Binary, stat.OldSize, stat.NewSize = true, len(oldContent), len(newContent)
+		} else {
+			for _, edit := range DiffLines(SplitLines(oldContent), SplitLines(newContent)) {
+				switch edit.Op {
+				case EditInsert:
+					stat.Insertions++
+				case EditDelete:
+					stat.Deletions++
+				}
+			}
+		}
+		stats = append(stats, stat)
+		return nil
+	})
+	return stats, err
+}
+
+// formatStat formats line counts like git diff --stat: a line per file with
+// a bar of + and - scaled to fit in width, then a summary
+func formatStat(stats []FileStat, width int) string {
+	if len(stats) == 0 {
+		return ""
+	}
+	nameWidth, maxChange := 0, 0
+	insertions, deletions := 0, 0
+	for _, stat := range stats {
+		nameWidth = max(nameWidth, len(stat.Path))
+		if !stat.Binary {
+			maxChange = max(maxChange, stat.Insertions+stat.Deletions)
+		}
+		insertions += stat.Insertions
+		deletions += stat.Deletions
+	}
+	numberWidth := len(strconv.Itoa(maxChange))
+	// " name | count bar", leaving the last column empty
+	barWidth := max(width-nameWidth-numberWidth-6, 6)
+
+	var out strings.Builder
+	for _, stat := range stats {
+		if stat.Binary {
+			fmt.Fprintf(&out, " %-*s | Bin %d -> %d bytes\n", nameWidth, stat.Path, stat.OldSize, stat.NewSize)
+			continue
+		}
+		added, deleted := stat.Insertions, stat.Deletions
+		if maxChange > barWidth {
+			total := scaleStat(added+deleted, barWidth, maxChange)
+			if total < 2 && added > 0 && deleted > 0 {
+				total = 2
+			}
+			if added < deleted {
+				added = scaleStat(added, barWidth, maxChange)
+				deleted = total - added
+			} else {
+				deleted = scaleStat(deleted, barWidth, maxChange)
+				added = total - deleted
+			}
+		}
+		fmt.Fprintf(&out, " %-*s | %*d", nameWidth, stat.Path, numberWidth, stat.Insertions+stat.Deletions)
+		if added+deleted > 0 {
+			out.WriteString(" " + strings.Repeat("+", added) + strings.Repeat("-", deleted))
+		}
+		out.WriteString("\n")
+	}
+
+	fmt.Fprintf(&out, " %d %s changed", len(stats), plural(len(stats), "file", "files"))
+	if insertions > 0 || deletions == 0 {
+		fmt.Fprintf(&out, ", %d %s(+)", insertions, plural(insertions, "insertion", "insertions"))
+	}
+	if deletions > 0 || insertions == 0 {
+		fmt.Fprintf(&out, ", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+	out.WriteString("\n")
+	return out.String()
+}
+
+// scaleStat scales a line count to a bar of at most width characters, as
+// Git does, so that any change gets at least one
+func scaleStat(count, width, maxChange int) int {
+	if count == 0 {
+		return 0
+	}
+	return 1 + count*(width-1)/maxChange
+}
+
+// formatNameStatus lists the changed files with a letter for the kind of
+// change, like git diff --name-status
+func formatNameStatus(changes []FileChange) string {
+	var out strings.Builder
+	for _, change := range changes {
+		fmt.Fprintf(&out, "%c\t%s\n", statusCode(change.Change), change.Path)
+	}
+	return out.String()
+}
+
+// plural returns one when n is 1, and many otherwise
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
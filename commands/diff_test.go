@@ -0,0 +1,170 @@
+package commands_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestDiffLines tests that the edit script turns one text into the other
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       []string
+		b       []string
+		changes int
+	}{
+		{name: "both empty", a: nil, b: nil, changes: 0},
+		{name: "identical", a: []string{"a", "b"}, b: []string{"a", "b"}, changes: 0},
+		{name: "insert into empty", a: nil, b: []string{"a", "b"}, changes: 2},
+		{name: "delete everything", a: []string{"a", "b"}, b: nil, changes: 2},
+		{name: "replace middle line", a: []string{"a", "b", "c"}, b: []string{"a", "x", "c"}, changes: 2},
+		{
+			name:    "classic example",
+			a:       strings.Split("ABCABBA", ""),
+			b:       strings.Split("CBABAC", ""),
+			changes: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits := commands.DiffLines(tt.a, tt.b)
+
+			var oldLines, newLines []string
+			changes := 0
+			for _, edit := range edits {
+				if edit.Op != commands.EditInsert {
+					oldLines = append(oldLines, edit.Text)
+				}
+				if edit.Op != commands.EditDelete {
+					newLines = append(newLines, edit.Text)
+				}
+				if edit.Op != commands.EditEqual {
+					changes++
+				}
+			}
+
+			if strings.Join(oldLines, "") != strings.Join(tt.a, "") {
+				t.Errorf("edit script does not reproduce old text: %v", oldLines)
+			}
+			if strings.Join(newLines, "") != strings.Join(tt.b, "") {
+				t.Errorf("edit script does not reproduce new text: %v", newLines)
+			}
+			if changes != tt.changes {
+				t.Errorf("expected %d changed lines, got %d", tt.changes, changes)
+			}
+		})
+	}
+}
+
+// TestUnifiedDiff tests unified diff formatting
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		context  int
+		expected string
+	}{
+		{
+			name:     "no changes",
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			context:  3,
+			expected: "",
+		},
+		{
+			name:    "single change with context",
+			old:     "a\nb\nc\n",
+			new:     "a\nx\nc\n",
+			context: 3,
+			expected: "--- old\n+++ new\n" +
+				"@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			name:    "separate hunks",
+			old:     "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:     "one\n2\n3\n4\n5\n6\n7\neight\n",
+			context: 1,
+			expected: "--- old\n+++ new\n" +
+				"@@ -1,2 +1,2 @@\n-1\n+one\n 2\n" +
+				"@@ -7,2 +7,2 @@\n 7\n-8\n+eight\n",
+		},
+		{
+			name:    "new file",
+			old:     "",
+			new:     "a\n",
+			context: 3,
+			expected: "--- old\n+++ new\n" +
+				"@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name:    "missing newline at end of file",
+			old:     "a\n",
+			new:     "a",
+			context: 3,
+			expected: "--- old\n+++ new\n" +
+				"@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := commands.UnifiedDiff("old", "new", commands.SplitLines([]byte(tt.old)), commands.SplitLines([]byte(tt.new)), tt.context)
+			if got != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestIsBinary tests binary content detection
+func TestIsBinary(t *testing.T) {
+	if commands.IsBinary([]byte("plain text\n")) {
+		t.Errorf("text content detected as binary")
+	}
+	if !commands.IsBinary([]byte("bin\x00ary")) {
+		t.Errorf("content with NUL byte not detected as binary")
+	}
+}
+
+// TestDiff tests the diff command in its different modes
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          commands.DiffOptions
+		expectedError bool
+	}{
+		{name: "working tree against index", opts: commands.DiffOptions{Context: 3}},
+		{name: "index against HEAD", opts: commands.DiffOptions{Staged: true, Context: 3}},
+		{name: "working tree against commit", opts: commands.DiffOptions{Commits: []string{"HEAD"}, Context: 3}},
+		{name: "unknown commit", opts: commands.DiffOptions{Commits: []string{"deadbeef"}}, expectedError: true},
+		{name: "too many commits", opts: commands.DiffOptions{Commits: []string{"HEAD", "HEAD", "HEAD"}}, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			os.Chdir(tempDir)
+			commands.Init()
+			os.WriteFile("file.txt", []byte("line 1\nline 2\n"), 0644)
+			commands.Add([]string{"file.txt"})
+			commands.Commit("Initial commit")
+			os.WriteFile("file.txt", []byte("line 1\nchanged\n"), 0644)
+			commands.Add([]string{"file.txt"})
+			os.WriteFile("file.txt", []byte("line 1\nchanged again\n"), 0644)
+
+			err := commands.Diff(tt.opts)
+
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
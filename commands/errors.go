@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Error codes classify the errors commands return, so that scripts do not
+// have to match messages. The command line reports them with --json and
+// exits with a status of its own for each, listed in errorCodes.
+const (
+	// CodeError is any error without a more specific code
+	CodeError = "error"
+	// CodeUsage is a command line that cannot be run as given
+	CodeUsage = "usage"
+	// CodeNotRepository means no repository was found
+	CodeNotRepository = "not_a_repository"
+	// CodeUnknownRevision means a revision names no commit or object
+	CodeUnknownRevision = "unknown_revision"
+	// CodeNothingToCommit means the index matches HEAD
+	CodeNothingToCommit = "nothing_to_commit"
+	// CodeConflict means merge conflicts are in the way or were left behind
+	CodeConflict = "conflict"
+	// CodeLocalChanges means a command would overwrite or remove local
+	// changes
+	CodeLocalChanges = "local_changes"
+	// CodeLocked means another process holds a lock on the repository
+	CodeLocked = "locked"
+)
+
+// errorCodes lists the error codes by exit status, starting at 1
+var errorCodes = []string{
+	CodeError,
+	CodeUsage,
+	CodeNotRepository,
+	CodeUnknownRevision,
+	CodeNothingToCommit,
+	CodeConflict,
+	CodeLocalChanges,
+	CodeLocked,
+}
+
+var (
+	// ErrNotRepository is returned when no repository can be found
+	ErrNotRepository = errors.New("not a mygit repository (run 'mygit init' first)")
+	// ErrUnknownRevision is returned for revisions that name nothing
+	ErrUnknownRevision = errors.New("unknown revision")
+	// ErrNothingToCommit is returned by Commit when the index matches HEAD
+	ErrNothingToCommit = errors.New("no changes staged for commit")
+	// ErrConflict is returned when unresolved merge conflicts prevent a
+	// command, and by Merge when it stops at conflicts
+	ErrConflict = errors.New("unresolved merge conflicts")
+	// ErrLocalChanges is returned when a command would overwrite or remove
+	// changes in the index or working tree
+	ErrLocalChanges = errors.New("local changes would be overwritten")
+)
+
+// UsageError is returned for a command line that cannot be run as given
+type UsageError struct {
+	Message string
+}
+
+func (e *UsageError) Error() string {
+	return e.Message
+}
+
+// usageErrorf formats a UsageError
+func usageErrorf(format string, args ...any) error {
+	return &UsageError{Message: fmt.Sprintf(format, args...)}
+}
+
+// StatusError gives an error the exit status of a command that keeps Git's
+// exit statuses, such as 128 for errors of check-ignore, instead of the
+// status of its code
+type StatusError struct {
+	Err    error
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// kindError is an error with a message of its own that errors.Is matches
+// with a sentinel error such as ErrConflict
+type kindError struct {
+	kind    error
+	message string
+}
+
+func (e *kindError) Error() string {
+	return e.message
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// kindErrorf formats an error that errors.Is matches with kind
+func kindErrorf(kind error, format string, args ...any) error {
+	return &kindError{kind: kind, message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorCode returns the code that classifies err, or CodeError when it has
+// no more specific code
+func ErrorCode(err error) string {
+	var usage *UsageError
+	var lock *LockError
+	switch {
+	case errors.As(err, &usage):
+		return CodeUsage
+	case errors.Is(err, ErrNotRepository):
+		return CodeNotRepository
+	case errors.Is(err, ErrUnknownRevision):
+		return CodeUnknownRevision
+	case errors.Is(err, ErrNothingToCommit):
+		return CodeNothingToCommit
+	case errors.Is(err, ErrConflict):
+		return CodeConflict
+	case errors.Is(err, ErrLocalChanges):
+		return CodeLocalChanges
+	case errors.As(err, &lock):
+		return CodeLocked
+	}
+	return CodeError
+}
+
+// ErrorExitStatus returns the exit status of the command line for err: the
+// status of a StatusError, or else the status of its code
+func ErrorExitStatus(err error) int {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Status
+	}
+	return ExitStatus(ErrorCode(err))
+}
+
+// ExitStatus returns the exit status of the command line for an error code
+func ExitStatus(code string) int {
+	if i := slices.Index(errorCodes, code); i >= 0 {
+		return i + 1
+	}
+	return 1
+}
@@ -0,0 +1,331 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// FastExport writes the history of the given branches and tags, or of all
+// of them, to standard output as a fast-export stream
+func FastExport(refs []string) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := r.FastExport(w, refs); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// FastExport writes the commits reachable from the given branches and tags,
+// or from all of them, in the text format of "git fast-export", which "git
+// fast-import" and "mygit fast-import" read back. Each commit lists the
+// files changed from its first parent, preceded by the blobs not exported
+// yet; objects are numbered with marks in the order they are written. Tags
+// pointing to annotated tag objects are written as tag commands.
+func (r *Repository) FastExport(w io.Writer, refs []string) error {
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		branches, err := r.listBranches()
+		if err != nil {
+			return err
+		}
+		tags, err := r.listTags()
+		if err != nil {
+			return err
+		}
+		for _, branch := range branches {
+			refs = append(refs, BranchPrefix+branch)
+		}
+		for _, tag := range tags {
+			refs = append(refs, TagPrefix+tag)
+		}
+	}
+
+	e := &fastExporter{
+		repo:    r,
+		w:       w,
+		commits: make(map[string]*CommitRecord),
+		refName: make(map[string]string),
+		marks:   make(map[string]int),
+	}
+	for i := range metadata.CommitHistory {
+		e.commits[metadata.CommitHistory[i].ID] = &metadata.CommitHistory[i]
+	}
+
+	// Name every commit after the first ref that reaches it
+	tips := make(map[string]string)
+	names := refs
+	refs = nil
+	for _, name := range names {
+		ref, target, err := r.resolveExportRef(name)
+		if err != nil {
+			return err
+		}
+		if _, ok := tips[ref]; ok {
+			continue
+		}
+		refs = append(refs, ref)
+		commitID, err := r.peelTag(target)
+		if err != nil {
+			return fmt.Errorf("cannot export %s: %w", ref, err)
+		}
+		tips[ref] = commitID
+		if err := e.nameCommits(ref, commitID); err != nil {
+			return err
+		}
+	}
+
+	// Parents come before their children in the history
+	for i := range metadata.CommitHistory {
+		commit := &metadata.CommitHistory[i]
+		if e.refName[commit.ID] != "" {
+			if err := e.exportCommit(commit); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Point the refs whose tip was written under another name at it, and
+	// write annotated tags
+	for _, ref := range refs {
+		target, err := r.readRef(ref)
+		if err != nil {
+			return err
+		}
+		if target != tips[ref] {
+			if err := e.exportTag(ref, target); err != nil {
+				return err
+			}
+			continue
+		}
+		if e.refName[tips[ref]] != ref {
+			e.printf("reset %s\nfrom :%d\n\n", ref, e.marks[tips[ref]])
+		}
+	}
+	return e.err
+}
+
+// resolveExportRef finds the full name of a branch or tag given by name,
+// such as "main", "refs/heads/main" or "v1.0", and the object it points at
+func (r *Repository) resolveExportRef(name string) (string, string, error) {
+	candidates := []string{name}
+	if !strings.HasPrefix(name, RefsDir+"/") {
+		candidates = []string{BranchPrefix + name, TagPrefix + name}
+	}
+	for _, ref := range candidates {
+		target, err := r.readRef(ref)
+		if err != nil {
+			return "", "", err
+		}
+		if target != "" {
+			return ref, target, nil
+		}
+	}
+	return "", "", fmt.Errorf("unknown branch or tag: %s", name)
+}
+
+// fastExporter writes a fast-export stream
+type fastExporter struct {
+	repo    *Repository
+	w       io.Writer
+	commits map[string]*CommitRecord
+	// refName holds the ref each exported commit is written under
+	refName map[string]string
+	// marks numbers the written blobs and commits by object ID
+	marks map[string]int
+	// err is the first error writing to w
+	err error
+}
+
+// printf writes to the stream, remembering the first error
+func (e *fastExporter) printf(format string, args ...any) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+// data writes a data command holding content
+func (e *fastExporter) data(content []byte) {
+	e.printf("data %d\n", len(content))
+	if e.err == nil {
+		_, e.err = e.w.Write(content)
+	}
+}
+
+// mark numbers the next written object
+func (e *fastExporter) mark(id string) int {
+	e.marks[id] = len(e.marks) + 1
+	return e.marks[id]
+}
+
+// nameCommits names the commits reachable from commitID that no earlier
+// ref reached after ref
+func (e *fastExporter) nameCommits(ref, commitID string) error {
+	stack := []string{commitID}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if e.refName[id] != "" {
+			continue
+		}
+		commit, ok := e.commits[id]
+		if !ok {
+			return fmt.Errorf("unknown commit: %s", id)
+		}
+		e.refName[id] = ref
+		stack = append(stack, commit.Parents...)
+	}
+	return nil
+}
+
+// exportCommit writes the blobs a commit adds and the commit itself
+func (e *fastExporter) exportCommit(commit *CommitRecord) error {
+	files, err := e.repo.commitFiles(commit)
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %w", commit.ID, err)
+	}
+	var parentFiles []FileEntry
+	if len(commit.Parents) > 0 {
+		if parentFiles, err = e.repo.commitFiles(e.commits[commit.Parents[0]]); err != nil {
+			return fmt.Errorf("failed to read commit %s: %w", commit.Parents[0], err)
+		}
+	}
+	byPath := make(map[string]FileEntry, len(files))
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	changes := diffSnapshots(parentFiles, files)
+
+	for _, change := range changes {
+		file, ok := byPath[change.Path]
+		// Submodules name commits of other repositories, which are not exported
+		if !ok || file.Mode == ModeGitlink || e.marks[file.Hash] != 0 {
+			continue
+		}
+		_, data, err := e.repo.ReadObject(file.Hash)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		e.printf("blob\nmark :%d\n", e.mark(file.Hash))
+		e.data(data)
+		e.printf("\n")
+	}
+
+	// Commits stored as Git objects give their message exactly
+	message := objectMessage(commit.Message)
+	if objType, data, err := e.repo.ReadObject(commit.ID); err == nil && objType == CommitObject {
+		if decoded, err := decodeCommit(data); err == nil {
+			message = decoded.Message
+		}
+	}
+	author, committer := commit.gitSignatures()
+
+	ref := e.refName[commit.ID]
+	if len(commit.Parents) == 0 {
+		e.printf("reset %s\n", ref)
+	}
+	e.printf("commit %s\nmark :%d\n", ref, e.mark(commit.ID))
+	e.printf("author %s %s\n", author, rawDate(author.When))
+	e.printf("committer %s %s\n", committer, rawDate(committer.When))
+	e.data([]byte(message))
+	for i, parent := range commit.Parents {
+		command := "merge"
+		if i == 0 {
+			command = "from"
+		}
+		e.printf("%s :%d\n", command, e.marks[parent])
+	}
+	for _, change := range changes {
+		if file, ok := byPath[change.Path]; ok && file.Mode == ModeGitlink {
+			e.printf("M %s %s %s\n", file.Mode, file.Hash, quotePath(file.Path))
+		} else if ok {
+			e.printf("M %s :%d %s\n", file.Mode, e.marks[file.Hash], quotePath(file.Path))
+		} else {
+			e.printf("D %s\n", quotePath(change.Path))
+		}
+	}
+	e.printf("\n")
+	return e.err
+}
+
+// exportTag writes an annotated tag
+func (e *fastExporter) exportTag(ref, id string) error {
+	_, data, err := e.repo.ReadObject(id)
+	if err != nil {
+		return err
+	}
+	tag, err := decodeTag(data)
+	if err != nil {
+		return fmt.Errorf("tag %s: %w", id, err)
+	}
+	commitID, err := e.repo.peelTag(id)
+	if err != nil {
+		return err
+	}
+	e.printf("tag %s\nfrom :%d\n", strings.TrimPrefix(ref, TagPrefix), e.marks[commitID])
+	if !tag.Tagger.IsZero() {
+		e.printf("tagger %s %s\n", tag.Tagger, rawDate(tag.Tagger.When))
+	}
+	e.data([]byte(tag.Message))
+	e.printf("\n")
+	return e.err
+}
+
+// quotePath quotes a path the way Git does in fast-export streams and
+// diffs when it starts with a quote or holds quotes, backslashes or control
+// characters: in double quotes, with C escapes
+func quotePath(p string) string {
+	needsQuotes := strings.HasPrefix(p, `"`)
+	for i := 0; i < len(p); i++ {
+		if p[i] < 0x20 || p[i] == 0x7f || p[i] == '"' || p[i] == '\\' {
+			needsQuotes = true
+		}
+	}
+	if !needsQuotes {
+		return p
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\v':
+			b.WriteString(`\v`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\%03o`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,815 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FastImportOptions controls FastImport
+type FastImportOptions struct {
+	// Force moves existing branches to commits that do not descend from
+	// them, dropping the commits only they reached
+	Force bool
+	// Progress receives the progress commands of the stream
+	Progress io.Writer
+}
+
+// FastImportResult summarizes a fast-import stream
+type FastImportResult struct {
+	// Commits, Blobs and Tags count the objects the stream created
+	Commits int
+	Blobs   int
+	Tags    int
+	// Refs lists the branches and tags that were created or moved
+	Refs []string
+	// Marks maps the marks of the stream to object IDs
+	Marks map[int]string
+}
+
+// FastImport reads a fast-export stream from standard input and adds its
+// commits, branches and tags to the history
+func FastImport(opts FastImportOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	if opts.Progress == nil {
+		opts.Progress = os.Stdout
+	}
+	result, err := r.FastImport(os.Stdin, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d commits, %d blobs and %d tags; updated %d refs\n", result.Commits, result.Blobs, result.Tags, len(result.Refs))
+	return nil
+}
+
+// FastImport reads a stream in the text format of "git fast-import", such as
+// one written by "git fast-export" or FastExport, and builds the commits,
+// blobs and annotated tags it describes. The blob, commit, tag, reset,
+// progress, checkpoint, feature, option and done commands are supported,
+// with both forms of data and the M, D, C, R and deleteall file changes.
+//
+// Commits get the IDs Git would give them. Branches and tags are updated
+// once the whole stream is read; an existing branch is only moved to a
+// commit descending from it unless opts.Force is set. The index and the
+// working tree are left alone.
+func (r *Repository) FastImport(in io.Reader, opts FastImportOptions) (*FastImportResult, error) {
+	unlock, err := r.lock(HeadFile, MetadataFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	f := &fastImporter{
+		repo:     r,
+		in:       bufio.NewReader(in),
+		metadata: metadata,
+		commits:  make(map[string]int),
+		refs:     make(map[string]string),
+		result:   &FastImportResult{Marks: make(map[int]string)},
+		progress: opts.Progress,
+	}
+	for i, commit := range metadata.CommitHistory {
+		f.commits[commit.ID] = i
+	}
+	if err := f.run(); err != nil {
+		if f.lineNum > 0 {
+			return nil, fmt.Errorf("fast-import stream, line %d: %w", f.lineNum, err)
+		}
+		return nil, err
+	}
+
+	// Check every ref before changing any
+	names := make([]string, 0, len(f.refs))
+	for ref := range f.refs {
+		names = append(names, ref)
+	}
+	sort.Strings(names)
+	var updates []string
+	for _, ref := range names {
+		target := f.refs[ref]
+		existing, err := r.readRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		if target == "" || target == existing {
+			continue
+		}
+		if existing != "" && strings.HasPrefix(ref, BranchPrefix) && !opts.Force && !metadata.isAncestor(existing, target) {
+			return nil, fmt.Errorf("not updating %s: %s does not descend from %s; use --force to move it anyway", ref, target, existing)
+		}
+		updates = append(updates, ref)
+	}
+
+	if f.result.Commits > 0 {
+		if err := r.writeMetadata(metadata); err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range updates {
+		if err := r.writeRef(ref, f.refs[ref]); err != nil {
+			return nil, err
+		}
+	}
+	f.result.Refs = updates
+	return f.result, nil
+}
+
+// fastImporter parses a fast-import stream
+type fastImporter struct {
+	repo     *Repository
+	in       *bufio.Reader
+	metadata *metadata
+	// commits indexes the history by commit ID
+	commits map[string]int
+	// refs holds the new target of every ref the stream changed, or an
+	// empty string for a branch reset to start over
+	refs     map[string]string
+	result   *FastImportResult
+	progress io.Writer
+
+	// line is a line read ahead and not handled yet
+	line    string
+	hasLine bool
+	lineNum int
+	// requireDone is set by "feature done"
+	requireDone bool
+}
+
+// readLine returns the next line that is not a comment, or io.EOF
+func (f *fastImporter) readLine() (string, error) {
+	if f.hasLine {
+		f.hasLine = false
+		return f.line, nil
+	}
+	for {
+		line, err := f.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		if err != nil {
+			return "", err
+		}
+		f.lineNum++
+		line = strings.TrimSuffix(line, "\n")
+		if !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+}
+
+// unreadLine hands a line back to the next readLine
+func (f *fastImporter) unreadLine(line string) {
+	f.line, f.hasLine = line, true
+}
+
+// optional reads the argument of an optional command such as "mark", or
+// returns false when the next line is another command
+func (f *fastImporter) optional(command string) (string, bool, error) {
+	line, err := f.readLine()
+	if err == io.EOF {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if arg, ok := strings.CutPrefix(line, command+" "); ok {
+		return arg, true, nil
+	}
+	f.unreadLine(line)
+	return "", false, nil
+}
+
+// run reads the commands of the stream
+func (f *fastImporter) run() error {
+	for {
+		line, err := f.readLine()
+		if err == io.EOF {
+			if f.requireDone {
+				return errors.New("stream ended without 'done'")
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read the stream: %w", err)
+		}
+
+		command, arg, _ := strings.Cut(line, " ")
+		switch command {
+		case "blob":
+			err = f.blob()
+		case "commit":
+			err = f.commit(arg)
+		case "tag":
+			err = f.tag(arg)
+		case "reset":
+			err = f.reset(arg)
+		case "progress":
+			if f.progress != nil {
+				fmt.Fprintln(f.progress, line)
+			}
+		case "feature":
+			err = f.feature(arg)
+		case "done":
+			return nil
+		case "checkpoint", "option", "":
+			// Nothing to do: objects are written as they are read and
+			// options only concern Git
+		default:
+			err = fmt.Errorf("unsupported command '%s'", command)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// feature checks that the stream only relies on supported features
+func (f *fastImporter) feature(name string) error {
+	switch name {
+	case "done":
+		f.requireDone = true
+	case "date-format=raw", "date-format=raw-permissive":
+	default:
+		return fmt.Errorf("unsupported feature '%s'", name)
+	}
+	return nil
+}
+
+// data reads a data command: "data <count>" followed by that many bytes, or
+// "data <<<delimiter>" followed by lines up to the delimiter
+func (f *fastImporter) data() ([]byte, error) {
+	line, err := f.readLine()
+	if err == io.EOF {
+		err = errors.New("expected data, found the end of the stream")
+	}
+	if err != nil {
+		return nil, err
+	}
+	arg, ok := strings.CutPrefix(line, "data ")
+	if !ok {
+		return nil, fmt.Errorf("expected data, found '%s'", line)
+	}
+
+	if delimiter, ok := strings.CutPrefix(arg, "<<"); ok {
+		var buf bytes.Buffer
+		for {
+			line, err := f.in.ReadString('\n')
+			if err != nil {
+				return nil, fmt.Errorf("data ended without '%s'", delimiter)
+			}
+			f.lineNum++
+			if strings.TrimSuffix(line, "\n") == delimiter {
+				return buf.Bytes(), nil
+			}
+			buf.WriteString(line)
+		}
+	}
+
+	size, err := strconv.Atoi(arg)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid data size '%s'", arg)
+	}
+	content := make([]byte, size)
+	if _, err := io.ReadFull(f.in, content); err != nil {
+		return nil, fmt.Errorf("data ended early: %w", err)
+	}
+	f.lineNum += bytes.Count(content, []byte("\n"))
+	// A newline may follow the data
+	if c, err := f.in.ReadByte(); err == nil {
+		if c == '\n' {
+			f.lineNum++
+		} else {
+			f.in.UnreadByte()
+		}
+	}
+	return content, nil
+}
+
+// mark reads an optional mark and original-oid, returning the mark or 0
+func (f *fastImporter) mark() (int, error) {
+	mark := 0
+	if arg, ok, err := f.optional("mark"); err != nil {
+		return 0, err
+	} else if ok {
+		n, err := strconv.Atoi(strings.TrimPrefix(arg, ":"))
+		if err != nil || n <= 0 || !strings.HasPrefix(arg, ":") {
+			return 0, fmt.Errorf("invalid mark '%s'", arg)
+		}
+		mark = n
+	}
+	if _, _, err := f.optional("original-oid"); err != nil {
+		return 0, err
+	}
+	return mark, nil
+}
+
+// setMark records the object a mark stands for
+func (f *fastImporter) setMark(mark int, id string) {
+	if mark > 0 {
+		f.result.Marks[mark] = id
+	}
+}
+
+// object resolves a reference to an object: a mark such as ":1", an object
+// ID, or a branch or tag
+func (f *fastImporter) object(ref string) (string, error) {
+	if mark, ok := strings.CutPrefix(ref, ":"); ok {
+		n, err := strconv.Atoi(mark)
+		if id, found := f.result.Marks[n]; err == nil && found {
+			return id, nil
+		}
+		return "", fmt.Errorf("unknown mark '%s'", ref)
+	}
+	if isObjectID(ref) {
+		if _, ok := f.commits[ref]; ok || f.repo.hasObject(ref) {
+			return ref, nil
+		}
+		return "", fmt.Errorf("unknown object %s", ref)
+	}
+	for _, name := range []string{ref, BranchPrefix + ref, TagPrefix + ref} {
+		if target, ok := f.refs[name]; ok && target != "" {
+			return target, nil
+		}
+		target, err := f.repo.readRef(name)
+		if err != nil {
+			return "", err
+		}
+		if target != "" {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("unknown branch or tag '%s'", ref)
+}
+
+// commitish resolves a reference to a commit, following annotated tags
+func (f *fastImporter) commitish(ref string) (string, error) {
+	id, err := f.object(ref)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := f.commits[id]; ok {
+		return id, nil
+	}
+	commitID, err := f.repo.peelTag(id)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a commit: %w", ref, err)
+	}
+	if _, ok := f.commits[commitID]; !ok {
+		return "", fmt.Errorf("unknown commit: %s", commitID)
+	}
+	return commitID, nil
+}
+
+// checkRef accepts the branches and tags a stream can update
+func checkRef(ref string) error {
+	name, ok := strings.CutPrefix(ref, BranchPrefix)
+	if !ok {
+		name, ok = strings.CutPrefix(ref, TagPrefix)
+	}
+	if !ok || checkBranchName(name) != nil {
+		return fmt.Errorf("invalid ref '%s': only refs/heads/ and refs/tags/ refs are supported", ref)
+	}
+	return nil
+}
+
+// blob reads a blob command
+func (f *fastImporter) blob() error {
+	mark, err := f.mark()
+	if err != nil {
+		return err
+	}
+	content, err := f.data()
+	if err != nil {
+		return err
+	}
+	id, err := f.repo.WriteObject(BlobObject, content)
+	if err != nil {
+		return err
+	}
+	f.setMark(mark, id)
+	f.result.Blobs++
+	return nil
+}
+
+// reset reads a reset command, which points a branch at a commit or makes
+// its next commit a root commit
+func (f *fastImporter) reset(ref string) error {
+	if err := checkRef(ref); err != nil {
+		return err
+	}
+	target := ""
+	if from, ok, err := f.optional("from"); err != nil {
+		return err
+	} else if ok && from != zeroHash {
+		if target, err = f.object(from); err != nil {
+			return err
+		}
+	}
+	f.refs[ref] = target
+	if line, err := f.readLine(); err == nil && line != "" {
+		f.unreadLine(line)
+	}
+	return nil
+}
+
+// commit reads a commit command and the file changes that follow it
+func (f *fastImporter) commit(ref string) error {
+	if err := checkRef(ref); err != nil {
+		return err
+	}
+	mark, err := f.mark()
+	if err != nil {
+		return err
+	}
+	var author, committer Signature
+	if arg, ok, err := f.optional("author"); err != nil {
+		return err
+	} else if ok {
+		if author, err = parseSignatureLine(arg); err != nil {
+			return err
+		}
+	}
+	arg, ok, err := f.optional("committer")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("commit to %s has no committer", ref)
+	}
+	if committer, err = parseSignatureLine(arg); err != nil {
+		return err
+	}
+	if author.IsZero() {
+		author = committer
+	}
+	if _, _, err := f.optional("encoding"); err != nil {
+		return err
+	}
+	message, err := f.data()
+	if err != nil {
+		return err
+	}
+
+	// The first parent is the from commit, or else the tip of the branch;
+	// its snapshot is the starting point of the file changes
+	var parents []string
+	from, hasFrom, err := f.optional("from")
+	if err != nil {
+		return err
+	}
+	switch {
+	case hasFrom && from != zeroHash:
+		parent, err := f.commitish(from)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, parent)
+	case !hasFrom:
+		tip, ok := f.refs[ref]
+		if !ok {
+			if tip, err = f.repo.readRef(ref); err != nil {
+				return err
+			}
+		}
+		if tip != "" {
+			if tip, err = f.commitish(tip); err != nil {
+				return err
+			}
+			parents = append(parents, tip)
+		}
+	}
+	for {
+		merge, ok, err := f.optional("merge")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		parent, err := f.commitish(merge)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, parent)
+	}
+
+	files := make(map[string]FileEntry)
+	if len(parents) > 0 {
+		parentFiles, err := f.repo.commitFiles(&f.metadata.CommitHistory[f.commits[parents[0]]])
+		if err != nil {
+			return fmt.Errorf("failed to read commit %s: %w", parents[0], err)
+		}
+		for _, file := range parentFiles {
+			files[file.Path] = file
+		}
+	}
+	if err := f.fileChanges(files); err != nil {
+		return err
+	}
+
+	snapshot := make([]FileEntry, 0, len(files))
+	for _, file := range files {
+		snapshot = append(snapshot, file)
+	}
+	tree, err := f.repo.WriteTree(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to write tree: %w", err)
+	}
+	id, err := f.repo.WriteObject(CommitObject, encodeCommit(tree, parents, author, committer, string(message)))
+	if err != nil {
+		return fmt.Errorf("failed to write commit: %w", err)
+	}
+	if _, ok := f.commits[id]; !ok {
+		f.commits[id] = len(f.metadata.CommitHistory)
+		f.metadata.CommitHistory = append(f.metadata.CommitHistory, CommitRecord{
+			ID:        id,
+			Tree:      tree,
+			Parents:   parents,
+			Message:   strings.TrimSuffix(string(message), "\n"),
+			Author:    author,
+			Committer: committer,
+			Time:      committer.When,
+		})
+		f.metadata.StagingArea = nil
+		f.result.Commits++
+	}
+	f.setMark(mark, id)
+	f.refs[ref] = id
+	return nil
+}
+
+// fileChanges applies the file changes of a commit to its snapshot, up to
+// the blank line or command that ends them
+func (f *fastImporter) fileChanges(files map[string]FileEntry) error {
+	for {
+		line, err := f.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			return nil
+		}
+
+		command, arg, _ := strings.Cut(line, " ")
+		switch command {
+		case "M":
+			err = f.modify(files, arg)
+		case "D":
+			var p string
+			if p, _, err = parsePath(arg, true); err == nil {
+				removePath(files, p)
+			}
+		case "C", "R":
+			var src, dst string
+			if src, arg, err = parsePath(arg, false); err != nil {
+				break
+			}
+			if dst, _, err = parsePath(arg, true); err != nil {
+				break
+			}
+			err = copyPath(files, src, dst, command == "R")
+		case "deleteall":
+			clear(files)
+		case "N":
+			err = errors.New("notes are not supported")
+		default:
+			f.unreadLine(line)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// modify applies "M <mode> <dataref> <path>", where dataref is a mark, an
+// object ID or "inline" followed by a data command
+func (f *fastImporter) modify(files map[string]FileEntry, arg string) error {
+	fields := strings.SplitN(arg, " ", 3)
+	if len(fields) != 3 {
+		return fmt.Errorf("malformed file change 'M %s'", arg)
+	}
+	mode, dataref := fields[0], fields[1]
+	switch mode {
+	case "644":
+		mode = ModeFile
+	case "755":
+		mode = ModeExecutable
+	case ModeFile, ModeExecutable, ModeSymlink, ModeGitlink:
+	default:
+		return fmt.Errorf("unsupported file mode %s", mode)
+	}
+	p, _, err := parsePath(fields[2], true)
+	if err != nil {
+		return err
+	}
+
+	var hash string
+	switch {
+	case dataref == "inline":
+		content, err := f.data()
+		if err != nil {
+			return err
+		}
+		if hash, err = f.repo.WriteObject(BlobObject, content); err != nil {
+			return err
+		}
+		f.result.Blobs++
+	case mode == ModeGitlink && isObjectID(dataref):
+		// Submodule commits live in another repository
+		hash = dataref
+	default:
+		if hash, err = f.object(dataref); err != nil {
+			return err
+		}
+	}
+	removePath(files, p)
+	files[p] = FileEntry{Path: p, Mode: mode, Hash: hash}
+	return nil
+}
+
+// tag reads a tag command, which creates an annotated tag object
+func (f *fastImporter) tag(name string) error {
+	ref := TagPrefix + name
+	if err := checkRef(ref); err != nil {
+		return err
+	}
+	mark, err := f.mark()
+	if err != nil {
+		return err
+	}
+	from, ok, err := f.optional("from")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("tag %s has no from command", name)
+	}
+	object, err := f.object(from)
+	if err != nil {
+		return err
+	}
+	objType := CommitObject
+	if _, ok := f.commits[object]; !ok {
+		if objType, _, err = f.repo.ReadObject(object); err != nil {
+			return err
+		}
+	}
+	if _, _, err := f.optional("original-oid"); err != nil {
+		return err
+	}
+	var tagger Signature
+	if arg, ok, err := f.optional("tagger"); err != nil {
+		return err
+	} else if ok {
+		if tagger, err = parseSignatureLine(arg); err != nil {
+			return err
+		}
+	}
+	message, err := f.data()
+	if err != nil {
+		return err
+	}
+
+	tag := &gitTag{Object: object, Type: objType, Name: name, Tagger: tagger, Message: string(message)}
+	id, err := f.repo.WriteObject(TagObject, encodeTag(tag))
+	if err != nil {
+		return err
+	}
+	f.setMark(mark, id)
+	f.refs[ref] = id
+	f.result.Tags++
+	return nil
+}
+
+// parsePath parses a path of a file change. A path in double quotes may
+// hold C escapes; otherwise it runs to the end of the line when last is
+// set, or up to the next space. The rest of the line is returned too.
+func parsePath(s string, last bool) (string, string, error) {
+	var p, rest string
+	if strings.HasPrefix(s, `"`) {
+		var err error
+		if p, rest, err = unquotePath(s); err != nil {
+			return "", "", err
+		}
+		rest = strings.TrimPrefix(rest, " ")
+	} else if last {
+		p = s
+	} else {
+		var ok bool
+		if p, rest, ok = strings.Cut(s, " "); !ok {
+			return "", "", fmt.Errorf("malformed file change: '%s' needs two paths", s)
+		}
+	}
+	if p == "" || strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") || strings.Contains(p, "//") {
+		return "", "", fmt.Errorf("invalid path '%s'", p)
+	}
+	for _, component := range strings.Split(p, "/") {
+		if !validPathComponent(component) {
+			return "", "", fmt.Errorf("invalid path '%s'", p)
+		}
+	}
+	return p, rest, nil
+}
+
+// unquotePath reads a path in double quotes written by quotePath and returns
+// it along with the rest of s
+func unquotePath(s string) (string, string, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), s[i+1:], nil
+		case c != '\\':
+			b.WriteByte(c)
+		case i+1 >= len(s):
+			return "", "", fmt.Errorf("unterminated path %s", s)
+		default:
+			i++
+			switch s[i] {
+			case 'a':
+				b.WriteByte('\a')
+			case 'b':
+				b.WriteByte('\b')
+			case 't':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'v':
+				b.WriteByte('\v')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteByte(s[i])
+			default:
+				n, err := strconv.ParseUint(s[i:min(i+3, len(s))], 8, 8)
+				if err != nil {
+					return "", "", fmt.Errorf("invalid escape in path %s", s)
+				}
+				b.WriteByte(byte(n))
+				i += 2
+			}
+		}
+	}
+	return "", "", fmt.Errorf("unterminated path %s", s)
+}
+
+// removePath deletes a file, or every file in a directory, from a snapshot,
+// along with files in the way of its parent directories
+func removePath(files map[string]FileEntry, p string) {
+	delete(files, p)
+	for name := range files {
+		if strings.HasPrefix(name, p+"/") {
+			delete(files, name)
+		}
+	}
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		delete(files, dir)
+	}
+}
+
+// copyPath copies or renames a file or a directory within a snapshot
+func copyPath(files map[string]FileEntry, src, dst string, rename bool) error {
+	moved := make(map[string]FileEntry)
+	for name, file := range files {
+		if name == src {
+			moved[dst] = file
+		} else if rest, ok := strings.CutPrefix(name, src+"/"); ok {
+			moved[dst+"/"+rest] = file
+		}
+	}
+	if len(moved) == 0 {
+		return fmt.Errorf("path not in the commit: %s", src)
+	}
+	if rename {
+		removePath(files, src)
+	}
+	removePath(files, dst)
+	for name, file := range moved {
+		file.Path = name
+		files[name] = file
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package commands_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// setupExportHistory creates two branches joined by a merge and a
+// lightweight tag, returning the repository
+func setupExportHistory(t *testing.T) *commands.Repository {
+	t.Helper()
+	setFixedIdentity(t)
+	ids := setupTwoCommits(t)
+	commands.CreateBranch("feature", ids[0])
+	commands.Switch("feature", commands.SwitchOptions{})
+	os.WriteFile(`say "hi".txt`, []byte("hi\n"), 0755)
+	commands.Add([]string{`say "hi".txt`})
+	commands.Commit("Feature commit")
+	commands.Switch("main", commands.SwitchOptions{})
+	commands.Remove([]string{"dir/new.txt"}, commands.RemoveOptions{})
+	commands.Commit("Remove new.txt")
+	if err := commands.Merge("feature", commands.MergeOptions{}); err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+	os.MkdirAll(filepath.Join(commands.MyGitDir, "refs", "tags"), 0755)
+	os.WriteFile(filepath.Join(commands.MyGitDir, "refs", "tags", "v1"), []byte(ids[1]+"\n"), 0644)
+
+	repo, err := commands.Open(".")
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	return repo
+}
+
+// newRepository creates an empty repository in a new directory and opens it
+func newRepository(t *testing.T) *commands.Repository {
+	t.Helper()
+	os.Chdir(t.TempDir())
+	commands.Init()
+	repo, err := commands.Open(".")
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	return repo
+}
+
+// refTargets reads the objects the given refs point at
+func refTargets(t *testing.T, refs ...string) []string {
+	t.Helper()
+	var targets []string
+	for _, ref := range refs {
+		data, _ := os.ReadFile(filepath.Join(commands.MyGitDir, filepath.FromSlash(ref)))
+		targets = append(targets, strings.TrimSpace(string(data)))
+	}
+	return targets
+}
+
+// TestFastExportImport tests that importing an exported stream rebuilds
+// the same commits, branches and tags
+func TestFastExportImport(t *testing.T) {
+	repo := setupExportHistory(t)
+	refs := []string{"refs/heads/main", "refs/heads/feature", "refs/tags/v1"}
+	expected := refTargets(t, refs...)
+
+	var stream bytes.Buffer
+	if err := repo.FastExport(&stream, nil); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if !strings.Contains(stream.String(), `M 100755 :`) || !strings.Contains(stream.String(), `"say \"hi\".txt"`) {
+		t.Errorf("expected a quoted executable path in the stream:\n%s", stream.String())
+	}
+	if !strings.Contains(stream.String(), "\nD dir/new.txt\n") {
+		t.Errorf("expected the deletion of dir/new.txt in the stream:\n%s", stream.String())
+	}
+
+	imported := newRepository(t)
+	result, err := imported.FastImport(bytes.NewReader(stream.Bytes()), commands.FastImportOptions{})
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if result.Commits != 5 || len(result.Refs) != 3 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if got := refTargets(t, refs...); strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected refs at %v, got %v", expected, got)
+	}
+
+	// Exporting only a branch leaves the others out
+	stream.Reset()
+	if err := imported.FastExport(&stream, []string{"feature"}); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if strings.Contains(stream.String(), "refs/heads/main") || strings.Count(stream.String(), "commit refs/heads/feature\n") != 2 {
+		t.Errorf("expected only the feature branch in the stream:\n%s", stream.String())
+	}
+	if err := imported.FastExport(&stream, []string{"unknown"}); err == nil {
+		t.Errorf("expected exporting an unknown branch to fail")
+	}
+}
+
+// TestFastImportStream tests the commands and file changes of a
+// handwritten stream
+func TestFastImportStream(t *testing.T) {
+	stream := `# Comments are skipped
+feature done
+blob
+mark :1
+data 6
+hello
+
+blob
+mark :2
+data <<EOF
+line 1
+line 2
+EOF
+commit refs/heads/main
+mark :3
+author Alice <alice@example.com> 1700000000 +0100
+committer Bob <bob@example.com> 1700000060 -0800
+data 15
+Initial commit
+
+M 100644 :1 hello.txt
+M 644 :2 "docs/a\tb.txt"
+M 755 inline bin/run
+data 10
+#!/bin/sh
+
+progress added files
+
+commit refs/heads/main
+mark :4
+committer Bob <bob@example.com> 1700000120 -0800
+data 7
+Rename
+R hello.txt greeting.txt
+C docs renamed
+D "docs/a\tb.txt"
+
+reset refs/heads/other
+from :3
+
+commit refs/heads/other
+committer Bob <bob@example.com> 1700000180 -0800
+data 6
+Clear
+deleteall
+M 100644 :1 only.txt
+
+tag v1
+from :4
+tagger Bob <bob@example.com> 1700000240 -0800
+data 8
+Release
+done
+`
+	repo := newRepository(t)
+	var progress bytes.Buffer
+	result, err := repo.FastImport(strings.NewReader(stream), commands.FastImportOptions{Progress: &progress})
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if result.Commits != 3 || result.Blobs != 3 || result.Tags != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if progress.String() != "progress added files\n" {
+		t.Errorf("unexpected progress %q", progress.String())
+	}
+
+	tests := []struct {
+		rev           string
+		expectedFiles string
+	}{
+		{rev: result.Marks[3], expectedFiles: "bin/run docs/a\tb.txt hello.txt"},
+		{rev: "main", expectedFiles: "bin/run greeting.txt renamed/a\tb.txt"},
+		{rev: "other", expectedFiles: "only.txt"},
+	}
+	for _, tt := range tests {
+		commit, err := repo.ResolveCommit(tt.rev)
+		if err != nil {
+			t.Fatalf("failed to resolve %s: %v", tt.rev, err)
+		}
+		files, _ := repo.FlattenTree(commit.Tree)
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		if got := strings.Join(paths, " "); got != tt.expectedFiles {
+			t.Errorf("expected %s to hold %q, got %q", tt.rev, tt.expectedFiles, got)
+		}
+	}
+
+	first, _ := repo.ResolveCommit(result.Marks[3])
+	if first.Author.String() != "Alice <alice@example.com>" || first.Committer.String() != "Bob <bob@example.com>" || first.Message != "Initial commit" {
+		t.Errorf("unexpected commit %+v", first)
+	}
+	second, _ := repo.ResolveCommit("main")
+	if second.Author != second.Committer || len(second.Parents) != 1 || second.Parents[0] != first.ID {
+		t.Errorf("expected the author to default to the committer and the branch tip to be the parent, got %+v", second)
+	}
+	if other, _ := repo.ResolveCommit("other"); other.Parents[0] != first.ID {
+		t.Errorf("expected the reset branch to continue from the first commit, got %v", other.Parents)
+	}
+	if objType, _, _ := repo.ReadObject(readTag(t, "v1")); objType != commands.TagObject {
+		t.Errorf("expected an annotated tag, got a %s", objType)
+	}
+}
+
+// TestFastImportErrors tests rejecting malformed streams and branch updates
+// that would lose commits
+func TestFastImportErrors(t *testing.T) {
+	commit := "commit refs/heads/main\ncommitter Bob <bob@example.com> 1700000000 +0000\ndata 4\nnew\n"
+	tests := []struct {
+		name   string
+		stream string
+		opts   commands.FastImportOptions
+	}{
+		{name: "unknown command", stream: "merge refs/heads/main\n"},
+		{name: "missing committer", stream: "commit refs/heads/main\ndata 4\nnew\n"},
+		{name: "unknown mark", stream: commit + "M 100644 :7 file.txt\n"},
+		{name: "invalid path", stream: commit + "M 100644 inline ../file.txt\ndata 0\n"},
+		{name: "truncated data", stream: "blob\ndata 100\nshort\n"},
+		{name: "unsupported ref", stream: "reset refs/notes/commits\n"},
+		{name: "unsupported feature", stream: "feature export-marks=marks.txt\n"},
+		{name: "missing done", stream: "feature done\n" + commit},
+		{name: "not a fast-forward", stream: "reset refs/heads/main\n" + commit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTwoCommits(t)
+			repo, err := commands.Open(".")
+			if err != nil {
+				t.Fatalf("failed to open repository: %v", err)
+			}
+			mainID := readBranch(t, "main")
+			if _, err := repo.FastImport(strings.NewReader(tt.stream), tt.opts); err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if got := readBranch(t, "main"); got != mainID {
+				t.Errorf("expected main to stay at %s, got %s", mainID, got)
+			}
+		})
+	}
+
+	// Forcing moves the branch to a new root commit
+	setupTwoCommits(t)
+	repo, _ := commands.Open(".")
+	if _, err := repo.FastImport(strings.NewReader("reset refs/heads/main\n"+commit), commands.FastImportOptions{Force: true}); err != nil {
+		t.Fatalf("failed to force the import: %v", err)
+	}
+	if head, _ := repo.ResolveCommit("main"); len(head.Parents) != 0 || head.Message != "new" {
+		t.Errorf("expected main to move to the imported root commit, got %+v", head)
+	}
+}
+
+// TestFastExportGit tests that Git imports the stream with the same commit
+// IDs
+func TestFastExportGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := setupExportHistory(t)
+	refs := []string{"refs/heads/main", "refs/heads/feature", "refs/tags/v1"}
+	expected := refTargets(t, refs...)
+	var stream bytes.Buffer
+	if err := repo.FastExport(&stream, nil); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	gitDir := t.TempDir()
+	gitIn(t, gitDir, "init", "-q", "--bare")
+	cmd := exec.Command("git", "fast-import", "--quiet")
+	cmd.Dir = gitDir
+	cmd.Stdin = &stream
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git fast-import failed: %v\n%s", err, out)
+	}
+	if got := gitIn(t, gitDir, append([]string{"rev-parse"}, refs...)...); got != strings.Join(expected, "\n") {
+		t.Errorf("expected refs at %v, got %s", expected, got)
+	}
+}
@@ -0,0 +1,382 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DotGitDir is the name of the directory holding a Git repository
+const DotGitDir = ".git"
+
+// unknownSignature stands in for the author and committer of commits made
+// before they were recorded, which Git commits cannot do without
+var unknownSignature = Signature{Name: "unknown", Email: "unknown"}
+
+// GitExportResult summarizes an export to a Git repository
+type GitExportResult struct {
+	// GitDir is the absolute path of the Git repository directory
+	GitDir string
+	// Commits maps the ID of every exported commit to its Git commit ID.
+	// They only differ for commits made before mygit wrote Git commit
+	// objects, and for the commits descending from them.
+	Commits map[string]string
+	// Objects is the number of objects written, including ones Git already had
+	Objects int
+	// Branches lists the exported branches
+	Branches []string
+	// Tags lists the exported tags
+	Tags []string
+}
+
+// Rewritten returns the number of commits whose Git commit ID differs from
+// their mygit commit ID
+func (g *GitExportResult) Rewritten() int {
+	n := 0
+	for id, gitID := range g.Commits {
+		if id != gitID {
+			n++
+		}
+	}
+	return n
+}
+
+// ExportGit writes the history as a Git repository in gitDir, which defaults
+// to .git at the root of the working tree
+func ExportGit(gitDir string) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	if gitDir == "" {
+		gitDir = filepath.Join(r.workTree, DotGitDir)
+	}
+
+	result, err := r.ExportGit(gitDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d commits, %d branches and %d tags to %s\n", len(result.Commits), len(result.Branches), len(result.Tags), displayPath(result.GitDir))
+	if n := result.Rewritten(); n > 0 {
+		fmt.Printf("%d commits made by older versions of mygit have new IDs in Git\n", n)
+	}
+	return nil
+}
+
+// ExportGit writes every commit of the history, the trees and blobs they
+// reference, the branches, the tags and HEAD into the Git repository directory gitDir
+// as loose objects and refs, so Git can read the history. The repository is
+// created when gitDir does not exist; in an existing one, objects are added
+// and the exported branches, tags and HEAD are overwritten.
+func (r *Repository) ExportGit(gitDir string) (*GitExportResult, error) {
+	gitDir = absPath(r.cwd, gitDir)
+	if realPath(gitDir) == r.gitDir {
+		return nil, errors.New("cannot export into the mygit repository directory")
+	}
+
+	unlock, err := r.lock(HeadFile, MetadataFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	if err := initGitRepository(gitDir); err != nil {
+		return nil, err
+	}
+
+	e := &gitExporter{
+		repo:       r,
+		metadata:   metadata,
+		objectsDir: filepath.Join(gitDir, ObjectsDir),
+		written:    make(map[string]bool),
+		result:     &GitExportResult{GitDir: gitDir, Commits: make(map[string]string)},
+	}
+	for i := range metadata.CommitHistory {
+		if _, err := e.exportCommit(&metadata.CommitHistory[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Point the branches and HEAD at the Git commits
+	branches, err := r.listBranches()
+	if err != nil {
+		return nil, err
+	}
+	for _, branch := range branches {
+		commitID, err := r.readRef(BranchPrefix + branch)
+		if err != nil {
+			return nil, err
+		}
+		gitID, ok := e.result.Commits[commitID]
+		if !ok {
+			return nil, fmt.Errorf("branch '%s' points to unknown commit %s", branch, commitID)
+		}
+		if err := writeGitFile(gitDir, BranchPrefix+branch, gitID+"\n"); err != nil {
+			return nil, err
+		}
+		e.result.Branches = append(e.result.Branches, branch)
+	}
+
+	tags, err := r.listTags()
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		target, err := r.readRef(TagPrefix + tag)
+		if err != nil {
+			return nil, err
+		}
+		gitID, err := e.exportTarget(target)
+		if err != nil {
+			return nil, fmt.Errorf("cannot export tag '%s': %w", tag, err)
+		}
+		if err := writeGitFile(gitDir, TagPrefix+tag, gitID+"\n"); err != nil {
+			return nil, err
+		}
+		e.result.Tags = append(e.result.Tags, tag)
+	}
+
+	ref, headID, err := r.readHead()
+	if err != nil {
+		return nil, err
+	}
+	head := symbolicRefPrefix + ref + "\n"
+	if ref == "" {
+		gitID, ok := e.result.Commits[headID]
+		if !ok {
+			return nil, fmt.Errorf("HEAD points to unknown commit %s", headID)
+		}
+		head = gitID + "\n"
+	}
+	if err := writeGitFile(gitDir, HeadFile, head); err != nil {
+		return nil, err
+	}
+
+	return e.result, nil
+}
+
+// initGitRepository creates the skeleton of a Git repository directory,
+// unless gitDir already holds one
+func initGitRepository(gitDir string) error {
+	if _, err := os.Stat(filepath.Join(gitDir, HeadFile)); err == nil {
+		if info, err := os.Stat(filepath.Join(gitDir, ObjectsDir)); err == nil && info.IsDir() {
+			return nil
+		}
+	}
+	if entries, err := os.ReadDir(gitDir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("'%s' exists and is not a Git repository", gitDir)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", gitDir, err)
+	}
+
+	for _, dir := range []string{ObjectsDir, BranchPrefix, TagPrefix} {
+		if err := os.MkdirAll(filepath.Join(gitDir, filepath.FromSlash(dir)), 0755); err != nil {
+			return fmt.Errorf("failed to create Git repository: %w", err)
+		}
+	}
+	// A .git directory belongs to a working tree; anywhere else the
+	// repository is bare
+	bare := filepath.Base(gitDir) != DotGitDir
+	config := fmt.Sprintf("[core]\n\trepositoryformatversion = 0\n\tfilemode = true\n\tbare = %t\n", bare)
+	if err := writeGitFile(gitDir, ConfigFile, config); err != nil {
+		return err
+	}
+	if !bare {
+		// Keep Git from listing the mygit repository as untracked
+		if err := writeGitFile(gitDir, "info/exclude", "/"+MyGitDir+"/\n"); err != nil {
+			return err
+		}
+	}
+	return writeGitFile(gitDir, HeadFile, symbolicRefPrefix+BranchPrefix+DefaultBranch+"\n")
+}
+
+// writeGitFile writes a file such as a ref into a Git repository directory
+func writeGitFile(gitDir, name, content string) error {
+	filePath := filepath.Join(gitDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", name, err)
+	}
+	if err := writeFileAtomic(filePath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// gitExporter copies objects from a mygit repository into a Git repository
+type gitExporter struct {
+	repo       *Repository
+	metadata   *metadata
+	objectsDir string
+	// written records the objects already copied
+	written map[string]bool
+	result  *GitExportResult
+}
+
+// exportCommit writes a commit and everything it references, parents
+// first, and returns its Git commit ID. Commits stored as Git commit objects
+// are copied as they are; older commits are converted, which changes their
+// IDs and those of their descendants.
+func (e *gitExporter) exportCommit(commit *CommitRecord) (string, error) {
+	if gitID, ok := e.result.Commits[commit.ID]; ok {
+		return gitID, nil
+	}
+
+	parents := make([]string, len(commit.Parents))
+	rewritten := false
+	for i, parentID := range commit.Parents {
+		parent := e.metadata.find(parentID)
+		if parent == nil {
+			return "", fmt.Errorf("commit %s has unknown parent %s", commit.ID, parentID)
+		}
+		gitID, err := e.exportCommit(parent)
+		if err != nil {
+			return "", err
+		}
+		parents[i] = gitID
+		rewritten = rewritten || gitID != parentID
+	}
+
+	tree := commit.Tree
+	if tree == "" {
+		// Commits made before tree objects existed only list their files
+		var err error
+		if tree, err = e.repo.WriteTree(commit.files); err != nil {
+			return "", fmt.Errorf("failed to write tree of commit %s: %w", commit.ID, err)
+		}
+		rewritten = true
+	}
+	if err := e.copyTree(tree); err != nil {
+		return "", err
+	}
+
+	if !rewritten {
+		objType, data, err := e.repo.ReadObject(commit.ID)
+		switch {
+		case err == nil && objType == CommitObject:
+			if err := e.writeObject(commit.ID, CommitObject, data); err != nil {
+				return "", err
+			}
+			e.result.Commits[commit.ID] = commit.ID
+			return commit.ID, nil
+		case err != nil && !errors.Is(err, ErrObjectNotFound):
+			return "", err
+		}
+	}
+
+	// Convert a commit made before commits were stored as Git objects
+	author, committer := commit.gitSignatures()
+	data := encodeCommit(tree, parents, author, committer, objectMessage(commit.Message))
+	gitID := HashObject(CommitObject, data)
+	if err := e.writeObject(gitID, CommitObject, data); err != nil {
+		return "", err
+	}
+	e.result.Commits[commit.ID] = gitID
+	return gitID, nil
+}
+
+// exportTarget returns the Git object ID of what a tag points at: a
+// commit, or an annotated tag object, which is copied as it is unless the
+// commit it tags got a new ID
+func (e *gitExporter) exportTarget(id string) (string, error) {
+	if gitID, ok := e.result.Commits[id]; ok {
+		return gitID, nil
+	}
+	objType, data, err := e.repo.ReadObject(id)
+	if err != nil {
+		return "", err
+	}
+	if objType != TagObject {
+		return "", fmt.Errorf("%s is not a commit in the history", id)
+	}
+	tag, err := decodeTag(data)
+	if err != nil {
+		return "", fmt.Errorf("tag %s: %w", id, err)
+	}
+	object, err := e.exportTarget(tag.Object)
+	if err != nil {
+		return "", err
+	}
+	if object != tag.Object {
+		tag.Object = object
+		data = encodeTag(tag)
+	}
+	gitID := HashObject(TagObject, data)
+	if err := e.writeObject(gitID, TagObject, data); err != nil {
+		return "", err
+	}
+	return gitID, nil
+}
+
+// gitSignatures returns the author and committer of a commit as Git needs
+// them: commits made before they were recorded get unknownSignature and the
+// commit time
+func (c *CommitRecord) gitSignatures() (Signature, Signature) {
+	author, committer := c.Author, c.Committer
+	if author.IsZero() {
+		author = unknownSignature
+	}
+	if committer.IsZero() {
+		committer = unknownSignature
+	}
+	if author.When.IsZero() {
+		author.When = c.Time
+	}
+	if committer.When.IsZero() {
+		committer.When = c.Time
+	}
+	return author, committer
+}
+
+// copyTree copies a tree and everything in it
+func (e *gitExporter) copyTree(hash string) error {
+	if e.written[hash] {
+		return nil
+	}
+	objType, data, err := e.repo.ReadObject(hash)
+	if err == nil && objType != TreeObject {
+		err = fmt.Errorf("object %s is a %s, not a tree", hash, objType)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read tree %s: %w", hash, err)
+	}
+	entries, err := decodeTree(data)
+	if err != nil {
+		return fmt.Errorf("failed to read tree %s: %w", hash, err)
+	}
+	for _, entry := range entries {
+		if entry.Mode == ModeDir {
+			if err := e.copyTree(entry.Hash); err != nil {
+				return err
+			}
+			continue
+		}
+		if entry.Mode == ModeGitlink || e.written[entry.Hash] {
+			continue
+		}
+		objType, data, err := e.repo.ReadObject(entry.Hash)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.Name, err)
+		}
+		if err := e.writeObject(entry.Hash, objType, data); err != nil {
+			return err
+		}
+	}
+	return e.writeObject(hash, TreeObject, data)
+}
+
+// writeObject stores an object in the Git repository
+func (e *gitExporter) writeObject(hash, objType string, data []byte) error {
+	if _, err := writeLooseObject(e.objectsDir, objType, data); err != nil {
+		return err
+	}
+	e.written[hash] = true
+	e.result.Objects++
+	return nil
+}
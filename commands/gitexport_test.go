@@ -0,0 +1,188 @@
+package commands_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// setFixedIdentity makes new commits reproducible by fixing their author,
+// committer and dates
+func setFixedIdentity(t *testing.T) {
+	t.Helper()
+	for _, env := range []string{commands.AuthorNameEnv, commands.CommitterNameEnv} {
+		t.Setenv(env, "Alice")
+	}
+	for _, env := range []string{commands.AuthorEmailEnv, commands.CommitterEmailEnv} {
+		t.Setenv(env, "alice@example.com")
+	}
+	for _, env := range []string{commands.AuthorDateEnv, commands.CommitterDateEnv} {
+		t.Setenv(env, "1700000000 +0100")
+	}
+}
+
+// runGit runs git against a Git repository directory and returns its output,
+// skipping the test when git is not installed
+func runGit(t *testing.T, gitDir string, args ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	cmd := exec.Command("git", append([]string{"--git-dir", gitDir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// TestGitObjectIDs tests that blobs, trees and commits get the object IDs
+// Git computes for the same content
+func TestGitObjectIDs(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+	setFixedIdentity(t)
+	os.WriteFile("hello.txt", []byte("hello\n"), 0644)
+	os.Mkdir("src", 0755)
+	os.WriteFile(filepath.Join("src", "main.go"), []byte("x\n"), 0755)
+	commands.Add([]string{"hello.txt", "src"})
+
+	repo, err := commands.Open(".")
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	commit, err := repo.Commit("Initial commit")
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	// Expected IDs are those of "git commit" with the same files, identity and dates
+	expected := map[string]string{
+		"blob":   "ce013625030ba8dba906f756967f9e9ca394464a",
+		"commit": "de5836be3a4e62d0ddc99628ee830ced2404e703",
+	}
+	idx, _ := repo.ReadIndex()
+	if entry, _ := idx.Entry("hello.txt"); entry.Hash != expected["blob"] {
+		t.Errorf("expected blob %s, got %s", expected["blob"], entry.Hash)
+	}
+	if commit.ID != expected["commit"] {
+		t.Errorf("expected commit %s, got %s", expected["commit"], commit.ID)
+	}
+	objType, _, err := repo.ReadObject(commit.ID)
+	if err != nil || objType != commands.CommitObject {
+		t.Errorf("expected the commit to be stored as a commit object, got %s, %v", objType, err)
+	}
+}
+
+// TestExportGit tests that Git reads exported history with the same object IDs
+func TestExportGit(t *testing.T) {
+	setFixedIdentity(t)
+	ids := setupTwoCommits(t)
+	commands.CreateBranch("feature", ids[0])
+	commands.Switch("feature", commands.SwitchOptions{})
+	os.WriteFile("other.txt", []byte("other\n"), 0644)
+	commands.Add([]string{"other.txt"})
+	commands.Commit("Feature commit")
+	commands.Switch("main", commands.SwitchOptions{})
+	if err := commands.Merge("feature", commands.MergeOptions{}); err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+	main := readBranch(t, "main")
+	feature := readBranch(t, "feature")
+
+	if err := commands.ExportGit(""); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	gitDir := commands.DotGitDir
+
+	runGit(t, gitDir, "fsck", "--strict", "--no-dangling")
+	if got := runGit(t, gitDir, "rev-parse", "refs/heads/main", "refs/heads/feature"); got != main+"\n"+feature {
+		t.Errorf("expected branches at %s and %s, got %s", main, feature, got)
+	}
+	if got := runGit(t, gitDir, "symbolic-ref", "HEAD"); got != "refs/heads/main" {
+		t.Errorf("expected HEAD to point at main, got %s", got)
+	}
+	if got := runGit(t, gitDir, "log", "--format=%s|%an <%ae>|%ad", "--date=raw", "-1", "feature"); got != "Feature commit|Alice <alice@example.com>|1700000000 +0100" {
+		t.Errorf("unexpected feature commit %s", got)
+	}
+	if got := runGit(t, gitDir, "rev-list", "--count", "main"); got != "4" {
+		t.Errorf("expected 4 commits on main, got %s", got)
+	}
+	if got := runGit(t, gitDir, "cat-file", "-p", "main:dir/new.txt"); got != "new" {
+		t.Errorf("unexpected content of dir/new.txt: %s", got)
+	}
+
+	// The working tree is ignored by mygit, and the repository by Git
+	if result, err := commands.GetStatus(); err != nil || len(result.Untracked) != 0 {
+		t.Errorf("expected .git not to be untracked, got %+v, %v", result, err)
+	}
+	if got := runGit(t, gitDir, "check-ignore", commands.MyGitDir); got != commands.MyGitDir {
+		t.Errorf("expected Git to ignore %s, got %s", commands.MyGitDir, got)
+	}
+
+	// Exporting again only updates the branches
+	os.WriteFile("file.txt", []byte("version 3\n"), 0644)
+	commands.Add([]string{"file.txt"})
+	commands.Commit("Third commit")
+	if err := commands.ExportGit(""); err != nil {
+		t.Fatalf("failed to export again: %v", err)
+	}
+	if got := runGit(t, gitDir, "rev-parse", "main"); got != readBranch(t, "main") {
+		t.Errorf("expected main to move to %s, got %s", readBranch(t, "main"), got)
+	}
+}
+
+// TestExportGitLegacy tests exporting commits made before commits were Git
+// objects, which get new IDs
+func TestExportGitLegacy(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+	blob, _ := commands.WriteObject(commands.BlobObject, []byte("old\n"))
+	legacy := `{"commit_history": [
+		{"commit_id": "abc", "commit_message": "Old", "commit_timestamp": "2021-01-01 09:30:00", "files": [{"file_path": "old.txt", "file_hash": "` + blob + `"}]},
+		{"commit_id": "def", "commit_message": "Older", "commit_timestamp": "2021-01-02 09:30:00", "parent_commit_id": "abc", "files": [{"file_path": "old.txt", "file_hash": "` + blob + `"}]}
+	]}`
+	os.WriteFile(filepath.Join(commands.MyGitDir, commands.MetadataFile), []byte(legacy), 0644)
+	os.WriteFile(filepath.Join(commands.MyGitDir, "refs", "heads", "main"), []byte("def\n"), 0644)
+
+	repo, err := commands.Open(".")
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	gitDir := filepath.Join(tempDir, "export.git")
+	result, err := repo.ExportGit(gitDir)
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if result.Rewritten() != 2 {
+		t.Errorf("expected 2 rewritten commits, got %d", result.Rewritten())
+	}
+
+	runGit(t, gitDir, "fsck", "--strict", "--no-dangling")
+	if got := runGit(t, gitDir, "rev-parse", "main"); got != result.Commits["def"] {
+		t.Errorf("expected main at %s, got %s", result.Commits["def"], got)
+	}
+	if got := runGit(t, gitDir, "log", "--format=%s|%an", "main"); got != "Older|unknown\nOld|unknown" {
+		t.Errorf("unexpected history %s", got)
+	}
+}
+
+// TestExportGitErrors tests refusing to export over other directories
+func TestExportGitErrors(t *testing.T) {
+	setupTwoCommits(t)
+	os.Mkdir("notgit", 0755)
+	os.WriteFile(filepath.Join("notgit", "file.txt"), nil, 0644)
+
+	if err := commands.ExportGit("notgit"); err == nil {
+		t.Errorf("expected exporting into a non-empty directory to fail")
+	}
+	if err := commands.ExportGit(commands.MyGitDir); err == nil {
+		t.Errorf("expected exporting into the mygit repository to fail")
+	}
+}
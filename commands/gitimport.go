@@ -0,0 +1,471 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PackedRefsFile lists refs of a Git repository that are not stored as files
+const PackedRefsFile = "packed-refs"
+
+// GitImportResult summarizes an import from a Git repository
+type GitImportResult struct {
+	// GitDir is the absolute path of the Git repository directory
+	GitDir string
+	// Commits is the number of commits added to the history
+	Commits int
+	// Objects is the number of objects copied into the object database
+	Objects int
+	// Branches and Tags list the imported branches and tags
+	Branches []string
+	Tags     []string
+	// Skipped describes the refs that could not be imported
+	Skipped []string
+	// Head is the branch HEAD was attached to when importing into a
+	// repository without commits, or empty
+	Head string
+}
+
+// ImportGit copies the history of the Git repository directory gitDir, or
+// of the Git working tree containing it, into the repository
+func ImportGit(gitDir string) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	result, err := r.ImportGit(gitDir)
+	if err != nil {
+		return err
+	}
+	for _, skipped := range result.Skipped {
+		fmt.Printf("Skipped %s\n", skipped)
+	}
+	fmt.Printf("Imported %d commits, %d branches and %d tags from %s\n", result.Commits, len(result.Branches), len(result.Tags), displayPath(result.GitDir))
+	if result.Head != "" {
+		fmt.Printf("HEAD is now on branch '%s'\n", result.Head)
+	}
+	return nil
+}
+
+// ImportGit reads the branches and tags of a Git repository, from loose
+// objects and packfiles, and adds the commits they reach to the history
+// along with their trees and blobs. Commits keep their IDs, authors, dates
+// and messages, so importing again only adds the new commits. Branches and
+// tags are created, or moved when the imported commit descends from the one
+// they point at; annotated tags keep their tag objects.
+//
+// When the repository has no commits yet, HEAD is attached to the branch
+// Git's HEAD points at and the index is filled from it, so the Git working
+// tree shows no changes.
+func (r *Repository) ImportGit(gitDir string) (*GitImportResult, error) {
+	gitDir = absPath(r.cwd, gitDir)
+	if info, err := os.Stat(filepath.Join(gitDir, DotGitDir)); err == nil && info.IsDir() {
+		gitDir = filepath.Join(gitDir, DotGitDir)
+	}
+	if realPath(gitDir) == r.gitDir {
+		return nil, errors.New("cannot import the mygit repository into itself")
+	}
+	if !isGitRepository(gitDir) {
+		return nil, fmt.Errorf("'%s' is not a Git repository", gitDir)
+	}
+
+	unlock, err := r.lock(IndexFile, HeadFile, MetadataFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := readGitRefs(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := openGitObjects(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	defer objects.close()
+
+	imp := &gitImporter{
+		repo:     r,
+		objects:  objects,
+		metadata: metadata,
+		known:    make(map[string]bool),
+		copied:   make(map[string]bool),
+		result:   &GitImportResult{GitDir: gitDir},
+	}
+	for _, commit := range metadata.CommitHistory {
+		imp.known[commit.ID] = true
+	}
+	empty := len(metadata.CommitHistory) == 0
+
+	// Import the commits of every branch and tag, leaving the refs for
+	// after the history is saved
+	names := make([]string, 0, len(refs))
+	for ref := range refs {
+		names = append(names, ref)
+	}
+	sort.Strings(names)
+	updates := make(map[string]string)
+	for _, ref := range names {
+		name := strings.TrimPrefix(strings.TrimPrefix(ref, BranchPrefix), TagPrefix)
+		if err := checkBranchName(name); err != nil {
+			imp.skip(ref, "the name is not valid in mygit")
+			continue
+		}
+		target, ok, err := imp.importRef(ref, refs[ref])
+		if err != nil {
+			return nil, fmt.Errorf("failed to import %s: %w", ref, err)
+		}
+		if !ok {
+			continue
+		}
+
+		// Existing refs are only moved forward
+		commitID, err := imp.peel(target)
+		if err != nil {
+			return nil, err
+		}
+		existing, err := r.readRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		if existing != "" && existing != target {
+			if strings.HasPrefix(ref, TagPrefix) {
+				return nil, fmt.Errorf("tag '%s' already exists and points elsewhere", name)
+			}
+			if !metadata.isAncestor(existing, commitID) {
+				return nil, fmt.Errorf("branch '%s' already exists and the imported one does not descend from it", name)
+			}
+		}
+		updates[ref] = target
+		if strings.HasPrefix(ref, TagPrefix) {
+			imp.result.Tags = append(imp.result.Tags, name)
+		} else {
+			imp.result.Branches = append(imp.result.Branches, name)
+		}
+	}
+
+	if imp.result.Commits > 0 {
+		if err := r.writeMetadata(metadata); err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range names {
+		if target, ok := updates[ref]; ok {
+			if err := r.writeRef(ref, target); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if empty {
+		if err := imp.checkoutGitHead(gitDir, updates); err != nil {
+			return nil, err
+		}
+	}
+	return imp.result, nil
+}
+
+// isGitRepository reports whether dir looks like a Git repository directory
+func isGitRepository(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, HeadFile)); err != nil {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(dir, ObjectsDir))
+	return err == nil && info.IsDir()
+}
+
+// readGitRefs returns the branches and tags of a Git repository and the
+// objects they point at. Refs stored as files take precedence over the same
+// refs in packed-refs.
+func readGitRefs(gitDir string) (map[string]string, error) {
+	refs := make(map[string]string)
+	isImported := func(ref string) bool {
+		return strings.HasPrefix(ref, BranchPrefix) || strings.HasPrefix(ref, TagPrefix)
+	}
+
+	file, err := os.Open(filepath.Join(gitDir, PackedRefsFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", PackedRefsFile, err)
+	}
+	if err == nil {
+		defer file.Close()
+		// Lines are "<object ID> <ref>"; comments start with "#" and the
+		// commit a tag peels to follows it as "^<object ID>"
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			id, ref, ok := strings.Cut(scanner.Text(), " ")
+			if ok && isObjectID(id) && isImported(ref) {
+				refs[ref] = id
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", PackedRefsFile, err)
+		}
+	}
+
+	for _, prefix := range []string{BranchPrefix, TagPrefix} {
+		dir := filepath.Join(gitDir, filepath.FromSlash(prefix))
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(gitDir, p)
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			// Symbolic refs are left out
+			if id := strings.TrimSpace(string(data)); isObjectID(id) {
+				refs[filepath.ToSlash(rel)] = id
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read Git refs: %w", err)
+		}
+	}
+	return refs, nil
+}
+
+// gitImporter copies commits and the objects they reference from a Git
+// repository into a mygit repository
+type gitImporter struct {
+	repo     *Repository
+	objects  *gitObjects
+	metadata *metadata
+	// known records the commits in the history and copied the other
+	// objects already in the object database
+	known  map[string]bool
+	copied map[string]bool
+	result *GitImportResult
+}
+
+// skip records a ref that is not imported
+func (imp *gitImporter) skip(ref, reason string) {
+	imp.result.Skipped = append(imp.result.Skipped, fmt.Sprintf("%s: %s", ref, reason))
+}
+
+// importRef imports the commits a branch or tag reaches and returns the
+// object the ref should point at in mygit. It returns false for refs that
+// cannot be imported, such as tags of trees.
+func (imp *gitImporter) importRef(ref, id string) (string, bool, error) {
+	objType, data, err := imp.objects.read(id)
+	if err != nil {
+		return "", false, err
+	}
+
+	// Copy the chain of annotated tags down to the commit they tag
+	target := id
+	for objType == TagObject && strings.HasPrefix(ref, TagPrefix) {
+		if _, err := imp.repo.WriteObject(TagObject, data); err != nil {
+			return "", false, err
+		}
+		imp.result.Objects++
+		tag, err := decodeTag(data)
+		if err != nil {
+			return "", false, fmt.Errorf("tag %s: %w", id, err)
+		}
+		id, objType = tag.Object, tag.Type
+		if objType == CommitObject {
+			break
+		}
+		if objType, data, err = imp.objects.read(id); err != nil {
+			return "", false, err
+		}
+	}
+	if objType != CommitObject {
+		imp.skip(ref, fmt.Sprintf("points to a %s, not a commit", objType))
+		return "", false, nil
+	}
+	if err := imp.importCommits(id); err != nil {
+		return "", false, err
+	}
+	return target, true, nil
+}
+
+// peel returns the commit an imported ref target resolves to, following
+// annotated tags stored in the object database
+func (imp *gitImporter) peel(id string) (string, error) {
+	for !imp.known[id] {
+		objType, data, err := imp.repo.ReadObject(id)
+		if err != nil {
+			return "", err
+		}
+		if objType != TagObject {
+			return "", fmt.Errorf("object %s is a %s, not a commit", id, objType)
+		}
+		tag, err := decodeTag(data)
+		if err != nil {
+			return "", err
+		}
+		id = tag.Object
+	}
+	return id, nil
+}
+
+// pendingCommit is a commit whose parents are imported before it
+type pendingCommit struct {
+	commit *gitCommit
+	data   []byte
+}
+
+// importCommits adds a commit and its ancestors to the history, parents
+// first. The history is walked with an explicit stack, since it can be far
+// deeper than the call stack should grow.
+func (imp *gitImporter) importCommits(tip string) error {
+	pending := make(map[string]*pendingCommit)
+	stack := []string{tip}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		if imp.known[id] {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		p, ok := pending[id]
+		if !ok {
+			// First visit: import the parents, which are now above it
+			objType, data, err := imp.objects.read(id)
+			if err == nil && objType != CommitObject {
+				err = fmt.Errorf("object %s is a %s, not a commit", id, objType)
+			}
+			if errors.Is(err, ErrObjectNotFound) {
+				err = fmt.Errorf("%w; shallow and partial clones cannot be imported", err)
+			}
+			if err != nil {
+				return err
+			}
+			commit, err := decodeCommit(data)
+			if err != nil {
+				return fmt.Errorf("commit %s: %w", id, err)
+			}
+			pending[id] = &pendingCommit{commit: commit, data: data}
+			for i := len(commit.Parents) - 1; i >= 0; i-- {
+				if !imp.known[commit.Parents[i]] {
+					stack = append(stack, commit.Parents[i])
+				}
+			}
+			continue
+		}
+
+		stack = stack[:len(stack)-1]
+		if err := imp.copyTree(p.commit.Tree); err != nil {
+			return fmt.Errorf("commit %s: %w", id, err)
+		}
+		// The commit object is copied as it is, so its ID stays the same
+		if _, err := imp.repo.WriteObject(CommitObject, p.data); err != nil {
+			return err
+		}
+		imp.result.Objects++
+		imp.metadata.CommitHistory = append(imp.metadata.CommitHistory, CommitRecord{
+			ID:        id,
+			Tree:      p.commit.Tree,
+			Parents:   p.commit.Parents,
+			Message:   strings.TrimSuffix(p.commit.Message, "\n"),
+			Author:    p.commit.Author,
+			Committer: p.commit.Committer,
+			Time:      p.commit.Committer.When,
+		})
+		imp.known[id] = true
+		delete(pending, id)
+		imp.result.Commits++
+	}
+	return nil
+}
+
+// copyTree copies a tree and everything in it that the object database
+// lacks. Trees are written after their entries, so a tree that is already
+// stored is complete.
+func (imp *gitImporter) copyTree(hash string) error {
+	if imp.copied[hash] || imp.repo.hasObject(hash) {
+		return nil
+	}
+	objType, data, err := imp.objects.read(hash)
+	if err == nil && objType != TreeObject {
+		err = fmt.Errorf("object %s is a %s, not a tree", hash, objType)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read tree %s: %w", hash, err)
+	}
+	entries, err := decodeTree(data)
+	if err != nil {
+		return fmt.Errorf("failed to read tree %s: %w", hash, err)
+	}
+	for _, entry := range entries {
+		switch {
+		case entry.Mode == ModeDir:
+			if err := imp.copyTree(entry.Hash); err != nil {
+				return err
+			}
+		case entry.Mode == ModeGitlink, imp.copied[entry.Hash], imp.repo.hasObject(entry.Hash):
+		default:
+			objType, data, err := imp.objects.read(entry.Hash)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", entry.Name, err)
+			}
+			if err := imp.write(entry.Hash, objType, data); err != nil {
+				return err
+			}
+		}
+	}
+	return imp.write(hash, TreeObject, data)
+}
+
+// write stores a copied object
+func (imp *gitImporter) write(hash, objType string, data []byte) error {
+	if _, err := imp.repo.WriteObject(objType, data); err != nil {
+		return err
+	}
+	imp.copied[hash] = true
+	imp.result.Objects++
+	return nil
+}
+
+// checkoutGitHead attaches HEAD to the imported branch Git's HEAD points at
+// and fills the index from it, leaving the working tree alone
+func (imp *gitImporter) checkoutGitHead(gitDir string, updates map[string]string) error {
+	data, err := os.ReadFile(filepath.Join(gitDir, HeadFile))
+	if err != nil {
+		return fmt.Errorf("failed to read Git HEAD: %w", err)
+	}
+	ref, ok := strings.CutPrefix(strings.TrimSpace(string(data)), symbolicRefPrefix)
+	if !ok || !strings.HasPrefix(ref, BranchPrefix) || updates[ref] == "" {
+		return nil
+	}
+
+	branch := strings.TrimPrefix(ref, BranchPrefix)
+	if err := imp.repo.attachHead(branch); err != nil {
+		return err
+	}
+	commit := imp.metadata.find(updates[ref])
+	files, err := imp.repo.commitFiles(commit)
+	if err != nil {
+		return err
+	}
+	idx, err := imp.repo.ReadIndex()
+	if err != nil {
+		return err
+	}
+	resetIndex(idx, files)
+	if err := idx.Write(); err != nil {
+		return err
+	}
+	imp.result.Head = branch
+	return nil
+}
@@ -0,0 +1,442 @@
+package commands_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// gitIn runs git in a working tree and returns its output, skipping the
+// test when git is not installed
+func gitIn(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// setupGitHistory creates a Git repository with two branches, a merge and
+// two tags, and returns its working tree. Most objects are packed with
+// deltas by the given git command, "repack" by default; the last commit is
+// left as loose objects.
+func setupGitHistory(t *testing.T, repack ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(dir, "gitconfig"))
+	t.Setenv("GIT_AUTHOR_NAME", "Alice")
+	t.Setenv("GIT_AUTHOR_EMAIL", "alice@example.com")
+	t.Setenv("GIT_AUTHOR_DATE", "1700000000 +0100")
+	t.Setenv("GIT_COMMITTER_NAME", "Bob")
+	t.Setenv("GIT_COMMITTER_EMAIL", "bob@example.com")
+	t.Setenv("GIT_COMMITTER_DATE", "1700003600 -0800")
+
+	gitIn(t, dir, "init", "-q", "-b", "main")
+	// A long file changed a little in every commit gets stored as deltas
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	commit := func(message string, change int) {
+		lines[change] = "changed " + message
+		os.WriteFile(filepath.Join(dir, "long.txt"), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+		gitIn(t, dir, "add", "-A")
+		gitIn(t, dir, "commit", "-q", "-m", message)
+	}
+	commit("First commit", 0)
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "run.sh"), []byte("#!/bin/sh\n"), 0755)
+	commit("Second commit\n\nWith a body.", 100)
+	gitIn(t, dir, "tag", "v1")
+	gitIn(t, dir, "switch", "-q", "-c", "feature")
+	commit("Feature commit", 199)
+	gitIn(t, dir, "switch", "-q", "main")
+	commit("Third commit", 50)
+	gitIn(t, dir, "merge", "-q", "--no-edit", "feature")
+	gitIn(t, dir, "tag", "-a", "-m", "Release 2", "v2")
+
+	if repack == nil {
+		repack = []string{"repack", "-a", "-d", "-q"}
+	}
+	gitIn(t, dir, repack...)
+	gitIn(t, dir, "pack-refs", "--all")
+	commit("Loose commit", 150)
+	return dir
+}
+
+// TestImportGit tests importing branches, tags and commits from packed and
+// loose Git objects
+func TestImportGit(t *testing.T) {
+	tests := []struct {
+		name   string
+		repack []string
+	}{
+		{name: "offset deltas", repack: []string{"repack", "-a", "-d", "-q"}},
+		// Deltas name their base by object ID instead of its offset
+		{name: "ref deltas", repack: []string{"-c", "repack.useDeltaBaseOffset=false", "repack", "-a", "-d", "-f", "-q"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitDir := setupGitHistory(t, tt.repack...)
+			if out := gitIn(t, gitDir, "count-objects", "-v"); !strings.Contains(out, "packs: 1") {
+				t.Fatalf("expected the history to be packed, got %s", out)
+			}
+
+			tempDir := t.TempDir()
+			os.Chdir(tempDir)
+			commands.Init()
+			repo, err := commands.Open(".")
+			if err != nil {
+				t.Fatalf("failed to open repository: %v", err)
+			}
+			result, err := repo.ImportGit(gitDir)
+			if err != nil {
+				t.Fatalf("failed to import: %v", err)
+			}
+			if result.Commits != 6 || len(result.Branches) != 2 || len(result.Tags) != 2 || result.Head != "main" {
+				t.Errorf("unexpected result %+v", result)
+			}
+
+			// Branches and tags point at the same objects as in Git
+			for _, ref := range []string{"refs/heads/main", "refs/heads/feature", "refs/tags/v1", "refs/tags/v2"} {
+				expected := gitIn(t, gitDir, "rev-parse", ref)
+				data, _ := os.ReadFile(filepath.Join(commands.MyGitDir, filepath.FromSlash(ref)))
+				if got := strings.TrimSpace(string(data)); got != expected {
+					t.Errorf("expected %s at %s, got %s", ref, expected, got)
+				}
+			}
+			if objType, _, err := repo.ReadObject(readTag(t, "v2")); err != nil || objType != commands.TagObject {
+				t.Errorf("expected v2 to keep its tag object, got %s, %v", objType, err)
+			}
+
+			// Commits keep their IDs, authors, dates and messages
+			history, err := repo.Log()
+			if err != nil {
+				t.Fatalf("failed to read the history: %v", err)
+			}
+			if len(history) != 5 {
+				t.Fatalf("expected 5 commits on the first-parent history, got %d", len(history))
+			}
+			feature, err := repo.ResolveCommit("feature")
+			if err != nil {
+				t.Fatalf("failed to resolve feature: %v", err)
+			}
+			for _, commit := range append(history, *feature) {
+				expected := gitIn(t, gitDir, "log", "-1", "--format=%an <%ae> %ad|%cn <%ce> %cd|%B", "--date=raw", commit.ID)
+				got := fmt.Sprintf("%s %s|%s %s|%s", commit.Author, commands.FormatDate(commit.Author.When, commands.DateRaw),
+					commit.Committer, commands.FormatDate(commit.Committer.When, commands.DateRaw), commit.Message)
+				if got != expected {
+					t.Errorf("expected commit %s to be\n%s\ngot\n%s", commit.ID, expected, got)
+				}
+			}
+			if len(history[1].Parents) != 2 {
+				t.Errorf("expected the merge commit to have two parents, got %v", history[1].Parents)
+			}
+
+			// The files can be checked out
+			if err := commands.Reset("", commands.ResetOptions{Mode: commands.ResetHard}); err != nil {
+				t.Fatalf("failed to check out the imported history: %v", err)
+			}
+			for _, name := range []string{"long.txt", filepath.Join("src", "run.sh")} {
+				expected, _ := os.ReadFile(filepath.Join(gitDir, name))
+				if got, _ := os.ReadFile(name); string(got) != string(expected) {
+					t.Errorf("expected %s to match Git's", name)
+				}
+			}
+
+			// Importing again only adds new commits
+			gitIn(t, gitDir, "commit", "-q", "--allow-empty", "-m", "New commit")
+			result, err = repo.ImportGit(gitDir)
+			if err != nil {
+				t.Fatalf("failed to import again: %v", err)
+			}
+			if result.Commits != 1 || result.Head != "" || readBranch(t, "main") != gitIn(t, gitDir, "rev-parse", "main") {
+				t.Errorf("expected main to move to the new commit, got %+v", result)
+			}
+		})
+	}
+}
+
+// readTag returns the object a tag points at
+func readTag(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(commands.MyGitDir, "refs", "tags", name))
+	if err != nil {
+		t.Fatalf("failed to read tag %s: %v", name, err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// TestImportGitWorkTree tests importing the Git repository of the working
+// tree, which leaves no changes to commit
+func TestImportGitWorkTree(t *testing.T) {
+	gitDir := setupGitHistory(t)
+	os.Chdir(gitDir)
+	commands.Init()
+	if err := commands.ImportGit("."); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+
+	result, err := commands.GetStatus()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if len(result.Staged) != 0 || len(result.Unstaged) != 0 || len(result.Untracked) != 0 {
+		t.Errorf("expected a clean working tree, got %+v", result)
+	}
+}
+
+// TestImportGitSubmodule tests that a submodule imported from Git stays in
+// the index and in later commits, while its directory is left alone
+func TestImportGitSubmodule(t *testing.T) {
+	gitDir := setupGitHistory(t)
+	subCommit := gitIn(t, gitDir, "rev-parse", "HEAD~1")
+	gitIn(t, gitDir, "update-index", "--add", "--cacheinfo", "160000,"+subCommit+",lib/sub")
+	gitIn(t, gitDir, "commit", "-q", "-m", "Add submodule")
+	// An uninitialised submodule is an empty directory
+	os.MkdirAll(filepath.Join(gitDir, "lib", "sub"), 0755)
+	os.Chdir(gitDir)
+	commands.Init()
+	if err := commands.ImportGit("."); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+
+	idx, err := commands.ReadIndex()
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
+	}
+	if entry, ok := idx.Entry("lib/sub"); !ok || entry.Mode != commands.ModeGitlink || entry.Hash != subCommit {
+		t.Errorf("expected lib/sub to be a submodule at %s, got %+v", subCommit, entry)
+	}
+	result, err := commands.GetStatus()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if !result.Clean() {
+		t.Errorf("expected a clean working tree, got %+v", result)
+	}
+
+	// Neither adding everything nor cleaning touches the submodule
+	os.WriteFile("long.txt", []byte("rewritten\n"), 0644)
+	if err := commands.AddWithOptions(nil, commands.AddOptions{All: true}); err != nil {
+		t.Fatalf("failed to add: %v", err)
+	}
+	if err := commands.Commit("Rewrite long.txt"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if err := commands.Clean(commands.CleanOptions{Force: true, Directories: true}); err != nil {
+		t.Fatalf("failed to clean: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join("lib", "sub")); err != nil {
+		t.Errorf("expected the submodule directory to be kept: %v", err)
+	}
+	repo, err := commands.Open(".")
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	head, err := repo.ResolveCommit("HEAD")
+	if err != nil {
+		t.Fatalf("failed to resolve HEAD: %v", err)
+	}
+	files, err := repo.FlattenTree(head.Tree)
+	if err != nil {
+		t.Fatalf("failed to read tree: %v", err)
+	}
+	found := false
+	for _, file := range files {
+		if file.Path == "lib/sub" {
+			found = file.Mode == commands.ModeGitlink && file.Hash == subCommit
+		}
+	}
+	if !found {
+		t.Errorf("expected the commit to keep lib/sub at %s, got %+v", subCommit, files)
+	}
+}
+
+// TestImportGitSymlink tests that symbolic links imported from Git are
+// checked out as links and stay links through later commits and exports
+func TestImportGitSymlink(t *testing.T) {
+	gitDir := setupGitHistory(t)
+	if err := os.Symlink("src/run.sh", filepath.Join(gitDir, "run")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	os.Symlink("src", filepath.Join(gitDir, "scripts"))
+	gitIn(t, gitDir, "add", "run", "scripts")
+	gitIn(t, gitDir, "commit", "-q", "-m", "Add links")
+
+	os.Chdir(t.TempDir())
+	commands.Init()
+	if err := commands.ImportGit(gitDir); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if err := commands.Restore([]string{"."}, commands.RestoreOptions{}); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	for link, target := range map[string]string{"run": "src/run.sh", "scripts": "src"} {
+		if got, err := os.Readlink(link); err != nil || got != target {
+			t.Errorf("expected %s to link to %s, got %q (%v)", link, target, got, err)
+		}
+	}
+	result, err := commands.GetStatus()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if !result.Clean() {
+		t.Errorf("expected a clean working tree, got %+v", result)
+	}
+
+	os.WriteFile("long.txt", []byte("rewritten\n"), 0644)
+	commands.AddWithOptions(nil, commands.AddOptions{All: true})
+	if err := commands.Commit("Rewrite long.txt"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	exportDir := filepath.Join(t.TempDir(), "export.git")
+	if err := commands.ExportGit(exportDir); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if got := runGit(t, exportDir, "ls-tree", "HEAD", "run", "scripts"); got != gitIn(t, gitDir, "ls-tree", "HEAD", "run", "scripts") {
+		t.Errorf("expected the links to be exported as links, got\n%s", got)
+	}
+}
+
+// TestImportGitUnsafeNames tests refusing trees whose entry names would
+// lead out of the working tree or into a repository directory
+func TestImportGitUnsafeNames(t *testing.T) {
+	for _, name := range []string{"..", ".", commands.MyGitDir, ".git"} {
+		t.Run(name, func(t *testing.T) {
+			gitDir := setupGitHistory(t)
+			// git mktree writes names as they are given
+			mktree := func(entry string) string {
+				cmd := exec.Command("git", "mktree")
+				cmd.Dir = gitDir
+				cmd.Stdin = strings.NewReader(entry + "\n")
+				out, err := cmd.Output()
+				if err != nil {
+					t.Fatalf("failed to make tree: %v", err)
+				}
+				return strings.TrimSpace(string(out))
+			}
+			blob := gitIn(t, gitDir, "hash-object", "-w", "long.txt")
+			tree := mktree("040000 tree " + mktree("100644 blob "+blob+"\tpwned") + "\t" + name)
+			commit := gitIn(t, gitDir, "commit-tree", "-p", "HEAD", "-m", "Unsafe", tree)
+			gitIn(t, gitDir, "update-ref", "refs/heads/main", commit)
+
+			os.Chdir(t.TempDir())
+			commands.Init()
+			if err := commands.ImportGit(gitDir); err == nil || !strings.Contains(err.Error(), "invalid tree entry name") {
+				t.Errorf("expected an invalid name error, got %v", err)
+			}
+			if _, err := os.Stat(filepath.Join("..", "pwned")); !os.IsNotExist(err) {
+				t.Errorf("expected no file outside the working tree, got %v", err)
+			}
+		})
+	}
+}
+
+// writeDeltaPack adds a packfile to a Git repository holding a single
+// REF_DELTA object against a loose blob, whose delta claims resultSize, and
+// a pack index in the original format with the given fanout table. The
+// branch "corrupt" points at the object.
+func writeDeltaPack(t *testing.T, gitDir string, fanout [256]uint32, resultSize uint64) {
+	t.Helper()
+	os.WriteFile(filepath.Join(gitDir, "base.txt"), []byte("base\n"), 0644)
+	base, _ := hex.DecodeString(gitIn(t, gitDir, "hash-object", "-w", "base.txt"))
+	delta := binary.AppendUvarint(nil, 5)
+	delta = binary.AppendUvarint(delta, resultSize)
+	delta = append(delta, 1, 'x')
+
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	binary.Write(&pack, binary.BigEndian, [2]uint32{2, 1})
+	pack.WriteByte(7<<4 | byte(len(delta)))
+	pack.Write(base)
+	zw := zlib.NewWriter(&pack)
+	zw.Write(delta)
+	zw.Close()
+	sum := sha1.Sum(pack.Bytes())
+	pack.Write(sum[:])
+
+	id := bytes.Repeat([]byte{0}, 20)
+	var index bytes.Buffer
+	binary.Write(&index, binary.BigEndian, fanout)
+	binary.Write(&index, binary.BigEndian, uint32(12))
+	index.Write(id)
+
+	packDir := filepath.Join(gitDir, ".git", "objects", "pack")
+	os.WriteFile(filepath.Join(packDir, "pack-corrupt.pack"), pack.Bytes(), 0644)
+	os.WriteFile(filepath.Join(packDir, "pack-corrupt.idx"), index.Bytes(), 0644)
+	os.WriteFile(filepath.Join(gitDir, ".git", "refs", "heads", "corrupt"), []byte(hex.EncodeToString(id)+"\n"), 0644)
+}
+
+// TestImportGitCorruptPack tests that a corrupt pack index or delta is
+// reported as an error instead of crashing or exhausting memory
+func TestImportGitCorruptPack(t *testing.T) {
+	valid := [256]uint32{}
+	for i := range valid {
+		valid[i] = 1
+	}
+	unsorted := valid
+	unsorted[0] = 2
+
+	tests := []struct {
+		name       string
+		fanout     [256]uint32
+		resultSize uint64
+		expected   string
+	}{
+		{name: "unsorted fanout table", fanout: unsorted, resultSize: 1, expected: "corrupt fanout table"},
+		{name: "huge delta result", fanout: valid, resultSize: 1 << 62, expected: "delta result has the wrong size"},
+		{name: "delta longer than its result", fanout: valid, resultSize: 0, expected: "delta result exceeds its size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitDir := setupGitHistory(t)
+			writeDeltaPack(t, gitDir, tt.fanout, tt.resultSize)
+
+			os.Chdir(t.TempDir())
+			commands.Init()
+			if err := commands.ImportGit(gitDir); err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected an error about %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+// TestImportGitErrors tests refusing imports that would lose history
+func TestImportGitErrors(t *testing.T) {
+	gitDir := setupGitHistory(t)
+	setupTwoCommits(t)
+
+	if err := commands.ImportGit(t.TempDir()); err == nil {
+		t.Errorf("expected importing a directory without a Git repository to fail")
+	}
+	if err := commands.ImportGit(commands.MyGitDir); err == nil {
+		t.Errorf("expected importing the repository into itself to fail")
+	}
+
+	// The main branch of the Git repository does not descend from ours
+	mainID := readBranch(t, "main")
+	if err := commands.ImportGit(gitDir); err == nil {
+		t.Errorf("expected importing a diverged branch to fail")
+	}
+	if got := readBranch(t, "main"); got != mainID {
+		t.Errorf("expected main to stay at %s, got %s", mainID, got)
+	}
+}
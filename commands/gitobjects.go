@@ -0,0 +1,429 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Object types numbered as in Git packfiles
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+// packObjectTypes maps the packfile types of whole objects to their names
+var packObjectTypes = map[int]string{
+	packCommit: CommitObject,
+	packTree:   TreeObject,
+	packBlob:   BlobObject,
+	packTag:    TagObject,
+}
+
+// maxDeltaChain bounds how many deltas an object can be built from, which
+// stops cycles in damaged packs
+const maxDeltaChain = 10000
+
+// gitObjects reads the object database of a Git repository: loose objects
+// and the packfiles under objects/pack
+type gitObjects struct {
+	dir   string
+	packs []*gitPack
+}
+
+// openGitObjects opens the object database of the Git repository directory
+// gitDir. Call close when done.
+func openGitObjects(gitDir string) (*gitObjects, error) {
+	g := &gitObjects{dir: filepath.Join(gitDir, ObjectsDir)}
+	indexes, err := filepath.Glob(filepath.Join(g.dir, "pack", "pack-*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(indexes)
+	for _, indexPath := range indexes {
+		pack, err := openGitPack(strings.TrimSuffix(indexPath, ".idx"))
+		if err != nil {
+			g.close()
+			return nil, err
+		}
+		pack.objects = g
+		g.packs = append(g.packs, pack)
+	}
+	return g, nil
+}
+
+// close closes the packfiles
+func (g *gitObjects) close() {
+	for _, pack := range g.packs {
+		pack.file.Close()
+	}
+}
+
+// read returns the type and content of an object, which is looked for
+// among the loose objects first and then in every packfile
+func (g *gitObjects) read(hash string) (string, []byte, error) {
+	if !isObjectID(hash) {
+		return "", nil, fmt.Errorf("invalid object ID: %q", hash)
+	}
+	objType, data, err := readLooseObject(filepath.Join(g.dir, hash[:2], hash[2:]), hash)
+	if !errors.Is(err, ErrObjectNotFound) {
+		return objType, data, err
+	}
+
+	raw, _ := hex.DecodeString(hash)
+	for _, pack := range g.packs {
+		offset, ok := pack.find(raw)
+		if !ok {
+			continue
+		}
+		objType, data, err := pack.read(offset, 0)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read object %s from %s: %w", hash, filepath.Base(pack.file.Name()), err)
+		}
+		if HashObject(objType, data) != hash {
+			return "", nil, fmt.Errorf("object %s is corrupt: hash mismatch", hash)
+		}
+		return objType, data, nil
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrObjectNotFound, hash)
+}
+
+// gitPack is a packfile and its index
+type gitPack struct {
+	file *os.File
+	size int64
+	// objects resolves the bases of REF_DELTA objects, which may be stored
+	// anywhere in the repository
+	objects *gitObjects
+
+	// fanout[b] is the number of objects whose ID starts with a byte <= b
+	fanout [256]uint32
+	// ids holds the sorted 20-byte object IDs and offsets their positions
+	// in the packfile
+	ids     []byte
+	offsets []int64
+
+	// bases caches objects recently used as delta bases, by offset
+	bases map[int64]packedObject
+}
+
+// packedObject is an object read from a packfile
+type packedObject struct {
+	objType string
+	data    []byte
+}
+
+// maxCachedBases bounds the number of delta bases a pack keeps in memory
+const maxCachedBases = 256
+
+// openGitPack opens the packfile base+".pack" and reads its index
+// base+".idx", which may be in the current version 2 or the original format
+func openGitPack(base string) (*gitPack, error) {
+	index, err := os.ReadFile(base + ".idx")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pack index: %w", err)
+	}
+	p := &gitPack{bases: make(map[int64]packedObject)}
+	if err := p.readIndex(index); err != nil {
+		return nil, fmt.Errorf("pack index %s is corrupt: %w", filepath.Base(base)+".idx", err)
+	}
+
+	file, err := os.Open(base + ".pack")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open packfile: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open packfile: %w", err)
+	}
+	header := make([]byte, 12)
+	if _, err := file.ReadAt(header, 0); err != nil || string(header[:4]) != "PACK" {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a packfile", file.Name())
+	}
+	if version := binary.BigEndian.Uint32(header[4:]); version != 2 && version != 3 {
+		file.Close()
+		return nil, fmt.Errorf("packfile %s has unsupported version %d", file.Name(), version)
+	}
+	p.file, p.size = file, info.Size()
+	return p, nil
+}
+
+// readIndex parses a pack index
+func (p *gitPack) readIndex(index []byte) error {
+	version2 := bytes.HasPrefix(index, []byte("\xfftOc"))
+	fanoutStart := 0
+	if version2 {
+		if len(index) < 8 || binary.BigEndian.Uint32(index[4:]) != 2 {
+			return errors.New("unsupported version")
+		}
+		fanoutStart = 8
+	}
+	if len(index) < fanoutStart+256*4 {
+		return errors.New("truncated fanout table")
+	}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(index[fanoutStart+i*4:])
+		// Each count includes the objects of the smaller first bytes
+		if i > 0 && p.fanout[i] < p.fanout[i-1] {
+			return errors.New("corrupt fanout table")
+		}
+	}
+	n := int(p.fanout[255])
+	table := index[fanoutStart+256*4:]
+
+	if !version2 {
+		// Each entry is a 4-byte offset followed by the object ID
+		if len(table) < n*24 {
+			return errors.New("truncated object table")
+		}
+		p.ids = make([]byte, 0, n*20)
+		p.offsets = make([]int64, n)
+		for i := 0; i < n; i++ {
+			entry := table[i*24:]
+			p.offsets[i] = int64(binary.BigEndian.Uint32(entry))
+			p.ids = append(p.ids, entry[4:24]...)
+		}
+		return nil
+	}
+
+	// Object IDs, CRC32s and 4-byte offsets follow each other; offsets
+	// with the high bit set index a table of 8-byte offsets
+	if len(table) < n*28 {
+		return errors.New("truncated object table")
+	}
+	p.ids = table[:n*20]
+	small := table[n*24 : n*28]
+	large := table[n*28:]
+	p.offsets = make([]int64, n)
+	for i := 0; i < n; i++ {
+		offset := binary.BigEndian.Uint32(small[i*4:])
+		if offset&0x80000000 == 0 {
+			p.offsets[i] = int64(offset)
+			continue
+		}
+		j := int(offset & 0x7fffffff)
+		if len(large) < (j+1)*8 {
+			return errors.New("truncated large offset table")
+		}
+		p.offsets[i] = int64(binary.BigEndian.Uint64(large[j*8:]))
+	}
+	return nil
+}
+
+// find returns the offset of an object in the packfile
+func (p *gitPack) find(id []byte) (int64, bool) {
+	lo := 0
+	if id[0] > 0 {
+		lo = int(p.fanout[id[0]-1])
+	}
+	hi := int(p.fanout[id[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.ids[(lo+i)*20:(lo+i+1)*20], id) >= 0
+	})
+	if i < hi && bytes.Equal(p.ids[i*20:(i+1)*20], id) {
+		return p.offsets[i], true
+	}
+	return 0, false
+}
+
+// read returns the object at offset, applying deltas to their bases. depth
+// counts the deltas already followed.
+func (p *gitPack) read(offset int64, depth int) (string, []byte, error) {
+	if cached, ok := p.bases[offset]; ok {
+		return cached.objType, cached.data, nil
+	}
+	if depth > maxDeltaChain {
+		return "", nil, errors.New("delta chain is too long")
+	}
+	if offset < 12 || offset >= p.size {
+		return "", nil, fmt.Errorf("invalid object offset %d", offset)
+	}
+	r := bufio.NewReader(io.NewSectionReader(p.file, offset, p.size-offset))
+
+	// The header holds the type in bits 4-6 of the first byte and the
+	// inflated size in little-endian groups of 7 bits
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", nil, err
+	}
+	typeNum := int(c>>4) & 7
+	size := uint64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return "", nil, err
+		}
+		size |= uint64(c&0x7f) << shift
+	}
+
+	var baseType string
+	var base []byte
+	switch typeNum {
+	case packOfsDelta:
+		// The base is at a negative offset encoded big-endian in groups of
+		// 7 bits, each continuation adding one
+		if c, err = r.ReadByte(); err != nil {
+			return "", nil, err
+		}
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return "", nil, err
+			}
+			distance = (distance+1)<<7 | int64(c&0x7f)
+		}
+		if distance <= 0 || distance > offset {
+			return "", nil, fmt.Errorf("invalid delta base offset at %d", offset)
+		}
+		if baseType, base, err = p.read(offset-distance, depth+1); err != nil {
+			return "", nil, err
+		}
+		p.cacheBase(offset-distance, baseType, base)
+	case packRefDelta:
+		id := make([]byte, 20)
+		if _, err := io.ReadFull(r, id); err != nil {
+			return "", nil, err
+		}
+		if baseOffset, ok := p.find(id); ok {
+			if baseType, base, err = p.read(baseOffset, depth+1); err != nil {
+				return "", nil, err
+			}
+			p.cacheBase(baseOffset, baseType, base)
+		} else if baseType, base, err = p.objects.read(hex.EncodeToString(id)); err != nil {
+			return "", nil, fmt.Errorf("failed to read delta base: %w", err)
+		}
+	default:
+		if _, ok := packObjectTypes[typeNum]; !ok {
+			return "", nil, fmt.Errorf("unknown object type %d at offset %d", typeNum, offset)
+		}
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return "", nil, fmt.Errorf("object at offset %d is corrupt: %w", offset, err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, fmt.Errorf("object at offset %d is corrupt: %w", offset, err)
+	}
+	if uint64(len(data)) != size {
+		return "", nil, fmt.Errorf("object at offset %d is corrupt: size mismatch", offset)
+	}
+
+	if typeNum != packOfsDelta && typeNum != packRefDelta {
+		return packObjectTypes[typeNum], data, nil
+	}
+	result, err := applyDelta(base, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("object at offset %d is corrupt: %w", offset, err)
+	}
+	return baseType, result, nil
+}
+
+// cacheBase keeps a delta base in memory, since objects deltified against
+// the same base tend to be read one after another
+func (p *gitPack) cacheBase(offset int64, objType string, data []byte) {
+	if len(p.bases) >= maxCachedBases {
+		p.bases = make(map[int64]packedObject)
+	}
+	p.bases[offset] = packedObject{objType: objType, data: data}
+}
+
+// applyDelta rebuilds an object from its delta base and a delta: the sizes
+// of the base and the result, followed by instructions that either copy a
+// range of the base or insert new bytes
+func applyDelta(base, delta []byte) ([]byte, error) {
+	readSize := func() (uint64, error) {
+		size, n := binary.Uvarint(delta)
+		if n <= 0 {
+			return 0, errors.New("malformed delta header")
+		}
+		delta = delta[n:]
+		return size, nil
+	}
+	baseSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != uint64(len(base)) {
+		return nil, errors.New("delta does not match its base")
+	}
+	resultSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	// A corrupt delta may claim any size, so the result grows only as its
+	// instructions fill it, and never past the size it claims
+	result := make([]byte, 0, min(resultSize, uint64(len(base)+len(delta))))
+	grow := func(n uint64) error {
+		if uint64(len(result))+n > resultSize {
+			return errors.New("delta result exceeds its size")
+		}
+		return nil
+	}
+	for len(delta) > 0 {
+		cmd := delta[0]
+		delta = delta[1:]
+		switch {
+		case cmd&0x80 != 0:
+			// Bits 0-3 select the bytes of the offset and bits 4-6 those
+			// of the size that follow, least significant first
+			var offset, size uint64
+			for i := 0; i < 7; i++ {
+				if cmd&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errors.New("truncated delta")
+				}
+				if i < 4 {
+					offset |= uint64(delta[0]) << (8 * i)
+				} else {
+					size |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, errors.New("delta copies past the end of its base")
+			}
+			if err := grow(size); err != nil {
+				return nil, err
+			}
+			result = append(result, base[offset:offset+size]...)
+		case cmd != 0:
+			if int(cmd) > len(delta) {
+				return nil, errors.New("truncated delta")
+			}
+			if err := grow(uint64(cmd)); err != nil {
+				return nil, err
+			}
+			result = append(result, delta[:cmd]...)
+			delta = delta[cmd:]
+		default:
+			return nil, errors.New("invalid delta instruction")
+		}
+	}
+	if uint64(len(result)) != resultSize {
+		return nil, errors.New("delta result has the wrong size")
+	}
+	return result, nil
+}
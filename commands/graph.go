@@ -0,0 +1,196 @@
+package commands
+
+import "strings"
+
+// logGraph draws the history as ASCII art to the left of log output, like
+// git log --graph. Each column is a line of history leading to the commit it
+// names. A nil graph draws nothing.
+type logGraph struct {
+	// columns holds the commit each line of history leads to
+	columns []string
+	// pending holds the lines drawn after the last commit that have not been
+	// printed yet, such as a merge opening a column
+	pending []string
+	// width is what the lines of the last commit are padded to, so that its
+	// text stays aligned
+	width int
+	// previous draws the columns as they were before the last commit
+	previous string
+}
+
+// commit adds a commit with its parents in the graph and returns the prefix
+// of its first line
+func (g *logGraph) commit(id string, parents []string) string {
+	if g == nil {
+		return ""
+	}
+	idx := -1
+	for i, column := range g.columns {
+		if column == id {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		// A commit no line leads to, such as a second tip, starts a column
+		g.columns = append(g.columns, id)
+		idx = len(g.columns) - 1
+	}
+	g.previous = g.state()
+	cells := make([]string, len(g.columns))
+	for i := range cells {
+		cells[i] = "|"
+	}
+	cells[idx] = "*"
+	line := strings.Join(cells, " ")
+
+	g.pending = nil
+	if len(parents) == 2 && idx+1 < len(g.columns) && g.columns[idx+1] == parents[1] {
+		// A second parent the next column leads to already joins it
+		// directly: "|\|"
+		joined := []byte(g.previous)
+		joined[2*idx+1] = '\\'
+		g.pending = append(g.pending, string(joined))
+		parents = parents[:1]
+	}
+	// The column of the commit continues to its first parent, and further
+	// parents open columns to its right
+	columns := append([]string{}, g.columns[:idx]...)
+	columns = append(columns, parents...)
+	columns = append(columns, g.columns[idx+1:]...)
+	if len(parents) == 0 && idx < len(g.columns)-1 {
+		g.pending = append(g.pending, shiftLine(len(g.columns), idx))
+	}
+	for i := 1; i < len(parents); i++ {
+		g.pending = append(g.pending, openLine(len(g.columns)+i-1, idx+i-1))
+	}
+	// Lines leading to the same commit join
+	for {
+		i, j := duplicateColumn(columns)
+		if j < 0 {
+			break
+		}
+		g.pending = append(g.pending, joinLine(len(columns), i, j))
+		columns = append(columns[:j], columns[j+1:]...)
+	}
+	g.columns = columns
+
+	g.width = len(line)
+	for _, pending := range g.pending {
+		g.width = max(g.width, len(pending))
+	}
+	g.width = max(g.width, len(g.state())) + 1
+	return g.pad(line)
+}
+
+// next returns the prefix of the next line of the last commit
+func (g *logGraph) next() string {
+	if g == nil {
+		return ""
+	}
+	if len(g.pending) > 0 {
+		line := g.pending[0]
+		g.pending = g.pending[1:]
+		return g.pad(line)
+	}
+	return g.pad(g.state())
+}
+
+// flush returns the lines of the last commit that have not been printed,
+// which go on lines of their own
+func (g *logGraph) flush() []string {
+	if g == nil {
+		return nil
+	}
+	var lines []string
+	for len(g.pending) > 0 {
+		lines = append(lines, g.next())
+	}
+	return lines
+}
+
+// indent returns the width of the prefixes of the last commit
+func (g *logGraph) indent() int {
+	if g == nil {
+		return 0
+	}
+	return g.width
+}
+
+// separator returns the prefix of the blank line before the last commit,
+// which shows the columns leading to it
+func (g *logGraph) separator() string {
+	if g == nil {
+		return ""
+	}
+	return g.pad(g.previous)
+}
+
+// state draws the columns continuing straight down
+func (g *logGraph) state() string {
+	return strings.TrimSuffix(strings.Repeat("| ", len(g.columns)), " ")
+}
+
+func (g *logGraph) pad(line string) string {
+	if len(line) >= g.width {
+		return line
+	}
+	return line + strings.Repeat(" ", g.width-len(line))
+}
+
+// openLine draws a column opening to the right of column at, out of n,
+// pushing the columns after it to the right: "|\ \"
+func openLine(n, at int) string {
+	line := []byte(strings.Repeat(" ", 2*n+1))
+	for k := 0; k <= at; k++ {
+		line[2*k] = '|'
+	}
+	line[2*at+1] = '\\'
+	for k := at + 1; k < n; k++ {
+		line[2*k+1] = '\\'
+	}
+	return strings.TrimRight(string(line), " ")
+}
+
+// shiftLine draws column at, out of n, ending, and the columns after it
+// moving left: "| /"
+func shiftLine(n, at int) string {
+	line := []byte(strings.Repeat(" ", 2*n))
+	for k := 0; k < at; k++ {
+		line[2*k] = '|'
+	}
+	for k := at + 1; k < n; k++ {
+		line[2*k-1] = '/'
+	}
+	return strings.TrimRight(string(line), " ")
+}
+
+// joinLine draws column j, out of n, joining column i to its left, and the
+// columns after it moving left: "|/" or "|_|/"
+func joinLine(n, i, j int) string {
+	line := []byte(strings.Repeat(" ", 2*n))
+	for k := 0; k < j; k++ {
+		line[2*k] = '|'
+		if k > i {
+			line[2*k-1] = '_'
+		}
+	}
+	line[2*j-1] = '/'
+	for k := j + 1; k < n; k++ {
+		line[2*k-1] = '/'
+	}
+	return strings.TrimRight(string(line), " ")
+}
+
+// duplicateColumn returns the first column j leading to the same commit as
+// an earlier column i, or -1 for both
+func duplicateColumn(columns []string) (int, int) {
+	first := make(map[string]int)
+	for j, column := range columns {
+		if i, ok := first[column]; ok {
+			return i, j
+		}
+		first[column] = j
+	}
+	return -1, -1
+}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+const (
+	// AuthorNameEnv and AuthorEmailEnv override user.name and user.email for
+	// the author of new commits
+	AuthorNameEnv  = "MYGIT_AUTHOR_NAME"
+	AuthorEmailEnv = "MYGIT_AUTHOR_EMAIL"
+	// CommitterNameEnv and CommitterEmailEnv override user.name and
+	// user.email for the committer of new commits
+	CommitterNameEnv  = "MYGIT_COMMITTER_NAME"
+	CommitterEmailEnv = "MYGIT_COMMITTER_EMAIL"
+)
+
+// Signature identifies the author or the committer of a commit and when
+// they made it
+type Signature struct {
+	Name  string
+	Email string
+	// When is the date, in the time zone it was recorded in
+	When time.Time
+}
+
+// String formats the signature as "Name <email>"
+func (s Signature) String() string {
+	return fmt.Sprintf("%s <%s>", s.Name, s.Email)
+}
+
+// IsZero reports whether the identity is unknown, as in commits made before
+// authors were recorded
+func (s Signature) IsZero() bool {
+	return s.Name == "" && s.Email == ""
+}
+
+// ParseSignature parses an identity written as "Name <email>"
+func ParseSignature(text string) (Signature, error) {
+	open := strings.LastIndex(text, "<")
+	if open < 0 || !strings.HasSuffix(text, ">") {
+		return Signature{}, fmt.Errorf("invalid identity '%s': expected 'Name <email>'", text)
+	}
+	sig := Signature{
+		Name:  strings.TrimSpace(text[:open]),
+		Email: strings.TrimSpace(text[open+1 : len(text)-1]),
+	}
+	if err := sig.validate(); err != nil {
+		return Signature{}, err
+	}
+	return sig, nil
+}
+
+// validate checks that the signature can be recorded in a commit
+func (s Signature) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("invalid identity '%s': the name is empty", s)
+	}
+	if strings.ContainsAny(s.Name+s.Email, "<>\n") {
+		return fmt.Errorf("invalid identity '%s': names and emails cannot contain '<', '>' or newlines", s)
+	}
+	return nil
+}
+
+// identity returns who makes a new commit as its author or its committer.
+// The given environment variables override user.name and user.email, which
+// default to the login name and an address at the local host.
+func (r *Repository) identity(nameEnv, emailEnv string) (Signature, error) {
+	config, err := r.Config()
+	if err != nil {
+		return Signature{}, err
+	}
+	var sig Signature
+	sig.Name, _ = config.Get("user.name")
+	sig.Email, _ = config.Get("user.email")
+	if name := os.Getenv(nameEnv); name != "" {
+		sig.Name = name
+	}
+	if email := os.Getenv(emailEnv); email != "" {
+		sig.Email = email
+	}
+
+	if sig.Name == "" || sig.Email == "" {
+		login := "unknown"
+		if current, err := user.Current(); err == nil {
+			login = current.Username
+			if sig.Name == "" {
+				sig.Name = current.Name
+			}
+		}
+		if sig.Name == "" {
+			sig.Name = login
+		}
+		if sig.Email == "" {
+			host, err := os.Hostname()
+			if err != nil || host == "" {
+				host = "localhost"
+			}
+			sig.Email = login + "@" + host
+		}
+	}
+
+	if err := sig.validate(); err != nil {
+		return Signature{}, fmt.Errorf("%w; set user.name and user.email with 'mygit config'", err)
+	}
+	return sig, nil
+}
@@ -0,0 +1,403 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// IgnoreFile lists untracked paths to ignore in the directory holding it
+	// and below
+	IgnoreFile = ".mygitignore"
+	// ExcludeFile lists ignored paths for this repository only; it is not
+	// committed
+	ExcludeFile = "info/exclude"
+)
+
+// CheckIgnoreOptions controls CheckIgnore
+type CheckIgnoreOptions struct {
+	// Verbose prints the rule that matched each path, including negated
+	// rules that un-ignore it
+	Verbose bool
+}
+
+// CheckIgnore prints which of the given paths are ignored and reports
+// whether any of them are. Tracked files are never ignored.
+func CheckIgnore(paths []string, opts CheckIgnoreOptions) (bool, error) {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return false, err
+	}
+	if len(paths) == 0 {
+		return false, errors.New("check-ignore requires at least one path")
+	}
+
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return false, err
+	}
+	ignore, err := r.newIgnoreMatcher()
+	if err != nil {
+		return false, err
+	}
+
+	anyIgnored := false
+	for _, arg := range paths {
+		name, err := r.repoPath(arg)
+		if err != nil {
+			return false, err
+		}
+		if name == "." {
+			return false, fmt.Errorf("'%s' is the root of the working tree", arg)
+		}
+		if _, tracked := idx.Entry(name); tracked {
+			continue
+		}
+		isDir := strings.HasSuffix(arg, "/")
+		if info, err := os.Stat(r.workPath(name)); err == nil && info.IsDir() {
+			isDir = true
+		}
+
+		rule, err := ignore.match(name, isDir)
+		if err != nil {
+			return false, err
+		}
+		if rule == nil {
+			continue
+		}
+		if !rule.negate {
+			anyIgnored = true
+		}
+		switch {
+		case opts.Verbose:
+			fmt.Printf("%s:%d:%s\t%s\n", rule.source, rule.line, rule.pattern, arg)
+		case !rule.negate:
+			fmt.Println(arg)
+		}
+	}
+	return anyIgnored, nil
+}
+
+// ignoreRule is a single pattern read from an ignore file
+type ignoreRule struct {
+	// source and line locate the pattern for check-ignore -v
+	source string
+	line   int
+	// pattern is the pattern as written
+	pattern string
+	// base is the directory the pattern is relative to, "" for the root
+	base string
+
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	re       *regexp.Regexp
+}
+
+// matches reports whether the rule matches a slash-separated path
+func (r *ignoreRule) matches(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel := p
+	if r.base != "" {
+		if !strings.HasPrefix(p, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(p, r.base+"/")
+	}
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	return r.re.MatchString(rel)
+}
+
+// ignoreMatcher decides which untracked paths are ignored. Rules come from,
+// in increasing order of precedence, the global ignore file,
+// .mygit/info/exclude and the .mygitignore files from the root down to the
+// directory holding a path. The last matching rule wins, and a path inside an
+// ignored directory is always ignored.
+type ignoreMatcher struct {
+	repo     *Repository
+	rules    []*ignoreRule
+	dirRules map[string][]*ignoreRule
+	dirMatch map[string]*ignoreRule
+}
+
+// newIgnoreMatcher loads the global and repository-wide ignore rules;
+// .mygitignore files are read as directories are visited
+func (r *Repository) newIgnoreMatcher() (*ignoreMatcher, error) {
+	m := &ignoreMatcher{
+		repo:     r,
+		dirRules: make(map[string][]*ignoreRule),
+		dirMatch: make(map[string]*ignoreRule),
+	}
+	if global := globalIgnoreFile(); global != "" {
+		rules, err := readIgnoreFile(global, global, "")
+		if err != nil {
+			return nil, err
+		}
+		m.rules = append(m.rules, rules...)
+	}
+	exclude := r.gitPath(filepath.FromSlash(ExcludeFile))
+	rules, err := readIgnoreFile(exclude, MyGitDir+"/"+ExcludeFile, "")
+	if err != nil {
+		return nil, err
+	}
+	m.rules = append(m.rules, rules...)
+	return m, nil
+}
+
+// globalIgnoreFile returns the path of the per-user ignore file,
+// $XDG_CONFIG_HOME/mygit/ignore or ~/.config/mygit/ignore
+func globalIgnoreFile() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "mygit", "ignore")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "mygit", "ignore")
+}
+
+// match returns the rule that decides whether a slash-separated path is
+// ignored, or nil when no rule matches. The returned rule may be a negation,
+// which means the path is explicitly not ignored.
+func (m *ignoreMatcher) match(p string, isDir bool) (*ignoreRule, error) {
+	// A path cannot be re-included once a parent directory is excluded
+	for i := 0; i < len(p); i++ {
+		if p[i] != '/' {
+			continue
+		}
+		dir := p[:i]
+		rule, ok := m.dirMatch[dir]
+		if !ok {
+			var err error
+			if rule, err = m.matchRules(dir, true); err != nil {
+				return nil, err
+			}
+			m.dirMatch[dir] = rule
+		}
+		if rule != nil && !rule.negate {
+			return rule, nil
+		}
+	}
+	return m.matchRules(p, isDir)
+}
+
+// ignored reports whether a slash-separated path is ignored
+func (m *ignoreMatcher) ignored(p string, isDir bool) (bool, error) {
+	rule, err := m.match(p, isDir)
+	if err != nil {
+		return false, err
+	}
+	return rule != nil && !rule.negate, nil
+}
+
+// matchRules returns the last rule matching p without considering its parent
+// directories
+func (m *ignoreMatcher) matchRules(p string, isDir bool) (*ignoreRule, error) {
+	var found *ignoreRule
+	for _, rule := range m.rules {
+		if rule.matches(p, isDir) {
+			found = rule
+		}
+	}
+
+	// Walk the .mygitignore files from the root down to p's directory
+	dirs := []string{""}
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' {
+			dirs = append(dirs, p[:i])
+		}
+	}
+	for _, dir := range dirs {
+		rules, err := m.rulesIn(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			if rule.matches(p, isDir) {
+				found = rule
+			}
+		}
+	}
+	return found, nil
+}
+
+// rulesIn returns the rules of the .mygitignore file in a directory
+func (m *ignoreMatcher) rulesIn(dir string) ([]*ignoreRule, error) {
+	if rules, ok := m.dirRules[dir]; ok {
+		return rules, nil
+	}
+	source := path.Join(dir, IgnoreFile)
+	rules, err := readIgnoreFile(m.repo.workPath(source), source, dir)
+	if err != nil {
+		return nil, err
+	}
+	m.dirRules[dir] = rules
+	return rules, nil
+}
+
+// readIgnoreFile parses an ignore file. A missing file has no rules.
+func readIgnoreFile(filename, source, base string) ([]*ignoreRule, error) {
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	defer file.Close()
+
+	var rules []*ignoreRule
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		rule, err := parseIgnoreLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", source, line, err)
+		}
+		if rule == nil {
+			continue
+		}
+		rule.source, rule.line, rule.base = source, line, base
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	return rules, nil
+}
+
+// parseIgnoreLine parses one line of an ignore file using gitignore syntax,
+// returning nil for blank lines and comments
+func parseIgnoreLine(line string) (*ignoreRule, error) {
+	line = strings.TrimSuffix(line, "\r")
+	if line == "" || line[0] == '#' {
+		return nil, nil
+	}
+
+	// Trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" {
+		return nil, nil
+	}
+	rule := &ignoreRule{pattern: line}
+
+	switch {
+	case line[0] == '!':
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// A slash at the start or in the middle anchors the pattern to the
+	// directory of the ignore file
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return nil, nil
+	}
+
+	re, err := compileIgnorePattern(line)
+	if err != nil {
+		return nil, err
+	}
+	rule.re = re
+	return rule, nil
+}
+
+// compileIgnorePattern translates a gitignore glob into a regular expression.
+// "*" and "?" do not match "/", while "**" matches across directories when it
+// forms a whole path component: "**/x", "x/**" or "x/**/y".
+func compileIgnorePattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); {
+		atComponentStart := i == 0 || pattern[i-1] == '/'
+		switch {
+		case atComponentStart && strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 3
+		case atComponentStart && pattern[i:] == "**":
+			b.WriteString(".*")
+			i += 2
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+			i++
+		case pattern[i] == '[':
+			end := i + 1
+			if end < len(pattern) && (pattern[end] == '!' || pattern[end] == '^') {
+				end++
+			}
+			if end < len(pattern) && pattern[end] == ']' {
+				end++
+			}
+			for end < len(pattern) && pattern[end] != ']' {
+				end++
+			}
+			if end >= len(pattern) {
+				// An unterminated bracket is a literal "["
+				b.WriteString(`\[`)
+				i++
+				continue
+			}
+			class := strings.ReplaceAll(pattern[i+1:end], `\`, `\\`)
+			if class[0] == '!' || class[0] == '^' {
+				b.WriteString("[^/" + class[1:] + "]")
+			} else {
+				b.WriteString("[" + class + "]")
+			}
+			i = end + 1
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			b.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i += 2
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			i++
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// trackedDirs returns every directory that contains a tracked path
+func trackedDirs(idx *Index) map[string]bool {
+	dirs := make(map[string]bool)
+	add := func(p string) {
+		for dir := path.Dir(p); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	for _, entry := range idx.Entries {
+		add(entry.Path)
+	}
+	for _, conflict := range idx.Conflicts {
+		add(conflict.Path)
+	}
+	return dirs
+}
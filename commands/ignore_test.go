@@ -0,0 +1,341 @@
+package commands_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// setupIgnoreRepo creates an empty repository with the given files and an
+// isolated global ignore file
+func setupIgnoreRepo(t *testing.T, files map[string]string) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+	for name, content := range files {
+		path := filepath.FromSlash(name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+}
+
+// captureOutput returns what f prints to standard output
+func captureOutput(t *testing.T, f func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	err = f()
+	w.Close()
+	os.Stdout = stdout
+	out := <-done
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return out
+}
+
+// TestIgnorePatterns tests gitignore pattern semantics
+func TestIgnorePatterns(t *testing.T) {
+	tests := []struct {
+		name            string
+		rules           string
+		path            string
+		expectedIgnored bool
+	}{
+		{name: "basename in any directory", rules: "*.log\n", path: "a/b/debug.log", expectedIgnored: true},
+		{name: "star does not cross directories", rules: "a/*.log\n", path: "a/b/debug.log", expectedIgnored: false},
+		{name: "anchored pattern", rules: "/build\n", path: "build/out.o", expectedIgnored: true},
+		{name: "anchored pattern only at root", rules: "/build\n", path: "src/build/out.o", expectedIgnored: false},
+		{name: "unanchored directory name", rules: "build\n", path: "src/build/out.o", expectedIgnored: true},
+		{name: "middle slash anchors", rules: "doc/frotz\n", path: "a/doc/frotz", expectedIgnored: false},
+		{name: "directory-only rule skips files", rules: "cache/\n", path: "cache", expectedIgnored: false},
+		{name: "directory-only rule matches directories", rules: "cache/\n", path: "cache/data", expectedIgnored: true},
+		{name: "leading double star", rules: "**/logs\n", path: "x/y/logs/today", expectedIgnored: true},
+		{name: "trailing double star", rules: "abc/**\n", path: "abc/x/y", expectedIgnored: true},
+		{name: "middle double star", rules: "a/**/b\n", path: "a/x/y/b", expectedIgnored: true},
+		{name: "middle double star matches zero directories", rules: "a/**/b\n", path: "a/b", expectedIgnored: true},
+		{name: "question mark", rules: "file?.txt\n", path: "file1.txt", expectedIgnored: true},
+		{name: "bracket range", rules: "file[0-9].txt\n", path: "filex.txt", expectedIgnored: false},
+		{name: "negated bracket", rules: "file[!0-9].txt\n", path: "filex.txt", expectedIgnored: true},
+		{name: "negation", rules: "*.log\n!keep.log\n", path: "keep.log", expectedIgnored: false},
+		{name: "later rule wins", rules: "!keep.log\n*.log\n", path: "keep.log", expectedIgnored: true},
+		{name: "no re-include inside excluded directory", rules: "out/\n!out/keep.txt\n", path: "out/keep.txt", expectedIgnored: true},
+		{name: "re-include after excluding contents", rules: "out/*\n!out/keep.txt\n", path: "out/keep.txt", expectedIgnored: false},
+		{name: "comment", rules: "# comment.txt\n", path: "# comment.txt", expectedIgnored: false},
+		{name: "escaped hash", rules: "\\#file\n", path: "#file", expectedIgnored: true},
+		{name: "escaped exclamation mark", rules: "\\!important\n", path: "!important", expectedIgnored: true},
+		{name: "trailing spaces are trimmed", rules: "trim.txt   \n", path: "trim.txt", expectedIgnored: true},
+		{name: "escaped trailing space", rules: "space\\ \n", path: "space ", expectedIgnored: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupIgnoreRepo(t, map[string]string{
+				commands.IgnoreFile: tt.rules,
+				tt.path:             "content\n",
+			})
+
+			ignored, err := commands.CheckIgnore([]string{tt.path}, commands.CheckIgnoreOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ignored != tt.expectedIgnored {
+				t.Errorf("expected ignored %v, got %v", tt.expectedIgnored, ignored)
+			}
+		})
+	}
+}
+
+// TestIgnoreSources tests the precedence of the files rules are read from
+func TestIgnoreSources(t *testing.T) {
+	tests := []struct {
+		name            string
+		files           map[string]string
+		global          string
+		path            string
+		expectedIgnored bool
+	}{
+		{
+			name:            "nested file applies below its directory",
+			files:           map[string]string{"sub/.mygitignore": "*.tmp\n", "sub/a.tmp": "", "b.tmp": ""},
+			path:            "sub/a.tmp",
+			expectedIgnored: true,
+		},
+		{
+			name:            "nested file does not apply above its directory",
+			files:           map[string]string{"sub/.mygitignore": "*.tmp\n", "sub/a.tmp": "", "b.tmp": ""},
+			path:            "b.tmp",
+			expectedIgnored: false,
+		},
+		{
+			name:            "nested patterns are anchored to their directory",
+			files:           map[string]string{"sub/.mygitignore": "/gen\n", "sub/gen": "", "gen": ""},
+			path:            "gen",
+			expectedIgnored: false,
+		},
+		{
+			name:            "deeper file overrides root",
+			files:           map[string]string{".mygitignore": "*.tmp\n", "sub/.mygitignore": "!keep.tmp\n", "sub/keep.tmp": ""},
+			path:            "sub/keep.tmp",
+			expectedIgnored: false,
+		},
+		{
+			name:            "info/exclude",
+			files:           map[string]string{".mygit/info/exclude": "secret\n", "secret": ""},
+			path:            "secret",
+			expectedIgnored: true,
+		},
+		{
+			name:            "global file",
+			files:           map[string]string{"swap.swp": ""},
+			global:          "*.swp\n",
+			path:            "swap.swp",
+			expectedIgnored: true,
+		},
+		{
+			name:            "repository overrides global file",
+			files:           map[string]string{".mygitignore": "!swap.swp\n", "swap.swp": ""},
+			global:          "*.swp\n",
+			path:            "swap.swp",
+			expectedIgnored: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupIgnoreRepo(t, tt.files)
+			if tt.global != "" {
+				globalDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "mygit")
+				os.MkdirAll(globalDir, 0755)
+				os.WriteFile(filepath.Join(globalDir, "ignore"), []byte(tt.global), 0644)
+			}
+
+			ignored, err := commands.CheckIgnore([]string{tt.path}, commands.CheckIgnoreOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ignored != tt.expectedIgnored {
+				t.Errorf("expected ignored %v, got %v", tt.expectedIgnored, ignored)
+			}
+		})
+	}
+}
+
+// TestIgnoreInAddAndStatus tests that ignored files are neither listed nor added
+func TestIgnoreInAddAndStatus(t *testing.T) {
+	setupIgnoreRepo(t, map[string]string{
+		commands.IgnoreFile:   "*.log\nnode_modules/\n",
+		"main.go":             "package main\n",
+		"debug.log":           "log\n",
+		"node_modules/x/y.js": "js\n",
+	})
+
+	result, err := commands.GetStatus()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	expected := []string{commands.IgnoreFile, "main.go"}
+	if len(result.Untracked) != len(expected) || result.Untracked[0] != expected[0] || result.Untracked[1] != expected[1] {
+		t.Errorf("expected untracked %v, got %v", expected, result.Untracked)
+	}
+
+	if err := commands.AddWithOptions(nil, commands.AddOptions{All: true}); err != nil {
+		t.Fatalf("failed to add: %v", err)
+	}
+	idx, _ := commands.ReadIndex()
+	if len(idx.Entries) != 2 {
+		t.Errorf("expected only unignored files to be added, got %v", idx.Entries)
+	}
+
+	// Naming an ignored file is an error unless forced
+	if err := commands.Add([]string{"debug.log"}); err == nil {
+		t.Errorf("expected error adding an ignored file")
+	}
+	if err := commands.AddWithOptions([]string{"debug.log"}, commands.AddOptions{Force: true}); err != nil {
+		t.Errorf("unexpected error forcing an ignored file: %v", err)
+	}
+
+	// Tracked files are never ignored
+	os.WriteFile("debug.log", []byte("changed\n"), 0644)
+	result, _ = commands.GetStatus()
+	if len(result.Unstaged) != 1 || result.Unstaged[0].Path != "debug.log" {
+		t.Errorf("expected tracked debug.log to show as modified, got %v", result.Unstaged)
+	}
+	if ignored, _ := commands.CheckIgnore([]string{"debug.log"}, commands.CheckIgnoreOptions{}); ignored {
+		t.Errorf("tracked files should not be reported as ignored")
+	}
+}
+
+// TestClean tests removing untracked files
+func TestClean(t *testing.T) {
+	tests := []struct {
+		name            string
+		opts            commands.CleanOptions
+		expectedError   bool
+		expectedRemoved []string
+		expectedKept    []string
+	}{
+		{
+			name:          "requires force",
+			opts:          commands.CleanOptions{},
+			expectedError: true,
+			expectedKept:  []string{"untracked.txt"},
+		},
+		{
+			name:         "dry run",
+			opts:         commands.CleanOptions{DryRun: true, Directories: true},
+			expectedKept: []string{"untracked.txt", "newdir/file.txt", "debug.log"},
+		},
+		{
+			name:            "files only",
+			opts:            commands.CleanOptions{Force: true},
+			expectedRemoved: []string{"untracked.txt"},
+			expectedKept:    []string{"tracked.txt", "newdir/file.txt", "debug.log"},
+		},
+		{
+			name:            "with directories",
+			opts:            commands.CleanOptions{Force: true, Directories: true},
+			expectedRemoved: []string{"untracked.txt", "newdir"},
+			expectedKept:    []string{"tracked.txt", "debug.log"},
+		},
+		{
+			name:            "including ignored",
+			opts:            commands.CleanOptions{Force: true, IncludeIgnored: true},
+			expectedRemoved: []string{"untracked.txt", "debug.log"},
+			expectedKept:    []string{"tracked.txt", commands.IgnoreFile},
+		},
+		{
+			name:            "only ignored",
+			opts:            commands.CleanOptions{Force: true, OnlyIgnored: true},
+			expectedRemoved: []string{"debug.log"},
+			expectedKept:    []string{"tracked.txt", "untracked.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupIgnoreRepo(t, map[string]string{
+				commands.IgnoreFile: "*.log\n",
+				"tracked.txt":       "tracked\n",
+			})
+			commands.Add([]string{"tracked.txt", commands.IgnoreFile})
+			commands.Commit("Initial commit")
+			os.WriteFile("untracked.txt", []byte("x\n"), 0644)
+			os.WriteFile("debug.log", []byte("log\n"), 0644)
+			os.Mkdir("newdir", 0755)
+			os.WriteFile(filepath.Join("newdir", "file.txt"), []byte("x\n"), 0644)
+
+			err := commands.Clean(tt.opts)
+
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			for _, path := range tt.expectedRemoved {
+				if _, err := os.Stat(filepath.FromSlash(path)); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed", path)
+				}
+			}
+			for _, path := range tt.expectedKept {
+				if _, err := os.Stat(filepath.FromSlash(path)); err != nil {
+					t.Errorf("expected %s to be kept: %v", path, err)
+				}
+			}
+		})
+	}
+}
+
+// TestCleanDryRun tests that a dry run lists what a real run removes, with
+// untracked directories listed once when nothing inside them is kept
+func TestCleanDryRun(t *testing.T) {
+	setupIgnoreRepo(t, map[string]string{
+		commands.IgnoreFile:  "*.log\n",
+		"tracked.txt":        "tracked\n",
+		"untracked.txt":      "x\n",
+		"newdir/file.txt":    "x\n",
+		"newdir/sub/deep":    "x\n",
+		"mixed/file.txt":     "x\n",
+		"mixed/sub/keep.log": "log\n",
+	})
+	commands.Add([]string{"tracked.txt", commands.IgnoreFile})
+	commands.Commit("Initial commit")
+	os.Mkdir("empty", 0755)
+
+	opts := commands.CleanOptions{DryRun: true, Directories: true}
+	dryRun := captureOutput(t, func() error { return commands.Clean(opts) })
+	opts = commands.CleanOptions{Force: true, Directories: true}
+	removed := captureOutput(t, func() error { return commands.Clean(opts) })
+
+	expected := []string{"empty/", "mixed/file.txt", "newdir/", "untracked.txt"}
+	if got := strings.ReplaceAll(dryRun, "Would remove ", "Removing "); got != removed {
+		t.Errorf("expected the dry run to match\n%s\ngot\n%s", removed, dryRun)
+	}
+	if got := strings.Fields(strings.ReplaceAll(removed, "Removing ", "")); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v to be removed, got %v", expected, got)
+	}
+	for _, path := range expected {
+		if _, err := os.Stat(filepath.FromSlash(path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("mixed", "sub", "keep.log")); err != nil {
+		t.Errorf("expected the ignored file to be kept: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	IndexFile    = "index"
+	IndexVersion = 1
+)
+
+// IndexEntry is a tracked file in the index together with the stat data
+// recorded when its content was last hashed
+type IndexEntry struct {
+	Path  string `json:"path"`
+	Hash  string `json:"hash"`
+	Mode  string `json:"mode"`
+	Size  int64  `json:"size"`
+	MTime int64  `json:"mtime"`
+	CTime int64  `json:"ctime"`
+	Inode uint64 `json:"inode"`
+}
+
+// ConflictEntry is a path left unmerged by a merge. It records the base,
+// ours and theirs versions; a side where the file does not exist has an
+// empty hash.
+type ConflictEntry struct {
+	Path       string `json:"path"`
+	BaseHash   string `json:"base_hash,omitempty"`
+	BaseMode   string `json:"base_mode,omitempty"`
+	OursHash   string `json:"ours_hash,omitempty"`
+	OursMode   string `json:"ours_mode,omitempty"`
+	TheirsHash string `json:"theirs_hash,omitempty"`
+	TheirsMode string `json:"theirs_mode,omitempty"`
+}
+
+// Index holds every tracked path: the snapshot the next commit will record.
+// After a commit it mirrors the committed tree. Paths with unresolved merge
+// conflicts are kept in Conflicts instead of Entries until they are added.
+type Index struct {
+	Version   int             `json:"version"`
+	Entries   []IndexEntry    `json:"entries"`
+	Conflicts []ConflictEntry `json:"conflicts,omitempty"`
+
+	// modTime is when the index file was last written, used to detect
+	// files modified within the same timestamp granularity
+	modTime time.Time
+	// repo is the repository the index belongs to
+	repo *Repository
+}
+
+// ReadIndex loads the index of the repository in the current directory
+func ReadIndex() (*Index, error) {
+	r, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+	return r.ReadIndex()
+}
+
+// ReadIndex loads the index. When no index file exists yet it is seeded from
+// the HEAD commit and any legacy staging_area entries in metadata.json.
+func (r *Repository) ReadIndex() (*Index, error) {
+	indexPath := r.gitPath(IndexFile)
+	data, err := os.ReadFile(indexPath)
+	if os.IsNotExist(err) {
+		return r.seedIndex()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	idx := &Index{repo: r}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("failed to parse index: %w; run 'mygit recover' to rebuild it", err)
+	}
+	if idx.Version != IndexVersion {
+		return nil, fmt.Errorf("unsupported index version %d", idx.Version)
+	}
+	if info, err := os.Stat(indexPath); err == nil {
+		idx.modTime = info.ModTime()
+	}
+	return idx, nil
+}
+
+// Write saves the index, replacing the previous file atomically
+func (idx *Index) Write() error {
+	idx.Version = IndexVersion
+	if idx.Entries == nil {
+		idx.Entries = []IndexEntry{}
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	if err := writeFileAtomic(idx.repo.gitPath(IndexFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	idx.modTime = time.Now()
+	return nil
+}
+
+// Entry returns the entry for path
+func (idx *Index) Entry(path string) (IndexEntry, bool) {
+	i := sort.Search(len(idx.Entries), func(i int) bool { return idx.Entries[i].Path >= path })
+	if i < len(idx.Entries) && idx.Entries[i].Path == path {
+		return idx.Entries[i], true
+	}
+	return IndexEntry{}, false
+}
+
+// Set adds or replaces the entry for entry.Path, keeping entries sorted by path
+func (idx *Index) Set(entry IndexEntry) {
+	i := sort.Search(len(idx.Entries), func(i int) bool { return idx.Entries[i].Path >= entry.Path })
+	if i < len(idx.Entries) && idx.Entries[i].Path == entry.Path {
+		idx.Entries[i] = entry
+		return
+	}
+	idx.Entries = append(idx.Entries, IndexEntry{})
+	copy(idx.Entries[i+1:], idx.Entries[i:])
+	idx.Entries[i] = entry
+}
+
+// Remove deletes the entry for path and reports whether it existed
+func (idx *Index) Remove(path string) bool {
+	i := sort.Search(len(idx.Entries), func(i int) bool { return idx.Entries[i].Path >= path })
+	if i < len(idx.Entries) && idx.Entries[i].Path == path {
+		idx.Entries = append(idx.Entries[:i], idx.Entries[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// isGitlink reports whether path is a submodule in the index
+func (idx *Index) isGitlink(path string) bool {
+	entry, ok := idx.Entry(path)
+	return ok && entry.Mode == ModeGitlink
+}
+
+// Files returns the snapshot described by the index
+func (idx *Index) Files() []FileEntry {
+	files := make([]FileEntry, 0, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		files = append(files, FileEntry{Path: entry.Path, Mode: entry.Mode, Hash: entry.Hash})
+	}
+	return files
+}
+
+// Conflict returns the conflict recorded for path
+func (idx *Index) Conflict(path string) (ConflictEntry, bool) {
+	for _, conflict := range idx.Conflicts {
+		if conflict.Path == path {
+			return conflict, true
+		}
+	}
+	return ConflictEntry{}, false
+}
+
+// SetConflict marks a path as unmerged, replacing its regular entry
+func (idx *Index) SetConflict(conflict ConflictEntry) {
+	idx.Remove(conflict.Path)
+	idx.ResolveConflict(conflict.Path)
+	idx.Conflicts = append(idx.Conflicts, conflict)
+	sort.Slice(idx.Conflicts, func(i, j int) bool { return idx.Conflicts[i].Path < idx.Conflicts[j].Path })
+}
+
+// ResolveConflict drops the conflict recorded for path and reports whether
+// there was one
+func (idx *Index) ResolveConflict(path string) bool {
+	for i, conflict := range idx.Conflicts {
+		if conflict.Path == path {
+			idx.Conflicts = append(idx.Conflicts[:i], idx.Conflicts[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// StatClean reports whether a file on disk can be assumed to still match its
+// entry without rehashing it. Files modified no earlier than the index was
+// written are never considered clean, since a later change within the same
+// timestamp tick would be invisible.
+func (idx *Index) StatClean(entry IndexEntry, info os.FileInfo) bool {
+	ctime, inode := statExtra(info)
+	if entry.Size != info.Size() || entry.MTime != info.ModTime().UnixNano() ||
+		entry.CTime != ctime || entry.Inode != inode || entry.Mode != fileMode(info) {
+		return false
+	}
+	return !idx.modTime.IsZero() && entry.MTime < idx.modTime.UnixNano()
+}
+
+// newIndexEntry creates an index entry for a file and its stat data
+func newIndexEntry(path, hash string, info os.FileInfo) IndexEntry {
+	ctime, inode := statExtra(info)
+	return IndexEntry{
+		Path:  path,
+		Hash:  hash,
+		Mode:  fileMode(info),
+		Size:  info.Size(),
+		MTime: info.ModTime().UnixNano(),
+		CTime: ctime,
+		Inode: inode,
+	}
+}
+
+// seedIndex builds the initial index from the HEAD commit's snapshot and
+// the staging_area list used by older versions of metadata.json
+func (r *Repository) seedIndex() (*Index, error) {
+	idx := &Index{Version: IndexVersion, repo: r}
+
+	files, err := r.headFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		idx.Set(IndexEntry{Path: file.Path, Hash: file.Hash, Mode: file.Mode})
+	}
+
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range metadata.StagingArea {
+		mode := file.Mode
+		if mode == "" {
+			mode = ModeFile
+		}
+		idx.Set(IndexEntry{Path: filepath.ToSlash(filepath.Clean(file.Path)), Hash: file.Hash, Mode: mode})
+	}
+
+	return idx, nil
+}
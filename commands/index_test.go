@@ -0,0 +1,106 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestIndexRecordsStatData tests that add records stat data for each entry
+func TestIndexRecordsStatData(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+
+	os.WriteFile("run.sh", []byte("#!/bin/sh\n"), 0755)
+	if err := commands.Add([]string{"run.sh"}); err != nil {
+		t.Fatalf("failed to add file: %v", err)
+	}
+
+	idx, err := commands.ReadIndex()
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
+	}
+	entry, ok := idx.Entry("run.sh")
+	if !ok {
+		t.Fatalf("run.sh was not staged")
+	}
+
+	info, _ := os.Stat("run.sh")
+	if entry.Mode != commands.ModeExecutable {
+		t.Errorf("expected mode %s, got %s", commands.ModeExecutable, entry.Mode)
+	}
+	if entry.Size != info.Size() {
+		t.Errorf("expected size %d, got %d", info.Size(), entry.Size)
+	}
+	if entry.MTime != info.ModTime().UnixNano() {
+		t.Errorf("expected mtime %d, got %d", info.ModTime().UnixNano(), entry.MTime)
+	}
+}
+
+// TestIndexMirrorsHead tests that commits snapshot the whole index without re-adding files
+func TestIndexMirrorsHead(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+
+	os.WriteFile("file1.txt", []byte("content1"), 0644)
+	os.WriteFile("file2.txt", []byte("content2"), 0644)
+	commands.Add([]string{"file1.txt", "file2.txt"})
+	if err := commands.Commit("First commit"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	// Only modify one file; the other stays tracked through the index
+	os.WriteFile("file1.txt", []byte("changed"), 0644)
+	commands.Add([]string{"file1.txt"})
+	if err := commands.Commit("Second commit"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	idx, err := commands.ReadIndex()
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
+	}
+	if len(idx.Entries) != 2 {
+		t.Errorf("expected 2 index entries, got %d", len(idx.Entries))
+	}
+
+	// Re-adding an unchanged file leaves nothing to commit
+	commands.Add([]string{"file2.txt"})
+	if err := commands.Commit("Nothing changed"); err == nil {
+		t.Errorf("expected error when nothing changed")
+	}
+}
+
+// TestIndexSeedsFromLegacyStagingArea tests migration of staging_area entries from metadata.json
+func TestIndexSeedsFromLegacyStagingArea(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+
+	metadata := map[string]any{
+		"staging_area": []map[string]string{
+			{"file_path": "legacy.txt", "file_hash": helloBlob},
+		},
+	}
+	metadataPath := filepath.Join(commands.MyGitDir, commands.MetadataFile)
+	file, _ := os.Create(metadataPath)
+	json.NewEncoder(file).Encode(metadata)
+	file.Close()
+
+	idx, err := commands.ReadIndex()
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
+	}
+	entry, ok := idx.Entry("legacy.txt")
+	if !ok {
+		t.Fatalf("legacy staging entry was not migrated")
+	}
+	if entry.Hash != helloBlob || entry.Mode != commands.ModeFile {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
@@ -0,0 +1,402 @@
+package commands
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+// The JSON output of commands is meant for scripts. Its fields are only
+// ever added to: existing fields keep their names and meaning. Lists are
+// empty rather than null, and dates use RFC 3339 with the time zone they
+// were recorded in.
+
+// JSONSignature is an author or committer in JSON output
+type JSONSignature struct {
+	// Name and Email are empty for commits made before they were recorded
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Date  string `json:"date"`
+}
+
+// JSONCommit is a commit in the JSON output of log, commit and show
+type JSONCommit struct {
+	ID string `json:"id"`
+	// Tree is empty for commits made before trees were stored
+	Tree string `json:"tree"`
+	// Parents holds the IDs of the parents, first parent first
+	Parents   []string      `json:"parents"`
+	Author    JSONSignature `json:"author"`
+	Committer JSONSignature `json:"committer"`
+	// Subject is the first paragraph of the message on one line, and Body
+	// the rest of the message
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	Message string `json:"message"`
+	// Files lists what the commit changed compared with its first parent.
+	// It is left out when the files are not asked for.
+	Files []FileStat `json:"files,omitempty"`
+	// Patch holds the changes show prints for the commit, if any
+	Patch string `json:"patch,omitempty"`
+}
+
+// JSONLog is the JSON output of log
+type JSONLog struct {
+	// Commits holds the commits in the order log shows them
+	Commits []JSONCommit `json:"commits"`
+}
+
+// JSONShow is the JSON output of show
+type JSONShow struct {
+	// Objects holds the objects in the order show shows them. An annotated
+	// tag is followed by the object it tags.
+	Objects []JSONObject `json:"objects"`
+}
+
+// JSONObject is an object in the JSON output of show. Only the field of
+// its type is set.
+type JSONObject struct {
+	// Type is "commit", "tree", "blob" or "tag"
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	// Name is the revision that named the object, or the name of the tag
+	// that led to it
+	Name   string      `json:"name"`
+	Commit *JSONCommit `json:"commit,omitempty"`
+	Tree   *JSONTree   `json:"tree,omitempty"`
+	Blob   *JSONBlob   `json:"blob,omitempty"`
+	Tag    *JSONTag    `json:"tag,omitempty"`
+}
+
+// JSONTree is the content of a tree in JSON output
+type JSONTree struct {
+	Entries []JSONTreeEntry `json:"entries"`
+}
+
+// JSONTreeEntry is an entry of a tree in JSON output
+type JSONTreeEntry struct {
+	Name string `json:"name"`
+	Mode string `json:"mode"`
+	// Type is the type of the object the entry points at
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// JSONBlob is the content of a file in JSON output
+type JSONBlob struct {
+	Size int `json:"size"`
+	// Content is the content as text, or encoded in base64 when Encoding
+	// is "base64" because it is binary or not valid UTF-8
+	Content  string `json:"content"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// JSONTag is an annotated tag in JSON output
+type JSONTag struct {
+	Name string `json:"name"`
+	// Object and Type identify the tagged object
+	Object string `json:"object"`
+	Type   string `json:"type"`
+	// Tagger is left out for tags made by old versions of Git, which have
+	// none
+	Tagger  *JSONSignature `json:"tagger,omitempty"`
+	Message string         `json:"message"`
+}
+
+// JSONStatus is the JSON output of status
+type JSONStatus struct {
+	// Branch is the current branch, or empty when HEAD is detached
+	Branch string `json:"branch"`
+	// Head is the commit HEAD points to, or empty before the first commit
+	Head     string `json:"head"`
+	Detached bool   `json:"detached"`
+	// Merging is set while a merge is waiting to be committed
+	Merging   bool            `json:"merging"`
+	Staged    []FileChange    `json:"staged"`
+	Unstaged  []FileChange    `json:"unstaged"`
+	Untracked []string        `json:"untracked"`
+	Unmerged  []ConflictEntry `json:"unmerged"`
+	// Clean is set when there is nothing to commit and no untracked files
+	Clean bool `json:"clean"`
+}
+
+// JSONDiffStat is the JSON output of diff, which counts the changed lines
+// like diff --stat
+type JSONDiffStat struct {
+	Files        []FileStat `json:"files"`
+	FilesChanged int        `json:"files_changed"`
+	Insertions   int        `json:"insertions"`
+	Deletions    int        `json:"deletions"`
+}
+
+// JSONBranches is the JSON output of branch
+type JSONBranches struct {
+	// Head is the commit HEAD points to, or empty before the first commit
+	Head     string   `json:"head"`
+	Detached bool     `json:"detached"`
+	Branches []Branch `json:"branches"`
+}
+
+// JSONError is an error in JSON output, written as {"error": {...}}
+type JSONError struct {
+	// Code is one of the Code constants, such as CodeUnknownRevision
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// ExitStatus is the status the command line exits with
+	ExitStatus int `json:"exit_status"`
+}
+
+// StatusJSON prints the status as JSONStatus
+func StatusJSON() error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	result, err := r.Status()
+	if err != nil {
+		return err
+	}
+	branch, attached, err := r.currentBranch()
+	if err != nil {
+		return err
+	}
+	_, headID, err := r.readHead()
+	if err != nil {
+		return err
+	}
+
+	return printJSON(JSONStatus{
+		Branch:    branch,
+		Head:      headID,
+		Detached:  !attached,
+		Merging:   result.Merging,
+		Staged:    nonNil(result.Staged),
+		Unstaged:  nonNil(result.Unstaged),
+		Untracked: nonNil(result.Untracked),
+		Unmerged:  nonNil(result.Unmerged),
+		Clean:     result.Clean(),
+	})
+}
+
+// LogJSON prints the commits log shows as JSONLog. With Stat or NameStatus
+// the commits list their files, except merge commits. Format, DateFormat,
+// Patch and Graph cannot be used.
+func LogJSON(opts LogOptions) error {
+	if opts.Format != "" || opts.DateFormat != "" || opts.Patch || opts.Graph {
+		return &UsageError{Message: "--format, --date, -p and --graph cannot be used with --json"}
+	}
+
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	// As in Git, the arguments after the revisions name files
+	if len(opts.Paths) == 0 {
+		opts.Revisions, opts.Paths = r.splitRevisions(opts.Revisions)
+	}
+	walk, err := r.walkLog(opts)
+	if err != nil {
+		return err
+	}
+
+	out := JSONLog{Commits: []JSONCommit{}}
+	for _, commit := range walk.commits {
+		entry := newJSONCommit(commit)
+		if (opts.Stat || opts.NameStatus) && len(commit.Parents) < 2 {
+			if entry.Files, err = r.commitStats(commit, walk.paths); err != nil {
+				return err
+			}
+		}
+		out.Commits = append(out.Commits, entry)
+	}
+	return printJSON(out)
+}
+
+// DiffJSON prints the changes Diff shows as JSONDiffStat
+func DiffJSON(opts DiffOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	stats, err := r.DiffStat(opts)
+	if err != nil {
+		return err
+	}
+
+	out := JSONDiffStat{Files: nonNil(stats), FilesChanged: len(stats)}
+	for _, stat := range stats {
+		out.Insertions += stat.Insertions
+		out.Deletions += stat.Deletions
+	}
+	return printJSON(out)
+}
+
+// ListBranchesJSON prints all branches as JSONBranches
+func ListBranchesJSON() error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	branches, err := r.Branches()
+	if err != nil {
+		return err
+	}
+	_, attached, err := r.currentBranch()
+	if err != nil {
+		return err
+	}
+	_, headID, err := r.readHead()
+	if err != nil {
+		return err
+	}
+	return printJSON(JSONBranches{Head: headID, Detached: !attached, Branches: branches})
+}
+
+// CommitJSON records a commit like CommitWithOptions and prints it as
+// JSONCommit, with the files it changed
+func CommitJSON(message string, opts CommitOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	commit, err := r.CommitWithOptions(message, opts)
+	if err != nil {
+		return err
+	}
+
+	out := newJSONCommit(*commit)
+	if out.Files, err = r.commitStats(*commit, nil); err != nil {
+		return err
+	}
+	out.Files = nonNil(out.Files)
+	return printJSON(out)
+}
+
+// ShowJSON prints the objects Show shows as JSONShow. Commits list the files
+// they changed compared with their first parent, and unless NoPatch is set
+// their changes as show prints them. Format and DateFormat cannot be used.
+func ShowJSON(revs []string, opts ShowOptions) error {
+	if opts.Format != "" || opts.DateFormat != "" {
+		return &UsageError{Message: "--format and --date cannot be used with --json"}
+	}
+
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	objects, err := r.showObjects(revs)
+	if err != nil {
+		return err
+	}
+
+	out := JSONShow{Objects: []JSONObject{}}
+	for _, object := range objects {
+		entry := JSONObject{Type: object.Type, ID: object.ID, Name: object.Name}
+		switch object.Type {
+		case CommitObject:
+			commit := newJSONCommit(*object.Commit)
+			if commit.Files, err = r.commitStats(*object.Commit, nil); err != nil {
+				return err
+			}
+			commit.Files = nonNil(commit.Files)
+			if !opts.NoPatch {
+				if commit.Patch, err = r.CommitPatch(object.Commit); err != nil {
+					return err
+				}
+			}
+			entry.Commit = &commit
+		case TreeObject:
+			tree := JSONTree{Entries: []JSONTreeEntry{}}
+			for _, e := range object.Tree.Entries {
+				tree.Entries = append(tree.Entries, JSONTreeEntry{Name: e.Name, Mode: e.Mode, Type: treeEntryType(e), ID: e.Hash})
+			}
+			entry.Tree = &tree
+		case TagObject:
+			tag := JSONTag{Name: object.Tag.Name, Object: object.Tag.Object, Type: object.Tag.Type, Message: object.Tag.Message}
+			if !object.Tag.Tagger.IsZero() {
+				tagger := newJSONSignature(object.Tag.Tagger, object.Tag.Tagger.When)
+				tag.Tagger = &tagger
+			}
+			entry.Tag = &tag
+		default:
+			blob := JSONBlob{Size: len(object.Data), Content: string(object.Data)}
+			if IsBinary(object.Data) || !utf8.Valid(object.Data) {
+				blob.Content, blob.Encoding = base64.StdEncoding.EncodeToString(object.Data), "base64"
+			}
+			entry.Blob = &blob
+		}
+		out.Objects = append(out.Objects, entry)
+	}
+	return printJSON(out)
+}
+
+// WriteJSONError writes err to w as a JSONError, for the command line
+// to report errors with --json
+func WriteJSONError(w io.Writer, err error) error {
+	return encodeJSON(w, map[string]JSONError{
+		"error": {Code: ErrorCode(err), Message: err.Error(), ExitStatus: ErrorExitStatus(err)},
+	})
+}
+
+// newJSONCommit converts a commit for JSON output
+func newJSONCommit(commit CommitRecord) JSONCommit {
+	committerDate := commit.Committer.When
+	if committerDate.IsZero() {
+		committerDate = commit.Time
+	}
+	return JSONCommit{
+		ID:        commit.ID,
+		Tree:      commit.Tree,
+		Parents:   nonNil(commit.Parents),
+		Author:    newJSONSignature(commit.Author, authorDate(commit)),
+		Committer: newJSONSignature(commit.Committer, committerDate),
+		Subject:   commitSubject(commit.Message),
+		Body:      commitBody(commit.Message),
+		Message:   commit.Message,
+	}
+}
+
+func newJSONSignature(sig Signature, date time.Time) JSONSignature {
+	return JSONSignature{Name: sig.Name, Email: sig.Email, Date: date.Format(time.RFC3339)}
+}
+
+// commitStats counts the lines a commit changed in the given paths,
+// compared with its first parent
+func (r *Repository) commitStats(commit CommitRecord, paths []string) ([]FileStat, error) {
+	oldSide, newSide, err := r.commitSides(commit, paths)
+	if err != nil {
+		return nil, err
+	}
+	return diffStats(oldSide, newSide)
+}
+
+// printJSON prints v as indented JSON
+func printJSON(v any) error {
+	return encodeJSON(os.Stdout, v)
+}
+
+// encodeJSON writes v to w as indented JSON. Characters such as < and > are
+// written as they are, since the output is not meant for HTML.
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// nonNil returns an empty slice for nil, so that it is encoded as []
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,272 @@
+package commands_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestErrorCode tests the codes and exit statuses of errors returned by
+// commands
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      func(t *testing.T) error
+		expected string
+	}{
+		{
+			name: "not a repository",
+			run: func(t *testing.T) error {
+				os.Chdir(t.TempDir())
+				return commands.Log()
+			},
+			expected: commands.CodeNotRepository,
+		},
+		{
+			name: "unknown revision",
+			run: func(t *testing.T) error {
+				setupTwoCommits(t)
+				return commands.Checkout("HEAD~5", commands.CheckoutOptions{})
+			},
+			expected: commands.CodeUnknownRevision,
+		},
+		{
+			name: "nothing to commit",
+			run: func(t *testing.T) error {
+				setupTwoCommits(t)
+				return commands.Commit("Nothing")
+			},
+			expected: commands.CodeNothingToCommit,
+		},
+		{
+			name: "merge conflict",
+			run: func(t *testing.T) error {
+				ids := setupTwoCommits(t)
+				commands.Switch("feature", commands.SwitchOptions{Create: true})
+				commands.Reset(ids[0], commands.ResetOptions{Mode: commands.ResetHard})
+				os.WriteFile("file.txt", []byte("feature\n"), 0644)
+				commands.Add([]string{"file.txt"})
+				commands.Commit("Feature commit")
+				return commands.Merge("main", commands.MergeOptions{})
+			},
+			expected: commands.CodeConflict,
+		},
+		{
+			name: "local changes",
+			run: func(t *testing.T) error {
+				ids := setupTwoCommits(t)
+				os.WriteFile("file.txt", []byte("local\n"), 0644)
+				return commands.Checkout(ids[0], commands.CheckoutOptions{})
+			},
+			expected: commands.CodeLocalChanges,
+		},
+		{
+			name: "locked",
+			run: func(t *testing.T) error {
+				setupTwoCommits(t)
+				writeLock(t, commands.IndexFile, os.Getpid())
+				return commands.Add([]string{"file.txt"})
+			},
+			expected: commands.CodeLocked,
+		},
+		{
+			name: "usage",
+			run: func(t *testing.T) error {
+				return commands.LogJSON(commands.LogOptions{Graph: true})
+			},
+			expected: commands.CodeUsage,
+		},
+		{
+			name: "other errors",
+			run: func(t *testing.T) error {
+				setupTwoCommits(t)
+				return commands.CreateBranch("main", "")
+			},
+			expected: commands.CodeError,
+		},
+	}
+	statuses := make(map[int]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(t)
+			if err == nil {
+				t.Fatalf("expected error but got none")
+			}
+			if code := commands.ErrorCode(err); code != tt.expected {
+				t.Errorf("expected code %s, got %s (%v)", tt.expected, code, err)
+			}
+			status := commands.ExitStatus(tt.expected)
+			if other, ok := statuses[status]; ok || status < 1 {
+				t.Errorf("expected a distinct exit status, got %d like %s", status, other)
+			}
+			statuses[status] = tt.expected
+		})
+	}
+
+	// Sentinel errors keep the messages of the errors they classify
+	setupTwoCommits(t)
+	err := commands.Commit("Nothing")
+	if !errors.Is(err, commands.ErrNothingToCommit) || err.Error() != "no changes staged for commit" {
+		t.Errorf("expected the nothing to commit error, got %v", err)
+	}
+}
+
+// decodeJSON runs f and decodes what it prints into v
+func decodeJSON(t *testing.T, f func() error, v any) {
+	t.Helper()
+	out := captureOutput(t, f)
+	decoder := json.NewDecoder(strings.NewReader(out))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		t.Fatalf("failed to decode %s: %v", out, err)
+	}
+}
+
+// TestJSONOutput tests the JSON printed for status, log, diff, branch and
+// commit
+func TestJSONOutput(t *testing.T) {
+	repo := setupLogHistory(t)
+	head, _ := repo.ResolveCommit("HEAD")
+	os.WriteFile("file.txt", []byte("version 3\n"), 0644)
+	os.WriteFile("untracked.txt", []byte("new\n"), 0644)
+
+	var status commands.JSONStatus
+	decodeJSON(t, commands.StatusJSON, &status)
+	if status.Branch != "main" || status.Head != head.ID || status.Detached || status.Clean {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if len(status.Staged) != 0 || status.Staged == nil {
+		t.Errorf("expected no staged changes as [], got %v", status.Staged)
+	}
+	if len(status.Unstaged) != 1 || status.Unstaged[0] != (commands.FileChange{Path: "file.txt", Change: commands.ChangeModified}) {
+		t.Errorf("unexpected unstaged changes %v", status.Unstaged)
+	}
+	if strings.Join(status.Untracked, " ") != "untracked.txt" {
+		t.Errorf("unexpected untracked files %v", status.Untracked)
+	}
+
+	var diff commands.JSONDiffStat
+	decodeJSON(t, func() error { return commands.DiffJSON(commands.DiffOptions{}) }, &diff)
+	expectedStat := commands.FileStat{Path: "file.txt", Change: commands.ChangeModified, Deletions: 1}
+	if diff.FilesChanged != 1 || diff.Insertions != 0 || diff.Deletions != 1 || len(diff.Files) != 1 || diff.Files[0] != expectedStat {
+		t.Errorf("unexpected diff %+v", diff)
+	}
+
+	var log commands.JSONLog
+	decodeJSON(t, func() error { return commands.LogJSON(commands.LogOptions{MaxCount: 4, Stat: true}) }, &log)
+	if len(log.Commits) != 4 {
+		t.Fatalf("expected 4 commits, got %d", len(log.Commits))
+	}
+	third := log.Commits[3]
+	if third.Subject != "Third commit" || third.Body != "With a body\nof two lines\n" || len(third.Parents) != 1 {
+		t.Errorf("unexpected commit %+v", third)
+	}
+	if third.Author != (commands.JSONSignature{Name: "Alice", Email: "alice@example.com", Date: "2023-11-14T23:15:20+01:00"}) {
+		t.Errorf("unexpected author %+v", third.Author)
+	}
+	if len(third.Files) != 1 || third.Files[0] != (commands.FileStat{Path: "file.txt", Change: commands.ChangeModified, Insertions: 2, Deletions: 1}) {
+		t.Errorf("unexpected files %+v", third.Files)
+	}
+	if merge := log.Commits[1]; len(merge.Parents) != 2 || merge.Files != nil {
+		t.Errorf("expected a merge without files, got %+v", merge)
+	}
+	if log.Commits[0].ID != head.ID || log.Commits[0].Tree != head.Tree {
+		t.Errorf("expected HEAD first, got %+v", log.Commits[0])
+	}
+
+	var branches commands.JSONBranches
+	decodeJSON(t, commands.ListBranchesJSON, &branches)
+	expectedBranches := []commands.Branch{{Name: "feature", Commit: log.Commits[2].ID}, {Name: "main", Commit: head.ID, Current: true}}
+	if branches.Head != head.ID || len(branches.Branches) != 2 || branches.Branches[0] != expectedBranches[0] || branches.Branches[1] != expectedBranches[1] {
+		t.Errorf("unexpected branches %+v", branches)
+	}
+
+	commands.Add([]string{"file.txt"})
+	var commit commands.JSONCommit
+	decodeJSON(t, func() error { return commands.CommitJSON("Fourth commit", commands.CommitOptions{}) }, &commit)
+	if commit.Message != "Fourth commit" || len(commit.Parents) != 1 || commit.Parents[0] != head.ID || len(commit.Files) != 1 {
+		t.Errorf("unexpected commit %+v", commit)
+	}
+}
+
+// TestWriteJSONError tests the JSON written for errors
+func TestWriteJSONError(t *testing.T) {
+	var out bytes.Buffer
+	err := &commands.UsageError{Message: "log takes no such option"}
+	if writeErr := commands.WriteJSONError(&out, err); writeErr != nil {
+		t.Fatalf("unexpected error: %v", writeErr)
+	}
+	var decoded struct {
+		Error commands.JSONError `json:"error"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode %s: %v", out.String(), err)
+	}
+	expected := commands.JSONError{Code: commands.CodeUsage, Message: "log takes no such option", ExitStatus: 2}
+	if decoded.Error != expected {
+		t.Errorf("expected %+v, got %+v", expected, decoded.Error)
+	}
+
+	// Commands keeping Git's exit statuses report them
+	out.Reset()
+	commands.WriteJSONError(&out, &commands.StatusError{Err: errors.New("cannot read .gitignore"), Status: 128})
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode %s: %v", out.String(), err)
+	}
+	expected = commands.JSONError{Code: commands.CodeError, Message: "cannot read .gitignore", ExitStatus: 128}
+	if decoded.Error != expected {
+		t.Errorf("expected %+v, got %+v", expected, decoded.Error)
+	}
+
+	// Messages are written as they are, without HTML escapes
+	out.Reset()
+	commands.WriteJSONError(&out, errors.New("invalid identity 'a <b>' & more"))
+	if !strings.Contains(out.String(), `"invalid identity 'a <b>' & more"`) {
+		t.Errorf("expected the message unescaped, got %s", out.String())
+	}
+}
+
+// TestLogUsageErrors tests that bad log arguments are usage errors
+func TestLogUsageErrors(t *testing.T) {
+	setupTwoCommits(t)
+	for _, opts := range []commands.LogOptions{
+		{Since: "someday"},
+		{Until: "someday"},
+		{Author: "("},
+		{Grep: "["},
+		{Format: "bogus"},
+		{DateFormat: "bogus"},
+	} {
+		if err := commands.LogWithOptions(opts); commands.ErrorCode(err) != commands.CodeUsage {
+			t.Errorf("expected a usage error for %+v, got %v", opts, err)
+		}
+	}
+}
+
+// TestDiffStat tests that diff --stat prints what git diff --stat prints
+func TestDiffStat(t *testing.T) {
+	repo := setupLogHistory(t)
+	gitDir := filepath.Join(t.TempDir(), "export.git")
+	if _, err := repo.ExportGit(gitDir); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	for _, rev := range []string{"HEAD~4..HEAD", "feature...main", "HEAD~1"} {
+		t.Run(rev, func(t *testing.T) {
+			opts := commands.DiffOptions{Commits: []string{rev}, Stat: true}
+			if !strings.Contains(rev, ".") {
+				opts.Commits = []string{rev, "HEAD"}
+			}
+			out := captureOutput(t, func() error { return commands.Diff(opts) })
+			expected := runGit(t, gitDir, append([]string{"diff", "--stat"}, opts.Commits...)...)
+			if strings.TrimSpace(out) != expected {
+				t.Errorf("expected\n%s\ngot\n%s", expected, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// LockTimeoutEnv sets how long commands wait for a lock held by another
+	// process, as a duration such as "5s"; by default they fail at once
+	LockTimeoutEnv = "MYGIT_LOCK_TIMEOUT"
+
+	// LockSuffix is appended to a file name to get the name of its lock file,
+	// such as "index.lock"
+	LockSuffix = ".lock"
+
+	// takeoverSuffix is appended to the name of a lock file to get the file
+	// held while taking over a stale lock, such as "index.lock.takeover"
+	takeoverSuffix = ".takeover"
+
+	// lockPollInterval is how often a held lock is retried while waiting
+	lockPollInterval = 50 * time.Millisecond
+)
+
+// LockError is returned when a lock file is held by another process
+type LockError struct {
+	// Path is the path of the lock file
+	Path string
+	// PID is the process holding the lock, or 0 when it is unknown
+	PID int
+}
+
+func (e *LockError) Error() string {
+	holder := "another mygit process"
+	if e.PID != 0 {
+		holder = fmt.Sprintf("another mygit process (pid %d)", e.PID)
+	}
+	return fmt.Sprintf("unable to lock '%s': %s is using the repository\nwait for it to finish or use --wait; if it crashed, run 'mygit recover' or remove the file", e.Path, holder)
+}
+
+// lock creates the lock files of the given files in the repository
+// directory and returns a function removing them. Lock files are created
+// exclusively, so only one process at a time can hold each of them; callers
+// take them before reading the files they are going to replace, in the order
+// index, HEAD, metadata.json. HEAD.lock also guards the branch refs. A lock
+// held by another process is waited for up to LockTimeout, and a lock left
+// by a process that no longer exists is taken over.
+func (r *Repository) lock(names ...string) (func(), error) {
+	return r.lockWithin(r.LockTimeout, names...)
+}
+
+// tryLock is lock without waiting for locks held by other processes
+func (r *Repository) tryLock(names ...string) (func(), error) {
+	return r.lockWithin(0, names...)
+}
+
+func (r *Repository) lockWithin(timeout time.Duration, names ...string) (func(), error) {
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = r.gitPath(filepath.FromSlash(name))
+	}
+	return lockPaths(timeout, paths...)
+}
+
+// lockPaths locks files given by their paths, such as files outside the
+// repository directory, waiting up to timeout for locks held by others
+func lockPaths(timeout time.Duration, paths ...string) (func(), error) {
+	var held []string
+	unlock := func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			os.Remove(held[i])
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for _, p := range paths {
+		lockPath := p + LockSuffix
+		for {
+			err := createLockFile(lockPath)
+			if err == nil {
+				held = append(held, lockPath)
+				break
+			}
+			if !errors.Is(err, fs.ErrExist) {
+				unlock()
+				return nil, fmt.Errorf("failed to create %s: %w", lockPath, err)
+			}
+
+			pid, stale, err := readLockFile(lockPath)
+			if errors.Is(err, fs.ErrNotExist) {
+				// Released in the meantime
+				continue
+			}
+			if stale {
+				free, err := takeOverLock(lockPath)
+				if err != nil {
+					unlock()
+					return nil, err
+				}
+				if free {
+					continue
+				}
+			}
+			if !time.Now().Before(deadline) {
+				unlock()
+				return nil, &LockError{Path: displayPath(lockPath), PID: pid}
+			}
+			time.Sleep(lockPollInterval)
+		}
+	}
+	return unlock, nil
+}
+
+// createLockFile creates a lock file recording the current process and host,
+// failing with fs.ErrExist when it already exists
+func createLockFile(lockPath string) error {
+	file, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	_, err = fmt.Fprintf(file, "%d %s\n", os.Getpid(), host)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(lockPath)
+		return err
+	}
+	return nil
+}
+
+// takeOverLock removes a stale lock file and reports whether the lock may be
+// free now. Only one process at a time takes over a lock, holding its
+// takeover file; when another one is, the lock is not free. A lock is only
+// removed if it is still the same file after its holder was found dead,
+// since the holder may have released it and exited in the meantime, letting
+// another process create a new lock.
+func takeOverLock(lockPath string) (bool, error) {
+	takeoverPath := lockPath + takeoverSuffix
+	if err := createLockFile(takeoverPath); errors.Is(err, fs.ErrExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", takeoverPath, err)
+	}
+	defer os.Remove(takeoverPath)
+
+	// The first file is kept open so that its inode cannot be reused
+	file, info, data, err := openLockFile(lockPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	if _, stale := parseLockFile(data); !stale {
+		return false, nil
+	}
+
+	current, currentInfo, currentData, err := openLockFile(lockPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	current.Close()
+	if !os.SameFile(info, currentInfo) || !bytes.Equal(data, currentData) {
+		// Replaced by a new lock, which is checked again
+		return true, nil
+	}
+	if err := os.Remove(lockPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("failed to remove stale %s: %w", displayPath(lockPath), err)
+	}
+	return true, nil
+}
+
+// openLockFile opens a lock file and returns it with its file info and
+// content, which all belong to the same file even if it is being replaced
+func openLockFile(lockPath string) (*os.File, fs.FileInfo, []byte, error) {
+	file, err := os.Open(lockPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, nil, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, nil, err
+	}
+	return file, info, data, nil
+}
+
+// readLockFile returns the process holding a lock file and reports whether
+// the lock is stale, see parseLockFile
+func readLockFile(lockPath string) (int, bool, error) {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return 0, false, err
+	}
+	pid, stale := parseLockFile(data)
+	return pid, stale, nil
+}
+
+// parseLockFile returns the process recorded in a lock file and reports
+// whether the lock is stale: it was created on this host by a process that
+// no longer exists. Lock files that cannot be parsed are never stale.
+func parseLockFile(data []byte) (int, bool) {
+	var pid int
+	var host string
+	if _, err := fmt.Sscanf(string(data), "%d %s", &pid, &host); err != nil || pid <= 0 {
+		return 0, false
+	}
+	currentHost, _ := os.Hostname()
+	return pid, host == currentHost && !processExists(pid)
+}
+
+// lockTimeoutFromEnv returns the lock timeout set by MYGIT_LOCK_TIMEOUT
+func lockTimeoutFromEnv() (time.Duration, error) {
+	value := os.Getenv(LockTimeoutEnv)
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid %s '%s'", LockTimeoutEnv, value)
+	}
+	return timeout, nil
+}
+
+// displayPath returns a path relative to the current directory when it is
+// below it, for use in messages
+func displayPath(p string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return p
+	}
+	if rel, err := filepath.Rel(cwd, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return p
+}
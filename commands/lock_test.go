@@ -0,0 +1,270 @@
+package commands_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// writeLock creates a lock file as if it was held by the given process
+func writeLock(t *testing.T, name string, pid int) string {
+	t.Helper()
+	host, _ := os.Hostname()
+	lockPath := filepath.Join(commands.MyGitDir, name+commands.LockSuffix)
+	if err := os.WriteFile(lockPath, []byte(fmt.Sprintf("%d %s\n", pid, host)), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", lockPath, err)
+	}
+	return lockPath
+}
+
+// deadPID is above the largest process ID Linux hands out
+const deadPID = 1 << 30
+
+// TestLocks tests that commands refuse to run while another process holds
+// the files they update, and take over locks of crashed processes
+func TestLocks(t *testing.T) {
+	tests := []struct {
+		name          string
+		lock          string
+		pid           int
+		run           func() error
+		expectedError bool
+	}{
+		{
+			name:          "add with a held index lock",
+			lock:          commands.IndexFile,
+			pid:           os.Getpid(),
+			run:           func() error { return commands.Add([]string{"file.txt"}) },
+			expectedError: true,
+		},
+		{
+			name:          "commit with a held metadata lock",
+			lock:          commands.MetadataFile,
+			pid:           os.Getpid(),
+			run:           func() error { return commands.Commit("Third commit") },
+			expectedError: true,
+		},
+		{
+			name:          "branch with a held HEAD lock",
+			lock:          commands.HeadFile,
+			pid:           os.Getpid(),
+			run:           func() error { return commands.CreateBranch("feature", "") },
+			expectedError: true,
+		},
+		{
+			name:          "recover with a held index lock",
+			lock:          commands.IndexFile,
+			pid:           os.Getpid(),
+			run:           commands.Recover,
+			expectedError: true,
+		},
+		{
+			name: "status with a held index lock",
+			lock: commands.IndexFile,
+			pid:  os.Getpid(),
+			run: func() error {
+				_, err := commands.GetStatus()
+				return err
+			},
+		},
+		{
+			name: "add with a stale index lock",
+			lock: commands.IndexFile,
+			pid:  deadPID,
+			run:  func() error { return commands.Add([]string{"file.txt"}) },
+		},
+		{
+			name: "recover with a stale HEAD lock",
+			lock: commands.HeadFile,
+			pid:  deadPID,
+			run:  commands.Recover,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.pid == deadPID && runtime.GOOS == "windows" {
+				t.Skip("stale locks are not detected on this platform")
+			}
+			setupTwoCommits(t)
+			os.WriteFile("file.txt", []byte("version 3\n"), 0644)
+			commands.Add([]string{"file.txt"})
+			lockPath := writeLock(t, tt.lock, tt.pid)
+
+			err := tt.run()
+			if tt.expectedError {
+				var lockErr *commands.LockError
+				if !errors.As(err, &lockErr) {
+					t.Fatalf("expected a lock error, got %v", err)
+				}
+				if lockErr.PID != tt.pid {
+					t.Errorf("expected the error to name pid %d, got %d", tt.pid, lockErr.PID)
+				}
+				if _, err := os.Stat(lockPath); err != nil {
+					t.Errorf("expected the lock to be left alone")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.pid == deadPID {
+				if _, err := os.Stat(lockPath); err == nil {
+					t.Errorf("expected the stale lock to be removed")
+				}
+			}
+		})
+	}
+}
+
+// TestLockRelease tests that commands remove their locks when they finish,
+// including when they fail
+func TestLockRelease(t *testing.T) {
+	setupTwoCommits(t)
+	os.WriteFile("file.txt", []byte("version 3\n"), 0644)
+	commands.Add([]string{"file.txt"})
+	commands.Commit("Third commit")
+	commands.Commit("Nothing to commit")
+	commands.Merge("missing", commands.MergeOptions{})
+
+	for _, name := range []string{commands.IndexFile, commands.HeadFile, commands.MetadataFile} {
+		if _, err := os.Stat(filepath.Join(commands.MyGitDir, name+commands.LockSuffix)); err == nil {
+			t.Errorf("expected %s%s to be removed", name, commands.LockSuffix)
+		}
+	}
+}
+
+// TestLockWait tests waiting for a lock held by another process
+func TestLockWait(t *testing.T) {
+	tests := []struct {
+		name          string
+		timeout       string
+		release       time.Duration
+		expectedError bool
+	}{
+		{
+			name:          "no wait",
+			timeout:       "",
+			release:       200 * time.Millisecond,
+			expectedError: true,
+		},
+		{
+			name:    "released before the timeout",
+			timeout: "5s",
+			release: 200 * time.Millisecond,
+		},
+		{
+			name:          "timeout",
+			timeout:       "200ms",
+			release:       2 * time.Second,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTwoCommits(t)
+			t.Setenv(commands.LockTimeoutEnv, tt.timeout)
+			os.WriteFile("file.txt", []byte("version 3\n"), 0644)
+			lockPath := writeLock(t, commands.IndexFile, os.Getpid())
+			released := make(chan struct{})
+			go func() {
+				time.Sleep(tt.release)
+				os.Remove(lockPath)
+				close(released)
+			}()
+			defer func() { <-released }()
+
+			err := commands.Add([]string{"file.txt"})
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// TestInvalidLockTimeout tests that a malformed MYGIT_LOCK_TIMEOUT is rejected
+func TestInvalidLockTimeout(t *testing.T) {
+	setupTwoCommits(t)
+	t.Setenv(commands.LockTimeoutEnv, "soon")
+	if _, err := commands.GetStatus(); err == nil {
+		t.Errorf("expected error but got none")
+	}
+}
+
+// addProcessEnv names the file TestAddProcess adds when the test binary is
+// run as a separate mygit process
+const addProcessEnv = "MYGIT_TEST_ADD_FILE"
+
+// TestAddProcess adds a file in a process of its own for TestLockProcesses;
+// it does nothing in a normal test run
+func TestAddProcess(t *testing.T) {
+	file := os.Getenv(addProcessEnv)
+	if file == "" {
+		t.Skip("only run as a separate process")
+	}
+	if err := commands.Add([]string{file}); err != nil {
+		t.Fatalf("failed to add %s: %v", file, err)
+	}
+}
+
+// TestLockProcesses tests that processes adding files at the same time keep
+// all their changes, including when they take over a stale lock together
+func TestLockProcesses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stale locks are not detected on this platform")
+	}
+	for _, stale := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stale lock %v", stale), func(t *testing.T) {
+			for iteration := 0; iteration < 5; iteration++ {
+				setupTwoCommits(t)
+				if stale {
+					writeLock(t, commands.IndexFile, deadPID)
+				}
+				var processes []*exec.Cmd
+				for i := 0; i < 8; i++ {
+					file := fmt.Sprintf("file%d.txt", i)
+					os.WriteFile(file, []byte(file+"\n"), 0644)
+					cmd := exec.Command(os.Args[0], "-test.run=^TestAddProcess$")
+					cmd.Env = append(os.Environ(), addProcessEnv+"="+file, commands.LockTimeoutEnv+"=30s")
+					if err := cmd.Start(); err != nil {
+						t.Fatalf("failed to start process: %v", err)
+					}
+					processes = append(processes, cmd)
+				}
+				for _, cmd := range processes {
+					if err := cmd.Wait(); err != nil {
+						t.Errorf("process failed: %v", err)
+					}
+				}
+
+				repo, err := commands.Open(".")
+				if err != nil {
+					t.Fatalf("failed to open repository: %v", err)
+				}
+				idx, err := repo.ReadIndex()
+				if err != nil {
+					t.Fatalf("failed to read index: %v", err)
+				}
+				for i := 0; i < 8; i++ {
+					if _, ok := idx.Entry(fmt.Sprintf("file%d.txt", i)); !ok {
+						t.Errorf("iteration %d: expected file%d.txt to be staged", iteration, i)
+					}
+				}
+			}
+		})
+	}
+}
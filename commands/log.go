@@ -0,0 +1,821 @@
+package commands
+
+import (
+	"container/heap"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Formats of log
+const (
+	// FormatOneline shows the abbreviated ID and the subject of each commit
+	// on one line
+	FormatOneline = "oneline"
+	// FormatShort shows the ID, the author and the subject
+	FormatShort = "short"
+	// FormatMedium shows the ID, the author, the author date and the message
+	FormatMedium = "medium"
+	// FormatFull shows the ID, the author, the committer and the message
+	FormatFull = "full"
+)
+
+// Log shows the commit history
+func Log() error {
+	return LogWithOptions(LogOptions{})
+}
+
+// LogOptions controls which commits log shows and how
+type LogOptions struct {
+	// Revisions are the commits to start from, such as a tag, and the
+	// ranges to show, such as "v1.0..main" or "^main". HEAD is used when
+	// there are none.
+	Revisions []string
+	// Paths limits the history to the commits that changed these paths,
+	// and their changes to these paths
+	Paths []string
+	// MaxCount limits the number of commits shown when it is positive
+	MaxCount int
+	// Since and Until limit the history to the commits made after and
+	// before these dates, such as "2024-03-01" or "2 weeks ago"
+	Since string
+	Until string
+	// Author and Grep limit the history to the commits whose author
+	// ("Name <email>") and message match these regular expressions
+	Author string
+	Grep   string
+	// FirstParent follows only the first parent of merge commits
+	FirstParent bool
+	// Format is FormatOneline, FormatShort, FormatMedium (the default),
+	// FormatFull or a template such as "%h %an %s", optionally prefixed with
+	// "format:" or "tformat:". Templates expand %H and %h (the commit ID),
+	// %T and %t (the tree ID), %P and %p (the parent IDs), %an, %ae, %ad,
+	// %ar and %at (the author name, email and date), the same with c for the
+	// committer, %s (the subject), %b (the body), %B (the message), %n (a
+	// newline) and %% (a %).
+	Format string
+	// DateFormat is one of DateDefault, DateLocal, DateISO, DateRelative
+	// and DateRaw
+	DateFormat string
+	// Stat, NameStatus and Patch show what each commit changed compared
+	// with its first parent: line counts per file, the files with the kind
+	// of change, or the diff. NameStatus replaces the others. Merge commits
+	// show no changes.
+	Stat       bool
+	NameStatus bool
+	Patch      bool
+	// Graph draws the history to the left of the commits, which are shown
+	// in topological order
+	Graph bool
+}
+
+// LogWithOptions shows the commit history with the given options
+func LogWithOptions(opts LogOptions) error {
+	if err := CheckDateFormat(opts.DateFormat); err != nil {
+		return err
+	}
+	if err := checkLogFormat(opts.Format); err != nil {
+		return err
+	}
+
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	// As in Git, the arguments after the revisions name files
+	if len(opts.Paths) == 0 {
+		opts.Revisions, opts.Paths = r.splitRevisions(opts.Revisions)
+	}
+
+	// Check if there are any commits
+	walk, err := r.walkLog(opts)
+	if err != nil {
+		return err
+	}
+	if walk.unborn {
+		fmt.Println("No commits yet")
+		return nil
+	}
+
+	var graph *logGraph
+	if opts.Graph {
+		graph = &logGraph{}
+	}
+	for i, commit := range walk.commits {
+		for _, line := range graph.flush() {
+			fmt.Println(line)
+		}
+		prefix := graph.commit(commit.ID, walk.parents[commit.ID])
+		// Formats spanning several lines leave a blank line between commits
+		if i > 0 && separatesCommits(opts.Format) {
+			fmt.Println(graph.separator())
+		}
+
+		lines := formatLogEntry(commit, opts)
+		fmt.Println(prefix + lines[0])
+		for _, line := range lines[1:] {
+			fmt.Println(graph.next() + line)
+		}
+
+		changes, err := r.logChanges(commit, walk.paths, opts, statWidth-graph.indent())
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if opts.Format != FormatOneline {
+			separator := ""
+			if opts.Stat && opts.Patch && !opts.NameStatus {
+				separator = "---"
+			}
+			fmt.Println(graph.next() + separator)
+		}
+		for _, line := range graph.flush() {
+			fmt.Println(line)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(changes, "\n"), "\n") {
+			fmt.Println(graph.next() + line)
+		}
+	}
+	for _, line := range graph.flush() {
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// Log returns the commits from HEAD back through their first parents,
+// newest first. It is empty when the current branch has no commits yet.
+func (r *Repository) Log() ([]CommitRecord, error) {
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	return metadata.firstParents(r.headCommit(metadata))
+}
+
+// LogFrom returns the commits from rev, such as a tag, back through their
+// first parents, newest first
+func (r *Repository) LogFrom(rev string) ([]CommitRecord, error) {
+	commit, err := r.ResolveCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	return metadata.firstParents(commit)
+}
+
+// LogWithOptions returns the commits log shows with the given options, in
+// the order it shows them. Unlike Log it follows every parent of merge
+// commits, and shows the newest commits first.
+func (r *Repository) LogWithOptions(opts LogOptions) ([]CommitRecord, error) {
+	walk, err := r.walkLog(opts)
+	if err != nil {
+		return nil, err
+	}
+	return walk.commits, nil
+}
+
+// firstParents returns commit and its ancestors through first parents
+func (m *metadata) firstParents(commit *CommitRecord) ([]CommitRecord, error) {
+	var commits []CommitRecord
+	for commit != nil {
+		commits = append(commits, *commit)
+
+		// Follow the first parent
+		if len(commit.Parents) == 0 {
+			break
+		}
+		parentID := commit.Parents[0]
+		if commit = m.find(parentID); commit == nil {
+			return nil, fmt.Errorf("unknown commit: %s", parentID)
+		}
+	}
+	return commits, nil
+}
+
+// logWalk is the history selected by log
+type logWalk struct {
+	// commits holds the commits to show, in order
+	commits []CommitRecord
+	// parents maps each commit shown to its parents in the graph: the
+	// nearest ancestors that are shown too
+	parents map[string][]string
+	// paths holds the paths the history is limited to, relative to the
+	// root of the working tree
+	paths []string
+	// unborn is set when there are no commits to start from
+	unborn bool
+}
+
+// splitRevisions splits log arguments into revisions and paths: the first
+// argument that is not a revision but names a file starts the paths
+func (r *Repository) splitRevisions(args []string) ([]string, []string) {
+	for i, arg := range args {
+		if _, err := r.ResolveRange(arg); err == nil {
+			continue
+		}
+		if _, err := os.Lstat(absPath(r.cwd, arg)); err == nil {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+// walkLog selects the commits to show: those reachable from the included
+// revisions but not from the excluded ones, which pass the filters. Like
+// Git, the walk goes through the newest commit first and, when limited to
+// paths, follows only a parent of a merge that left the paths unchanged.
+func (r *Repository) walkLog(opts LogOptions) (*logWalk, error) {
+	filter, err := newLogFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	revisions := opts.Revisions
+	if len(revisions) == 0 {
+		if r.headCommit(metadata) == nil {
+			return &logWalk{unborn: true}, nil
+		}
+		revisions = []string{HeadFile}
+	}
+	walk := &logWalk{parents: make(map[string][]string)}
+	if walk.paths, err = r.repoPaths(opts.Paths); err != nil {
+		return nil, err
+	}
+
+	var include, exclude []string
+	for _, rev := range revisions {
+		revs, err := r.ResolveRange(rev)
+		if err != nil {
+			return nil, err
+		}
+		include = append(include, revs.Include...)
+		exclude = append(exclude, revs.Exclude...)
+	}
+	excluded := make(map[string]bool)
+	for queue := exclude; len(queue) > 0; queue = queue[1:] {
+		if id := queue[0]; !excluded[id] {
+			excluded[id] = true
+			if commit := metadata.find(id); commit != nil {
+				queue = append(queue, commit.Parents...)
+			}
+		}
+	}
+
+	// Walk from the included commits, newest first
+	var walked []*CommitRecord
+	followed := make(map[string][]string)
+	shown := make(map[string]bool)
+	queued := make(map[string]bool)
+	queue := &commitQueue{}
+	push := func(id string) error {
+		if queued[id] || excluded[id] {
+			return nil
+		}
+		commit := metadata.find(id)
+		if commit == nil {
+			return fmt.Errorf("unknown commit: %s", id)
+		}
+		queued[id] = true
+		queue.push(commit)
+		return nil
+	}
+	for _, id := range include {
+		if err := push(id); err != nil {
+			return nil, err
+		}
+	}
+	snapshots := make(map[string]string)
+	for queue.Len() > 0 {
+		commit := queue.pop()
+		parents := commit.Parents
+		if opts.FirstParent && len(parents) > 1 {
+			parents = parents[:1]
+		}
+		show := filter.match(commit)
+		if len(walk.paths) > 0 {
+			changed, same, err := r.changedPaths(metadata, commit, parents, walk.paths, snapshots)
+			if err != nil {
+				return nil, err
+			}
+			show = show && changed
+			if same != "" {
+				parents = []string{same}
+			}
+		}
+		walked = append(walked, commit)
+		followed[commit.ID] = parents
+		shown[commit.ID] = show
+		for _, parent := range parents {
+			if err := push(parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.Graph {
+		walked = topoSort(walked, followed)
+	}
+	for _, commit := range walked {
+		if shown[commit.ID] && (opts.MaxCount <= 0 || len(walk.commits) < opts.MaxCount) {
+			walk.commits = append(walk.commits, *commit)
+		}
+	}
+
+	// Draw the graph through the commits that are shown
+	rewritten := make(map[string][]string)
+	var rewrite func(id string) []string
+	rewrite = func(id string) []string {
+		if shown[id] {
+			return []string{id}
+		}
+		if ids, ok := rewritten[id]; ok {
+			return ids
+		}
+		var ids []string
+		for _, parent := range followed[id] {
+			ids = appendUnique(ids, rewrite(parent)...)
+		}
+		rewritten[id] = ids
+		return ids
+	}
+	for _, commit := range walk.commits {
+		var parents []string
+		for _, parent := range followed[commit.ID] {
+			parents = appendUnique(parents, rewrite(parent)...)
+		}
+		walk.parents[commit.ID] = parents
+	}
+	return walk, nil
+}
+
+// changedPaths reports whether a commit changed the given paths compared
+// with each of its parents. When it left them as one of the parents had
+// them, that parent is returned.
+func (r *Repository) changedPaths(metadata *metadata, commit *CommitRecord, parents, paths []string, snapshots map[string]string) (bool, string, error) {
+	snapshot := func(commit *CommitRecord) (string, error) {
+		if key, ok := snapshots[commit.ID]; ok {
+			return key, nil
+		}
+		files, err := r.commitFiles(commit)
+		if err != nil {
+			return "", fmt.Errorf("failed to read commit %s: %w", commit.ID, err)
+		}
+		var key strings.Builder
+		for _, file := range files {
+			if matchPathspec(paths, file.Path) {
+				fmt.Fprintf(&key, "%s %s %s\n", file.Mode, file.Hash, file.Path)
+			}
+		}
+		snapshots[commit.ID] = key.String()
+		return key.String(), nil
+	}
+
+	key, err := snapshot(commit)
+	if err != nil {
+		return false, "", err
+	}
+	if len(parents) == 0 {
+		return key != "", "", nil
+	}
+	for _, id := range parents {
+		parent := metadata.find(id)
+		if parent == nil {
+			return false, "", fmt.Errorf("unknown commit: %s", id)
+		}
+		parentKey, err := snapshot(parent)
+		if err != nil {
+			return false, "", err
+		}
+		if parentKey == key {
+			return false, id, nil
+		}
+	}
+	return true, "", nil
+}
+
+// topoSort orders commits so that each comes before its parents and the
+// commits of a branch stay together, the way Git orders them for --graph
+func topoSort(commits []*CommitRecord, parents map[string][]string) []*CommitRecord {
+	// indegree is one more than the number of children not sorted yet
+	indegree := make(map[string]int, len(commits))
+	byID := make(map[string]*CommitRecord, len(commits))
+	for _, commit := range commits {
+		indegree[commit.ID] = 1
+		byID[commit.ID] = commit
+	}
+	for _, commit := range commits {
+		for _, parent := range parents[commit.ID] {
+			if indegree[parent] > 0 {
+				indegree[parent]++
+			}
+		}
+	}
+
+	// Start from the tips; the last parent pushed is sorted first
+	var stack []*CommitRecord
+	for i := len(commits) - 1; i >= 0; i-- {
+		if indegree[commits[i].ID] == 1 {
+			stack = append(stack, commits[i])
+		}
+	}
+	sorted := make([]*CommitRecord, 0, len(commits))
+	for len(stack) > 0 {
+		commit := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, parent := range parents[commit.ID] {
+			if indegree[parent] == 0 {
+				continue
+			}
+			if indegree[parent]--; indegree[parent] == 1 {
+				stack = append(stack, byID[parent])
+			}
+		}
+		sorted = append(sorted, commit)
+	}
+	return sorted
+}
+
+// appendUnique appends the IDs that ids does not hold yet
+func appendUnique(ids []string, more ...string) []string {
+	for _, id := range more {
+		found := false
+		for _, existing := range ids {
+			if existing == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// commitQueue holds commits to walk, newest first. Commits with the same
+// date come out in the order they went in.
+type commitQueue struct {
+	commits []*CommitRecord
+	order   []int
+	next    int
+}
+
+func (q *commitQueue) Len() int { return len(q.commits) }
+
+func (q *commitQueue) Less(i, j int) bool {
+	if !q.commits[i].Time.Equal(q.commits[j].Time) {
+		return q.commits[i].Time.After(q.commits[j].Time)
+	}
+	return q.order[i] < q.order[j]
+}
+
+func (q *commitQueue) Swap(i, j int) {
+	q.commits[i], q.commits[j] = q.commits[j], q.commits[i]
+	q.order[i], q.order[j] = q.order[j], q.order[i]
+}
+
+func (q *commitQueue) Push(x any) {
+	q.commits = append(q.commits, x.(*CommitRecord))
+	q.order = append(q.order, q.next)
+	q.next++
+}
+
+func (q *commitQueue) Pop() any {
+	n := len(q.commits) - 1
+	commit := q.commits[n]
+	q.commits, q.order = q.commits[:n], q.order[:n]
+	return commit
+}
+
+func (q *commitQueue) push(commit *CommitRecord) { heap.Push(q, commit) }
+
+func (q *commitQueue) pop() *CommitRecord { return heap.Pop(q).(*CommitRecord) }
+
+// logFilter selects commits by date, author and message
+type logFilter struct {
+	since  time.Time
+	until  time.Time
+	author *regexp.Regexp
+	grep   *regexp.Regexp
+}
+
+func newLogFilter(opts LogOptions) (*logFilter, error) {
+	filter := &logFilter{}
+	now := time.Now()
+	var err error
+	if opts.Since != "" {
+		if filter.since, err = parseApproxDate(opts.Since, now); err != nil {
+			return nil, usageErrorf("invalid --since: %v", err)
+		}
+	}
+	if opts.Until != "" {
+		if filter.until, err = parseApproxDate(opts.Until, now); err != nil {
+			return nil, usageErrorf("invalid --until: %v", err)
+		}
+	}
+	if opts.Author != "" {
+		if filter.author, err = regexp.Compile(opts.Author); err != nil {
+			return nil, usageErrorf("invalid --author pattern: %v", err)
+		}
+	}
+	if opts.Grep != "" {
+		if filter.grep, err = regexp.Compile(opts.Grep); err != nil {
+			return nil, usageErrorf("invalid --grep pattern: %v", err)
+		}
+	}
+	return filter, nil
+}
+
+// match reports whether a commit passes the filter. Dates are compared with
+// the committer date, like in Git.
+func (f *logFilter) match(commit *CommitRecord) bool {
+	if !f.since.IsZero() && commit.Time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && commit.Time.After(f.until) {
+		return false
+	}
+	if f.author != nil && !f.author.MatchString(commit.Author.String()) {
+		return false
+	}
+	if f.grep != nil && !f.grep.MatchString(commit.Message) {
+		return false
+	}
+	return true
+}
+
+// logChanges formats what a commit changed in the given paths for --stat,
+// --name-status or -p, fitting --stat in width columns
+func (r *Repository) logChanges(commit CommitRecord, paths []string, opts LogOptions, width int) (string, error) {
+	if !opts.Stat && !opts.NameStatus && !opts.Patch || len(commit.Parents) > 1 {
+		return "", nil
+	}
+	oldSide, newSide, err := r.commitSides(commit, paths)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.NameStatus {
+		return formatNameStatus(diffSnapshots(oldSide.files, newSide.files)), nil
+	}
+	var out strings.Builder
+	if opts.Stat {
+		stats, err := diffStats(oldSide, newSide)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(formatStat(stats, width))
+	}
+	if opts.Patch {
+		patch, err := formatDiff(oldSide, newSide, DefaultContextLines)
+		if err != nil {
+			return "", err
+		}
+		if out.Len() > 0 && patch != "" {
+			out.WriteString("\n")
+		}
+		out.WriteString(patch)
+	}
+	return out.String(), nil
+}
+
+// commitSides returns the first parent of a commit and the commit as diff
+// sides, limited to the given paths. A commit without parents is compared
+// with nothing.
+func (r *Repository) commitSides(commit CommitRecord, paths []string) (diffSource, diffSource, error) {
+	oldSide, newSide := diffSource{repo: r}, diffSource{repo: r}
+	var err error
+	if newSide.files, err = r.commitFiles(&commit); err != nil {
+		return oldSide, newSide, fmt.Errorf("failed to read commit %s: %w", commit.ID, err)
+	}
+	if len(commit.Parents) > 0 {
+		if oldSide, err = r.commitSource(commit.Parents[0]); err != nil {
+			return oldSide, newSide, err
+		}
+	}
+	if len(paths) > 0 {
+		oldSide.files = filterFiles(oldSide.files, paths)
+		newSide.files = filterFiles(newSide.files, paths)
+	}
+	return oldSide, newSide, nil
+}
+
+// filterFiles returns the files named by the given paths
+func filterFiles(files []FileEntry, paths []string) []FileEntry {
+	var matched []FileEntry
+	for _, file := range files {
+		if matchPathspec(paths, file.Path) {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
+
+// checkLogFormat returns an error unless format is one of the formats of
+// log or a template
+func checkLogFormat(format string) error {
+	switch format {
+	case "", FormatOneline, FormatShort, FormatMedium, FormatFull:
+		return nil
+	}
+	if _, ok := logTemplate(format); ok {
+		return nil
+	}
+	return usageErrorf("unknown format '%s': use oneline, short, medium, full or a template such as '%%h %%s'", format)
+}
+
+// logTemplate returns the template of a format such as "format:%h %s", and
+// whether it is one
+func logTemplate(format string) (string, bool) {
+	if template, ok := strings.CutPrefix(format, "format:"); ok {
+		return template, true
+	}
+	if template, ok := strings.CutPrefix(format, "tformat:"); ok {
+		return template, true
+	}
+	return format, strings.Contains(format, "%")
+}
+
+// separatesCommits reports whether a format leaves a blank line between
+// commits
+func separatesCommits(format string) bool {
+	switch format {
+	case "", FormatShort, FormatMedium, FormatFull:
+		return true
+	}
+	return false
+}
+
+// formatLogEntry formats the lines log shows for a commit, before its changes
+func formatLogEntry(commit CommitRecord, opts LogOptions) []string {
+	if template, ok := logTemplate(opts.Format); ok && opts.Format != "" {
+		return strings.Split(expandLogTemplate(template, commit, opts.DateFormat), "\n")
+	}
+	if opts.Format == FormatOneline {
+		return []string{shortID(commit.ID) + " " + commitSubject(commit.Message)}
+	}
+
+	lines := []string{"commit " + commit.ID}
+	if len(commit.Parents) > 1 {
+		short := make([]string, len(commit.Parents))
+		for i, parent := range commit.Parents {
+			short[i] = shortID(parent)
+		}
+		lines = append(lines, "Merge: "+strings.Join(short, " "))
+	}
+	if !commit.Author.IsZero() {
+		lines = append(lines, "Author: "+commit.Author.String())
+	}
+	switch opts.Format {
+	case FormatShort:
+		return append(lines, "", "    "+commitSubject(commit.Message))
+	case FormatFull:
+		if !commit.Committer.IsZero() {
+			lines = append(lines, "Commit: "+commit.Committer.String())
+		}
+	default:
+		lines = append(lines, "Date:   "+FormatDate(authorDate(commit), opts.DateFormat))
+	}
+	lines = append(lines, "")
+	for _, line := range strings.Split(commit.Message, "\n") {
+		lines = append(lines, "    "+line)
+	}
+	return lines
+}
+
+// expandLogTemplate expands the placeholders of a --format template
+func expandLogTemplate(template string, commit CommitRecord, dateFormat string) string {
+	committerDate := commit.Committer.When
+	if committerDate.IsZero() {
+		committerDate = commit.Time
+	}
+	people := map[byte]struct {
+		signature Signature
+		date      time.Time
+	}{
+		'a': {commit.Author, authorDate(commit)},
+		'c': {commit.Committer, committerDate},
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' || i+1 == len(template) {
+			out.WriteByte(template[i])
+			continue
+		}
+		i++
+		switch template[i] {
+		case 'H':
+			out.WriteString(commit.ID)
+		case 'h':
+			out.WriteString(shortID(commit.ID))
+		case 'T':
+			out.WriteString(commit.Tree)
+		case 't':
+			out.WriteString(shortID(commit.Tree))
+		case 'P':
+			out.WriteString(strings.Join(commit.Parents, " "))
+		case 'p':
+			short := make([]string, len(commit.Parents))
+			for i, parent := range commit.Parents {
+				short[i] = shortID(parent)
+			}
+			out.WriteString(strings.Join(short, " "))
+		case 's':
+			out.WriteString(commitSubject(commit.Message))
+		case 'b':
+			out.WriteString(commitBody(commit.Message))
+		case 'B':
+			out.WriteString(commit.Message + "\n")
+		case 'n':
+			out.WriteByte('\n')
+		case '%':
+			out.WriteByte('%')
+		case 'a', 'c':
+			person := people[template[i]]
+			if i+1 == len(template) {
+				out.WriteString("%" + template[i:])
+				continue
+			}
+			i++
+			switch template[i] {
+			case 'n':
+				out.WriteString(person.signature.Name)
+			case 'e':
+				out.WriteString(person.signature.Email)
+			case 'd':
+				out.WriteString(FormatDate(person.date, dateFormat))
+			case 'r':
+				out.WriteString(FormatDate(person.date, DateRelative))
+			case 't':
+				out.WriteString(fmt.Sprint(person.date.Unix()))
+			default:
+				out.WriteString("%" + template[i-1:i+1])
+			}
+		default:
+			// Unknown placeholders are kept as they are
+			out.WriteString("%" + template[i:i+1])
+		}
+	}
+	return out.String()
+}
+
+// authorDate returns when the changes of a commit were written, which is
+// all older commits know
+func authorDate(commit CommitRecord) time.Time {
+	if commit.Author.When.IsZero() {
+		return commit.Time
+	}
+	return commit.Author.When
+}
+
+// shortID abbreviates an object ID to 7 hex digits
+func shortID(id string) string {
+	if len(id) > 7 {
+		return id[:7]
+	}
+	return id
+}
+
+// commitSubject returns the first paragraph of a message on one line
+func commitSubject(message string) string {
+	subject, _ := splitMessage(message)
+	return strings.Join(subject, " ")
+}
+
+// commitBody returns the message after its first paragraph, ending with a
+// newline unless it is empty
+func commitBody(message string) string {
+	_, body := splitMessage(message)
+	if len(body) == 0 {
+		return ""
+	}
+	return strings.Join(body, "\n") + "\n"
+}
+
+// splitMessage splits a message into the lines of its first paragraph and
+// the lines after the blank lines that follow it
+func splitMessage(message string) ([]string, []string) {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	i := 0
+	for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+		i++
+	}
+	subject := lines[:i]
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	return subject, lines[i:]
+}
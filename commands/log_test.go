@@ -0,0 +1,182 @@
+package commands_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// setupLogHistory creates a history where main and feature both change
+// files before feature is merged, with a minute between commits
+func setupLogHistory(t *testing.T) *commands.Repository {
+	t.Helper()
+	setFixedIdentity(t)
+	ids := setupTwoCommits(t)
+	minute := 0
+	next := func() {
+		minute++
+		for _, env := range []string{commands.AuthorDateEnv, commands.CommitterDateEnv} {
+			t.Setenv(env, fmt.Sprintf("%d +0100", 1700000000+60*minute))
+		}
+	}
+
+	commands.CreateBranch("feature", ids[0])
+	commands.Switch("feature", commands.SwitchOptions{})
+	os.WriteFile("notes.txt", []byte("one\ntwo\n"), 0644)
+	commands.Add([]string{"notes.txt"})
+	next()
+	commands.Commit("Add notes")
+	commands.Switch("main", commands.SwitchOptions{})
+	os.WriteFile("file.txt", []byte("version 3\nwith a second line\n"), 0644)
+	commands.Add([]string{"file.txt"})
+	next()
+	commands.Commit("Third commit\n\nWith a body\nof two lines")
+	commands.Switch("feature", commands.SwitchOptions{})
+	os.WriteFile("notes.txt", []byte("one\n2\nthree\n"), 0644)
+	commands.Add([]string{"notes.txt"})
+	next()
+	commands.Commit("Update notes")
+	commands.Switch("main", commands.SwitchOptions{})
+	next()
+	if err := commands.Merge("feature", commands.MergeOptions{}); err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+	commands.Remove([]string{"dir/new.txt"}, commands.RemoveOptions{})
+	next()
+	commands.Commit("Remove new.txt")
+
+	repo, err := commands.Open(".")
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	return repo
+}
+
+// TestLogWithOptions tests selecting commits with revisions, paths and
+// filters
+func TestLogWithOptions(t *testing.T) {
+	repo := setupLogHistory(t)
+
+	tests := []struct {
+		name     string
+		opts     commands.LogOptions
+		expected string
+	}{
+		{
+			name:     "all commits, newest first",
+			expected: "Remove new.txt, Merge branch 'feature', Update notes, Third commit, Add notes, Second commit, First commit",
+		},
+		{
+			name:     "topological order",
+			opts:     commands.LogOptions{Graph: true},
+			expected: "Remove new.txt, Merge branch 'feature', Update notes, Add notes, Third commit, Second commit, First commit",
+		},
+		{name: "max count", opts: commands.LogOptions{MaxCount: 2}, expected: "Remove new.txt, Merge branch 'feature'"},
+		{
+			name:     "first parent",
+			opts:     commands.LogOptions{FirstParent: true},
+			expected: "Remove new.txt, Merge branch 'feature', Third commit, Second commit, First commit",
+		},
+		{name: "revision", opts: commands.LogOptions{Revisions: []string{"feature"}}, expected: "Update notes, Add notes, First commit"},
+		{name: "range", opts: commands.LogOptions{Revisions: []string{"main~2..feature"}}, expected: "Update notes, Add notes"},
+		{
+			name:     "symmetric range",
+			opts:     commands.LogOptions{Revisions: []string{"main~2...feature"}},
+			expected: "Update notes, Third commit, Add notes, Second commit",
+		},
+		{name: "exclusion", opts: commands.LogOptions{Revisions: []string{"main", "^main~2"}}, expected: "Remove new.txt, Merge branch 'feature', Update notes, Add notes"},
+		{name: "file", opts: commands.LogOptions{Paths: []string{"notes.txt"}}, expected: "Update notes, Add notes"},
+		{name: "directory", opts: commands.LogOptions{Paths: []string{"dir"}}, expected: "Remove new.txt, Second commit"},
+		{name: "glob", opts: commands.LogOptions{Paths: []string{"*.txt"}}, expected: "Merge branch 'feature', Update notes, Third commit, Add notes, Second commit, First commit"},
+		{name: "author", opts: commands.LogOptions{Author: "^Alice <alice@"}, expected: "Remove new.txt, Merge branch 'feature', Update notes, Third commit, Add notes, Second commit, First commit"},
+		{name: "other author", opts: commands.LogOptions{Author: "Bob"}, expected: ""},
+		{name: "grep", opts: commands.LogOptions{Grep: "notes|body"}, expected: "Update notes, Third commit, Add notes"},
+		{name: "since", opts: commands.LogOptions{Since: "1700000120 +0100"}, expected: "Remove new.txt, Merge branch 'feature', Update notes, Third commit"},
+		{name: "until", opts: commands.LogOptions{Until: "2023-11-14 23:14:30 +0100"}, expected: "Add notes, Second commit, First commit"},
+		{name: "relative date", opts: commands.LogOptions{Since: "2 weeks ago"}, expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits, err := repo.LogWithOptions(tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var subjects []string
+			for _, commit := range commits {
+				subjects = append(subjects, strings.SplitN(commit.Message, "\n", 2)[0])
+			}
+			if got := strings.Join(subjects, ", "); got != tt.expected {
+				t.Errorf("expected\n%s\ngot\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestLogErrors tests rejecting invalid options
+func TestLogErrors(t *testing.T) {
+	setupLogHistory(t)
+	tests := []struct {
+		name string
+		opts commands.LogOptions
+	}{
+		{name: "unknown format", opts: commands.LogOptions{Format: "fancy"}},
+		{name: "unknown date format", opts: commands.LogOptions{DateFormat: "short"}},
+		{name: "invalid author pattern", opts: commands.LogOptions{Author: "["}},
+		{name: "invalid grep pattern", opts: commands.LogOptions{Grep: "("}},
+		{name: "invalid date", opts: commands.LogOptions{Since: "someday"}},
+		{name: "unknown revision", opts: commands.LogOptions{Revisions: []string{"missing"}}},
+		{name: "path outside the repository", opts: commands.LogOptions{Paths: []string{"../elsewhere"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := commands.LogWithOptions(tt.opts); err == nil {
+				t.Errorf("expected error but got none")
+			}
+		})
+	}
+}
+
+// TestLogOutput tests that log prints what git log prints for the same
+// history
+func TestLogOutput(t *testing.T) {
+	repo := setupLogHistory(t)
+	gitDir := filepath.Join(t.TempDir(), "export.git")
+	if _, err := repo.ExportGit(gitDir); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	tests := []struct {
+		opts commands.LogOptions
+		args []string
+	}{
+		{opts: commands.LogOptions{}, args: nil},
+		{opts: commands.LogOptions{Format: commands.FormatOneline, Graph: true}, args: []string{"--oneline", "--graph"}},
+		{opts: commands.LogOptions{Graph: true}, args: []string{"--graph"}},
+		{opts: commands.LogOptions{Stat: true}, args: []string{"--stat"}},
+		{opts: commands.LogOptions{Graph: true, Stat: true, Patch: true}, args: []string{"--graph", "--stat", "-p"}},
+		{opts: commands.LogOptions{Format: commands.FormatOneline, Patch: true}, args: []string{"--oneline", "-p"}},
+		{opts: commands.LogOptions{Format: commands.FormatOneline, NameStatus: true, Graph: true}, args: []string{"--oneline", "--name-status", "--graph"}},
+		{opts: commands.LogOptions{Format: commands.FormatShort, FirstParent: true}, args: []string{"--format=short", "--first-parent"}},
+		{opts: commands.LogOptions{Format: commands.FormatFull, MaxCount: 3}, args: []string{"--format=full", "-n", "3"}},
+		{
+			opts: commands.LogOptions{Format: "%H %h %T %t %P %p%n%an <%ae> %ad %at%n%cn <%ce> %cd %ct%n%s%n%b%%", DateFormat: commands.DateISO},
+			args: []string{"--format=%H %h %T %t %P %p%n%an <%ae> %ad %at%n%cn <%ce> %cd %ct%n%s%n%b%%", "--date=iso"},
+		},
+		{opts: commands.LogOptions{Format: commands.FormatOneline, Revisions: []string{"main~2..feature"}}, args: []string{"--oneline", "main~2..feature"}},
+		{opts: commands.LogOptions{Stat: true, Graph: true, Paths: []string{"notes.txt"}}, args: []string{"--stat", "--graph", "--", "notes.txt"}},
+		{opts: commands.LogOptions{Format: commands.FormatOneline, Revisions: []string{"file.txt"}}, args: []string{"--oneline", "--", "file.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(append([]string{"log"}, tt.args...), " "), func(t *testing.T) {
+			out := captureOutput(t, func() error { return commands.LogWithOptions(tt.opts) })
+			expected := runGit(t, gitDir, append([]string{"log"}, tt.args...)...)
+			if strings.TrimSpace(out) != expected {
+				t.Errorf("expected\n%s\ngot\n%s", expected, out)
+			}
+		})
+	}
+}
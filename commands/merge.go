@@ -0,0 +1,437 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// MergeHeadFile holds the commit being merged while conflicts are resolved
+	MergeHeadFile = "MERGE_HEAD"
+	// MergeMsgFile holds the message prepared for the merge commit
+	MergeMsgFile = "MERGE_MSG"
+)
+
+// MergeOptions controls Merge
+type MergeOptions struct {
+	// NoFastForward creates a merge commit even when HEAD could simply be
+	// moved forward
+	NoFastForward bool
+	// Message replaces the default merge commit message
+	Message string
+}
+
+// Merge joins the history of a branch or commit into the current branch.
+// When HEAD is an ancestor of the other commit the branch is fast-forwarded;
+// otherwise each file is merged line by line against the merge base and a
+// commit with both parents is created. Conflicting files are written with
+// conflict markers and recorded in the index, and the merge commit is created
+// by Commit once they have been resolved and added.
+func Merge(rev string, opts MergeOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	unlock, err := r.lock(IndexFile, HeadFile, MetadataFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	mergeHead, _, err := r.readMergeState()
+	if err != nil {
+		return err
+	}
+	if mergeHead != "" {
+		return errors.New("you have not concluded your merge (MERGE_HEAD exists); commit the result first")
+	}
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	if len(idx.Conflicts) > 0 {
+		return kindErrorf(ErrConflict, "merging is not possible because you have unmerged files")
+	}
+
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return err
+	}
+	theirs, err := r.ResolveCommit(rev)
+	if err != nil {
+		return err
+	}
+	theirsID := theirs.ID
+
+	ours := r.headCommit(metadata)
+	if ours == nil {
+		// Nothing to merge into yet; adopt the other history
+		if err := r.checkoutCommit(theirs, false); err != nil {
+			return err
+		}
+		if err := r.updateHead(theirsID, "merge "+rev+": Fast-forward"); err != nil {
+			return err
+		}
+		fmt.Printf("Fast-forward to %s\n", theirsID[:7])
+		return nil
+	}
+	oursID := ours.ID
+
+	baseID := metadata.mergeBase(oursID, theirsID)
+	switch {
+	case baseID == "":
+		return fmt.Errorf("refusing to merge unrelated histories: %s", rev)
+	case baseID == theirsID:
+		fmt.Println("Already up to date.")
+		return nil
+	case baseID == oursID && !opts.NoFastForward:
+		if err := r.checkoutCommit(theirs, false); err != nil {
+			return err
+		}
+		if err := r.updateHead(theirsID, "merge "+rev+": Fast-forward"); err != nil {
+			return err
+		}
+		fmt.Printf("Updating %s..%s\n", oursID[:7], theirsID[:7])
+		fmt.Println("Fast-forward")
+		return nil
+	}
+
+	// A three-way merge starts from a clean index
+	oursFiles, err := r.commitFiles(ours)
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %w", oursID, err)
+	}
+	if len(diffSnapshots(oursFiles, idx.Files())) > 0 {
+		return errors.New("your index contains uncommitted changes; commit them before merging")
+	}
+	theirsFiles, err := r.commitFiles(theirs)
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %w", theirsID, err)
+	}
+	baseFiles, err := r.commitFiles(metadata.find(baseID))
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %w", baseID, err)
+	}
+
+	results, err := r.mergeSnapshots(baseFiles, oursFiles, theirsFiles, rev)
+	if err != nil {
+		return err
+	}
+
+	// Refuse to overwrite local modifications to files the merge changes
+	var dirty []string
+	for _, result := range results {
+		clean, err := r.workTreeSafe(idx, result.path, result.file, result.exists && result.conflict == nil)
+		if err != nil {
+			return err
+		}
+		if !clean {
+			dirty = append(dirty, result.path)
+		}
+	}
+	if len(dirty) > 0 {
+		return kindErrorf(ErrLocalChanges, "your local changes to the following files would be overwritten by merge:\n\t%s\ncommit them before merging", strings.Join(dirty, "\n\t"))
+	}
+
+	message := opts.Message
+	if message == "" {
+		message = r.mergeMessage(rev)
+	}
+
+	// Apply the merged snapshot to the index and working tree
+	var conflicted []string
+	for _, result := range results {
+		switch {
+		case result.conflict != nil:
+			if err := r.writeConflictFile(result); err != nil {
+				return err
+			}
+			idx.SetConflict(*result.conflict)
+			conflicted = append(conflicted, result.path)
+			fmt.Printf("CONFLICT (%s): Merge conflict in %s\n", conflictKind(*result.conflict), result.path)
+		case result.exists:
+			entry, err := r.checkoutFile(result.file)
+			if err != nil {
+				return err
+			}
+			idx.Set(entry)
+		default:
+			if err := r.removeWorkTreeFile(result.path); err != nil {
+				return err
+			}
+			idx.Remove(result.path)
+		}
+	}
+	if err := idx.Write(); err != nil {
+		return err
+	}
+
+	if len(conflicted) > 0 {
+		if err := r.writeMergeState(theirsID, message); err != nil {
+			return err
+		}
+		return kindErrorf(ErrConflict, "automatic merge failed; fix conflicts and then commit the result")
+	}
+
+	treeHash, err := r.WriteTree(idx.Files())
+	if err != nil {
+		return fmt.Errorf("failed to write tree: %w", err)
+	}
+	commit, err := r.writeCommit(metadata, message, treeHash, []string{oursID, theirsID}, CommitOptions{})
+	if err != nil {
+		return err
+	}
+	if err := r.updateHead(commit.ID, "merge "+rev+": Merge made by the three-way strategy."); err != nil {
+		return err
+	}
+
+	fmt.Printf("Merge made by the three-way strategy.\n")
+	fmt.Printf("Commit ID: %s\n", commit.ID)
+	return nil
+}
+
+// Merging reports whether a merge is waiting for its conflicts to be
+// resolved and committed
+func Merging() bool {
+	r, err := openRepository()
+	if err != nil {
+		return false
+	}
+	mergeHead, _, err := r.readMergeState()
+	return err == nil && mergeHead != ""
+}
+
+// mergeResult is the outcome of merging one path that differs from ours
+type mergeResult struct {
+	path string
+	// file is the merged content, valid when exists is set and there is no conflict
+	file   FileEntry
+	exists bool
+	// conflict is set when the path could not be merged automatically
+	conflict *ConflictEntry
+	// content is the working tree content written for a conflicted text file
+	content []byte
+}
+
+// mergeSnapshots merges ours and theirs against base and returns the paths
+// whose result differs from ours, sorted by path. Merged blobs are written to
+// the object store.
+func (r *Repository) mergeSnapshots(base, ours, theirs []FileEntry, theirsLabel string) ([]mergeResult, error) {
+	baseFiles := make(map[string]FileEntry, len(base))
+	paths := make(map[string]bool)
+	for _, file := range base {
+		baseFiles[file.Path] = file
+		paths[file.Path] = true
+	}
+	oursFiles := make(map[string]FileEntry, len(ours))
+	for _, file := range ours {
+		oursFiles[file.Path] = file
+		paths[file.Path] = true
+	}
+	theirsFiles := make(map[string]FileEntry, len(theirs))
+	for _, file := range theirs {
+		theirsFiles[file.Path] = file
+		paths[file.Path] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	same := func(a FileEntry, aOK bool, b FileEntry, bOK bool) bool {
+		return aOK == bOK && (!aOK || (a.Hash == b.Hash && a.Mode == b.Mode))
+	}
+
+	var results []mergeResult
+	for _, path := range sorted {
+		b, inBase := baseFiles[path]
+		o, inOurs := oursFiles[path]
+		t, inTheirs := theirsFiles[path]
+
+		// Only one side changed, or both made the same change
+		if same(o, inOurs, t, inTheirs) || same(b, inBase, t, inTheirs) {
+			continue
+		}
+		if same(b, inBase, o, inOurs) {
+			results = append(results, mergeResult{path: path, file: t, exists: inTheirs})
+			continue
+		}
+
+		conflict := &ConflictEntry{Path: path}
+		if inBase {
+			conflict.BaseHash, conflict.BaseMode = b.Hash, b.Mode
+		}
+		if inOurs {
+			conflict.OursHash, conflict.OursMode = o.Hash, o.Mode
+		}
+		if inTheirs {
+			conflict.TheirsHash, conflict.TheirsMode = t.Hash, t.Mode
+		}
+
+		// A file deleted on one side and modified on the other cannot be merged
+		if !inOurs || !inTheirs {
+			results = append(results, mergeResult{path: path, exists: true, conflict: conflict})
+			continue
+		}
+
+		// Submodule commits and link targets cannot be merged line by line
+		if isUnmergeable(o.Mode) || isUnmergeable(t.Mode) || (inBase && isUnmergeable(b.Mode)) {
+			results = append(results, mergeResult{path: path, exists: true, conflict: conflict})
+			continue
+		}
+
+		var baseContent []byte
+		if inBase {
+			_, content, err := r.ReadObject(b.Hash)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			baseContent = content
+		}
+		_, oursContent, err := r.ReadObject(o.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		_, theirsContent, err := r.ReadObject(t.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		// Take the mode change from whichever side made one
+		mode := o.Mode
+		if inBase && o.Mode == b.Mode {
+			mode = t.Mode
+		}
+
+		if IsBinary(baseContent) || IsBinary(oursContent) || IsBinary(theirsContent) {
+			results = append(results, mergeResult{path: path, exists: true, conflict: conflict})
+			continue
+		}
+		merged, conflicted := MergeLines(SplitLines(baseContent), SplitLines(oursContent), SplitLines(theirsContent), "HEAD", theirsLabel)
+		if conflicted {
+			results = append(results, mergeResult{path: path, exists: true, conflict: conflict, content: []byte(merged)})
+			continue
+		}
+
+		hash, err := r.WriteObject(BlobObject, []byte(merged))
+		if err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", path, err)
+		}
+		file := FileEntry{Path: path, Mode: mode, Hash: hash}
+		if same(o, true, file, true) {
+			continue
+		}
+		results = append(results, mergeResult{path: path, file: file, exists: true})
+	}
+	return results, nil
+}
+
+// writeConflictFile writes the working tree version of a conflicted path:
+// the text with conflict markers, or otherwise whichever side still has the
+// file, preferring ours
+func (r *Repository) writeConflictFile(result mergeResult) error {
+	conflict := result.conflict
+	file := FileEntry{Path: result.path, Mode: conflict.OursMode, Hash: conflict.OursHash}
+	if conflict.OursHash == "" {
+		file = FileEntry{Path: result.path, Mode: conflict.TheirsMode, Hash: conflict.TheirsHash}
+	}
+	if result.content == nil {
+		if file.Mode == ModeGitlink {
+			return nil
+		}
+		_, err := r.writeWorkTreeFile(file)
+		return err
+	}
+
+	if !r.inWorkTree(result.path) || r.linkInPath(result.path) {
+		return fmt.Errorf("refusing to write %s outside the working tree", result.path)
+	}
+	path := r.workPath(result.path)
+	perm := os.FileMode(0644)
+	if file.Mode == ModeExecutable {
+		perm = 0755
+	}
+	if err := os.WriteFile(path, result.content, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", result.path, err)
+	}
+	return nil
+}
+
+// isUnmergeable reports whether files of a mode are kept whole in a merge
+func isUnmergeable(mode string) bool {
+	return mode == ModeGitlink || mode == ModeSymlink
+}
+
+// conflictKind describes why a path could not be merged, using Git's terms
+func conflictKind(conflict ConflictEntry) string {
+	switch {
+	case conflict.OursHash == "" || conflict.TheirsHash == "":
+		return "modify/delete"
+	case conflict.BaseHash == "":
+		return "add/add"
+	default:
+		return "content"
+	}
+}
+
+// mergeMessage returns the default message for merging rev
+func (r *Repository) mergeMessage(rev string) string {
+	if checkBranchName(rev) == nil {
+		if commitID, err := r.readRef(BranchPrefix + rev); err == nil && commitID != "" {
+			return fmt.Sprintf("Merge branch '%s'", rev)
+		}
+	}
+	return fmt.Sprintf("Merge commit '%s'", rev)
+}
+
+// readMergeState returns the commit being merged and the prepared message,
+// or empty strings when no merge is in progress
+func (r *Repository) readMergeState() (string, string, error) {
+	data, err := os.ReadFile(r.gitPath(MergeHeadFile))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", MergeHeadFile, err)
+	}
+	message, err := os.ReadFile(r.gitPath(MergeMsgFile))
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", fmt.Errorf("failed to read %s: %w", MergeMsgFile, err)
+	}
+	return strings.TrimSpace(string(data)), strings.TrimSpace(string(message)), nil
+}
+
+// writeMergeState records a merge that is waiting for conflicts to be resolved
+func (r *Repository) writeMergeState(commitID, message string) error {
+	if err := writeFileAtomic(r.gitPath(MergeHeadFile), []byte(commitID+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", MergeHeadFile, err)
+	}
+	if err := writeFileAtomic(r.gitPath(MergeMsgFile), []byte(message+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", MergeMsgFile, err)
+	}
+	return nil
+}
+
+// clearMergeState forgets an in-progress merge
+func (r *Repository) clearMergeState() error {
+	for _, name := range []string{MergeHeadFile, MergeMsgFile} {
+		if err := os.Remove(r.gitPath(name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// conflictPaths returns the unmerged paths in the index
+func conflictPaths(idx *Index) []string {
+	paths := make([]string, 0, len(idx.Conflicts))
+	for _, conflict := range idx.Conflicts {
+		paths = append(paths, conflict.Path)
+	}
+	return paths
+}
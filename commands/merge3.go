@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"strings"
+)
+
+// Conflict markers written around the two sides of a conflicting chunk
+const (
+	ConflictMarkerOurs   = "<<<<<<<"
+	ConflictMarkerSep    = "======="
+	ConflictMarkerTheirs = ">>>>>>>"
+)
+
+// MergeLines performs a line-level three-way merge of ours and theirs, which
+// both descend from base. Chunks changed on only one side take that side's
+// version; chunks changed differently on both sides are written between
+// conflict markers labelled with oursLabel and theirsLabel. Lines are expected
+// to come from SplitLines. It reports whether any conflicts were written.
+func MergeLines(base, ours, theirs []string, oursLabel, theirsLabel string) (string, bool) {
+	oursMatch := matchLines(base, ours)
+	theirsMatch := matchLines(base, theirs)
+
+	var out strings.Builder
+	conflicted := false
+	i, j, k := 0, 0, 0
+	for {
+		// Copy lines that are unchanged on both sides
+		n := 0
+		for i+n < len(base) && oursMatch[i+n] == j+n && theirsMatch[i+n] == k+n {
+			n++
+		}
+		writeLines(&out, base[i:i+n])
+		i, j, k = i+n, j+n, k+n
+
+		// Find the next base line kept by both sides; everything before it
+		// forms a chunk changed on at least one side
+		next := i
+		for next < len(base) && (oursMatch[next] < 0 || theirsMatch[next] < 0) {
+			next++
+		}
+		oursEnd, theirsEnd := len(ours), len(theirs)
+		if next < len(base) {
+			oursEnd, theirsEnd = oursMatch[next], theirsMatch[next]
+		}
+
+		baseChunk, oursChunk, theirsChunk := base[i:next], ours[j:oursEnd], theirs[k:theirsEnd]
+		switch {
+		case equalLines(oursChunk, theirsChunk), equalLines(baseChunk, theirsChunk):
+			writeLines(&out, oursChunk)
+		case equalLines(baseChunk, oursChunk):
+			writeLines(&out, theirsChunk)
+		default:
+			conflicted = true
+			out.WriteString(ConflictMarkerOurs + " " + oursLabel + "\n")
+			writeConflictSide(&out, oursChunk)
+			out.WriteString(ConflictMarkerSep + "\n")
+			writeConflictSide(&out, theirsChunk)
+			out.WriteString(ConflictMarkerTheirs + " " + theirsLabel + "\n")
+		}
+
+		if next == len(base) {
+			break
+		}
+		i, j, k = next, oursEnd, theirsEnd
+	}
+	return out.String(), conflicted
+}
+
+// matchLines maps each line of base to the line of other it is kept as, or
+// -1 when the line was deleted
+func matchLines(base, other []string) []int {
+	match := make([]int, len(base))
+	for i := range match {
+		match[i] = -1
+	}
+	for _, edit := range DiffLines(base, other) {
+		if edit.Op == EditEqual {
+			match[edit.OldLine] = edit.NewLine
+		}
+	}
+	return match
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// writeConflictSide writes one side of a conflict, terminating its last line
+// so that the following marker starts on its own line
+func writeConflictSide(out *strings.Builder, lines []string) {
+	writeLines(out, lines)
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		out.WriteString("\n")
+	}
+}
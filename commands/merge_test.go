@@ -0,0 +1,349 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestMergeLines tests the line-level three-way merge
+func TestMergeLines(t *testing.T) {
+	tests := []struct {
+		name               string
+		base               string
+		ours               string
+		theirs             string
+		expected           string
+		expectedConflicted bool
+	}{
+		{
+			name:     "no changes",
+			base:     "a\nb\nc\n",
+			ours:     "a\nb\nc\n",
+			theirs:   "a\nb\nc\n",
+			expected: "a\nb\nc\n",
+		},
+		{
+			name:     "change on one side",
+			base:     "a\nb\nc\n",
+			ours:     "a\nb\nc\n",
+			theirs:   "a\nB\nc\n",
+			expected: "a\nB\nc\n",
+		},
+		{
+			name:     "changes in different places",
+			base:     "a\nb\nc\nd\ne\n",
+			ours:     "A\nb\nc\nd\ne\n",
+			theirs:   "a\nb\nc\nd\nE\n",
+			expected: "A\nb\nc\nd\nE\n",
+		},
+		{
+			name:     "same change on both sides",
+			base:     "a\nb\nc\n",
+			ours:     "a\nx\nc\n",
+			theirs:   "a\nx\nc\n",
+			expected: "a\nx\nc\n",
+		},
+		{
+			name:     "insertions at both ends",
+			base:     "b\n",
+			ours:     "a\nb\n",
+			theirs:   "b\nc\n",
+			expected: "a\nb\nc\n",
+		},
+		{
+			name:               "conflicting change",
+			base:               "a\nb\nc\n",
+			ours:               "a\nours\nc\n",
+			theirs:             "a\ntheirs\nc\n",
+			expected:           "a\n<<<<<<< HEAD\nours\n=======\ntheirs\n>>>>>>> feature\nc\n",
+			expectedConflicted: true,
+		},
+		{
+			name:               "conflict without trailing newline",
+			base:               "a",
+			ours:               "b",
+			theirs:             "c",
+			expected:           "<<<<<<< HEAD\nb\n=======\nc\n>>>>>>> feature\n",
+			expectedConflicted: true,
+		},
+		{
+			name:               "both added",
+			base:               "",
+			ours:               "one\n",
+			theirs:             "two\n",
+			expected:           "<<<<<<< HEAD\none\n=======\ntwo\n>>>>>>> feature\n",
+			expectedConflicted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicted := commands.MergeLines(
+				commands.SplitLines([]byte(tt.base)),
+				commands.SplitLines([]byte(tt.ours)),
+				commands.SplitLines([]byte(tt.theirs)),
+				"HEAD", "feature",
+			)
+
+			if merged != tt.expected {
+				t.Errorf("expected:\n%q\ngot:\n%q", tt.expected, merged)
+			}
+			if conflicted != tt.expectedConflicted {
+				t.Errorf("expected conflicted %v, got %v", tt.expectedConflicted, conflicted)
+			}
+		})
+	}
+}
+
+// setupDivergedBranches creates main and feature branches that both changed
+// since their common commit, and leaves main checked out
+func setupDivergedBranches(t *testing.T, mainContent, featureContent string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+	os.WriteFile("file.txt", []byte("one\ntwo\nthree\nfour\nfive\n"), 0644)
+	commands.Add([]string{"file.txt"})
+	commands.Commit("Base commit")
+
+	commands.Switch("feature", commands.SwitchOptions{Create: true})
+	os.WriteFile("file.txt", []byte(featureContent), 0644)
+	os.WriteFile("feature.txt", []byte("feature\n"), 0644)
+	commands.Add([]string{"file.txt", "feature.txt"})
+	if err := commands.Commit("Feature commit"); err != nil {
+		t.Fatalf("failed to commit on feature: %v", err)
+	}
+
+	commands.Switch("main", commands.SwitchOptions{})
+	os.WriteFile("file.txt", []byte(mainContent), 0644)
+	commands.Add([]string{"file.txt"})
+	if err := commands.Commit("Main commit"); err != nil {
+		t.Fatalf("failed to commit on main: %v", err)
+	}
+}
+
+// commitParentIDs returns the parents recorded for a commit
+func commitParentIDs(t *testing.T, commitID string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(commands.MyGitDir, commands.MetadataFile))
+	if err != nil {
+		t.Fatalf("failed to read metadata: %v", err)
+	}
+	var metadata struct {
+		CommitHistory []struct {
+			CommitID        string   `json:"commit_id"`
+			ParentCommitIDs []string `json:"parent_commit_ids"`
+		} `json:"commit_history"`
+	}
+	json.Unmarshal(data, &metadata)
+	for _, commit := range metadata.CommitHistory {
+		if commit.CommitID == commitID {
+			return commit.ParentCommitIDs
+		}
+	}
+	t.Fatalf("commit %s not found", commitID)
+	return nil
+}
+
+// TestMergeFastForward tests that a branch behind the merged one only moves forward
+func TestMergeFastForward(t *testing.T) {
+	ids := setupTwoCommits(t)
+	commands.CreateBranch("old", ids[0])
+	commands.Switch("old", commands.SwitchOptions{})
+
+	if err := commands.Merge("main", commands.MergeOptions{}); err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+
+	if readBranch(t, "old") != ids[1] {
+		t.Errorf("expected old to be fast-forwarded to %s", ids[1])
+	}
+	if len(commitIDs(t)) != 2 {
+		t.Errorf("fast-forward should not create a commit")
+	}
+	content, _ := os.ReadFile("file.txt")
+	if string(content) != "version 2\n" {
+		t.Errorf("expected working tree to be updated, got %q", content)
+	}
+
+	// Merging again has nothing to do
+	if err := commands.Merge("main", commands.MergeOptions{}); err != nil {
+		t.Errorf("unexpected error merging again: %v", err)
+	}
+}
+
+// TestMergeNoFastForward tests forcing a merge commit
+func TestMergeNoFastForward(t *testing.T) {
+	ids := setupTwoCommits(t)
+	commands.CreateBranch("old", ids[0])
+	commands.Switch("old", commands.SwitchOptions{})
+
+	if err := commands.Merge("main", commands.MergeOptions{NoFastForward: true}); err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+
+	tip := readBranch(t, "old")
+	parents := commitParentIDs(t, tip)
+	if len(parents) != 2 || parents[0] != ids[0] || parents[1] != ids[1] {
+		t.Errorf("expected parents [%s %s], got %v", ids[0], ids[1], parents)
+	}
+}
+
+// TestMergeClean tests a three-way merge without conflicts
+func TestMergeClean(t *testing.T) {
+	setupDivergedBranches(t,
+		"ONE\ntwo\nthree\nfour\nfive\n",
+		"one\ntwo\nthree\nfour\nFIVE\n",
+	)
+	mainTip := readBranch(t, "main")
+	featureTip := readBranch(t, "feature")
+
+	if err := commands.Merge("feature", commands.MergeOptions{}); err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+
+	content, _ := os.ReadFile("file.txt")
+	if string(content) != "ONE\ntwo\nthree\nfour\nFIVE\n" {
+		t.Errorf("unexpected merged content %q", content)
+	}
+	if _, err := os.Stat("feature.txt"); err != nil {
+		t.Errorf("expected feature.txt to be added: %v", err)
+	}
+
+	tip := readBranch(t, "main")
+	parents := commitParentIDs(t, tip)
+	if len(parents) != 2 || parents[0] != mainTip || parents[1] != featureTip {
+		t.Errorf("expected parents [%s %s], got %v", mainTip, featureTip, parents)
+	}
+
+	result, err := commands.GetStatus()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if !result.Clean() {
+		t.Errorf("expected clean status after merge, got %+v", result)
+	}
+}
+
+// TestMergeConflict tests conflict markers, unmerged index entries and
+// committing the resolution
+func TestMergeConflict(t *testing.T) {
+	setupDivergedBranches(t,
+		"one\nmain\nthree\nfour\nfive\n",
+		"one\nfeature\nthree\nfour\nfive\n",
+	)
+	mainTip := readBranch(t, "main")
+	featureTip := readBranch(t, "feature")
+
+	err := commands.Merge("feature", commands.MergeOptions{})
+	if err == nil {
+		t.Fatalf("expected merge to report conflicts")
+	}
+
+	content, _ := os.ReadFile("file.txt")
+	expected := "one\n<<<<<<< HEAD\nmain\n=======\nfeature\n>>>>>>> feature\nthree\nfour\nfive\n"
+	if string(content) != expected {
+		t.Errorf("expected conflict markers:\n%s\ngot:\n%s", expected, content)
+	}
+	if _, err := os.Stat("feature.txt"); err != nil {
+		t.Errorf("expected clean changes to be applied: %v", err)
+	}
+
+	result, err := commands.GetStatus()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if len(result.Unmerged) != 1 || result.Unmerged[0].Path != "file.txt" {
+		t.Errorf("expected file.txt to be unmerged, got %+v", result.Unmerged)
+	}
+	if !result.Merging {
+		t.Errorf("expected a merge to be in progress")
+	}
+
+	// Conflicts block committing and further merges
+	if err := commands.Commit(""); err == nil {
+		t.Errorf("expected commit to fail with unmerged paths")
+	}
+	if err := commands.Merge("feature", commands.MergeOptions{}); err == nil {
+		t.Errorf("expected merge to fail while another is in progress")
+	}
+
+	// Resolve and commit
+	os.WriteFile("file.txt", []byte("one\nresolved\nthree\nfour\nfive\n"), 0644)
+	if err := commands.Add([]string{"file.txt"}); err != nil {
+		t.Fatalf("failed to add: %v", err)
+	}
+	if err := commands.Commit(""); err != nil {
+		t.Fatalf("failed to commit merge: %v", err)
+	}
+
+	tip := readBranch(t, "main")
+	parents := commitParentIDs(t, tip)
+	if len(parents) != 2 || parents[0] != mainTip || parents[1] != featureTip {
+		t.Errorf("expected parents [%s %s], got %v", mainTip, featureTip, parents)
+	}
+	if _, err := os.Stat(filepath.Join(commands.MyGitDir, commands.MergeHeadFile)); !os.IsNotExist(err) {
+		t.Errorf("expected MERGE_HEAD to be removed")
+	}
+	if commands.Merging() {
+		t.Errorf("expected merge to be concluded")
+	}
+
+	data, _ := os.ReadFile(filepath.Join(commands.MyGitDir, commands.MetadataFile))
+	if !strings.Contains(string(data), "Merge branch 'feature'") {
+		t.Errorf("expected default merge message in metadata")
+	}
+}
+
+// TestMergeErrors tests merges that are refused
+func TestMergeErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		setupFunc func()
+		rev       string
+	}{
+		{
+			name:      "unknown branch",
+			setupFunc: func() {},
+			rev:       "missing",
+		},
+		{
+			name: "staged changes",
+			setupFunc: func() {
+				os.WriteFile("other.txt", []byte("staged\n"), 0644)
+				commands.Add([]string{"other.txt"})
+			},
+			rev: "feature",
+		},
+		{
+			name: "local modification to a merged file",
+			setupFunc: func() {
+				os.WriteFile("file.txt", []byte("local\n"), 0644)
+			},
+			rev: "feature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDivergedBranches(t,
+				"ONE\ntwo\nthree\nfour\nfive\n",
+				"one\ntwo\nthree\nfour\nFIVE\n",
+			)
+			mainTip := readBranch(t, "main")
+			tt.setupFunc()
+
+			if err := commands.Merge(tt.rev, commands.MergeOptions{}); err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if readBranch(t, "main") != mainTip {
+				t.Errorf("main should not move")
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MetadataBackupFile holds a copy of the last metadata.json written
+const MetadataBackupFile = "metadata.json.bak"
+
+// timestampLayout is the format of commit timestamps in older versions of
+// metadata.json, which are in the local time zone
+const timestampLayout = "2006-01-02 15:04:05"
+
+// CommitRecord is a commit in the history recorded in metadata.json
+type CommitRecord struct {
+	// ID identifies the commit
+	ID string
+	// Tree is the ID of the root tree holding the commit's snapshot. It is
+	// empty for commits made before tree objects existed.
+	Tree string
+	// Parents holds the IDs of the parent commits, first parent first. The
+	// first commit has none and a merge commit has two.
+	Parents []string
+	// Message is the commit message
+	Message string
+	// Author is who wrote the changes and when, and Committer who recorded
+	// the commit and when. Both are zero for commits made before they were
+	// recorded.
+	Author    Signature
+	Committer Signature
+	// Time is when the commit was recorded: the committer date, or the
+	// local time stored by commits made before dates had a time zone
+	Time time.Time
+
+	// files is the snapshot listed by commits made before tree objects existed
+	files []FileEntry
+}
+
+// commitJSON is the representation of a commit in metadata.json
+type commitJSON struct {
+	ID        string         `json:"commit_id"`
+	Message   string         `json:"commit_message"`
+	Tree      string         `json:"tree,omitempty"`
+	Parents   []string       `json:"parent_commit_ids"`
+	Author    *signatureJSON `json:"author,omitempty"`
+	Committer *signatureJSON `json:"committer,omitempty"`
+	// Timestamp, Parent and Files are only found in commits made by older
+	// versions
+	Timestamp string       `json:"commit_timestamp,omitempty"`
+	Parent    string       `json:"parent_commit_id,omitempty"`
+	Files     []legacyFile `json:"files,omitempty"`
+}
+
+// signatureJSON is the representation of an author or a committer in
+// metadata.json. The date is stored as Unix time and the UTC offset it was
+// recorded with; older versions stored no date.
+type signatureJSON struct {
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Offset    string `json:"tz_offset,omitempty"`
+}
+
+// newSignatureJSON encodes a signature, or returns nil for an unknown one
+func newSignatureJSON(s Signature) *signatureJSON {
+	if s.IsZero() {
+		return nil
+	}
+	record := &signatureJSON{Name: s.Name, Email: s.Email}
+	if !s.When.IsZero() {
+		record.Timestamp = s.When.Unix()
+		record.Offset = formatOffset(s.When)
+	}
+	return record
+}
+
+// signature decodes the signature
+func (s *signatureJSON) signature() (Signature, error) {
+	if s == nil {
+		return Signature{}, nil
+	}
+	sig := Signature{Name: s.Name, Email: s.Email}
+	if s.Offset != "" {
+		zone, err := parseOffset(s.Offset)
+		if err != nil {
+			return Signature{}, err
+		}
+		sig.When = time.Unix(s.Timestamp, 0).In(zone)
+	}
+	return sig, nil
+}
+
+// legacyFile is a file listed by older versions of metadata.json, in the
+// staging_area or in a commit made before tree objects existed
+type legacyFile struct {
+	Path string `json:"file_path"`
+	Hash string `json:"file_hash"`
+	Mode string `json:"file_mode,omitempty"`
+}
+
+// MarshalJSON encodes the commit in the metadata.json format
+func (c CommitRecord) MarshalJSON() ([]byte, error) {
+	parents := c.Parents
+	if parents == nil {
+		parents = []string{}
+	}
+	record := commitJSON{
+		ID:        c.ID,
+		Message:   c.Message,
+		Tree:      c.Tree,
+		Parents:   parents,
+		Author:    newSignatureJSON(c.Author),
+		Committer: newSignatureJSON(c.Committer),
+	}
+	if c.Committer.When.IsZero() {
+		record.Timestamp = c.Time.Format(timestampLayout)
+	}
+	for _, file := range c.files {
+		record.Files = append(record.Files, legacyFile{Path: file.Path, Hash: file.Hash})
+	}
+	return json.Marshal(record)
+}
+
+// UnmarshalJSON decodes a commit in the metadata.json format. Commits made
+// before merges existed record a single parent_commit_id.
+func (c *CommitRecord) UnmarshalJSON(data []byte) error {
+	var record commitJSON
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+	*c = CommitRecord{ID: record.ID, Tree: record.Tree, Message: record.Message}
+	var err error
+	if c.Author, err = record.Author.signature(); err != nil {
+		return fmt.Errorf("invalid author of commit %s: %w", record.ID, err)
+	}
+	if c.Committer, err = record.Committer.signature(); err != nil {
+		return fmt.Errorf("invalid committer of commit %s: %w", record.ID, err)
+	}
+	c.Time = c.Committer.When
+	if c.Time.IsZero() && record.Timestamp != "" {
+		t, err := time.ParseInLocation(timestampLayout, record.Timestamp, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp of commit %s: %w", record.ID, err)
+		}
+		c.Time = t
+	}
+	for _, parent := range record.Parents {
+		if parent != "" {
+			c.Parents = append(c.Parents, parent)
+		}
+	}
+	if record.Parents == nil && record.Parent != "" {
+		c.Parents = []string{record.Parent}
+	}
+	for _, file := range record.Files {
+		c.files = append(c.files, FileEntry{Path: filepath.ToSlash(filepath.Clean(file.Path)), Mode: ModeFile, Hash: file.Hash})
+	}
+	return nil
+}
+
+// metadata is the content of metadata.json
+type metadata struct {
+	// CommitHistory holds every commit in the order they were created
+	CommitHistory []CommitRecord `json:"commit_history,omitempty"`
+	// StagingArea is the staging area of older versions, which now lives in
+	// the index
+	StagingArea []legacyFile `json:"staging_area,omitempty"`
+
+	// byID maps the IDs of the first indexed commits to their position in
+	// CommitHistory. History walks look up every commit they visit.
+	byID    map[string]int
+	indexed int
+}
+
+// readMetadata loads metadata.json. A missing file holds no commits, but a
+// damaged one is an error: writing over it would lose the history.
+func (r *Repository) readMetadata() (*metadata, error) {
+	data, err := os.ReadFile(r.gitPath(MetadataFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &metadata{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata.json: %w", err)
+	}
+	m, err := decodeMetadata(data)
+	if err != nil {
+		return nil, fmt.Errorf("metadata.json is damaged: %w; run 'mygit recover' to restore it", err)
+	}
+	return m, nil
+}
+
+// decodeMetadata parses the content of metadata.json
+func decodeMetadata(data []byte) (*metadata, error) {
+	m := &metadata{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	m.indexCommits()
+	return m, nil
+}
+
+// writeMetadata replaces metadata.json atomically and then copies it to
+// metadata.json.bak. Should metadata.json ever be damaged, "mygit recover"
+// restores the backup. It holds the same history, or the one before if a
+// crash came between the two writes, so it repairs damage to metadata.json
+// but cannot undo a write.
+func (r *Repository) writeMetadata(m *metadata) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata.json: %w", err)
+	}
+	data = append(data, '\n')
+
+	if err := writeFileAtomic(r.gitPath(MetadataFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write metadata.json: %w", err)
+	}
+	if err := writeFileAtomic(r.gitPath(MetadataBackupFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to back up metadata.json: %w", err)
+	}
+	return nil
+}
+
+// find returns the commit with the given ID, or nil if there is none
+func (m *metadata) find(commitID string) *CommitRecord {
+	if commitID == "" {
+		return nil
+	}
+	m.indexCommits()
+	if i, ok := m.byID[commitID]; ok {
+		return &m.CommitHistory[i]
+	}
+	return nil
+}
+
+// indexCommits adds the commits appended since the last call to byID.
+// Commits are only ever appended, so earlier positions stay valid.
+func (m *metadata) indexCommits() {
+	if m.byID == nil {
+		m.byID = make(map[string]int, len(m.CommitHistory))
+	}
+	for ; m.indexed < len(m.CommitHistory); m.indexed++ {
+		if _, ok := m.byID[m.CommitHistory[m.indexed].ID]; !ok {
+			m.byID[m.CommitHistory[m.indexed].ID] = m.indexed
+		}
+	}
+}
+
+// latest returns the most recently created commit, or nil if there are no commits
+func (m *metadata) latest() *CommitRecord {
+	if len(m.CommitHistory) == 0 {
+		return nil
+	}
+	return &m.CommitHistory[len(m.CommitHistory)-1]
+}
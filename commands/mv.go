@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MoveOptions controls Move
+type MoveOptions struct {
+	// Force overwrites an existing destination file
+	Force bool
+}
+
+// Move renames tracked files or directories in both the working tree and the
+// index. With several sources, or when dst is an existing directory, the
+// sources are moved into dst.
+func Move(sources []string, dst string, opts MoveOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	unlock, err := r.lock(IndexFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if len(sources) == 0 {
+		return errors.New("mv requires a source and a destination")
+	}
+
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+
+	dstPath, err := r.repoPath(dst)
+	if err != nil {
+		return err
+	}
+	intoDir := len(sources) > 1
+	if info, err := os.Stat(r.workPath(dstPath)); err == nil && info.IsDir() {
+		intoDir = true
+	} else if intoDir {
+		return fmt.Errorf("destination '%s' is not a directory", dst)
+	}
+
+	// Work out every rename before touching anything
+	type rename struct {
+		from, to string
+	}
+	var renames []rename
+	renamed := make(map[string]string)
+	// unchanged records which files match their index entry before moving
+	unchanged := make(map[string]bool)
+	for _, src := range sources {
+		srcPath, err := r.repoPath(src)
+		if err != nil {
+			return err
+		}
+		target := dstPath
+		if intoDir {
+			target = path.Join(dstPath, path.Base(srcPath))
+		}
+		if target == srcPath || strings.HasPrefix(target, srcPath+"/") {
+			return fmt.Errorf("cannot move '%s' into itself", src)
+		}
+		if _, unmerged := idx.Conflict(srcPath); unmerged {
+			return kindErrorf(ErrConflict, "'%s' has unresolved merge conflicts", src)
+		}
+		if _, err := os.Lstat(r.workPath(srcPath)); err != nil {
+			return fmt.Errorf("bad source '%s': %w", src, err)
+		}
+
+		found := false
+		for _, entry := range idx.Entries {
+			var to string
+			switch {
+			case entry.Path == srcPath:
+				to = target
+			case strings.HasPrefix(entry.Path, srcPath+"/"):
+				to = target + strings.TrimPrefix(entry.Path, srcPath)
+			default:
+				continue
+			}
+			found = true
+			if _, tracked := idx.Entry(to); tracked && !opts.Force {
+				return fmt.Errorf("destination '%s' already exists", to)
+			}
+			if _, err := os.Lstat(r.workPath(to)); err == nil && !opts.Force {
+				return fmt.Errorf("destination '%s' already exists", to)
+			}
+			renamed[entry.Path] = to
+			if info, err := os.Lstat(r.workPath(entry.Path)); err == nil {
+				unchanged[entry.Path] = idx.StatClean(entry, info)
+			}
+		}
+		if !found {
+			return fmt.Errorf("'%s' is not tracked", src)
+		}
+		renames = append(renames, rename{from: srcPath, to: target})
+	}
+
+	for _, mv := range renames {
+		to := r.workPath(mv.to)
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", mv.to, err)
+		}
+		if err := os.Rename(r.workPath(mv.from), to); err != nil {
+			return fmt.Errorf("failed to move %s to %s: %w", mv.from, mv.to, err)
+		}
+		fmt.Printf("Renamed: %s -> %s\n", mv.from, mv.to)
+	}
+
+	// Move the index entries, keeping the staged content of each file
+	for from, to := range renamed {
+		entry, _ := idx.Entry(from)
+		idx.Remove(from)
+		moved := IndexEntry{Path: to, Hash: entry.Hash, Mode: entry.Mode}
+		if info, err := os.Lstat(r.workPath(to)); err == nil && unchanged[from] {
+			moved = newIndexEntry(to, entry.Hash, info)
+		}
+		idx.Set(moved)
+	}
+
+	return idx.Write()
+}
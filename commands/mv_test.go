@@ -0,0 +1,114 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestMove tests renaming tracked files and directories
+func TestMove(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupFunc     func()
+		sources       []string
+		dst           string
+		opts          commands.MoveOptions
+		expectedError bool
+		expectedMoves map[string]string
+	}{
+		{
+			name:          "rename file",
+			setupFunc:     func() {},
+			sources:       []string{"file.txt"},
+			dst:           "renamed.txt",
+			expectedMoves: map[string]string{"file.txt": "renamed.txt"},
+		},
+		{
+			name:          "move file into directory",
+			setupFunc:     func() {},
+			sources:       []string{"file.txt"},
+			dst:           "dir",
+			expectedMoves: map[string]string{"file.txt": "dir/file.txt"},
+		},
+		{
+			name:          "rename directory",
+			setupFunc:     func() {},
+			sources:       []string{"dir"},
+			dst:           "other",
+			expectedMoves: map[string]string{"dir/new.txt": "other/new.txt"},
+		},
+		{
+			name:          "existing destination",
+			setupFunc:     func() { os.WriteFile("taken.txt", []byte("taken\n"), 0644) },
+			sources:       []string{"file.txt"},
+			dst:           "taken.txt",
+			expectedError: true,
+		},
+		{
+			name:          "existing destination with force",
+			setupFunc:     func() { os.WriteFile("taken.txt", []byte("taken\n"), 0644) },
+			sources:       []string{"file.txt"},
+			dst:           "taken.txt",
+			opts:          commands.MoveOptions{Force: true},
+			expectedMoves: map[string]string{"file.txt": "taken.txt"},
+		},
+		{
+			name:          "untracked source",
+			setupFunc:     func() { os.WriteFile("untracked.txt", []byte("x\n"), 0644) },
+			sources:       []string{"untracked.txt"},
+			dst:           "moved.txt",
+			expectedError: true,
+		},
+		{
+			name:          "several sources into a file",
+			setupFunc:     func() {},
+			sources:       []string{"file.txt", "dir"},
+			dst:           "missing",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTwoCommits(t)
+			tt.setupFunc()
+
+			err := commands.Move(tt.sources, tt.dst, tt.opts)
+
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			idx, err := commands.ReadIndex()
+			if err != nil {
+				t.Fatalf("failed to read index: %v", err)
+			}
+			for from, to := range tt.expectedMoves {
+				if _, ok := idx.Entry(from); ok {
+					t.Errorf("expected %s to leave the index", from)
+				}
+				if _, ok := idx.Entry(to); !ok {
+					t.Errorf("expected %s in the index", to)
+				}
+				if _, err := os.Stat(filepath.FromSlash(to)); err != nil {
+					t.Errorf("expected %s on disk: %v", to, err)
+				}
+			}
+
+			// A rename is a deletion plus an addition with the same content
+			result, err := commands.GetStatus()
+			if err != nil {
+				t.Fatalf("failed to get status: %v", err)
+			}
+			if len(result.Unstaged) != 0 {
+				t.Errorf("expected no unstaged changes, got %v", result.Unstaged)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Kinds of edit operation produced by DiffLines
+const (
+	EditEqual  = ' '
+	EditDelete = '-'
+	EditInsert = '+'
+)
+
+// Edit is a single line of an edit script turning one text into another
+type Edit struct {
+	Op   byte
+	Text string
+	// OldLine and NewLine are 0-based line numbers in the old and new text,
+	// or -1 for lines that exist on only one side
+	OldLine int
+	NewLine int
+}
+
+// DiffLines computes a shortest edit script from a to b using Myers' O(ND)
+// algorithm
+func DiffLines(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v[offset+k] holds the furthest x reached on diagonal k; a copy is kept
+	// for every edit distance d so the path can be traced back
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	var d int
+search:
+	for d = 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk back from (n, m) to (0, 0), collecting edits in reverse
+	var edits []Edit
+	x, y := n, m
+	for ; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, Edit{Op: EditEqual, Text: a[x], OldLine: x, NewLine: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, Edit{Op: EditInsert, Text: b[y], OldLine: -1, NewLine: y})
+			} else {
+				x--
+				edits = append(edits, Edit{Op: EditDelete, Text: a[x], OldLine: x, NewLine: -1})
+			}
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// SplitLines splits data into lines, keeping each line's trailing newline so
+// that a missing newline at end of file shows up as a change
+func SplitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// IsBinary reports whether data looks like binary content, using the
+// presence of a NUL byte in the first 8000 bytes as the heuristic
+func IsBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// UnifiedDiff formats the differences between two texts as a unified diff
+// with the given number of context lines. Lines are expected to come from
+// SplitLines. An empty string is returned if the texts are equal.
+func UnifiedDiff(oldName, newName string, a, b []string, context int) string {
+	hunks := formatHunks(DiffLines(a, b), context)
+	if hunks == "" {
+		return ""
+	}
+	return fmt.Sprintf("--- %s\n+++ %s\n%s", oldName, newName, hunks)
+}
+
+// formatHunks groups edits into hunks surrounded by context lines
+func formatHunks(edits []Edit, context int) string {
+	if context < 0 {
+		context = 0
+	}
+	var out strings.Builder
+	for start := 0; start < len(edits); {
+		// Find the next change
+		for start < len(edits) && edits[start].Op == EditEqual {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+
+		// Extend the hunk while changes are close enough to share context
+		end := start
+		for i := start; i < len(edits); i++ {
+			if edits[i].Op == EditEqual {
+				continue
+			}
+			if i-end > 2*context {
+				break
+			}
+			end = i + 1
+		}
+
+		first := start - context
+		if first < 0 {
+			first = 0
+		}
+		last := end + context
+		if last > len(edits) {
+			last = len(edits)
+		}
+		oldBefore, newBefore := 0, 0
+		for _, edit := range edits[:first] {
+			if edit.OldLine >= 0 {
+				oldBefore++
+			}
+			if edit.NewLine >= 0 {
+				newBefore++
+			}
+		}
+		writeHunk(&out, edits[first:last], oldBefore, newBefore)
+		start = last
+	}
+	return out.String()
+}
+
+// writeHunk writes a single hunk; oldBefore and newBefore are the number of
+// lines on each side that precede it
+func writeHunk(out *strings.Builder, edits []Edit, oldBefore, newBefore int) {
+	oldLines, newLines := 0, 0
+	for _, edit := range edits {
+		if edit.OldLine >= 0 {
+			oldLines++
+		}
+		if edit.NewLine >= 0 {
+			newLines++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldBefore, oldLines), hunkRange(newBefore, newLines))
+
+	for _, edit := range edits {
+		out.WriteByte(edit.Op)
+		out.WriteString(edit.Text)
+		if !strings.HasSuffix(edit.Text, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats "start,count" for a hunk header. An empty range starts at
+// the line preceding the hunk, and a count of one is omitted.
+func hunkRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, count)
+	}
+}
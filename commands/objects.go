@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const ObjectsDir = "objects"
+
+// Object types stored in the object database
+const (
+	BlobObject   = "blob"
+	TreeObject   = "tree"
+	CommitObject = "commit"
+	TagObject    = "tag"
+)
+
+// zeroHash stands in for the object ID of a file that does not exist
+const zeroHash = "0000000000000000000000000000000000000000"
+
+// ErrObjectNotFound is returned when an object is missing from the object database
+var ErrObjectNotFound = errors.New("object not found")
+
+// HashObject returns the object ID of data stored as the given object type.
+// The ID is the SHA-1 of "<type> <size>\x00" followed by the data.
+func HashObject(objType string, data []byte) string {
+	sha := sha1.New()
+	fmt.Fprintf(sha, "%s %d\x00", objType, len(data))
+	sha.Write(data)
+	return fmt.Sprintf("%x", sha.Sum(nil))
+}
+
+// WriteObject stores data in the object database of the repository in the
+// current directory and returns its object ID
+func WriteObject(objType string, data []byte) (string, error) {
+	r, err := openRepository()
+	if err != nil {
+		return "", err
+	}
+	return r.WriteObject(objType, data)
+}
+
+// ReadObject reads an object from the object database of the repository in
+// the current directory
+func ReadObject(hash string) (string, []byte, error) {
+	r, err := openRepository()
+	if err != nil {
+		return "", nil, err
+	}
+	return r.ReadObject(hash)
+}
+
+// WriteObject stores data in the object database and returns its object ID.
+// Objects are zlib-compressed and written to a temporary file that is renamed
+// into place, so a partially written object is never visible.
+func (r *Repository) WriteObject(objType string, data []byte) (string, error) {
+	return writeLooseObject(r.gitPath(ObjectsDir), objType, data)
+}
+
+// writeLooseObject stores data as a loose object under objectsDir, which is
+// laid out the same way in mygit and Git repositories
+func writeLooseObject(objectsDir, objType string, data []byte) (string, error) {
+	hash := HashObject(objType, data)
+	objectPath := filepath.Join(objectsDir, hash[:2], hash[2:])
+
+	// Objects are immutable, so an existing file already holds this content
+	if _, err := os.Stat(objectPath); err == nil {
+		return hash, nil
+	}
+
+	objectDir := filepath.Dir(objectPath)
+	if err := os.MkdirAll(objectDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(objectDir, "tmp_obj_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary object file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	zw := zlib.NewWriter(tmp)
+	fmt.Fprintf(zw, "%s %d\x00", objType, len(data))
+	if _, err := zw.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to compress object %s: %w", hash, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to sync object %s: %w", hash, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to close object %s: %w", hash, err)
+	}
+	if err := os.Rename(tmpPath, objectPath); err != nil {
+		return "", fmt.Errorf("failed to store object %s: %w", hash, err)
+	}
+
+	return hash, nil
+}
+
+// ReadObject reads an object from the object database and returns its type and data
+func (r *Repository) ReadObject(hash string) (string, []byte, error) {
+	if !isObjectID(hash) {
+		return "", nil, fmt.Errorf("invalid object ID: %q", hash)
+	}
+	return readLooseObject(r.objectPath(hash), hash)
+}
+
+// readLooseObject reads the loose object stored at objectPath and checks
+// that its content matches its ID
+func readLooseObject(objectPath, hash string) (string, []byte, error) {
+	file, err := os.Open(objectPath)
+	if os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("%w: %s", ErrObjectNotFound, hash)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open object %s: %w", hash, err)
+	}
+	defer file.Close()
+
+	zr, err := zlib.NewReader(file)
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s is corrupt: %w", hash, err)
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s is corrupt: %w", hash, err)
+	}
+
+	// Parse "<type> <size>\x00<data>"
+	nul := bytes.IndexByte(raw, 0)
+	if nul < 0 {
+		return "", nil, fmt.Errorf("object %s is corrupt: missing header", hash)
+	}
+	objType, sizeStr, ok := bytes.Cut(raw[:nul], []byte(" "))
+	if !ok {
+		return "", nil, fmt.Errorf("object %s is corrupt: malformed header", hash)
+	}
+	size, err := strconv.Atoi(string(sizeStr))
+	data := raw[nul+1:]
+	if err != nil || size != len(data) {
+		return "", nil, fmt.Errorf("object %s is corrupt: size mismatch", hash)
+	}
+	if HashObject(string(objType), data) != hash {
+		return "", nil, fmt.Errorf("object %s is corrupt: hash mismatch", hash)
+	}
+
+	return string(objType), data, nil
+}
+
+// hasObject reports whether the object database holds an object
+func (r *Repository) hasObject(hash string) bool {
+	_, err := os.Stat(r.objectPath(hash))
+	return err == nil
+}
+
+// objectPath returns the path of an object, fanned out by the first two hex digits
+func (r *Repository) objectPath(hash string) string {
+	return r.gitPath(ObjectsDir, hash[:2], hash[2:])
+}
+
+// isObjectID reports whether s is a full 40-character hex object ID
+func isObjectID(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
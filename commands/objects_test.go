@@ -0,0 +1,143 @@
+package commands_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestHashObject tests that object IDs match the expected SHA-1 values
+func TestHashObject(t *testing.T) {
+	tests := []struct {
+		name     string
+		objType  string
+		data     string
+		expected string
+	}{
+		{
+			name:     "empty blob",
+			objType:  commands.BlobObject,
+			data:     "",
+			expected: "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+		},
+		{
+			name:     "blob with content",
+			objType:  commands.BlobObject,
+			data:     "hello\n",
+			expected: "ce013625030ba8dba906f756967f9e9ca394464a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash := commands.HashObject(tt.objType, []byte(tt.data))
+			if hash != tt.expected {
+				t.Errorf("expected hash %s, got %s", tt.expected, hash)
+			}
+		})
+	}
+}
+
+// TestWriteAndReadObject tests that stored objects can be read back by hash
+func TestWriteAndReadObject(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+
+	content := []byte("some file content\n")
+	hash, err := commands.WriteObject(commands.BlobObject, content)
+	if err != nil {
+		t.Fatalf("failed to write object: %v", err)
+	}
+
+	// Objects are fanned out by the first two characters of the hash
+	objectPath := filepath.Join(commands.MyGitDir, commands.ObjectsDir, hash[:2], hash[2:])
+	if _, err := os.Stat(objectPath); err != nil {
+		t.Errorf("object file was not created at %s", objectPath)
+	}
+
+	// Writing the same content again is a no-op
+	again, err := commands.WriteObject(commands.BlobObject, content)
+	if err != nil || again != hash {
+		t.Errorf("rewriting object returned %s, %v", again, err)
+	}
+
+	objType, data, err := commands.ReadObject(hash)
+	if err != nil {
+		t.Fatalf("failed to read object: %v", err)
+	}
+	if objType != commands.BlobObject {
+		t.Errorf("expected type %s, got %s", commands.BlobObject, objType)
+	}
+	if string(data) != string(content) {
+		t.Errorf("expected content %q, got %q", content, data)
+	}
+}
+
+// TestReadObjectErrors tests failure cases when reading objects
+func TestReadObjectErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+
+	tests := []struct {
+		name     string
+		hash     string
+		notFound bool
+	}{
+		{
+			name:     "missing object",
+			hash:     "0123456789012345678901234567890123456789",
+			notFound: true,
+		},
+		{
+			name: "invalid hash",
+			hash: "not-a-hash",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := commands.ReadObject(tt.hash)
+			if err == nil {
+				t.Fatalf("expected error but got none")
+			}
+			if tt.notFound && !errors.Is(err, commands.ErrObjectNotFound) {
+				t.Errorf("expected ErrObjectNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+// TestAddStoresBlob tests that add writes file contents into the object store
+func TestAddStoresBlob(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+
+	content := "content to preserve"
+	os.WriteFile("test.txt", []byte(content), 0644)
+	if err := commands.Add([]string{"test.txt"}); err != nil {
+		t.Fatalf("failed to add file: %v", err)
+	}
+
+	idx, err := commands.ReadIndex()
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
+	}
+	entry, ok := idx.Entry("test.txt")
+	if !ok {
+		t.Fatalf("test.txt was not staged")
+	}
+
+	_, data, err := commands.ReadObject(entry.Hash)
+	if err != nil {
+		t.Fatalf("failed to read staged blob: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("expected content %q, got %q", content, data)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package commands
+
+// processExists assumes that processes are running on platforms where it
+// cannot check, so their lock files are never considered stale
+func processExists(pid int) bool {
+	return true
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package commands
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processExists reports whether a process with the given ID is running
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
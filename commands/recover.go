@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Recover repairs a repository after a crash or a damaged write. It removes
+// lock files of processes that no longer exist and temporary files left
+// behind by interrupted writes, restores metadata.json from the copy in
+// metadata.json.bak when it cannot be read, and discards a damaged index so
+// it is rebuilt from HEAD. The copy holds the same history as the last
+// write, so it repairs damage but does not undo a write. Branches pointing
+// at commits missing from the history are reported, since those commits
+// cannot be recovered.
+func Recover() error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	repaired := false
+
+	// Crashed processes leave their locks behind
+	lockedFiles := []string{IndexFile, HeadFile, MetadataFile}
+	for _, name := range lockedFiles {
+		lockPath := r.gitPath(name + LockSuffix)
+		// A process that crashed while taking over a lock leaves the lock
+		// to be taken over until its takeover file is removed
+		if _, stale, err := readLockFile(lockPath + takeoverSuffix); err == nil && stale {
+			if err := os.Remove(lockPath + takeoverSuffix); err != nil {
+				return err
+			}
+			fmt.Printf("Removed stale lock %s\n", name+LockSuffix+takeoverSuffix)
+			repaired = true
+		}
+		if _, stale, err := readLockFile(lockPath); err == nil && stale {
+			free, err := takeOverLock(lockPath)
+			if err != nil {
+				return err
+			}
+			if free {
+				fmt.Printf("Removed stale lock %s\n", name+LockSuffix)
+				repaired = true
+			}
+		}
+	}
+	unlock, err := r.lock(lockedFiles...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Interrupted writes leave their temporary files behind
+	err = filepath.WalkDir(r.gitDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isTempFile(d.Name()) {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(r.gitDir, p)
+		fmt.Printf("Removed temporary file %s\n", filepath.ToSlash(rel))
+		repaired = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", MyGitDir, err)
+	}
+
+	// Restore a damaged or missing metadata.json from its copy
+	m, err := r.readMetadata()
+	if _, statErr := os.Stat(r.gitPath(MetadataFile)); err != nil || errors.Is(statErr, fs.ErrNotExist) {
+		backup, readErr := os.ReadFile(r.gitPath(MetadataBackupFile))
+		switch {
+		case readErr == nil:
+			if m, err = decodeMetadata(backup); err != nil {
+				return fmt.Errorf("cannot recover %s: its backup is damaged too: %w", MetadataFile, err)
+			}
+			if err := writeFileAtomic(r.gitPath(MetadataFile), backup, 0644); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", MetadataFile, err)
+			}
+			fmt.Printf("Restored %s from %s (%d commits)\n", MetadataFile, MetadataBackupFile, len(m.CommitHistory))
+			repaired = true
+		case err != nil:
+			return fmt.Errorf("cannot recover %s: no backup found", MetadataFile)
+		}
+		// Without a backup, a missing metadata.json simply has no commits
+	}
+
+	// A damaged index is dropped; the next command seeds it from HEAD
+	if data, err := os.ReadFile(r.gitPath(IndexFile)); err == nil {
+		idx := &Index{}
+		if err := json.Unmarshal(data, idx); err != nil || idx.Version != IndexVersion {
+			if err := os.Remove(r.gitPath(IndexFile)); err != nil {
+				return fmt.Errorf("failed to remove damaged index: %w", err)
+			}
+			fmt.Println("Removed damaged index; it will be rebuilt from HEAD")
+			repaired = true
+		}
+	}
+
+	// Report refs whose commits are not in the history
+	branches, err := r.listBranches()
+	if err != nil {
+		return err
+	}
+	for _, branch := range branches {
+		commitID, err := r.readRef(BranchPrefix + branch)
+		if err != nil {
+			return err
+		}
+		if m.find(commitID) == nil {
+			fmt.Printf("Warning: branch '%s' points to unknown commit %s\n", branch, commitID)
+			repaired = true
+		}
+	}
+	if ref, commitID, err := r.readHead(); err != nil {
+		return err
+	} else if ref == "" && m.find(commitID) == nil {
+		fmt.Printf("Warning: HEAD points to unknown commit %s\n", commitID)
+		repaired = true
+	}
+
+	if !repaired {
+		fmt.Println("Nothing to recover")
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestDamagedMetadata tests that commands refuse to use or overwrite a
+// damaged metadata.json
+func TestDamagedMetadata(t *testing.T) {
+	setupTwoCommits(t)
+	metadataPath := filepath.Join(commands.MyGitDir, commands.MetadataFile)
+	os.WriteFile(metadataPath, []byte(`{"commit_history": [`), 0644)
+
+	err := commands.Log()
+	if err == nil || !strings.Contains(err.Error(), "mygit recover") {
+		t.Errorf("expected log to point at recover, got %v", err)
+	}
+
+	os.WriteFile("file.txt", []byte("version 3\n"), 0644)
+	commands.Add([]string{"file.txt"})
+	if err := commands.Commit("Third commit"); err == nil {
+		t.Errorf("expected commit to fail")
+	}
+	data, _ := os.ReadFile(metadataPath)
+	if string(data) != `{"commit_history": [` {
+		t.Errorf("expected the damaged metadata.json to be left alone, got %s", data)
+	}
+}
+
+// TestMetadataBackup tests that every write of metadata.json is mirrored to
+// its backup and leaves no temporary files
+func TestMetadataBackup(t *testing.T) {
+	setupTwoCommits(t)
+
+	data, err := os.ReadFile(filepath.Join(commands.MyGitDir, commands.MetadataFile))
+	if err != nil {
+		t.Fatalf("failed to read metadata.json: %v", err)
+	}
+	backup, err := os.ReadFile(filepath.Join(commands.MyGitDir, commands.MetadataBackupFile))
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	if string(data) != string(backup) {
+		t.Errorf("expected the backup to match metadata.json")
+	}
+
+	entries, _ := os.ReadDir(commands.MyGitDir)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), "_tmp_") {
+			t.Errorf("unexpected temporary file %s", entry.Name())
+		}
+	}
+}
+
+// TestRecover tests repairing a repository after a crash
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name          string
+		damage        func()
+		expectedError bool
+	}{
+		{
+			name:   "nothing to recover",
+			damage: func() {},
+		},
+		{
+			name: "truncated metadata",
+			damage: func() {
+				os.WriteFile(filepath.Join(commands.MyGitDir, commands.MetadataFile), nil, 0644)
+			},
+		},
+		{
+			name: "missing metadata",
+			damage: func() {
+				os.Remove(filepath.Join(commands.MyGitDir, commands.MetadataFile))
+			},
+		},
+		{
+			name: "damaged index",
+			damage: func() {
+				os.WriteFile(filepath.Join(commands.MyGitDir, commands.IndexFile), []byte("{"), 0644)
+			},
+		},
+		{
+			name: "leftover temporary files",
+			damage: func() {
+				os.WriteFile(filepath.Join(commands.MyGitDir, "index_tmp_123"), []byte("{"), 0644)
+				os.WriteFile(filepath.Join(commands.MyGitDir, commands.ObjectsDir, "tmp_obj_456"), nil, 0644)
+			},
+		},
+		{
+			name: "damaged metadata and backup",
+			damage: func() {
+				os.WriteFile(filepath.Join(commands.MyGitDir, commands.MetadataFile), []byte("{"), 0644)
+				os.WriteFile(filepath.Join(commands.MyGitDir, commands.MetadataBackupFile), []byte("{"), 0644)
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := setupTwoCommits(t)
+			tt.damage()
+
+			err := commands.Recover()
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The full history and a clean index are back
+			if ids := commitIDs(t); len(ids) != 2 {
+				t.Fatalf("expected 2 commits after recovery, got %v", ids)
+			}
+			result, err := commands.GetStatus()
+			if err != nil {
+				t.Fatalf("failed to get status: %v", err)
+			}
+			if !result.Clean() {
+				t.Errorf("expected a clean status, got %+v", result)
+			}
+			if readBranch(t, "main") != ids[1] {
+				t.Errorf("expected main to stay at %s", ids[1])
+			}
+			entries, _ := os.ReadDir(commands.MyGitDir)
+			for _, entry := range entries {
+				if strings.Contains(entry.Name(), "_tmp_") {
+					t.Errorf("unexpected temporary file %s", entry.Name())
+				}
+			}
+			if _, err := os.Stat(filepath.Join(commands.MyGitDir, commands.ObjectsDir, "tmp_obj_456")); err == nil {
+				t.Errorf("expected the temporary object to be removed")
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// LogsDir holds the reflogs: logs/HEAD and logs/refs/heads/<branch>
+	LogsDir = "logs"
+
+	// zeroID is the old value in the reflog entry that creates a ref
+	zeroID = "0000000000000000000000000000000000000000"
+)
+
+// ReflogEntry records one update of a ref
+type ReflogEntry struct {
+	// Old and New are the commits the ref pointed at before and after
+	Old string
+	New string
+	// Committer is who updated the ref and when
+	Committer Signature
+	// Message says what updated the ref, such as "commit: Fix typo"
+	Message string
+}
+
+// Reflog returns the updates of a ref such as "HEAD" or "refs/heads/main",
+// newest first
+func (r *Repository) Reflog(ref string) ([]ReflogEntry, error) {
+	data, err := os.ReadFile(r.gitPath(LogsDir, filepath.FromSlash(ref)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the reflog of %s: %w", ref, err)
+	}
+
+	var entries []ReflogEntry
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i] == "" {
+			continue
+		}
+		entry, err := parseReflogLine(lines[i])
+		if err != nil {
+			return nil, fmt.Errorf("malformed reflog of %s: %w", ref, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseReflogLine parses a line of a reflog in Git's format:
+//
+//	<old> <new> <name> <<email>> <Unix time> <UTC offset>\t<message>
+func parseReflogLine(line string) (ReflogEntry, error) {
+	header, message, _ := strings.Cut(line, "\t")
+	oldID, rest, _ := strings.Cut(header, " ")
+	newID, signature, _ := strings.Cut(rest, " ")
+	if !isObjectID(oldID) || !isObjectID(newID) {
+		return ReflogEntry{}, fmt.Errorf("invalid entry '%s'", line)
+	}
+	committer, err := parseSignatureLine(signature)
+	if err != nil {
+		return ReflogEntry{}, err
+	}
+	return ReflogEntry{Old: oldID, New: newID, Committer: committer, Message: message}, nil
+}
+
+// logRefUpdate appends an entry to the reflog of ref, and to the reflog of
+// HEAD when HEAD points at ref. Only HEAD and branches have reflogs, like in
+// Git.
+func (r *Repository) logRefUpdate(ref, oldID, newID, message string) error {
+	refs := []string{ref}
+	if ref != HeadFile {
+		if !strings.HasPrefix(ref, BranchPrefix) {
+			return nil
+		}
+		if head, _, err := r.readHead(); err != nil {
+			return err
+		} else if head == ref {
+			refs = append(refs, HeadFile)
+		}
+	}
+
+	committer, err := r.identity(CommitterNameEnv, CommitterEmailEnv)
+	if err != nil {
+		return err
+	}
+	if committer.When, err = commitDate(CommitterDateEnv, time.Now().Truncate(time.Second)); err != nil {
+		return err
+	}
+	if oldID == "" {
+		oldID = zeroID
+	}
+	// A message must stay on one line
+	message = strings.ReplaceAll(strings.TrimSpace(message), "\n", " ")
+	line := fmt.Sprintf("%s %s %s %s\t%s\n", oldID, newID, committer, rawDate(committer.When), message)
+
+	for _, ref := range refs {
+		logPath := r.gitPath(LogsDir, filepath.FromSlash(ref))
+		if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for the reflog of %s: %w", ref, err)
+		}
+		f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to update the reflog of %s: %w", ref, err)
+		}
+		_, err = f.WriteString(line)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update the reflog of %s: %w", ref, err)
+		}
+	}
+	return nil
+}
+
+// renameReflog moves the reflog of a renamed branch
+func (r *Repository) renameReflog(oldRef, newRef string) error {
+	oldPath := r.gitPath(LogsDir, filepath.FromSlash(oldRef))
+	newPath := r.gitPath(LogsDir, filepath.FromSlash(newRef))
+	if _, err := os.Stat(oldPath); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for the reflog of %s: %w", newRef, err)
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to rename the reflog of %s: %w", oldRef, err)
+	}
+	r.removeEmptyLogDirs(oldPath)
+	return nil
+}
+
+// deleteReflog removes the reflog of a deleted branch
+func (r *Repository) deleteReflog(ref string) error {
+	logPath := r.gitPath(LogsDir, filepath.FromSlash(ref))
+	err := os.Remove(logPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete the reflog of %s: %w", ref, err)
+	}
+	r.removeEmptyLogDirs(logPath)
+	return nil
+}
+
+// removeEmptyLogDirs removes the directories a removed reflog leaves empty,
+// so they cannot block a branch of the same name
+func (r *Repository) removeEmptyLogDirs(logPath string) {
+	logsRoot := r.gitPath(LogsDir)
+	for dir := filepath.Dir(logPath); dir != logsRoot && strings.HasPrefix(dir, logsRoot); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+}
+
+// headName names what HEAD points at in reflog messages: the current branch,
+// or the commit of a detached HEAD
+func (r *Repository) headName() (string, error) {
+	ref, commitID, err := r.readHead()
+	if err != nil {
+		return "", err
+	}
+	if ref != "" {
+		return strings.TrimPrefix(ref, BranchPrefix), nil
+	}
+	return commitID, nil
+}
@@ -0,0 +1,244 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	RefsDir       = "refs"
+	BranchPrefix  = "refs/heads/"
+	TagPrefix     = "refs/tags/"
+	DefaultBranch = "main"
+
+	// symbolicRefPrefix starts the content of a HEAD that points at a branch
+	symbolicRefPrefix = "ref: "
+)
+
+// readHead returns the ref HEAD points at (empty when HEAD is detached) and
+// the commit it resolves to (empty when the branch has no commits yet)
+func (r *Repository) readHead() (string, string, error) {
+	if err := r.migrateHead(); err != nil {
+		return "", "", err
+	}
+
+	data, err := os.ReadFile(r.gitPath(HeadFile))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	content := strings.TrimSpace(string(data))
+	if ref, ok := strings.CutPrefix(content, symbolicRefPrefix); ok {
+		commitID, err := r.readRef(ref)
+		if err != nil {
+			return "", "", err
+		}
+		return ref, commitID, nil
+	}
+	return "", content, nil
+}
+
+// currentBranch returns the name of the branch HEAD points at, or false when
+// HEAD is detached
+func (r *Repository) currentBranch() (string, bool, error) {
+	ref, _, err := r.readHead()
+	if err != nil {
+		return "", false, err
+	}
+	if ref == "" {
+		return "", false, nil
+	}
+	return strings.TrimPrefix(ref, BranchPrefix), true, nil
+}
+
+// updateHead records a new commit for HEAD: the current branch is moved to
+// it, or HEAD itself when detached. The reflogs record the update with
+// message.
+func (r *Repository) updateHead(commitID, message string) error {
+	ref, oldID, err := r.readHead()
+	if err != nil {
+		return err
+	}
+	if ref != "" {
+		if err := r.writeRef(ref, commitID); err != nil {
+			return err
+		}
+		return r.logRefUpdate(ref, oldID, commitID, message)
+	}
+	if err := r.detachHead(commitID); err != nil {
+		return err
+	}
+	return r.logRefUpdate(HeadFile, oldID, commitID, message)
+}
+
+// attachHead points HEAD at a branch
+func (r *Repository) attachHead(branch string) error {
+	headPath := r.gitPath(HeadFile)
+	content := symbolicRefPrefix + BranchPrefix + branch + "\n"
+	if err := writeFileAtomic(headPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to update HEAD: %w", err)
+	}
+	return nil
+}
+
+// detachHead points HEAD directly at a commit
+func (r *Repository) detachHead(commitID string) error {
+	headPath := r.gitPath(HeadFile)
+	if err := writeFileAtomic(headPath, []byte(commitID+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to update HEAD: %w", err)
+	}
+	return nil
+}
+
+// readRef returns the commit a ref such as "refs/heads/main" points at, or an
+// empty string if the ref does not exist
+func (r *Repository) readRef(ref string) (string, error) {
+	data, err := os.ReadFile(r.gitPath(filepath.FromSlash(ref)))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", ref, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeRef points a ref at a commit
+func (r *Repository) writeRef(ref, commitID string) error {
+	refPath := r.gitPath(filepath.FromSlash(ref))
+	if err := os.MkdirAll(filepath.Dir(refPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", ref, err)
+	}
+	if err := writeFileAtomic(refPath, []byte(commitID+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to update %s: %w", ref, err)
+	}
+	return nil
+}
+
+// deleteRef removes a ref along with any directories it leaves empty
+func (r *Repository) deleteRef(ref string) error {
+	refPath := r.gitPath(filepath.FromSlash(ref))
+	if err := os.Remove(refPath); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", ref, err)
+	}
+	refsRoot := r.gitPath(RefsDir)
+	for dir := filepath.Dir(refPath); dir != refsRoot && strings.HasPrefix(dir, refsRoot); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// listBranches returns the names of all branches, sorted
+func (r *Repository) listBranches() ([]string, error) {
+	if err := r.migrateHead(); err != nil {
+		return nil, err
+	}
+	names, err := r.listRefNames(BranchPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+	return names, nil
+}
+
+// listTags returns the names of all tags, sorted
+func (r *Repository) listTags() ([]string, error) {
+	names, err := r.listRefNames(TagPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return names, nil
+}
+
+// listRefNames returns the names of the refs under a prefix such as
+// "refs/heads/", without the prefix, sorted
+func (r *Repository) listRefNames(prefix string) ([]string, error) {
+	dir := r.gitPath(filepath.FromSlash(prefix))
+	var names []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// checkBranchName validates a branch name using rules similar to Git's
+func checkBranchName(name string) error {
+	invalid := name == "" || name == "HEAD" || strings.HasPrefix(name, "-") ||
+		strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") ||
+		strings.HasSuffix(name, ".lock") || strings.Contains(name, "..") ||
+		strings.Contains(name, "//") || strings.Contains(name, "@{") ||
+		strings.ContainsAny(name, " ~^:?*[\\\x7f")
+	for _, r := range name {
+		if r < 0x20 {
+			invalid = true
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			invalid = true
+		}
+	}
+	if invalid || path.Clean(name) != name {
+		return fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+	return nil
+}
+
+// migrateHead upgrades repositories created before branches existed. Their
+// HEAD file is missing or holds a bare commit ID and there is no refs
+// directory; the history becomes the default branch.
+func (r *Repository) migrateHead() error {
+	if _, err := os.Stat(r.gitPath(RefsDir)); err == nil {
+		return nil
+	}
+
+	commitID := ""
+	data, err := os.ReadFile(r.gitPath(HeadFile))
+	switch {
+	case err == nil:
+		commitID = strings.TrimSpace(string(data))
+		if strings.HasPrefix(commitID, symbolicRefPrefix) {
+			commitID = ""
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		metadata, err := r.readMetadata()
+		if err != nil {
+			return err
+		}
+		if commit := metadata.latest(); commit != nil {
+			commitID = commit.ID
+		}
+	default:
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	if err := os.MkdirAll(r.gitPath(filepath.FromSlash(BranchPrefix)), 0755); err != nil {
+		return fmt.Errorf("failed to create refs directory: %w", err)
+	}
+	if commitID != "" {
+		if err := r.writeRef(BranchPrefix+DefaultBranch, commitID); err != nil {
+			return err
+		}
+	}
+	return r.attachHead(DefaultBranch)
+}
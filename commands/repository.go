@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// GitDirEnv overrides the repository directory, skipping discovery
+	GitDirEnv = "MYGIT_DIR"
+	// WorkTreeEnv overrides the root of the working tree
+	WorkTreeEnv = "MYGIT_WORK_TREE"
+)
+
+// Repository is a mygit repository: the .mygit directory holding the history
+// and the working tree it tracks. Paths stored in the index and in trees are
+// slash-separated and relative to the root of the working tree, while paths
+// passed to methods such as Add are relative to the directory the repository
+// was opened from.
+type Repository struct {
+	// gitDir is the absolute path of the .mygit directory
+	gitDir string
+	// workTree is the absolute path of the root of the working tree
+	workTree string
+	// gitDirName is the slash-separated path of gitDir relative to workTree,
+	// or "" when it is outside the working tree
+	gitDirName string
+	// cwd is the absolute path that relative paths given to methods are
+	// resolved against
+	cwd string
+
+	// LockTimeout is how long commands wait for a lock held by another
+	// process before failing with a *LockError
+	LockTimeout time.Duration
+}
+
+// Open opens the repository containing dir: dir and its parents are searched
+// for a .mygit directory, whose parent is the root of the working tree.
+// Unlike the command line, Open ignores MYGIT_DIR and MYGIT_WORK_TREE.
+func Open(dir string) (*Repository, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	return findRepository(abs, "", "")
+}
+
+// openRepository finds the repository for the current directory. Unless
+// MYGIT_DIR is set, the current directory and its parents are searched for a
+// .mygit directory, whose parent is the root of the working tree.
+// MYGIT_WORK_TREE overrides the root of the working tree; with MYGIT_DIR
+// alone it defaults to the current directory. MYGIT_LOCK_TIMEOUT sets
+// LockTimeout.
+func openRepository() (*Repository, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+	r, err := findRepository(cwd, os.Getenv(GitDirEnv), os.Getenv(WorkTreeEnv))
+	if err != nil {
+		return nil, err
+	}
+	if r.LockTimeout, err = lockTimeoutFromEnv(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// findRepository locates the repository for cwd given the values of
+// MYGIT_DIR and MYGIT_WORK_TREE
+func findRepository(cwd, gitDirEnv, workTreeEnv string) (*Repository, error) {
+	cwd = realPath(cwd)
+	r := &Repository{cwd: cwd}
+
+	if gitDirEnv != "" {
+		r.gitDir = realPath(absPath(cwd, gitDirEnv))
+		if info, err := os.Stat(r.gitDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("not a mygit repository: '%s'", gitDirEnv)
+		}
+		r.workTree = cwd
+	} else {
+		for dir := cwd; ; dir = filepath.Dir(dir) {
+			if info, err := os.Stat(filepath.Join(dir, MyGitDir)); err == nil && info.IsDir() {
+				r.gitDir = filepath.Join(dir, MyGitDir)
+				r.workTree = dir
+				break
+			}
+			if filepath.Dir(dir) == dir {
+				return nil, ErrNotRepository
+			}
+		}
+	}
+
+	if workTreeEnv != "" {
+		r.workTree = realPath(absPath(cwd, workTreeEnv))
+		if info, err := os.Stat(r.workTree); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("invalid work tree '%s'", workTreeEnv)
+		}
+	}
+	if rel, err := filepath.Rel(r.workTree, r.gitDir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		r.gitDirName = filepath.ToSlash(rel)
+	}
+	return r, nil
+}
+
+// GitDir returns the absolute path of the repository directory
+func (r *Repository) GitDir() string {
+	return r.gitDir
+}
+
+// WorkTree returns the absolute path of the root of the working tree
+func (r *Repository) WorkTree() string {
+	return r.workTree
+}
+
+// gitPath returns the path of a file inside the repository directory
+func (r *Repository) gitPath(elem ...string) string {
+	return filepath.Join(append([]string{r.gitDir}, elem...)...)
+}
+
+// workPath returns the path of a slash-separated working tree path
+func (r *Repository) workPath(name string) string {
+	return filepath.Join(r.workTree, filepath.FromSlash(name))
+}
+
+// inWorkTree reports whether a slash-separated path names a file inside the
+// working tree and outside any repository directory in it
+func (r *Repository) inWorkTree(name string) bool {
+	rel, err := filepath.Rel(r.workTree, r.workPath(name))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	for dir := filepath.ToSlash(rel); dir != "."; dir = path.Dir(dir) {
+		if r.isGitDir(dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// linkInPath reports whether a parent directory of a working tree path is a
+// symbolic link
+func (r *Repository) linkInPath(name string) bool {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if info, err := os.Lstat(r.workPath(dir)); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isGitDir reports whether a working tree path is the repository directory
+// or a Git repository directory, such as the one written by export-git.
+// Neither is ever part of the working tree.
+func (r *Repository) isGitDir(name string) bool {
+	return name == MyGitDir || name == r.gitDirName || path.Base(name) == DotGitDir
+}
+
+// workTreeFS returns the working tree as a file system whose names are
+// slash-separated working tree paths
+func (r *Repository) workTreeFS() fs.FS {
+	return os.DirFS(r.workTree)
+}
+
+// repoPath converts a path relative to the directory the repository was
+// opened from into a slash-separated path relative to the root of the working
+// tree. The root itself is ".".
+func (r *Repository) repoPath(arg string) (string, error) {
+	rel, err := filepath.Rel(r.workTree, absPath(r.cwd, arg))
+	if err != nil {
+		return "", fmt.Errorf("'%s' is outside the repository", arg)
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("'%s' is outside the repository", arg)
+	}
+	for dir := rel; dir != "."; dir = path.Dir(dir) {
+		if r.isGitDir(dir) {
+			return "", fmt.Errorf("'%s' is inside the repository directory", arg)
+		}
+	}
+	return rel, nil
+}
+
+// repoPaths converts several paths with repoPath
+func (r *Repository) repoPaths(args []string) ([]string, error) {
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		p, err := r.repoPath(arg)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// absPath resolves p against dir unless it is already absolute
+func absPath(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(dir, p)
+}
+
+// realPath resolves symbolic links so that paths can be compared, keeping the
+// path as is when that fails
+func realPath(p string) string {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	return p
+}
@@ -0,0 +1,187 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestRepositoryDiscovery tests running commands from a subdirectory
+func TestRepositoryDiscovery(t *testing.T) {
+	tests := []struct {
+		name          string
+		dir           string
+		args          []string
+		expectedError bool
+		expectedPaths []string
+	}{
+		{
+			name:          "file in current directory",
+			dir:           "a/b",
+			args:          []string{"deep.txt"},
+			expectedPaths: []string{"a/b/deep.txt"},
+		},
+		{
+			name:          "file in parent directory",
+			dir:           "a/b",
+			args:          []string{"../../top.txt"},
+			expectedPaths: []string{"top.txt"},
+		},
+		{
+			name:          "current directory",
+			dir:           "a",
+			args:          []string{"."},
+			expectedPaths: []string{"a/b/deep.txt", "a/mid.txt"},
+		},
+		{
+			name:          "glob in subdirectory",
+			dir:           "a",
+			args:          []string{"*.txt"},
+			expectedPaths: []string{"a/mid.txt"},
+		},
+		{
+			name:          "path outside the repository",
+			dir:           "a",
+			args:          []string{"../../outside.txt"},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			root := filepath.Join(tempDir, "repo")
+			os.MkdirAll(filepath.Join(root, "a", "b"), 0755)
+			os.Chdir(root)
+			commands.Init()
+			os.WriteFile("top.txt", []byte("top\n"), 0644)
+			os.WriteFile(filepath.Join("a", "mid.txt"), []byte("mid\n"), 0644)
+			os.WriteFile(filepath.Join("a", "b", "deep.txt"), []byte("deep\n"), 0644)
+			os.WriteFile(filepath.Join(tempDir, "outside.txt"), []byte("outside\n"), 0644)
+
+			os.Chdir(filepath.Join(root, filepath.FromSlash(tt.dir)))
+			err := commands.Add(tt.args)
+
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			idx, err := commands.ReadIndex()
+			if err != nil {
+				t.Fatalf("failed to read index: %v", err)
+			}
+			if len(idx.Entries) != len(tt.expectedPaths) {
+				t.Fatalf("expected %d entries, got %v", len(tt.expectedPaths), idx.Entries)
+			}
+			for i, path := range tt.expectedPaths {
+				if idx.Entries[i].Path != path {
+					t.Errorf("expected entry %d to be %s, got %s", i, path, idx.Entries[i].Path)
+				}
+			}
+		})
+	}
+}
+
+// TestRepositoryDiscoveryStatus tests that status and commit see the whole
+// working tree from a subdirectory
+func TestRepositoryDiscoveryStatus(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+	os.MkdirAll("sub", 0755)
+	os.WriteFile("top.txt", []byte("top\n"), 0644)
+	os.WriteFile(filepath.Join("sub", "file.txt"), []byte("sub\n"), 0644)
+
+	os.Chdir("sub")
+	if err := commands.AddWithOptions(nil, commands.AddOptions{All: true}); err != nil {
+		t.Fatalf("failed to add: %v", err)
+	}
+	if err := commands.Commit("Initial commit"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(tempDir, "top.txt"), []byte("changed\n"), 0644)
+	result, err := commands.GetStatus()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if len(result.Unstaged) != 1 || result.Unstaged[0].Path != "top.txt" {
+		t.Errorf("expected top.txt to be modified, got %v", result.Unstaged)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, ".mygit")); err != nil {
+		t.Errorf("expected the repository at the root: %v", err)
+	}
+	if _, err := os.Stat(".mygit"); !os.IsNotExist(err) {
+		t.Errorf("expected no repository in the subdirectory")
+	}
+}
+
+// TestRepositoryEnvironment tests the MYGIT_DIR and MYGIT_WORK_TREE overrides
+func TestRepositoryEnvironment(t *testing.T) {
+	tests := []struct {
+		name          string
+		gitDir        string
+		workTree      string
+		expectedError bool
+		expectedPaths []string
+	}{
+		{
+			name:          "repository directory and work tree",
+			gitDir:        "store",
+			workTree:      "tree",
+			expectedPaths: []string{"file.txt"},
+		},
+		{
+			name:          "repository directory only",
+			gitDir:        "store",
+			expectedPaths: []string{"tree/file.txt"},
+		},
+		{
+			name:          "missing repository directory",
+			gitDir:        "missing",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			os.Chdir(tempDir)
+			t.Setenv(commands.GitDirEnv, filepath.Join(tempDir, "store"))
+			commands.Init()
+			os.MkdirAll("tree", 0755)
+			os.WriteFile(filepath.Join("tree", "file.txt"), []byte("content\n"), 0644)
+
+			t.Setenv(commands.GitDirEnv, filepath.Join(tempDir, tt.gitDir))
+			if tt.workTree != "" {
+				t.Setenv(commands.WorkTreeEnv, filepath.Join(tempDir, tt.workTree))
+			}
+			err := commands.AddWithOptions(nil, commands.AddOptions{All: true})
+
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			idx, err := commands.ReadIndex()
+			if err != nil {
+				t.Fatalf("failed to read index: %v", err)
+			}
+			if len(idx.Entries) != len(tt.expectedPaths) || idx.Entries[0].Path != tt.expectedPaths[0] {
+				t.Errorf("expected entries %v, got %v", tt.expectedPaths, idx.Entries)
+			}
+			if _, err := os.Stat(filepath.Join(tempDir, "store", "index")); err != nil {
+				t.Errorf("expected the index in MYGIT_DIR: %v", err)
+			}
+		})
+	}
+}
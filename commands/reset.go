@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Modes accepted by Reset
+const (
+	ResetSoft  = "soft"
+	ResetMixed = "mixed"
+	ResetHard  = "hard"
+)
+
+// OrigHeadFile records the commit HEAD pointed at before the last reset, so
+// the reset can be undone with "mygit reset ORIG_HEAD"
+const OrigHeadFile = "ORIG_HEAD"
+
+// ResetOptions controls Reset
+type ResetOptions struct {
+	// Mode is ResetSoft, ResetMixed or ResetHard; it defaults to ResetMixed
+	Mode string
+}
+
+// Reset moves the current branch (or a detached HEAD) to a commit. The soft
+// mode keeps the index and working tree, so the undone commits' changes stay
+// staged. The mixed mode also resets the index, leaving the changes in the
+// working tree. The hard mode resets the index and working tree as well,
+// discarding all changes to tracked files.
+func Reset(rev string, opts ResetOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	unlock, err := r.lock(IndexFile, HeadFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if opts.Mode == "" {
+		opts.Mode = ResetMixed
+	}
+	if opts.Mode != ResetSoft && opts.Mode != ResetMixed && opts.Mode != ResetHard {
+		return fmt.Errorf("unknown reset mode: %s", opts.Mode)
+	}
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	commit, err := r.ResolveCommit(rev)
+	if err != nil {
+		return err
+	}
+	commitID := commit.ID
+	targetFiles, err := r.commitFiles(commit)
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %w", rev, err)
+	}
+
+	_, oldHeadID, err := r.readHead()
+	if err != nil {
+		return err
+	}
+	headSnapshot, err := r.headFiles()
+	if err != nil {
+		return err
+	}
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+
+	switch opts.Mode {
+	case ResetSoft:
+		if len(idx.Conflicts) > 0 {
+			return errors.New("cannot do a soft reset in the middle of a merge")
+		}
+	case ResetMixed:
+		resetIndex(idx, targetFiles)
+		if err := idx.Write(); err != nil {
+			return err
+		}
+	case ResetHard:
+		if err := r.checkoutSnapshot(idx, headSnapshot, targetFiles, true); err != nil {
+			return err
+		}
+		if err := idx.Write(); err != nil {
+			return err
+		}
+	}
+
+	if oldHeadID != "" {
+		if err := writeFileAtomic(r.gitPath(OrigHeadFile), []byte(oldHeadID+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", OrigHeadFile, err)
+		}
+	}
+	if err := r.updateHead(commitID, "reset: moving to "+rev); err != nil {
+		return err
+	}
+	if err := r.clearMergeState(); err != nil {
+		return err
+	}
+
+	switch opts.Mode {
+	case ResetHard:
+		message := commit.Message
+		fmt.Printf("HEAD is now at %s %s\n", commitID[:7], firstLine(message))
+	case ResetMixed:
+		result, err := r.Status()
+		if err != nil {
+			return err
+		}
+		if len(result.Unstaged) > 0 {
+			fmt.Println("Unstaged changes after reset:")
+			for _, change := range result.Unstaged {
+				fmt.Printf("%c\t%s\n", statusCode(change.Change), change.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// resetIndex makes the index hold exactly the given snapshot. Entries whose
+// content is unchanged keep their stat data so they are not rehashed.
+func resetIndex(idx *Index, files []FileEntry) {
+	entries := make([]IndexEntry, 0, len(files))
+	for _, file := range files {
+		if entry, ok := idx.Entry(file.Path); ok && entry.Hash == file.Hash && entry.Mode == file.Mode {
+			entries = append(entries, entry)
+			continue
+		}
+		entries = append(entries, IndexEntry{Path: file.Path, Hash: file.Hash, Mode: file.Mode})
+	}
+	idx.Entries = nil
+	idx.Conflicts = nil
+	for _, entry := range entries {
+		idx.Set(entry)
+	}
+}
@@ -0,0 +1,125 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestReset tests moving the branch back with each reset mode
+func TestReset(t *testing.T) {
+	tests := []struct {
+		name                string
+		mode                string
+		expectedWorkTree    string
+		expectedStaged      int
+		expectedUnstaged    int
+		expectedNewFileGone bool
+	}{
+		// The second commit modified file.txt and added dir/new.txt
+		{name: "soft keeps changes staged", mode: commands.ResetSoft, expectedWorkTree: "version 2\n", expectedStaged: 2},
+		{name: "mixed keeps changes in working tree", mode: commands.ResetMixed, expectedWorkTree: "version 2\n", expectedUnstaged: 1},
+		{name: "default mode is mixed", mode: "", expectedWorkTree: "version 2\n", expectedUnstaged: 1},
+		{name: "hard discards changes", mode: commands.ResetHard, expectedWorkTree: "version 1\n", expectedNewFileGone: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := setupTwoCommits(t)
+
+			if err := commands.Reset("HEAD~1", commands.ResetOptions{Mode: tt.mode}); err != nil {
+				t.Fatalf("failed to reset: %v", err)
+			}
+
+			if readBranch(t, "main") != ids[0] {
+				t.Errorf("expected main to point at %s", ids[0])
+			}
+			content, _ := os.ReadFile("file.txt")
+			if string(content) != tt.expectedWorkTree {
+				t.Errorf("expected working tree content %q, got %q", tt.expectedWorkTree, content)
+			}
+			_, err := os.Stat(filepath.Join("dir", "new.txt"))
+			if gone := os.IsNotExist(err); gone != tt.expectedNewFileGone {
+				t.Errorf("expected dir/new.txt removed to be %v", tt.expectedNewFileGone)
+			}
+
+			result, err := commands.GetStatus()
+			if err != nil {
+				t.Fatalf("failed to get status: %v", err)
+			}
+			if len(result.Staged) != tt.expectedStaged {
+				t.Errorf("expected %d staged changes, got %v", tt.expectedStaged, result.Staged)
+			}
+			if len(result.Unstaged) != tt.expectedUnstaged {
+				t.Errorf("expected %d unstaged changes, got %v", tt.expectedUnstaged, result.Unstaged)
+			}
+
+			data, _ := os.ReadFile(filepath.Join(commands.MyGitDir, commands.OrigHeadFile))
+			if strings.TrimSpace(string(data)) != ids[1] {
+				t.Errorf("expected ORIG_HEAD to record %s, got %q", ids[1], data)
+			}
+		})
+	}
+}
+
+// TestResetThenCommit tests fixing the last commit and undoing a reset
+func TestResetThenCommit(t *testing.T) {
+	ids := setupTwoCommits(t)
+
+	if err := commands.Reset("HEAD^", commands.ResetOptions{Mode: commands.ResetSoft}); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if err := commands.Commit("Second commit, reworded"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	tip := readBranch(t, "main")
+	if parents := commitParentIDs(t, tip); len(parents) != 1 || parents[0] != ids[0] {
+		t.Errorf("expected new commit to follow %s, got %v", ids[0], parents)
+	}
+
+	// ORIG_HEAD brings the reworded commit back after another reset
+	if err := commands.Reset("HEAD~1", commands.ResetOptions{Mode: commands.ResetHard}); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if err := commands.Reset("ORIG_HEAD", commands.ResetOptions{Mode: commands.ResetHard}); err != nil {
+		t.Fatalf("failed to reset to ORIG_HEAD: %v", err)
+	}
+	if readBranch(t, "main") != tip {
+		t.Errorf("expected main to be restored to %s", tip)
+	}
+	content, _ := os.ReadFile("file.txt")
+	if string(content) != "version 2\n" {
+		t.Errorf("expected working tree to be restored, got %q", content)
+	}
+}
+
+// TestResetErrors tests invalid resets
+func TestResetErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rev  string
+		mode string
+	}{
+		{name: "before the first commit", rev: "HEAD~2"},
+		{name: "missing second parent", rev: "HEAD^2"},
+		{name: "unknown commit", rev: "missing"},
+		{name: "malformed suffix", rev: "HEAD~x"},
+		{name: "unknown mode", rev: "HEAD", mode: "keep"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := setupTwoCommits(t)
+
+			if err := commands.Reset(tt.rev, commands.ResetOptions{Mode: tt.mode}); err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if readBranch(t, "main") != ids[1] {
+				t.Errorf("main should not move")
+			}
+		})
+	}
+}
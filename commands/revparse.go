@@ -0,0 +1,642 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// minAbbrevLength is the shortest abbreviated object ID that is looked up
+const minAbbrevLength = 4
+
+// RevisionRange is the set of commits named by a revision range: those
+// reachable from a commit in Include but from none in Exclude
+type RevisionRange struct {
+	Include []string
+	Exclude []string
+}
+
+// RevParseOptions controls RevParse
+type RevParseOptions struct {
+	// Verify requires exactly one revision naming a single object
+	Verify bool
+}
+
+// RevParse prints the object IDs the revisions name, one per line
+func RevParse(args []string, opts RevParseOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	if opts.Verify {
+		if len(args) != 1 {
+			return errors.New("--verify requires exactly one revision")
+		}
+		id, err := r.ResolveRevision(args[0])
+		if err != nil {
+			return err
+		}
+		lines = []string{id}
+	} else if lines, err = r.RevParse(args); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// RevParse resolves revisions to object IDs like "git rev-parse". A range
+// gives the commits it includes followed by the ones it excludes prefixed
+// with "^": "A..B" gives B and ^A, and "A...B" gives B, A and ^ their merge
+// base.
+func (r *Repository) RevParse(args []string) ([]string, error) {
+	var lines []string
+	for _, arg := range args {
+		if _, _, _, ok := splitRange(arg); !ok && !strings.HasPrefix(arg, "^") {
+			id, err := r.ResolveRevision(arg)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, id)
+			continue
+		}
+		revs, err := r.ResolveRange(arg)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, revs.Include...)
+		for _, id := range revs.Exclude {
+			lines = append(lines, "^"+id)
+		}
+	}
+	return lines, nil
+}
+
+// ResolveRevision returns the ID of the object a revision names. A
+// revision starts with one of
+//
+//   - HEAD (or @), ORIG_HEAD or MERGE_HEAD
+//   - a tag or branch name, or a full ref such as refs/heads/main; a tag
+//     wins over a branch of the same name
+//   - an object ID, or a unique abbreviation of at least 4 hex digits
+//   - <ref>@{<n>}: the n-th previous value of a branch or HEAD recorded in
+//     its reflog; @{<n>} alone uses the current branch
+//
+// followed by any number of suffixes
+//
+//   - ~<n>: the n-th first-parent ancestor
+//   - ^<n>: the n-th parent; ^0 is the commit itself
+//   - ^{commit}, ^{tree}, ^{}: the commit, its tree, or the object an
+//     annotated tag points at
+//
+// A revision can also name a file or directory: <rev>:<path> in the tree of
+// a commit, and :<path> or :<stage>:<path> in the index. Paths are relative
+// to the root of the working tree unless they start with ./ or ../.
+func (r *Repository) ResolveRevision(rev string) (string, error) {
+	p, err := r.newRevParser()
+	if err != nil {
+		return "", err
+	}
+	return p.object(rev, false)
+}
+
+// ResolveCommit finds the commit a revision names, such as "HEAD~1",
+// "main^2", "v1.0" or an abbreviated commit ID. Tags are followed to the
+// commit they tag. See ResolveRevision for the syntax.
+func (r *Repository) ResolveCommit(rev string) (*CommitRecord, error) {
+	p, err := r.newRevParser()
+	if err != nil {
+		return nil, err
+	}
+	id, err := p.object(rev, true)
+	if err != nil {
+		return nil, err
+	}
+	return p.peelCommit(id)
+}
+
+// ResolveRange resolves a revision range: "A..B" (the commits reachable
+// from B but not from A), "A...B" (the commits reachable from either but not
+// from both), "^A" (excluding the commits reachable from A) or a single
+// revision. A side left out of ".." or "..." defaults to HEAD.
+func (r *Repository) ResolveRange(expr string) (*RevisionRange, error) {
+	p, err := r.newRevParser()
+	if err != nil {
+		return nil, err
+	}
+	commitID := func(rev string) (string, error) {
+		id, err := p.object(rev, true)
+		if err != nil {
+			return "", err
+		}
+		commit, err := p.peelCommit(id)
+		if err != nil {
+			return "", err
+		}
+		return commit.ID, nil
+	}
+
+	if rev, ok := strings.CutPrefix(expr, "^"); ok {
+		id, err := commitID(rev)
+		if err != nil {
+			return nil, err
+		}
+		return &RevisionRange{Exclude: []string{id}}, nil
+	}
+	from, to, symmetric, ok := splitRange(expr)
+	if !ok {
+		id, err := commitID(expr)
+		if err != nil {
+			return nil, err
+		}
+		return &RevisionRange{Include: []string{id}}, nil
+	}
+
+	fromID, err := commitID(from)
+	if err != nil {
+		return nil, err
+	}
+	toID, err := commitID(to)
+	if err != nil {
+		return nil, err
+	}
+	if !symmetric {
+		return &RevisionRange{Include: []string{toID}, Exclude: []string{fromID}}, nil
+	}
+	revs := &RevisionRange{Include: []string{toID, fromID}}
+	if base := p.metadata.mergeBase(fromID, toID); base != "" {
+		revs.Exclude = []string{base}
+	}
+	return revs, nil
+}
+
+// splitRange splits "A..B" or "A...B" into its sides, which default to
+// HEAD, and reports whether it is symmetric ("...")
+func splitRange(expr string) (string, string, bool, bool) {
+	// Paths may contain dots; ref names cannot contain ".." or ":"
+	if strings.Contains(expr, ":") {
+		return "", "", false, false
+	}
+	sep := "..."
+	i := strings.Index(expr, sep)
+	if i < 0 {
+		sep = ".."
+		if i = strings.Index(expr, sep); i < 0 {
+			return "", "", false, false
+		}
+	}
+	from, to := expr[:i], expr[i+len(sep):]
+	if from == "" {
+		from = HeadFile
+	}
+	if to == "" {
+		to = HeadFile
+	}
+	return from, to, sep == "...", true
+}
+
+// revParser resolves revisions against the history
+type revParser struct {
+	repo     *Repository
+	metadata *metadata
+}
+
+func (r *Repository) newRevParser() (*revParser, error) {
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+	return &revParser{repo: r, metadata: metadata}, nil
+}
+
+// object resolves a revision to an object ID. When commitish is set, an
+// ambiguous abbreviated ID may match a single commit.
+func (p *revParser) object(rev string, commitish bool) (string, error) {
+	if i := strings.Index(rev, ":"); i >= 0 {
+		return p.pathObject(rev[:i], rev[i+1:])
+	}
+
+	name, suffix := rev, ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		name, suffix = rev[:i], rev[i:]
+	}
+	id, err := p.base(name, commitish || suffix != "")
+	if err != nil {
+		return "", err
+	}
+	for suffix != "" {
+		if id, suffix, err = p.applySuffix(id, suffix, rev); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// base resolves a revision without suffixes
+func (p *revParser) base(name string, commitish bool) (string, error) {
+	if name == "" {
+		return "", errors.New("invalid revision: missing the revision before the suffix")
+	}
+	if name == "@" {
+		name = HeadFile
+	}
+	if i := strings.Index(name, "@{"); i >= 0 && strings.HasSuffix(name, "}") {
+		return p.reflogEntry(name[:i], name[i+2:len(name)-1])
+	}
+
+	switch name {
+	case HeadFile:
+		_, commitID, err := p.repo.readHead()
+		if err != nil {
+			return "", err
+		}
+		if commitID == "" {
+			return "", errors.New("HEAD does not point to a commit yet")
+		}
+		return commitID, nil
+	case OrigHeadFile, MergeHeadFile:
+		data, err := os.ReadFile(p.repo.gitPath(name))
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrUnknownRevision, name)
+		}
+		commitID, _, _ := strings.Cut(string(data), "\n")
+		return strings.TrimSpace(commitID), nil
+	}
+
+	// Like Git, a tag wins over a branch of the same name
+	for _, ref := range []string{name, TagPrefix + name, BranchPrefix + name} {
+		refName, ok := strings.CutPrefix(ref, TagPrefix)
+		if !ok {
+			refName, ok = strings.CutPrefix(ref, BranchPrefix)
+		}
+		if !ok || checkBranchName(refName) != nil {
+			continue
+		}
+		target, err := p.repo.readRef(ref)
+		if err != nil {
+			return "", err
+		}
+		if target != "" {
+			return target, nil
+		}
+	}
+
+	if isObjectID(name) && (p.metadata.find(name) != nil || p.repo.hasObject(name)) {
+		return name, nil
+	}
+	if len(name) >= minAbbrevLength && isHex(name) {
+		return p.expand(name, commitish)
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownRevision, name)
+}
+
+// reflogEntry resolves <ref>@{<n>} to the value the ref had n updates ago
+func (p *revParser) reflogEntry(name, selector string) (string, error) {
+	n, err := strconv.Atoi(selector)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("unsupported reflog selector: %s@{%s}", name, selector)
+	}
+
+	var ref string
+	switch {
+	case name == "":
+		// The current branch, or HEAD when it is detached
+		head, _, err := p.repo.readHead()
+		if err != nil {
+			return "", err
+		}
+		ref = HeadFile
+		if head != "" {
+			ref = head
+		}
+	case name == HeadFile:
+		ref = HeadFile
+	case strings.HasPrefix(name, BranchPrefix):
+		ref = name
+	default:
+		ref = BranchPrefix + name
+	}
+	if ref != HeadFile && checkBranchName(strings.TrimPrefix(ref, BranchPrefix)) != nil {
+		return "", fmt.Errorf("%w: %s@{%s}", ErrUnknownRevision, name, selector)
+	}
+
+	entries, err := p.repo.Reflog(ref)
+	if err != nil {
+		return "", err
+	}
+	if n < len(entries) {
+		return entries[n].New, nil
+	}
+	if n == 0 {
+		// Refs updated without a reflog, such as imported branches
+		current, err := p.repo.readRef(ref)
+		if ref == HeadFile {
+			_, current, err = p.repo.readHead()
+		}
+		if err != nil {
+			return "", err
+		}
+		if current != "" {
+			return current, nil
+		}
+	}
+	return "", fmt.Errorf("the reflog of %s only has %d entries", ref, len(entries))
+}
+
+// expand finds the object an abbreviated ID names
+func (p *revParser) expand(prefix string, commitish bool) (string, error) {
+	seen := make(map[string]bool)
+	var matches, commits []string
+	add := func(id string) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		matches = append(matches, id)
+		if p.metadata.find(id) != nil {
+			commits = append(commits, id)
+		}
+	}
+	for _, commit := range p.metadata.CommitHistory {
+		if strings.HasPrefix(commit.ID, prefix) {
+			add(commit.ID)
+		}
+	}
+	entries, _ := os.ReadDir(p.repo.gitPath(ObjectsDir, prefix[:2]))
+	for _, entry := range entries {
+		id := prefix[:2] + entry.Name()
+		if isObjectID(id) && strings.HasPrefix(id, prefix) {
+			add(id)
+		}
+	}
+
+	if len(matches) > 1 && commitish && len(commits) == 1 {
+		return commits[0], nil
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrUnknownRevision, prefix)
+	case 1:
+		return matches[0], nil
+	}
+	for i, id := range matches {
+		matches[i] = id[:len(prefix)+4]
+	}
+	return "", fmt.Errorf("short ID %s is ambiguous; it matches %s", prefix, strings.Join(matches, ", "))
+}
+
+// applySuffix applies the first suffix of a revision to the object id and
+// returns the result with the remaining suffixes
+func (p *revParser) applySuffix(id, suffix, rev string) (string, string, error) {
+	op, rest := suffix[0], suffix[1:]
+
+	// ^{<type>} peels the object
+	if op == '^' && strings.HasPrefix(rest, "{") {
+		end := strings.Index(rest, "}")
+		if end < 0 {
+			return "", "", fmt.Errorf("invalid revision: %s", rev)
+		}
+		kind := rest[1:end]
+		rest = rest[end+1:]
+		switch kind {
+		case "":
+			id, err := p.peelTags(id)
+			return id, rest, err
+		case CommitObject:
+			commit, err := p.peelCommit(id)
+			if err != nil {
+				return "", "", err
+			}
+			return commit.ID, rest, nil
+		case TreeObject:
+			id, err := p.peelTree(id)
+			return id, rest, err
+		}
+		return "", "", fmt.Errorf("invalid revision: %s (^{%s} is not supported)", rev, kind)
+	}
+
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	n := 1
+	if digits > 0 {
+		var err error
+		if n, err = strconv.Atoi(rest[:digits]); err != nil {
+			return "", "", fmt.Errorf("invalid revision: %s", rev)
+		}
+	}
+	rest = rest[digits:]
+	if rest != "" && rest[0] != '~' && rest[0] != '^' {
+		return "", "", fmt.Errorf("invalid revision: %s", rev)
+	}
+
+	commit, err := p.peelCommit(id)
+	if err != nil {
+		return "", "", err
+	}
+	switch {
+	case op == '~':
+		for ; n > 0; n-- {
+			if len(commit.Parents) == 0 {
+				return "", "", fmt.Errorf("%w: %s (history is too short)", ErrUnknownRevision, rev)
+			}
+			if commit, err = p.peelCommit(commit.Parents[0]); err != nil {
+				return "", "", err
+			}
+		}
+	case n > 0:
+		if n > len(commit.Parents) {
+			return "", "", fmt.Errorf("%w: %s (no parent %d)", ErrUnknownRevision, rev, n)
+		}
+		if commit, err = p.peelCommit(commit.Parents[n-1]); err != nil {
+			return "", "", err
+		}
+	}
+	return commit.ID, rest, nil
+}
+
+// peelCommit follows annotated tags from id to a commit in the history
+func (p *revParser) peelCommit(id string) (*CommitRecord, error) {
+	for {
+		if commit := p.metadata.find(id); commit != nil {
+			return commit, nil
+		}
+		objType, data, err := p.repo.ReadObject(id)
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil, fmt.Errorf("unknown commit: %s", id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if objType != TagObject {
+			return nil, fmt.Errorf("object %s is a %s, not a commit", id, objType)
+		}
+		tag, err := decodeTag(data)
+		if err != nil {
+			return nil, fmt.Errorf("tag %s: %w", id, err)
+		}
+		id = tag.Object
+	}
+}
+
+// peelTree follows annotated tags and commits from id to a tree
+func (p *revParser) peelTree(id string) (string, error) {
+	for {
+		if commit := p.metadata.find(id); commit != nil {
+			if commit.Tree == "" {
+				return "", fmt.Errorf("commit %s was made before trees were stored and has none", id)
+			}
+			return commit.Tree, nil
+		}
+		objType, data, err := p.repo.ReadObject(id)
+		if err != nil {
+			return "", err
+		}
+		switch objType {
+		case TreeObject:
+			return id, nil
+		case TagObject:
+			tag, err := decodeTag(data)
+			if err != nil {
+				return "", fmt.Errorf("tag %s: %w", id, err)
+			}
+			id = tag.Object
+		default:
+			return "", fmt.Errorf("object %s is a %s, not a tree", id, objType)
+		}
+	}
+}
+
+// peelTags follows annotated tags from id to the object they tag
+func (p *revParser) peelTags(id string) (string, error) {
+	for p.metadata.find(id) == nil {
+		objType, data, err := p.repo.ReadObject(id)
+		if err != nil {
+			return "", err
+		}
+		if objType != TagObject {
+			break
+		}
+		tag, err := decodeTag(data)
+		if err != nil {
+			return "", fmt.Errorf("tag %s: %w", id, err)
+		}
+		id = tag.Object
+	}
+	return id, nil
+}
+
+// pathObject resolves <rev>:<path> to the object at path in the tree of
+// rev, and :<path> or :<stage>:<path> to the object staged for path
+func (p *revParser) pathObject(rev, name string) (string, error) {
+	stage := -1
+	if rev == "" {
+		stage = 0
+		if len(name) >= 2 && name[1] == ':' && name[0] >= '0' && name[0] <= '3' {
+			stage, name = int(name[0]-'0'), name[2:]
+		}
+	}
+	filePath := path.Clean("/" + name)[1:]
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") || name == "." || name == ".." {
+		var err error
+		if filePath, err = p.repo.repoPath(name); err != nil {
+			return "", err
+		}
+		if filePath == "." {
+			filePath = ""
+		}
+	}
+	if stage >= 0 {
+		return p.indexObject(stage, filePath)
+	}
+
+	id, err := p.object(rev, false)
+	if err != nil {
+		return "", err
+	}
+	// Commits made before trees were stored only list their files
+	if commit, err := p.peelCommit(id); err == nil && commit.Tree == "" {
+		for _, file := range commit.files {
+			if file.Path == filePath {
+				return file.Hash, nil
+			}
+		}
+		return "", fmt.Errorf("path '%s' does not exist in '%s'", filePath, rev)
+	}
+	tree, err := p.peelTree(id)
+	if err != nil {
+		return "", err
+	}
+	if filePath == "" {
+		return tree, nil
+	}
+
+	components := strings.Split(filePath, "/")
+	for i, component := range components {
+		_, data, err := p.repo.ReadObject(tree)
+		if err != nil {
+			return "", err
+		}
+		entries, err := decodeTree(data)
+		if err != nil {
+			return "", fmt.Errorf("failed to read tree %s: %w", tree, err)
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.Name != component {
+				continue
+			}
+			if i == len(components)-1 {
+				return entry.Hash, nil
+			}
+			if entry.Mode == ModeDir {
+				tree, found = entry.Hash, true
+			}
+			break
+		}
+		if !found {
+			break
+		}
+	}
+	return "", fmt.Errorf("path '%s' does not exist in '%s'", filePath, rev)
+}
+
+// indexObject returns the blob staged for a path: stage 0 is a merged entry,
+// and stages 1, 2 and 3 are the base, ours and theirs versions of a conflict
+func (p *revParser) indexObject(stage int, filePath string) (string, error) {
+	idx, err := p.repo.ReadIndex()
+	if err != nil {
+		return "", err
+	}
+	if stage == 0 {
+		for _, entry := range idx.Entries {
+			if entry.Path == filePath {
+				return entry.Hash, nil
+			}
+		}
+	} else if conflict, ok := idx.Conflict(filePath); ok {
+		hash := []string{conflict.BaseHash, conflict.OursHash, conflict.TheirsHash}[stage-1]
+		if hash != "" {
+			return hash, nil
+		}
+	}
+	return "", fmt.Errorf("path '%s' is not in the index at stage %d", filePath, stage)
+}
+
+// isHex reports whether s consists of lowercase hex digits
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
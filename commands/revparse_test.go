@@ -0,0 +1,256 @@
+package commands_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestResolveRevision tests the revision syntax against a history with a
+// merge, a tag and a reflog
+func TestResolveRevision(t *testing.T) {
+	repo := setupExportHistory(t)
+	repo.CreateTag("v2", "feature", commands.TagOptions{Message: "Release 2"})
+	resolve := func(rev string) string {
+		t.Helper()
+		id, err := repo.ResolveRevision(rev)
+		if err != nil {
+			t.Fatalf("failed to resolve %s: %v", rev, err)
+		}
+		return id
+	}
+	head, _ := repo.ResolveCommit("HEAD")
+	feature, _ := repo.ResolveCommit("feature")
+	removed, _ := repo.ResolveCommit(head.Parents[0])
+	second, _ := repo.ResolveCommit(removed.Parents[0])
+	first, _ := repo.ResolveCommit(second.Parents[0])
+	v2 := readTag(t, "v2")
+
+	tests := []struct {
+		rev      string
+		expected string
+	}{
+		{rev: "HEAD", expected: head.ID},
+		{rev: "@", expected: head.ID},
+		{rev: "main", expected: head.ID},
+		{rev: "refs/heads/feature", expected: feature.ID},
+		{rev: head.ID[:7], expected: head.ID},
+		{rev: "HEAD^", expected: removed.ID},
+		{rev: "HEAD^2", expected: feature.ID},
+		{rev: "HEAD~2", expected: second.ID},
+		{rev: "HEAD^1~1^", expected: first.ID},
+		{rev: "main^0", expected: head.ID},
+		{rev: "v1", expected: second.ID},
+		{rev: "v2", expected: v2},
+		{rev: "v2^{}", expected: feature.ID},
+		{rev: "v2^{commit}", expected: feature.ID},
+		{rev: "v2~1", expected: first.ID},
+		{rev: "HEAD^{tree}", expected: head.Tree},
+		{rev: "HEAD:", expected: head.Tree},
+		{rev: "HEAD~2:dir/new.txt", expected: commands.HashObject(commands.BlobObject, []byte("new\n"))},
+		{rev: "v1:file.txt", expected: commands.HashObject(commands.BlobObject, []byte("version 2\n"))},
+		{rev: ":file.txt", expected: commands.HashObject(commands.BlobObject, []byte("version 2\n"))},
+		{rev: ":0:file.txt", expected: commands.HashObject(commands.BlobObject, []byte("version 2\n"))},
+		{rev: "main@{0}", expected: head.ID},
+		{rev: "main@{1}", expected: removed.ID},
+		{rev: "@{2}", expected: second.ID},
+		{rev: "HEAD@{1}", expected: removed.ID},
+		{rev: "HEAD@{2}", expected: second.ID},
+		{rev: "feature@{0}", expected: feature.ID},
+		{rev: "feature@{1}", expected: first.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rev, func(t *testing.T) {
+			if got := resolve(tt.rev); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	// Paths starting with ./ are relative to the current directory
+	sub, err := commands.Open("dir")
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	if id, err := sub.ResolveRevision("HEAD~2:./new.txt"); err != nil || id != resolve("HEAD~2:dir/new.txt") {
+		t.Errorf("expected ./new.txt to be resolved in dir, got %s (%v)", id, err)
+	}
+}
+
+// TestResolveRevisionErrors tests rejecting revisions that name nothing
+func TestResolveRevisionErrors(t *testing.T) {
+	repo := setupExportHistory(t)
+	tests := []string{
+		"missing",
+		"HEAD~10",
+		"HEAD^3",
+		"~1",
+		"HEAD~x",
+		"HEAD^{blob}",
+		"HEAD:missing.txt",
+		"HEAD:file.txt/child",
+		":2:file.txt",
+		"main@{10}",
+		"main@{yesterday}",
+		"missing@{0}",
+		"ORIG_HEAD",
+		"fff",
+	}
+	for _, rev := range tests {
+		t.Run(rev, func(t *testing.T) {
+			if id, err := repo.ResolveRevision(rev); err == nil {
+				t.Errorf("expected error but got %s", id)
+			}
+		})
+	}
+
+	// A blob is not a commit
+	if _, err := repo.ResolveCommit("HEAD:file.txt"); err == nil {
+		t.Errorf("expected a blob to be rejected as a commit")
+	}
+}
+
+// TestResolveAbbreviatedID tests that an abbreviated ID shared by several
+// objects is ambiguous unless only one of them is a commit
+func TestResolveAbbreviatedID(t *testing.T) {
+	ids := setupTwoCommits(t)
+	repo, _ := commands.Open(".")
+
+	// Write blobs until one shares the first 4 hex digits of another object
+	// or of a commit
+	byPrefix := make(map[string]string)
+	for _, id := range ids {
+		byPrefix[id[:4]] = id
+	}
+	var prefix string
+	for i := 0; prefix == ""; i++ {
+		id, err := repo.WriteObject(commands.BlobObject, []byte(fmt.Sprintf("blob %d\n", i)))
+		if err != nil {
+			t.Fatalf("failed to write blob: %v", err)
+		}
+		if _, ok := byPrefix[id[:4]]; ok && byPrefix[id[:4]] != id {
+			prefix = id[:4]
+		}
+		byPrefix[id[:4]] = id
+	}
+
+	_, err := repo.ResolveRevision(prefix)
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("expected %s to be ambiguous, got %v", prefix, err)
+	}
+	if prefix == ids[0][:4] || prefix == ids[1][:4] {
+		commit, err := repo.ResolveCommit(prefix)
+		if err != nil || !strings.HasPrefix(commit.ID, prefix) {
+			t.Errorf("expected %s to name the only commit it matches, got %v", prefix, err)
+		}
+	}
+}
+
+// TestRevParse tests the lines printed for revisions and ranges, which
+// match Git's
+func TestRevParse(t *testing.T) {
+	repo := setupExportHistory(t)
+	head, _ := repo.ResolveCommit("HEAD")
+	feature, _ := repo.ResolveCommit("feature")
+	base, _ := repo.ResolveCommit("feature~1")
+	removed := head.Parents[0]
+
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{args: []string{"HEAD", "feature"}, expected: []string{head.ID, feature.ID}},
+		{args: []string{"feature..main"}, expected: []string{head.ID, "^" + feature.ID}},
+		{args: []string{"main~1...feature"}, expected: []string{feature.ID, removed, "^" + base.ID}},
+		{args: []string{"feature.."}, expected: []string{head.ID, "^" + feature.ID}},
+		{args: []string{"^feature", "main"}, expected: []string{"^" + feature.ID, head.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			lines, err := repo.RevParse(tt.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(lines, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("expected %v, got %v", tt.expected, lines)
+			}
+		})
+	}
+
+	// Git agrees once the history is exported
+	gitDir := filepath.Join(t.TempDir(), "export.git")
+	if _, err := repo.ExportGit(gitDir); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	args := []string{"HEAD^2", "main~2", "main...feature", "v1:file.txt", "HEAD^{tree}", "HEAD~2:dir"}
+	lines, err := repo.RevParse(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := runGit(t, gitDir, append([]string{"rev-parse"}, args...)...); got != strings.Join(lines, "\n") {
+		t.Errorf("expected Git to print\n%s\ngot\n%s", strings.Join(lines, "\n"), got)
+	}
+}
+
+// TestReflog tests the entries recorded for commands that move branches and
+// HEAD
+func TestReflog(t *testing.T) {
+	ids := setupTwoCommits(t)
+	repo, _ := commands.Open(".")
+	commands.Switch("feature", commands.SwitchOptions{Create: true})
+	commands.Reset("HEAD~1", commands.ResetOptions{Mode: commands.ResetHard})
+	commands.Checkout(ids[1], commands.CheckoutOptions{})
+
+	tests := []struct {
+		ref      string
+		expected []string
+	}{
+		{
+			ref: "HEAD",
+			expected: []string{
+				"checkout: moving from feature to " + ids[1],
+				"reset: moving to HEAD~1",
+				"checkout: moving from main to feature",
+				"commit: Second commit",
+				"commit (initial): First commit",
+			},
+		},
+		{ref: "refs/heads/main", expected: []string{"commit: Second commit", "commit (initial): First commit"}},
+		{ref: "refs/heads/feature", expected: []string{"reset: moving to HEAD~1", "branch: Created from HEAD"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			entries, err := repo.Reflog(tt.ref)
+			if err != nil {
+				t.Fatalf("failed to read reflog: %v", err)
+			}
+			var messages []string
+			for _, entry := range entries {
+				messages = append(messages, entry.Message)
+			}
+			if strings.Join(messages, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expected entries\n%s\ngot\n%s", strings.Join(tt.expected, "\n"), strings.Join(messages, "\n"))
+			}
+			if entries[len(entries)-1].Old != strings.Repeat("0", 40) {
+				t.Errorf("expected the first entry to create the ref")
+			}
+		})
+	}
+
+	// Renaming and deleting a branch take its reflog along
+	commands.RenameBranch("feature", "topic/old")
+	if entries, _ := repo.Reflog("refs/heads/topic/old"); len(entries) != 2 {
+		t.Errorf("expected the reflog to follow the renamed branch, got %d entries", len(entries))
+	}
+	commands.DeleteBranch("topic/old", true)
+	if _, err := os.Stat(filepath.Join(commands.MyGitDir, "logs", "refs", "heads", "topic")); !os.IsNotExist(err) {
+		t.Errorf("expected the reflog of the deleted branch to be removed")
+	}
+	if err := commands.CreateBranch("topic", ""); err != nil {
+		t.Errorf("failed to reuse the name of the removed reflog directory: %v", err)
+	}
+}
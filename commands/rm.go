@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RemoveOptions controls Remove
+type RemoveOptions struct {
+	// Cached removes paths from the index only, keeping the working tree files
+	Cached bool
+	// Recursive allows removing every tracked file under a directory
+	Recursive bool
+	// Force removes files even when they have local or staged changes
+	Force bool
+}
+
+// Remove stops tracking files: they are removed from the index, so the next
+// commit records their deletion, and from the working tree unless Cached is
+// set. Unless Force is set, files whose changes are not stored in HEAD are
+// kept and an error is returned.
+func Remove(paths []string, opts RemoveOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	unlock, err := r.lock(IndexFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if len(paths) == 0 {
+		return errors.New("rm requires at least one path")
+	}
+	specs, err := r.repoPaths(paths)
+	if err != nil {
+		return err
+	}
+
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	headSnapshot, err := r.headFiles()
+	if err != nil {
+		return err
+	}
+	headEntries := make(map[string]FileEntry, len(headSnapshot))
+	for _, file := range headSnapshot {
+		headEntries[file.Path] = file
+	}
+
+	// Expand each path to the tracked files it names
+	tracked := conflictPaths(idx)
+	for _, entry := range idx.Entries {
+		tracked = append(tracked, entry.Path)
+	}
+	matched := make(map[string]bool)
+	for i, spec := range specs {
+		arg := paths[i]
+		found := false
+		for _, path := range tracked {
+			if !matchPathspec([]string{spec}, path) {
+				continue
+			}
+			if path != spec && !opts.Recursive && !strings.ContainsAny(spec, "*?[") {
+				return fmt.Errorf("not removing '%s' recursively without -r", arg)
+			}
+			matched[path] = true
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("pathspec '%s' did not match any files", arg)
+		}
+	}
+	targets := make([]string, 0, len(matched))
+	for path := range matched {
+		targets = append(targets, path)
+	}
+	sort.Strings(targets)
+
+	// Refuse to lose content that is only in the index or the working tree
+	if !opts.Force {
+		var staged, modified []string
+		for _, path := range targets {
+			entry, inIndex := idx.Entry(path)
+			head, inHead := headEntries[path]
+			indexMatchesHead := inIndex && inHead && entry.Hash == head.Hash && entry.Mode == head.Mode
+			workTreeClean, err := r.workTreeSafe(idx, path, FileEntry{}, false)
+			if err != nil {
+				return err
+			}
+			if !inIndex {
+				// Unmerged paths only exist in the working tree
+				workTreeClean = false
+			}
+
+			switch {
+			case opts.Cached:
+				// The content survives in HEAD or in the working tree
+				if !indexMatchesHead && !workTreeClean {
+					staged = append(staged, path)
+				}
+			case !indexMatchesHead:
+				staged = append(staged, path)
+			case !workTreeClean:
+				modified = append(modified, path)
+			}
+		}
+		if len(staged) > 0 {
+			return kindErrorf(ErrLocalChanges, "the following files have changes staged in the index:\n\t%s\nuse --cached to keep the files, or -f to force removal", strings.Join(staged, "\n\t"))
+		}
+		if len(modified) > 0 {
+			return kindErrorf(ErrLocalChanges, "the following files have local modifications:\n\t%s\nuse --cached to keep the files, or -f to force removal", strings.Join(modified, "\n\t"))
+		}
+	}
+
+	for _, path := range targets {
+		if !opts.Cached {
+			if err := r.removeWorkTreeFile(path); err != nil {
+				return err
+			}
+		}
+		idx.Remove(path)
+		idx.ResolveConflict(path)
+		fmt.Printf("Removed: %s\n", path)
+	}
+
+	return idx.Write()
+}
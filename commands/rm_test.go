@@ -0,0 +1,287 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestRemove tests removing tracked files
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name            string
+		setupFunc       func()
+		paths           []string
+		opts            commands.RemoveOptions
+		expectedError   bool
+		expectedRemoved []string
+		expectedOnDisk  bool
+	}{
+		{
+			name:            "remove file",
+			setupFunc:       func() {},
+			paths:           []string{"file.txt"},
+			expectedRemoved: []string{"file.txt"},
+		},
+		{
+			name:            "remove from index only",
+			setupFunc:       func() {},
+			paths:           []string{"file.txt"},
+			opts:            commands.RemoveOptions{Cached: true},
+			expectedRemoved: []string{"file.txt"},
+			expectedOnDisk:  true,
+		},
+		{
+			name:          "directory without -r",
+			setupFunc:     func() {},
+			paths:         []string{"dir"},
+			expectedError: true,
+		},
+		{
+			name:            "directory with -r",
+			setupFunc:       func() {},
+			paths:           []string{"dir"},
+			opts:            commands.RemoveOptions{Recursive: true},
+			expectedRemoved: []string{"dir/new.txt"},
+		},
+		{
+			name:          "untracked file",
+			setupFunc:     func() { os.WriteFile("untracked.txt", []byte("x\n"), 0644) },
+			paths:         []string{"untracked.txt"},
+			expectedError: true,
+		},
+		{
+			name:          "local modifications",
+			setupFunc:     func() { os.WriteFile("file.txt", []byte("local\n"), 0644) },
+			paths:         []string{"file.txt"},
+			expectedError: true,
+		},
+		{
+			name:            "local modifications with --cached",
+			setupFunc:       func() { os.WriteFile("file.txt", []byte("local\n"), 0644) },
+			paths:           []string{"file.txt"},
+			opts:            commands.RemoveOptions{Cached: true},
+			expectedRemoved: []string{"file.txt"},
+			expectedOnDisk:  true,
+		},
+		{
+			name: "staged changes",
+			setupFunc: func() {
+				os.WriteFile("file.txt", []byte("staged\n"), 0644)
+				commands.Add([]string{"file.txt"})
+			},
+			paths:         []string{"file.txt"},
+			expectedError: true,
+		},
+		{
+			name: "staged changes with force",
+			setupFunc: func() {
+				os.WriteFile("file.txt", []byte("staged\n"), 0644)
+				commands.Add([]string{"file.txt"})
+			},
+			paths:           []string{"file.txt"},
+			opts:            commands.RemoveOptions{Force: true},
+			expectedRemoved: []string{"file.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTwoCommits(t)
+			tt.setupFunc()
+
+			err := commands.Remove(tt.paths, tt.opts)
+
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			idx, err := commands.ReadIndex()
+			if err != nil {
+				t.Fatalf("failed to read index: %v", err)
+			}
+			for _, path := range tt.expectedRemoved {
+				if _, ok := idx.Entry(path); ok {
+					t.Errorf("expected %s to be removed from the index", path)
+				}
+				_, statErr := os.Stat(filepath.FromSlash(path))
+				if onDisk := statErr == nil; onDisk != tt.expectedOnDisk {
+					t.Errorf("expected %s on disk to be %v", path, tt.expectedOnDisk)
+				}
+			}
+			if tt.expectedError {
+				if _, ok := idx.Entry("file.txt"); !ok {
+					t.Errorf("failed removal should keep the index unchanged")
+				}
+			}
+		})
+	}
+}
+
+// TestCommitRecordsRemoval tests that removed files leave the next snapshot
+func TestCommitRecordsRemoval(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove func() error
+	}{
+		{
+			name:   "rm",
+			remove: func() error { return commands.Remove([]string{"file.txt"}, commands.RemoveOptions{}) },
+		},
+		{
+			name: "add deleted path",
+			remove: func() error {
+				os.Remove("file.txt")
+				return commands.Add([]string{"file.txt"})
+			},
+		},
+		{
+			name: "add -A",
+			remove: func() error {
+				os.Remove("file.txt")
+				return commands.AddWithOptions(nil, commands.AddOptions{All: true})
+			},
+		},
+		{
+			name: "add -u",
+			remove: func() error {
+				os.Remove("file.txt")
+				return commands.AddWithOptions(nil, commands.AddOptions{Update: true})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTwoCommits(t)
+
+			if err := tt.remove(); err != nil {
+				t.Fatalf("failed to remove: %v", err)
+			}
+			result, err := commands.GetStatus()
+			if err != nil {
+				t.Fatalf("failed to get status: %v", err)
+			}
+			if len(result.Staged) != 1 || result.Staged[0].Change != commands.ChangeDeleted {
+				t.Errorf("expected staged deletion, got %v", result.Staged)
+			}
+
+			if err := commands.Commit("Remove file"); err != nil {
+				t.Fatalf("failed to commit: %v", err)
+			}
+			if err := commands.Checkout("HEAD~1", commands.CheckoutOptions{}); err != nil {
+				t.Fatalf("failed to checkout parent: %v", err)
+			}
+			if _, err := os.Stat("file.txt"); err != nil {
+				t.Errorf("expected file.txt in the parent commit: %v", err)
+			}
+			if err := commands.Checkout("main", commands.CheckoutOptions{}); err != nil {
+				t.Fatalf("failed to checkout main: %v", err)
+			}
+			if _, err := os.Stat("file.txt"); !os.IsNotExist(err) {
+				t.Errorf("expected file.txt to be absent from the new commit")
+			}
+		})
+	}
+}
+
+// TestAddWithOptions tests staging the whole working tree
+func TestAddWithOptions(t *testing.T) {
+	tests := []struct {
+		name              string
+		opts              commands.AddOptions
+		expectedTracked   []string
+		expectedUntracked []string
+		expectedError     bool
+	}{
+		{
+			name:            "all",
+			opts:            commands.AddOptions{All: true},
+			expectedTracked: []string{"dir/new.txt", "file.txt", "untracked.txt"},
+		},
+		{
+			name:              "update",
+			opts:              commands.AddOptions{Update: true},
+			expectedTracked:   []string{"dir/new.txt", "file.txt"},
+			expectedUntracked: []string{"untracked.txt"},
+		},
+		{
+			name:          "all and update",
+			opts:          commands.AddOptions{All: true, Update: true},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTwoCommits(t)
+			os.WriteFile("file.txt", []byte("version 3\n"), 0644)
+			os.WriteFile("untracked.txt", []byte("new\n"), 0644)
+
+			err := commands.AddWithOptions(nil, tt.opts)
+
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			result, err := commands.GetStatus()
+			if err != nil {
+				t.Fatalf("failed to get status: %v", err)
+			}
+			if len(result.Unstaged) != 0 {
+				t.Errorf("expected no unstaged changes, got %v", result.Unstaged)
+			}
+			if len(result.Untracked) != len(tt.expectedUntracked) {
+				t.Errorf("expected untracked %v, got %v", tt.expectedUntracked, result.Untracked)
+			}
+			idx, _ := commands.ReadIndex()
+			for _, path := range tt.expectedTracked {
+				if _, ok := idx.Entry(path); !ok {
+					t.Errorf("expected %s to be tracked", path)
+				}
+			}
+		})
+	}
+}
+
+// TestAddTrackedSymlink tests that add -A keeps a tracked symbolic link,
+// which is staged with the content of the file it points to
+func TestAddTrackedSymlink(t *testing.T) {
+	setupTwoCommits(t)
+	if err := os.Symlink("file.txt", "link.txt"); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := commands.Add([]string{"link.txt"}); err != nil {
+		t.Fatalf("failed to add link.txt: %v", err)
+	}
+	if err := commands.Commit("Add link"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := commands.AddWithOptions(nil, commands.AddOptions{All: true}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		idx, _ := commands.ReadIndex()
+		if entry, ok := idx.Entry("link.txt"); !ok || entry.Mode != commands.ModeSymlink {
+			t.Fatalf("expected link.txt to stay tracked as a link, got %+v", entry)
+		}
+		result, err := commands.GetStatus()
+		if err != nil {
+			t.Fatalf("failed to get status: %v", err)
+		}
+		if !result.Clean() {
+			t.Errorf("expected a clean status, got %+v", result)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ShowOptions controls how show prints commits
+type ShowOptions struct {
+	// Format and DateFormat are the formats of log, see LogOptions
+	Format     string
+	DateFormat string
+	// NoPatch leaves out the changes of commits
+	NoPatch bool
+}
+
+// shownObject is an object show prints, with its decoded contents
+type shownObject struct {
+	// Name is the revision that named the object, or the name of the tag
+	// object that led to it
+	Name string
+	Type string
+	ID   string
+	// Only the field of the type of the object is set, except Data which
+	// holds the content of blobs and tags
+	Commit *CommitRecord
+	Tree   *Tree
+	Tag    *gitTag
+	Data   []byte
+}
+
+// Show prints the objects the revisions name, or HEAD when there are none.
+// A commit shows its header and message like log, followed by its changes:
+// a patch against its parent, or a combined diff for a merge. A tree lists
+// its entries, a blob prints its content, and an annotated tag shows the
+// tagger and message followed by the object it tags.
+func Show(revs []string, opts ShowOptions) error {
+	if err := CheckDateFormat(opts.DateFormat); err != nil {
+		return err
+	}
+	if err := checkLogFormat(opts.Format); err != nil {
+		return err
+	}
+
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	objects, err := r.showObjects(revs)
+	if err != nil {
+		return err
+	}
+
+	// Like Git, blank lines separate the objects after the first one that
+	// is not a blob
+	shown := false
+	for _, object := range objects {
+		switch object.Type {
+		case CommitObject:
+			if shown && separatesCommits(opts.Format) {
+				fmt.Println()
+			}
+			for _, line := range formatLogEntry(*object.Commit, LogOptions{Format: opts.Format, DateFormat: opts.DateFormat}) {
+				fmt.Println(line)
+			}
+			if !opts.NoPatch {
+				patch, err := r.CommitPatch(object.Commit)
+				if err != nil {
+					return err
+				}
+				// Git leaves out the blank line before the patch for oneline,
+				// but not before a combined diff
+				if patch != "" && (opts.Format != FormatOneline || len(object.Commit.Parents) > 1) {
+					fmt.Println()
+				}
+				fmt.Print(patch)
+			}
+		case TreeObject:
+			if shown {
+				fmt.Println()
+			}
+			fmt.Printf("tree %s\n\n", object.Name)
+			for _, entry := range object.Tree.Entries {
+				if entry.Mode == ModeDir {
+					fmt.Println(entry.Name + "/")
+				} else {
+					fmt.Println(entry.Name)
+				}
+			}
+		case TagObject:
+			if shown {
+				fmt.Println()
+			}
+			fmt.Printf("tag %s\n", object.Tag.Name)
+			if !object.Tag.Tagger.IsZero() {
+				fmt.Printf("Tagger: %s\n", object.Tag.Tagger)
+				fmt.Printf("Date:   %s\n", FormatDate(object.Tag.Tagger.When, opts.DateFormat))
+			}
+			fmt.Printf("\n%s", object.Tag.Message)
+		default:
+			if _, err := os.Stdout.Write(object.Data); err != nil {
+				return err
+			}
+			continue
+		}
+		shown = true
+	}
+	return nil
+}
+
+// CommitPatch returns the changes of a commit as show prints them: a patch
+// against its parent, against nothing for the first commit, or a combined
+// diff against all parents for a merge
+func (r *Repository) CommitPatch(commit *CommitRecord) (string, error) {
+	if len(commit.Parents) < 2 {
+		oldSide, newSide, err := r.commitSides(*commit, nil)
+		if err != nil {
+			return "", err
+		}
+		return formatDiff(oldSide, newSide, DefaultContextLines)
+	}
+
+	result := diffSource{repo: r}
+	var err error
+	if result.files, err = r.commitFiles(commit); err != nil {
+		return "", fmt.Errorf("failed to read commit %s: %w", commit.ID, err)
+	}
+	parents := make([]diffSource, len(commit.Parents))
+	for i, parent := range commit.Parents {
+		if parents[i], err = r.commitSource(parent); err != nil {
+			return "", err
+		}
+	}
+	return formatCombinedDiff(parents, result, DefaultContextLines)
+}
+
+// showObjects resolves the revisions show is given to the objects it
+// prints. An annotated tag is followed by the objects it leads to.
+func (r *Repository) showObjects(revs []string) ([]shownObject, error) {
+	if len(revs) == 0 {
+		revs = []string{HeadFile}
+	}
+	metadata, err := r.readMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []shownObject
+	for _, rev := range revs {
+		id, err := r.ResolveRevision(rev)
+		if err != nil {
+			return nil, err
+		}
+		name := rev
+		for id != "" {
+			object := shownObject{Name: name, ID: id}
+			id = ""
+			// Commits made before commits were stored as Git objects have
+			// no object, so commits are looked up in the history first
+			if object.Commit = metadata.find(object.ID); object.Commit != nil {
+				object.Type = CommitObject
+				objects = append(objects, object)
+				continue
+			}
+			object.Type, object.Data, err = r.ReadObject(object.ID)
+			if errors.Is(err, ErrObjectNotFound) {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownRevision, rev)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			switch object.Type {
+			case TreeObject:
+				if object.Tree, err = r.ReadTree(object.ID); err != nil {
+					return nil, err
+				}
+			case TagObject:
+				if object.Tag, err = decodeTag(object.Data); err != nil {
+					return nil, fmt.Errorf("tag %s: %w", object.ID, err)
+				}
+				id, name = object.Tag.Object, object.Tag.Name
+			case CommitObject:
+				return nil, fmt.Errorf("unknown commit: %s", object.ID)
+			}
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+// treeEntryType returns the type of the object a tree entry points at
+func treeEntryType(entry TreeEntry) string {
+	switch entry.Mode {
+	case ModeDir:
+		return TreeObject
+	case ModeGitlink:
+		return CommitObject
+	}
+	return BlobObject
+}
@@ -0,0 +1,146 @@
+package commands_test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// setupShowHistory extends the history of setupLogHistory with a merge whose
+// conflicts were resolved by hand, an annotated tag and a binary file
+func setupShowHistory(t *testing.T) *commands.Repository {
+	t.Helper()
+	repo := setupLogHistory(t)
+	lines := []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten"}
+	write := func(changes map[int]string) {
+		content := make([]string, len(lines))
+		copy(content, lines)
+		for i, line := range changes {
+			content[i] = line
+		}
+		os.WriteFile("poem.txt", []byte(strings.Join(content, "\n")+"\n"), 0644)
+		commands.Add([]string{"poem.txt"})
+	}
+
+	write(nil)
+	commands.Commit("Add poem")
+	commands.CreateBranch("topic", "")
+	write(map[int]string{1: "Two", 7: "eight!"})
+	commands.Commit("Capitalize two")
+	commands.Switch("topic", commands.SwitchOptions{})
+	write(map[int]string{1: "TWO", 4: "five!"})
+	os.Mkdir("assets", 0755)
+	os.WriteFile("assets/image.bin", []byte("\x00\x01binary"), 0644)
+	commands.Add([]string{"assets/image.bin"})
+	commands.Commit("Shout two")
+	commands.Switch("main", commands.SwitchOptions{})
+	if err := commands.Merge("topic", commands.MergeOptions{}); err == nil {
+		t.Fatalf("expected a conflict")
+	}
+	write(map[int]string{1: "Two!", 4: "five!", 7: "eight!", 9: "TEN"})
+	if err := commands.Commit("Merge branch 'topic'"); err != nil {
+		t.Fatalf("failed to commit the merge: %v", err)
+	}
+	if err := commands.CreateTag("v1", "", commands.TagOptions{Message: "Release 1"}); err != nil {
+		t.Fatalf("failed to tag: %v", err)
+	}
+	return repo
+}
+
+// TestShow tests that show prints what git show prints for commits, merges,
+// trees, blobs and tags
+func TestShow(t *testing.T) {
+	repo := setupShowHistory(t)
+	gitDir := filepath.Join(t.TempDir(), "export.git")
+	if _, err := repo.ExportGit(gitDir); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		revs []string
+		opts commands.ShowOptions
+		args []string
+	}{
+		{name: "head"},
+		{name: "merge with resolved conflicts", revs: []string{"HEAD"}},
+		{name: "clean merge", revs: []string{"HEAD~4"}},
+		{name: "first commit", revs: []string{"HEAD~6"}},
+		{name: "binary file", revs: []string{"topic"}},
+		{name: "annotated tag", revs: []string{"v1"}},
+		{name: "tree", revs: []string{"HEAD^{tree}"}},
+		{name: "file", revs: []string{"HEAD:poem.txt"}},
+		{name: "several objects", revs: []string{"HEAD:file.txt", "HEAD~2", "HEAD^{tree}", "v1"}},
+		{name: "no patch", revs: []string{"HEAD", "HEAD~1"}, opts: commands.ShowOptions{NoPatch: true}, args: []string{"-s"}},
+		{name: "oneline", revs: []string{"HEAD", "HEAD~1"}, opts: commands.ShowOptions{Format: commands.FormatOneline}, args: []string{"--oneline"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureOutput(t, func() error { return commands.Show(tt.revs, tt.opts) })
+			args := append(append([]string{"show"}, tt.args...), tt.revs...)
+			expected := runGit(t, gitDir, args...)
+			if strings.TrimSpace(out) != expected {
+				t.Errorf("expected\n%s\ngot\n%s", expected, out)
+			}
+		})
+	}
+}
+
+// TestShowJSON tests the JSON printed for the objects show shows
+func TestShowJSON(t *testing.T) {
+	repo := setupShowHistory(t)
+	head, _ := repo.ResolveCommit("HEAD")
+	patch, err := repo.CommitPatch(head)
+	if err != nil || !strings.HasPrefix(patch, "diff --cc poem.txt\n") {
+		t.Fatalf("expected a combined diff, got %q (%v)", patch, err)
+	}
+
+	var show commands.JSONShow
+	decodeJSON(t, func() error {
+		return commands.ShowJSON([]string{"v1", "HEAD^{tree}", "topic:assets/image.bin", "HEAD:poem.txt"}, commands.ShowOptions{})
+	}, &show)
+	if len(show.Objects) != 5 {
+		t.Fatalf("expected 5 objects, got %+v", show.Objects)
+	}
+
+	tag := show.Objects[0]
+	if tag.Type != "tag" || tag.Tag == nil || tag.Tag.Name != "v1" || tag.Tag.Object != head.ID || tag.Tag.Message != "Release 1\n" {
+		t.Errorf("unexpected tag %+v", tag)
+	}
+	commit := show.Objects[1]
+	if commit.Type != "commit" || commit.ID != head.ID || commit.Commit == nil || commit.Commit.Patch != patch {
+		t.Errorf("unexpected commit %+v", commit)
+	}
+	if files := commit.Commit.Files; len(files) != 2 || files[1] != (commands.FileStat{Path: "poem.txt", Change: commands.ChangeModified, Insertions: 3, Deletions: 3}) {
+		t.Errorf("unexpected files %+v", files)
+	}
+
+	tree := show.Objects[2]
+	if tree.Type != "tree" || tree.ID != head.Tree || tree.Tree == nil {
+		t.Fatalf("unexpected tree %+v", tree)
+	}
+	var names []string
+	for _, entry := range tree.Tree.Entries {
+		names = append(names, entry.Type+" "+entry.Name)
+	}
+	if strings.Join(names, ",") != "tree assets,blob file.txt,blob notes.txt,blob poem.txt" {
+		t.Errorf("unexpected entries %v", names)
+	}
+
+	binary := show.Objects[3]
+	if binary.Blob == nil || binary.Blob.Encoding != "base64" || binary.Blob.Content != base64.StdEncoding.EncodeToString([]byte("\x00\x01binary")) {
+		t.Errorf("expected base64 content, got %+v", binary.Blob)
+	}
+	text := show.Objects[4]
+	if text.Blob == nil || text.Blob.Encoding != "" || !strings.HasPrefix(text.Blob.Content, "one\nTwo!\n") || text.Blob.Size != len(text.Blob.Content) {
+		t.Errorf("unexpected blob %+v", text.Blob)
+	}
+
+	if err := commands.ShowJSON(nil, commands.ShowOptions{Format: commands.FormatOneline}); commands.ErrorCode(err) != commands.CodeUsage {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
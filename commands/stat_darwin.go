@@ -0,0 +1,15 @@
+package commands
+
+import (
+	"os"
+	"syscall"
+)
+
+// statExtra returns the change time in nanoseconds and the inode number of a file
+func statExtra(info os.FileInfo) (int64, uint64) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return st.Ctimespec.Sec*1e9 + st.Ctimespec.Nsec, st.Ino
+}
@@ -0,0 +1,15 @@
+package commands
+
+import (
+	"os"
+	"syscall"
+)
+
+// statExtra returns the change time in nanoseconds and the inode number of a file
+func statExtra(info os.FileInfo) (int64, uint64) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return int64(st.Ctim.Sec)*1e9 + int64(st.Ctim.Nsec), uint64(st.Ino)
+}
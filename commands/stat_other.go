@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package commands
+
+import "os"
+
+// statExtra returns zero values on platforms without ctime and inode numbers,
+// so only size, mtime and mode are compared when checking for changes
+func statExtra(info os.FileInfo) (int64, uint64) {
+	return 0, 0
+}
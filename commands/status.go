@@ -0,0 +1,370 @@
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// Kinds of change reported by status
+const (
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+)
+
+// FileChange is a path that differs between two snapshots
+type FileChange struct {
+	Path string `json:"path"`
+	// Change is ChangeAdded, ChangeModified or ChangeDeleted
+	Change string `json:"status"`
+}
+
+// StatusResult compares HEAD, the index and the working tree
+type StatusResult struct {
+	// Staged lists differences between HEAD and the index
+	Staged []FileChange
+	// Unstaged lists differences between the index and the working tree
+	Unstaged []FileChange
+	// Untracked lists files in the working tree that are not in the index
+	Untracked []string
+	// Unmerged lists paths with unresolved merge conflicts
+	Unmerged []ConflictEntry
+	// Merging is set while a merge is waiting to be committed
+	Merging bool
+}
+
+// Clean reports whether there is nothing to commit and no untracked files
+func (s *StatusResult) Clean() bool {
+	return len(s.Staged) == 0 && len(s.Unstaged) == 0 && len(s.Untracked) == 0 && len(s.Unmerged) == 0
+}
+
+// StatusOptions controls the output format of Status
+type StatusOptions struct {
+	// Short prints one "XY path" line per changed file
+	Short bool
+	// Porcelain prints the short format, which is guaranteed to stay stable
+	// for scripts
+	Porcelain bool
+}
+
+// GetStatus compares HEAD, the index and the working tree of the repository
+// in the current directory
+func GetStatus() (*StatusResult, error) {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+	return r.Status()
+}
+
+// Status compares HEAD, the index and the working tree
+func (r *Repository) Status() (*StatusResult, error) {
+	// The index is only refreshed when no other process is using it
+	unlock, lockErr := r.tryLock(IndexFile)
+	if lockErr == nil {
+		defer unlock()
+	}
+
+	headSnapshot, err := r.headFiles()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := r.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	mergeHead, _, err := r.readMergeState()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StatusResult{
+		Unmerged: idx.Conflicts,
+		Merging:  mergeHead != "",
+	}
+	for _, change := range diffSnapshots(headSnapshot, idx.Files()) {
+		// Unmerged paths are reported separately
+		if _, unmerged := idx.Conflict(change.Path); !unmerged {
+			result.Staged = append(result.Staged, change)
+		}
+	}
+
+	// Compare each index entry with the working tree
+	refreshed := false
+	for i, entry := range idx.Entries {
+		// Submodules are not checked out, so there is nothing to compare
+		if entry.Mode == ModeGitlink {
+			continue
+		}
+		info, err := os.Lstat(r.workPath(entry.Path))
+		if err != nil || !isSnapshotFile(info) {
+			result.Unstaged = append(result.Unstaged, FileChange{Path: entry.Path, Change: ChangeDeleted})
+			continue
+		}
+		if idx.StatClean(entry, info) {
+			continue
+		}
+		content, err := r.readWorkTreeFile(entry.Path, info)
+		if err != nil {
+			return nil, err
+		}
+		if HashObject(BlobObject, content) != entry.Hash || fileMode(info) != entry.Mode {
+			result.Unstaged = append(result.Unstaged, FileChange{Path: entry.Path, Change: ChangeModified})
+			continue
+		}
+
+		// Content is unchanged; record fresh stat data so it is not rehashed next time
+		idx.Entries[i] = newIndexEntry(entry.Path, entry.Hash, info)
+		refreshed = true
+	}
+	if refreshed && lockErr == nil {
+		// Refreshing is only an optimization, so a failed write is not an error
+		idx.Write()
+	}
+
+	if result.Untracked, err = r.listUntracked(idx); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Status shows the state of the index and the working tree
+func Status(opts StatusOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+	result, err := r.Status()
+	if err != nil {
+		return err
+	}
+
+	if opts.Short || opts.Porcelain {
+		printShortStatus(result)
+		return nil
+	}
+
+	branch, attached, err := r.currentBranch()
+	if err != nil {
+		return err
+	}
+	if attached {
+		fmt.Printf("On branch %s\n", branch)
+	} else {
+		_, commitID, err := r.readHead()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("HEAD detached at %s\n", commitID[:7])
+	}
+	if result.Merging {
+		if len(result.Unmerged) > 0 {
+			fmt.Println("You have unmerged paths.")
+			fmt.Println("  (fix conflicts and run \"mygit commit\")")
+		} else {
+			fmt.Println("All conflicts fixed but you are still merging.")
+			fmt.Println("  (use \"mygit commit\" to conclude merge)")
+		}
+	}
+	fmt.Println()
+
+	if result.Clean() {
+		fmt.Println("nothing to commit, working tree clean")
+		return nil
+	}
+
+	if len(result.Staged) > 0 {
+		fmt.Println("Changes to be committed:")
+		for _, change := range result.Staged {
+			fmt.Printf("\t%-12s%s\n", statusLabel(change.Change)+":", change.Path)
+		}
+		fmt.Println()
+	}
+	if len(result.Unmerged) > 0 {
+		fmt.Println("Unmerged paths:")
+		for _, conflict := range result.Unmerged {
+			_, label := conflictStatus(conflict)
+			fmt.Printf("\t%-16s%s\n", label+":", conflict.Path)
+		}
+		fmt.Println()
+	}
+	if len(result.Unstaged) > 0 {
+		fmt.Println("Changes not staged for commit:")
+		for _, change := range result.Unstaged {
+			fmt.Printf("\t%-12s%s\n", statusLabel(change.Change)+":", change.Path)
+		}
+		fmt.Println()
+	}
+	if len(result.Untracked) > 0 {
+		fmt.Println("Untracked files:")
+		for _, path := range result.Untracked {
+			fmt.Printf("\t%s\n", path)
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// printShortStatus prints "XY path" lines where X is the staged change and Y
+// the unstaged change, followed by "?? path" lines for untracked files.
+// Unmerged paths use Git's codes such as "UU".
+func printShortStatus(result *StatusResult) {
+	codes := make(map[string][2]byte)
+	for _, conflict := range result.Unmerged {
+		code, _ := conflictStatus(conflict)
+		codes[conflict.Path] = [2]byte{code[0], code[1]}
+	}
+	for _, change := range result.Staged {
+		code := codes[change.Path]
+		code[0] = statusCode(change.Change)
+		codes[change.Path] = code
+	}
+	for _, change := range result.Unstaged {
+		code := codes[change.Path]
+		code[1] = statusCode(change.Change)
+		codes[change.Path] = code
+	}
+
+	paths := make([]string, 0, len(codes))
+	for path := range codes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		code := codes[path]
+		for i := range code {
+			if code[i] == 0 {
+				code[i] = ' '
+			}
+		}
+		fmt.Printf("%c%c %s\n", code[0], code[1], path)
+	}
+	for _, path := range result.Untracked {
+		fmt.Printf("?? %s\n", path)
+	}
+}
+
+func statusLabel(change string) string {
+	if change == ChangeAdded {
+		return "new file"
+	}
+	return change
+}
+
+// conflictStatus returns the short status code and long label for an
+// unmerged path
+func conflictStatus(conflict ConflictEntry) (string, string) {
+	switch {
+	case conflict.OursHash == "" && conflict.TheirsHash == "":
+		return "DD", "both deleted"
+	case conflict.OursHash == "" && conflict.BaseHash == "":
+		return "UA", "added by them"
+	case conflict.OursHash == "":
+		return "DU", "deleted by us"
+	case conflict.TheirsHash == "" && conflict.BaseHash == "":
+		return "AU", "added by us"
+	case conflict.TheirsHash == "":
+		return "UD", "deleted by them"
+	case conflict.BaseHash == "":
+		return "AA", "both added"
+	default:
+		return "UU", "both modified"
+	}
+}
+
+func statusCode(change string) byte {
+	switch change {
+	case ChangeAdded:
+		return 'A'
+	case ChangeDeleted:
+		return 'D'
+	default:
+		return 'M'
+	}
+}
+
+// diffSnapshots returns the changes needed to turn one snapshot into another, sorted by path
+func diffSnapshots(from, to []FileEntry) []FileChange {
+	before := make(map[string]FileEntry, len(from))
+	for _, file := range from {
+		before[file.Path] = file
+	}
+
+	var changes []FileChange
+	for _, file := range to {
+		old, ok := before[file.Path]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Path: file.Path, Change: ChangeAdded})
+		case old.Hash != file.Hash || old.Mode != file.Mode:
+			changes = append(changes, FileChange{Path: file.Path, Change: ChangeModified})
+		}
+		delete(before, file.Path)
+	}
+	for path := range before {
+		changes = append(changes, FileChange{Path: path, Change: ChangeDeleted})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// listUntracked returns the slash-separated paths of regular files in the
+// working tree that are neither tracked nor ignored, excluding the .mygit
+// directory
+func (r *Repository) listUntracked(idx *Index) ([]string, error) {
+	ignore, err := r.newIgnoreMatcher()
+	if err != nil {
+		return nil, err
+	}
+	dirs := trackedDirs(idx)
+
+	var files []string
+	err = fs.WalkDir(r.workTreeFS(), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if r.isGitDir(name) || idx.isGitlink(name) {
+				return fs.SkipDir
+			}
+			// Everything in an ignored directory without tracked files is ignored
+			if name != "." && !dirs[name] {
+				if ignored, err := ignore.ignored(name, true); err != nil {
+					return err
+				} else if ignored {
+					return fs.SkipDir
+				}
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() && d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		_, tracked := idx.Entry(name)
+		_, unmerged := idx.Conflict(name)
+		if tracked || unmerged {
+			return nil
+		}
+		ignored, err := ignore.ignored(name, false)
+		if err != nil {
+			return err
+		}
+		if !ignored {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan working tree: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,135 @@
+package commands_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestGetStatus tests comparison of HEAD, the index and the working tree
+func TestGetStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		setupFunc func()
+		expected  commands.StatusResult
+	}{
+		{
+			name:      "clean repository",
+			setupFunc: func() {},
+			expected:  commands.StatusResult{},
+		},
+		{
+			name: "untracked file",
+			setupFunc: func() {
+				os.WriteFile("new.txt", []byte("new"), 0644)
+			},
+			expected: commands.StatusResult{
+				Untracked: []string{"new.txt"},
+			},
+		},
+		{
+			name: "staged new file",
+			setupFunc: func() {
+				os.WriteFile("new.txt", []byte("new"), 0644)
+				commands.Add([]string{"new.txt"})
+			},
+			expected: commands.StatusResult{
+				Staged: []commands.FileChange{{Path: "new.txt", Change: commands.ChangeAdded}},
+			},
+		},
+		{
+			name: "unstaged modification and deletion",
+			setupFunc: func() {
+				os.WriteFile("tracked.txt", []byte("modified"), 0644)
+				os.Remove("other.txt")
+			},
+			expected: commands.StatusResult{
+				Unstaged: []commands.FileChange{
+					{Path: "other.txt", Change: commands.ChangeDeleted},
+					{Path: "tracked.txt", Change: commands.ChangeModified},
+				},
+			},
+		},
+		{
+			name: "staged and then modified again",
+			setupFunc: func() {
+				os.WriteFile("tracked.txt", []byte("staged"), 0644)
+				commands.Add([]string{"tracked.txt"})
+				os.WriteFile("tracked.txt", []byte("modified again"), 0644)
+			},
+			expected: commands.StatusResult{
+				Staged:   []commands.FileChange{{Path: "tracked.txt", Change: commands.ChangeModified}},
+				Unstaged: []commands.FileChange{{Path: "tracked.txt", Change: commands.ChangeModified}},
+			},
+		},
+		{
+			name: "file in subdirectory",
+			setupFunc: func() {
+				os.Mkdir("sub", 0755)
+				os.WriteFile("sub/file.txt", []byte("content"), 0644)
+			},
+			expected: commands.StatusResult{
+				Untracked: []string{"sub/file.txt"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			os.Chdir(tempDir)
+			commands.Init()
+			os.WriteFile("tracked.txt", []byte("tracked"), 0644)
+			os.WriteFile("other.txt", []byte("other"), 0644)
+			commands.Add([]string{"tracked.txt", "other.txt"})
+			commands.Commit("Initial commit")
+
+			tt.setupFunc()
+
+			result, err := commands.GetStatus()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(*result, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, *result)
+			}
+		})
+	}
+}
+
+// TestStatus tests the status command output modes
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		init          bool
+		opts          commands.StatusOptions
+		expectedError bool
+	}{
+		{name: "long format", init: true},
+		{name: "short format", init: true, opts: commands.StatusOptions{Short: true}},
+		{name: "porcelain format", init: true, opts: commands.StatusOptions{Porcelain: true}},
+		{name: "status without init", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			os.Chdir(tempDir)
+			if tt.init {
+				commands.Init()
+				os.WriteFile("file.txt", []byte("content"), 0644)
+			}
+
+			err := commands.Status(tt.opts)
+
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+)
+
+// TagOptions controls CreateTag
+type TagOptions struct {
+	// Annotate makes an annotated tag: a tag object recording the tagger, the
+	// date and a message
+	Annotate bool
+	// Message is the message of an annotated tag; setting it implies Annotate
+	Message string
+	// Force replaces an existing tag
+	Force bool
+}
+
+// ListTags prints the tags whose names match any of the glob patterns, or
+// all tags when none are given
+func ListTags(patterns []string) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	tags, err := r.ListTags(patterns)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		fmt.Println(tag)
+	}
+	return nil
+}
+
+// CreateTag creates a tag pointing at rev, or at HEAD when rev is empty
+func CreateTag(name, rev string, opts TagOptions) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	previous, err := r.readRef(TagPrefix + name)
+	if err != nil {
+		return err
+	}
+	target, err := r.CreateTag(name, rev, opts)
+	if err != nil {
+		return err
+	}
+	if previous != "" && previous != target {
+		fmt.Printf("Updated tag %s (was %s)\n", name, previous[:7])
+	} else {
+		fmt.Printf("Created tag %s at %s\n", name, target[:7])
+	}
+	return nil
+}
+
+// DeleteTag deletes the given tags
+func DeleteTag(names []string) error {
+	// Find the enclosing repository
+	r, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		target, err := r.DeleteTag(name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted tag %s (was %s)\n", name, target[:7])
+	}
+	return nil
+}
+
+// ListTags returns the names of the tags matching any of the patterns, or
+// of all tags when there are none, sorted. Patterns use the syntax of
+// path.Match, so "v1.*" matches "v1.0" and "v1.1" but not "v1.0/rc".
+func (r *Repository) ListTags(patterns []string) ([]string, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+	tags, err := r.listTags()
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return tags, nil
+	}
+
+	var matched []string
+	for _, tag := range tags {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, tag); ok {
+				matched = append(matched, tag)
+				break
+			}
+		}
+	}
+	return matched, nil
+}
+
+// CreateTag points the tag name at the commit rev resolves to, or at HEAD
+// when rev is empty, and returns the object the tag points at. A lightweight
+// tag points at the commit itself; an annotated tag points at a new tag
+// object naming the commit, the committer identity as the tagger, the
+// current date and the message.
+func (r *Repository) CreateTag(name, rev string, opts TagOptions) (string, error) {
+	if err := checkTagName(name); err != nil {
+		return "", err
+	}
+	annotate := opts.Annotate || opts.Message != ""
+	if annotate && opts.Message == "" {
+		return "", errors.New("an annotated tag needs a message; use -m")
+	}
+
+	unlock, err := r.lock(HeadFile)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if existing, err := r.readRef(TagPrefix + name); err != nil {
+		return "", err
+	} else if existing != "" && !opts.Force {
+		return "", fmt.Errorf("tag '%s' already exists", name)
+	}
+	if rev == "" {
+		rev = "HEAD"
+	}
+	commit, err := r.ResolveCommit(rev)
+	if err != nil {
+		return "", fmt.Errorf("cannot create tag '%s': %w", name, err)
+	}
+
+	target := commit.ID
+	if annotate {
+		tagger, err := r.identity(CommitterNameEnv, CommitterEmailEnv)
+		if err != nil {
+			return "", err
+		}
+		// The tagger date follows the committer date, like Git
+		if tagger.When, err = commitDate(CommitterDateEnv, time.Now().Truncate(time.Second)); err != nil {
+			return "", err
+		}
+		tag := &gitTag{
+			Object:  commit.ID,
+			Type:    CommitObject,
+			Name:    name,
+			Tagger:  tagger,
+			Message: objectMessage(opts.Message),
+		}
+		if target, err = r.WriteObject(TagObject, encodeTag(tag)); err != nil {
+			return "", fmt.Errorf("failed to write tag: %w", err)
+		}
+	}
+	if err := r.writeRef(TagPrefix+name, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// DeleteTag deletes a tag and returns the object it pointed at
+func (r *Repository) DeleteTag(name string) (string, error) {
+	unlock, err := r.lock(HeadFile)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	target, err := r.readRef(TagPrefix + name)
+	if err != nil {
+		return "", err
+	}
+	if target == "" || checkTagName(name) != nil {
+		return "", fmt.Errorf("tag '%s' not found", name)
+	}
+	if err := r.deleteRef(TagPrefix + name); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// checkTagName validates a tag name, which follows the rules of branch names
+func checkTagName(name string) error {
+	if checkBranchName(name) != nil {
+		return fmt.Errorf("'%s' is not a valid tag name", name)
+	}
+	return nil
+}
+
+// peelTag follows annotated tag objects from id to the commit they tag.
+// Commits made before commits were stored as Git objects have no object, so
+// an ID without one is returned as it is.
+func (r *Repository) peelTag(id string) (string, error) {
+	for {
+		objType, data, err := r.ReadObject(id)
+		if errors.Is(err, ErrObjectNotFound) {
+			return id, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch objType {
+		case CommitObject:
+			return id, nil
+		case TagObject:
+			tag, err := decodeTag(data)
+			if err != nil {
+				return "", fmt.Errorf("tag %s: %w", id, err)
+			}
+			id = tag.Object
+		default:
+			return "", fmt.Errorf("object %s is a %s, not a commit", id, objType)
+		}
+	}
+}
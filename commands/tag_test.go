@@ -0,0 +1,206 @@
+package commands_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+// TestCreateTag tests creating lightweight and annotated tags
+func TestCreateTag(t *testing.T) {
+	tests := []struct {
+		name          string
+		tag           string
+		rev           string
+		opts          commands.TagOptions
+		existing      bool
+		expectedError bool
+		expectedType  string
+	}{
+		{name: "lightweight tag at HEAD", tag: "v1", expectedType: commands.CommitObject},
+		{name: "lightweight tag at a commit", tag: "release/v1", rev: "HEAD~1", expectedType: commands.CommitObject},
+		{name: "annotated tag", tag: "v1", opts: commands.TagOptions{Annotate: true, Message: "Release 1"}, expectedType: commands.TagObject},
+		{name: "message implies annotated", tag: "v1", opts: commands.TagOptions{Message: "Release 1"}, expectedType: commands.TagObject},
+		{name: "annotated tag without message", tag: "v1", opts: commands.TagOptions{Annotate: true}, expectedError: true},
+		{name: "existing tag", tag: "v1", existing: true, expectedError: true},
+		{name: "replace existing tag", tag: "v1", existing: true, opts: commands.TagOptions{Force: true}, expectedType: commands.CommitObject},
+		{name: "invalid name", tag: "bad..name", expectedError: true},
+		{name: "unknown commit", tag: "v1", rev: "missing", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFixedIdentity(t)
+			ids := setupTwoCommits(t)
+			repo, err := commands.Open(".")
+			if err != nil {
+				t.Fatalf("failed to open repository: %v", err)
+			}
+			if tt.existing {
+				repo.CreateTag(tt.tag, "HEAD~1", commands.TagOptions{})
+			}
+
+			target, err := repo.CreateTag(tt.tag, tt.rev, tt.opts)
+
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if readTag(t, tt.tag) != target {
+				t.Errorf("expected tag %s to point at %s, got %s", tt.tag, target, readTag(t, tt.tag))
+			}
+			objType, _, err := repo.ReadObject(target)
+			if err != nil || objType != tt.expectedType {
+				t.Errorf("expected the tag to point at a %s, got a %s (%v)", tt.expectedType, objType, err)
+			}
+
+			// The tag names the commit wherever a commit is accepted
+			commit, err := repo.ResolveCommit(tt.tag)
+			if err != nil {
+				t.Fatalf("failed to resolve tag: %v", err)
+			}
+			expected := ids[1]
+			if tt.rev != "" {
+				expected = ids[0]
+			}
+			if commit.ID != expected {
+				t.Errorf("expected tag %s to resolve to %s, got %s", tt.tag, expected, commit.ID)
+			}
+		})
+	}
+}
+
+// TestAnnotatedTag tests the content of annotated tag objects and that Git
+// reads them
+func TestAnnotatedTag(t *testing.T) {
+	setFixedIdentity(t)
+	ids := setupTwoCommits(t)
+	repo, _ := commands.Open(".")
+
+	target, err := repo.CreateTag("v1", "", commands.TagOptions{Message: "Release 1"})
+	if err != nil {
+		t.Fatalf("failed to create tag: %v", err)
+	}
+	_, data, _ := repo.ReadObject(target)
+	expected := "object " + ids[1] + "\ntype commit\ntag v1\ntagger Alice <alice@example.com> 1700000000 +0100\n\nRelease 1\n"
+	if string(data) != expected {
+		t.Errorf("expected tag object:\n%s\ngot:\n%s", expected, data)
+	}
+
+	// Git reads the exported tag object, which keeps its ID
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	gitDir := filepath.Join(t.TempDir(), "export.git")
+	result, err := repo.ExportGit(gitDir)
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if strings.Join(result.Tags, " ") != "v1" {
+		t.Errorf("expected tag v1 to be exported, got %v", result.Tags)
+	}
+	if got := runGit(t, gitDir, "rev-parse", "v1", "v1^{commit}"); got != target+"\n"+ids[1] {
+		t.Errorf("expected Git to resolve v1 to %s and %s, got %s", target, ids[1], got)
+	}
+}
+
+// TestTagResolution tests using tags with log, diff and checkout
+func TestTagResolution(t *testing.T) {
+	setFixedIdentity(t)
+	ids := setupTwoCommits(t)
+	repo, _ := commands.Open(".")
+	repo.CreateTag("v1", "HEAD~1", commands.TagOptions{Message: "Release 1"})
+
+	history, err := repo.LogFrom("v1")
+	if err != nil {
+		t.Fatalf("failed to read log: %v", err)
+	}
+	if len(history) != 1 || history[0].ID != ids[0] {
+		t.Errorf("expected the log of v1 to hold only %s, got %v", ids[0], history)
+	}
+
+	if err := commands.Diff(commands.DiffOptions{Commits: []string{"v1", "main"}}); err != nil {
+		t.Errorf("failed to diff tags: %v", err)
+	}
+	if err := commands.Checkout("v1", commands.CheckoutOptions{}); err != nil {
+		t.Fatalf("failed to check out tag: %v", err)
+	}
+	content, _ := os.ReadFile("file.txt")
+	if string(content) != "version 1\n" {
+		t.Errorf("expected the tagged version to be checked out, got %q", content)
+	}
+	if head, _ := repo.ResolveCommit("HEAD"); head.ID != ids[0] {
+		t.Errorf("expected HEAD to be detached at %s", ids[0])
+	}
+
+	// A tag wins over a branch of the same name, as in Git
+	commands.CreateBranch("v1", "main")
+	if commit, _ := repo.ResolveCommit("v1"); commit.ID != ids[0] {
+		t.Errorf("expected v1 to resolve to the tag")
+	}
+	if commit, _ := repo.ResolveCommit("refs/heads/v1"); commit.ID != ids[1] {
+		t.Errorf("expected refs/heads/v1 to resolve to the branch")
+	}
+	if commit, _ := repo.ResolveCommit("v1^0"); commit == nil || commit.ID != ids[0] {
+		t.Errorf("expected suffixes to apply to tags")
+	}
+}
+
+// TestListAndDeleteTags tests listing tags with patterns and deleting them
+func TestListAndDeleteTags(t *testing.T) {
+	setupTwoCommits(t)
+	repo, _ := commands.Open(".")
+	for _, tag := range []string{"v1.0", "v1.1", "v2.0", "release/v1.0"} {
+		if _, err := repo.CreateTag(tag, "", commands.TagOptions{}); err != nil {
+			t.Fatalf("failed to create tag %s: %v", tag, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		expected string
+	}{
+		{name: "all tags", expected: "release/v1.0 v1.0 v1.1 v2.0"},
+		{name: "glob", patterns: []string{"v1.*"}, expected: "v1.0 v1.1"},
+		{name: "several patterns", patterns: []string{"v2*", "release/*"}, expected: "release/v1.0 v2.0"},
+		{name: "character class", patterns: []string{"v[2-9].0"}, expected: "v2.0"},
+		{name: "no match", patterns: []string{"v3*"}, expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := repo.ListTags(tt.patterns)
+			if err != nil {
+				t.Fatalf("failed to list tags: %v", err)
+			}
+			if got := strings.Join(tags, " "); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+	if _, err := repo.ListTags([]string{"v[1"}); err == nil {
+		t.Errorf("expected an invalid pattern to be rejected")
+	}
+
+	if _, err := repo.DeleteTag("release/v1.0"); err != nil {
+		t.Fatalf("failed to delete tag: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(commands.MyGitDir, "refs", "tags", "release")); !os.IsNotExist(err) {
+		t.Errorf("expected the empty tag directory to be removed")
+	}
+	if _, err := repo.DeleteTag("release/v1.0"); err == nil {
+		t.Errorf("expected deleting a missing tag to fail")
+	}
+	if tags, _ := repo.ListTags(nil); strings.Join(tags, " ") != "v1.0 v1.1 v2.0" {
+		t.Errorf("unexpected tags after deleting: %v", tags)
+	}
+}
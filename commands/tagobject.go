@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// gitTag is an annotated tag: a Git tag object naming another object
+type gitTag struct {
+	// Object and Type identify the tagged object, usually a commit
+	Object string
+	Type   string
+	// Name is the tag name without "refs/tags/"
+	Name string
+	// Tagger is who made the tag and when. Tags made by old versions of Git
+	// have none.
+	Tagger Signature
+	// Message is the message exactly as stored
+	Message string
+}
+
+// encodeTag serializes an annotated tag in the format of Git tag objects:
+//
+//	object <object ID>
+//	type <object type>
+//	tag <name>
+//	tagger <name> <<email>> <Unix time> <UTC offset>
+//
+//	<message>
+func encodeTag(tag *gitTag) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "object %s\ntype %s\ntag %s\n", tag.Object, tag.Type, tag.Name)
+	if !tag.Tagger.IsZero() {
+		fmt.Fprintf(&buf, "tagger %s %s\n", tag.Tagger, rawDate(tag.Tagger.When))
+	}
+	buf.WriteString("\n")
+	buf.WriteString(tag.Message)
+	return buf.Bytes()
+}
+
+// decodeTag parses a Git tag object
+func decodeTag(data []byte) (*gitTag, error) {
+	header, message, _ := strings.Cut(string(data), "\n\n")
+	tag := &gitTag{Message: message}
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			tag.Object = value
+		case "type":
+			tag.Type = value
+		case "tag":
+			tag.Name = value
+		case "tagger":
+			tagger, err := parseSignatureLine(value)
+			if err != nil {
+				return nil, fmt.Errorf("malformed tag object: %w", err)
+			}
+			tag.Tagger = tagger
+		}
+	}
+	if !isObjectID(tag.Object) || tag.Type == "" {
+		return nil, errors.New("malformed tag object")
+	}
+	return tag, nil
+}
@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// File modes recorded in tree entries
+const (
+	ModeFile       = "100644"
+	ModeExecutable = "100755"
+	ModeDir        = "40000"
+	// ModeSymlink marks a symbolic link, whose blob holds the link target
+	ModeSymlink = "120000"
+	// ModeGitlink marks a submodule: the entry names a commit of another
+	// repository. Such entries are kept in snapshots and the index, but
+	// submodules are never checked out: the working tree is left alone at
+	// their paths.
+	ModeGitlink = "160000"
+)
+
+// TreeEntry is a single entry of a tree object
+type TreeEntry struct {
+	Mode string
+	Name string
+	Hash string
+}
+
+// Tree is a tree object: the entries of one directory of a snapshot
+type Tree struct {
+	ID      string
+	Entries []TreeEntry
+}
+
+// FileEntry is a file in a snapshot, identified by its slash-separated path
+// relative to the repository root
+type FileEntry struct {
+	Path string
+	Mode string
+	Hash string
+}
+
+// WriteTree stores the given files as a hierarchy of tree objects in the
+// repository in the current directory
+func WriteTree(files []FileEntry) (string, error) {
+	r, err := openRepository()
+	if err != nil {
+		return "", err
+	}
+	return r.WriteTree(files)
+}
+
+// ReadTree reads a single tree object from the repository in the current
+// directory
+func ReadTree(hash string) (*Tree, error) {
+	r, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+	return r.ReadTree(hash)
+}
+
+// FlattenTree returns every file reachable from a tree in the repository in
+// the current directory
+func FlattenTree(hash string) ([]FileEntry, error) {
+	r, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+	return r.FlattenTree(hash)
+}
+
+// WriteTree stores the given files as a hierarchy of tree objects and returns
+// the ID of the root tree
+func (r *Repository) WriteTree(files []FileEntry) (string, error) {
+	var entries []TreeEntry
+	subdirs := make(map[string][]FileEntry)
+	var subdirNames []string
+
+	// Split files into entries of this directory and files of subdirectories
+	for _, file := range files {
+		name, rest, inSubdir := strings.Cut(file.Path, "/")
+		if !inSubdir {
+			entries = append(entries, TreeEntry{Mode: file.Mode, Name: name, Hash: file.Hash})
+			continue
+		}
+		if _, ok := subdirs[name]; !ok {
+			subdirNames = append(subdirNames, name)
+		}
+		subdirs[name] = append(subdirs[name], FileEntry{Path: rest, Mode: file.Mode, Hash: file.Hash})
+	}
+
+	// Write subdirectories first so their IDs can be referenced
+	for _, name := range subdirNames {
+		hash, err := r.WriteTree(subdirs[name])
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, TreeEntry{Mode: ModeDir, Name: name, Hash: hash})
+	}
+
+	data, err := encodeTree(entries)
+	if err != nil {
+		return "", err
+	}
+	return r.WriteObject(TreeObject, data)
+}
+
+// ReadTree reads a single tree object
+func (r *Repository) ReadTree(hash string) (*Tree, error) {
+	objType, data, err := r.ReadObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if objType != TreeObject {
+		return nil, fmt.Errorf("object %s is a %s, not a tree", hash, objType)
+	}
+	entries, err := decodeTree(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{ID: hash, Entries: entries}, nil
+}
+
+// FlattenTree returns every file reachable from a tree, sorted by path,
+// including submodules
+func (r *Repository) FlattenTree(hash string) ([]FileEntry, error) {
+	var files []FileEntry
+	if err := r.flattenTree(hash, "", &files); err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+func (r *Repository) flattenTree(hash, prefix string, files *[]FileEntry) error {
+	tree, err := r.ReadTree(hash)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree.Entries {
+		path := prefix + entry.Name
+		if entry.Mode == ModeDir {
+			if err := r.flattenTree(entry.Hash, path+"/", files); err != nil {
+				return err
+			}
+			continue
+		}
+		*files = append(*files, FileEntry{Path: path, Mode: entry.Mode, Hash: entry.Hash})
+	}
+	return nil
+}
+
+// encodeTree serializes entries as "<mode> <name>\x00<20-byte hash>" records.
+// Entries are sorted by name, with directories compared as if they had a
+// trailing slash, so the same snapshot always produces the same tree ID.
+func encodeTree(entries []TreeEntry) ([]byte, error) {
+	sorted := make([]TreeEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return treeSortKey(sorted[i]) < treeSortKey(sorted[j])
+	})
+
+	var buf bytes.Buffer
+	for _, entry := range sorted {
+		if entry.Name == "" || strings.ContainsAny(entry.Name, "/\x00") {
+			return nil, fmt.Errorf("invalid tree entry name: %q", entry.Name)
+		}
+		raw, err := hex.DecodeString(entry.Hash)
+		if err != nil || len(raw) != 20 {
+			return nil, fmt.Errorf("invalid object ID for %s: %q", entry.Name, entry.Hash)
+		}
+		fmt.Fprintf(&buf, "%s %s\x00", entry.Mode, entry.Name)
+		buf.Write(raw)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeTree(data []byte) ([]TreeEntry, error) {
+	var entries []TreeEntry
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || len(data) < nul+21 {
+			return nil, fmt.Errorf("malformed tree object")
+		}
+		// Trees from other repositories may be crafted to write outside the
+		// working tree or into a repository directory
+		name := string(data[space+1 : nul])
+		if !validPathComponent(name) {
+			return nil, fmt.Errorf("invalid tree entry name: %q", name)
+		}
+		entries = append(entries, TreeEntry{
+			Mode: string(data[:space]),
+			Name: name,
+			Hash: hex.EncodeToString(data[nul+1 : nul+21]),
+		})
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+// validPathComponent reports whether name can be a file or directory name of
+// a snapshot. Names that lead out of their directory or into a repository
+// directory are not.
+func validPathComponent(name string) bool {
+	switch name {
+	case "", ".", "..", DotGitDir, MyGitDir:
+		return false
+	}
+	return !strings.ContainsAny(name, "/\x00")
+}
+
+func treeSortKey(entry TreeEntry) string {
+	if entry.Mode == ModeDir {
+		return entry.Name + "/"
+	}
+	return entry.Name
+}
+
+// fileMode returns the tree entry mode for a file on disk, as found by
+// os.Lstat
+func fileMode(info os.FileInfo) string {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return ModeSymlink
+	case info.Mode()&0111 != 0:
+		return ModeExecutable
+	}
+	return ModeFile
+}
+
+// isSnapshotFile reports whether a file on disk, as found by os.Lstat, can
+// be stored in a snapshot: a regular file or a symbolic link
+func isSnapshotFile(info os.FileInfo) bool {
+	return info.Mode().IsRegular() || info.Mode()&os.ModeSymlink != 0
+}
@@ -0,0 +1,145 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+const helloBlob = "ce013625030ba8dba906f756967f9e9ca394464a"
+
+// TestWriteTree tests that tree IDs are stable for a given snapshot
+func TestWriteTree(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+
+	tests := []struct {
+		name     string
+		files    []commands.FileEntry
+		expected string
+	}{
+		{
+			name:     "empty tree",
+			files:    nil,
+			expected: "4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+		},
+		{
+			name: "nested tree",
+			files: []commands.FileEntry{
+				{Path: "hello.txt", Mode: commands.ModeFile, Hash: helloBlob},
+				{Path: "dir/a.txt", Mode: commands.ModeFile, Hash: helloBlob},
+			},
+			expected: "e3e6763c75a8b37a01fa16cc9eadc02241e02295",
+		},
+		{
+			name: "order of input does not matter",
+			files: []commands.FileEntry{
+				{Path: "dir/a.txt", Mode: commands.ModeFile, Hash: helloBlob},
+				{Path: "hello.txt", Mode: commands.ModeFile, Hash: helloBlob},
+			},
+			expected: "e3e6763c75a8b37a01fa16cc9eadc02241e02295",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := commands.WriteTree(tt.files)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if hash != tt.expected {
+				t.Errorf("expected tree %s, got %s", tt.expected, hash)
+			}
+		})
+	}
+}
+
+// TestFlattenTree tests that a written tree can be read back as a file list
+func TestFlattenTree(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+
+	files := []commands.FileEntry{
+		{Path: "b.txt", Mode: commands.ModeExecutable, Hash: helloBlob},
+		{Path: "a/b/c.txt", Mode: commands.ModeFile, Hash: helloBlob},
+		{Path: "a/d.txt", Mode: commands.ModeFile, Hash: helloBlob},
+	}
+	hash, err := commands.WriteTree(files)
+	if err != nil {
+		t.Fatalf("failed to write tree: %v", err)
+	}
+
+	tree, err := commands.ReadTree(hash)
+	if err != nil {
+		t.Fatalf("failed to read tree: %v", err)
+	}
+	if tree.ID != hash {
+		t.Errorf("expected tree ID %s, got %s", hash, tree.ID)
+	}
+	entries := tree.Entries
+	if len(entries) != 2 || entries[0].Name != "a" || entries[0].Mode != commands.ModeDir {
+		t.Errorf("unexpected root entries: %+v", entries)
+	}
+
+	flattened, err := commands.FlattenTree(hash)
+	if err != nil {
+		t.Fatalf("failed to flatten tree: %v", err)
+	}
+	expected := []commands.FileEntry{
+		{Path: "a/b/c.txt", Mode: commands.ModeFile, Hash: helloBlob},
+		{Path: "a/d.txt", Mode: commands.ModeFile, Hash: helloBlob},
+		{Path: "b.txt", Mode: commands.ModeExecutable, Hash: helloBlob},
+	}
+	if !reflect.DeepEqual(flattened, expected) {
+		t.Errorf("expected %+v, got %+v", expected, flattened)
+	}
+}
+
+// TestCommitRecordsFullSnapshot tests that unchanged files are carried forward
+func TestCommitRecordsFullSnapshot(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	commands.Init()
+
+	os.WriteFile("file1.txt", []byte("content1"), 0644)
+	commands.Add([]string{"file1.txt"})
+	commands.Commit("First commit")
+
+	os.Mkdir("sub", 0755)
+	os.WriteFile(filepath.Join("sub", "file2.txt"), []byte("content2"), 0644)
+	commands.Add([]string{"sub"})
+	if err := commands.Commit("Second commit"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	metadataPath := filepath.Join(commands.MyGitDir, commands.MetadataFile)
+	file, _ := os.Open(metadataPath)
+	defer file.Close()
+	var metadata struct {
+		CommitHistory []map[string]any `json:"commit_history"`
+	}
+	json.NewDecoder(file).Decode(&metadata)
+	if len(metadata.CommitHistory) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(metadata.CommitHistory))
+	}
+
+	treeHash, _ := metadata.CommitHistory[1]["tree"].(string)
+	files, err := commands.FlattenTree(treeHash)
+	if err != nil {
+		t.Fatalf("failed to flatten tree: %v", err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	expected := []string{"file1.txt", "sub/file2.txt"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected paths %v, got %v", expected, paths)
+	}
+}
@@ -0,0 +1,116 @@
+// Package mygit is the Go API of mygit. Open a repository and call its
+// methods to stage files, commit and read the history; unlike the commands
+// of the CLI they return typed data instead of printing it.
+//
+//	repo, err := mygit.Open(".")
+//	if err != nil {
+//		return err
+//	}
+//	if _, err := repo.Add([]string{"."}, mygit.AddOptions{}); err != nil {
+//		return err
+//	}
+//	commit, err := repo.Commit("Initial commit")
+//	if err != nil {
+//		return err
+//	}
+//	history, err := repo.Log()
+package mygit
+
+import (
+	"time"
+
+	"github.com/hgsgtk/mygit/commands"
+)
+
+type (
+	// Repository is an open repository
+	Repository = commands.Repository
+	// Commit is a commit in the history
+	Commit = commands.CommitRecord
+	// IndexEntry is a file tracked by the index
+	IndexEntry = commands.IndexEntry
+	// Index is the staging area
+	Index = commands.Index
+	// Tree is a tree object
+	Tree = commands.Tree
+	// TreeEntry is a single entry of a tree object
+	TreeEntry = commands.TreeEntry
+	// FileEntry is a file in a snapshot
+	FileEntry = commands.FileEntry
+	// FileChange is a path that differs between two snapshots
+	FileChange = commands.FileChange
+	// StatusResult compares HEAD, the index and the working tree
+	StatusResult = commands.StatusResult
+	// AddOptions controls Repository.Add
+	AddOptions = commands.AddOptions
+	// AddResult lists the paths whose index entries were changed by Add
+	AddResult = commands.AddResult
+	// IgnoredPathsError is returned by Add when paths named explicitly are ignored
+	IgnoredPathsError = commands.IgnoredPathsError
+	// CommitOptions controls Repository.CommitWithOptions
+	CommitOptions = commands.CommitOptions
+	// LogOptions controls Repository.LogWithOptions
+	LogOptions = commands.LogOptions
+	// Signature identifies the author or the committer of a commit
+	Signature = commands.Signature
+	// Config holds the variables of the user and repository config files
+	Config = commands.Config
+	// ConfigEntry is a variable set in a config file
+	ConfigEntry = commands.ConfigEntry
+	// GitExportResult summarizes Repository.ExportGit
+	GitExportResult = commands.GitExportResult
+	// GitImportResult summarizes Repository.ImportGit
+	GitImportResult = commands.GitImportResult
+	// TagOptions controls Repository.CreateTag
+	TagOptions = commands.TagOptions
+	// RevisionRange is the set of commits named by a revision range
+	RevisionRange = commands.RevisionRange
+	// ReflogEntry records one update of a ref
+	ReflogEntry = commands.ReflogEntry
+	// FastImportOptions controls Repository.FastImport
+	FastImportOptions = commands.FastImportOptions
+	// FastImportResult summarizes Repository.FastImport
+	FastImportResult = commands.FastImportResult
+	// Branch is a branch and the commit it points to
+	Branch = commands.Branch
+	// DiffOptions selects the sides Repository.DiffStat compares
+	DiffOptions = commands.DiffOptions
+	// FileStat counts the lines a change added and removed in one file
+	FileStat = commands.FileStat
+)
+
+// Errors that can be told apart with errors.Is
+var (
+	ErrNotRepository   = commands.ErrNotRepository
+	ErrUnknownRevision = commands.ErrUnknownRevision
+	ErrNothingToCommit = commands.ErrNothingToCommit
+	ErrConflict        = commands.ErrConflict
+	ErrLocalChanges    = commands.ErrLocalChanges
+)
+
+// Open opens the repository containing dir
+func Open(dir string) (*Repository, error) {
+	return commands.Open(dir)
+}
+
+// Init creates a repository in dir, which must exist, and opens it
+func Init(dir string) (*Repository, error) {
+	return commands.InitRepository(dir)
+}
+
+// ErrorCode returns the code the CLI reports for err with --json, such as
+// "unknown_revision"
+func ErrorCode(err error) string {
+	return commands.ErrorCode(err)
+}
+
+// ParseSignature parses an identity written as "Name <email>"
+func ParseSignature(text string) (Signature, error) {
+	return commands.ParseSignature(text)
+}
+
+// ParseDate parses a commit date such as "2006-01-02T15:04:05-07:00" or
+// "1136239445 -0700"
+func ParseDate(text string) (time.Time, error) {
+	return commands.ParseDate(text)
+}
@@ -0,0 +1,153 @@
+package mygit_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hgsgtk/mygit"
+)
+
+// TestRepositoryAPI tests staging, committing and reading the history
+// through the library without changing the current directory
+func TestRepositoryAPI(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := mygit.Init(dir)
+	if err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("b\n"), 0644)
+
+	added, err := repo.Add([]string{"a.txt", "sub"}, mygit.AddOptions{})
+	if err != nil {
+		t.Fatalf("failed to add: %v", err)
+	}
+	if len(added.Added) != 2 || added.Added[0] != "a.txt" || added.Added[1] != "sub/b.txt" {
+		t.Errorf("expected both files to be added, got %+v", added)
+	}
+	first, err := repo.Commit("Initial commit")
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if first.Message != "Initial commit" || len(first.Parents) != 0 || first.Tree == "" {
+		t.Errorf("unexpected first commit: %+v", first)
+	}
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("changed\n"), 0644)
+	os.Remove(filepath.Join(dir, "sub", "b.txt"))
+	updated, err := repo.Add(nil, mygit.AddOptions{All: true})
+	if err != nil {
+		t.Fatalf("failed to add: %v", err)
+	}
+	if len(updated.Updated) != 1 || len(updated.Removed) != 1 || updated.Removed[0] != "sub/b.txt" {
+		t.Errorf("expected a.txt updated and sub/b.txt removed, got %+v", updated)
+	}
+	second, err := repo.Commit("Second commit")
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	history, err := repo.Log()
+	if err != nil {
+		t.Fatalf("failed to read log: %v", err)
+	}
+	if len(history) != 2 || history[0].ID != second.ID || history[1].ID != first.ID {
+		t.Fatalf("expected the two commits newest first, got %+v", history)
+	}
+	if len(history[0].Parents) != 1 || history[0].Parents[0] != first.ID {
+		t.Errorf("expected the second commit to have the first as parent, got %v", history[0].Parents)
+	}
+
+	changes, err := repo.CommitChanges(second)
+	if err != nil {
+		t.Fatalf("failed to read changes: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("expected 2 changes, got %+v", changes)
+	}
+
+	tree, err := repo.ReadTree(second.Tree)
+	if err != nil {
+		t.Fatalf("failed to read tree: %v", err)
+	}
+	if len(tree.Entries) != 1 || tree.Entries[0].Name != "a.txt" {
+		t.Errorf("expected only a.txt in the tree, got %+v", tree.Entries)
+	}
+
+	resolved, err := repo.ResolveCommit("HEAD~1")
+	if err != nil || resolved.ID != first.ID {
+		t.Errorf("expected HEAD~1 to be the first commit, got %v (%v)", resolved, err)
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if !status.Clean() {
+		t.Errorf("expected a clean status, got %+v", status)
+	}
+}
+
+// TestOpen tests finding a repository from a subdirectory
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name          string
+		dir           string
+		expectedError bool
+	}{
+		{name: "root", dir: "repo"},
+		{name: "subdirectory", dir: "repo/sub"},
+		{name: "outside the repository", dir: ".", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			root := filepath.Join(tempDir, "repo")
+			os.MkdirAll(filepath.Join(root, "sub"), 0755)
+			if _, err := mygit.Init(root); err != nil {
+				t.Fatalf("failed to init: %v", err)
+			}
+
+			repo, err := mygit.Open(filepath.Join(tempDir, filepath.FromSlash(tt.dir)))
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected, _ := filepath.EvalSymlinks(root)
+			if repo.WorkTree() != expected {
+				t.Errorf("expected work tree %s, got %s", expected, repo.WorkTree())
+			}
+		})
+	}
+}
+
+// TestAddIgnoredPaths tests that ignored paths are reported as a typed error
+// while the other paths are still added
+func TestAddIgnoredPaths(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := mygit.Init(dir)
+	if err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, ".mygitignore"), []byte("*.log\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "debug.log"), []byte("log\n"), 0644)
+
+	result, err := repo.Add([]string{"a.txt", "debug.log"}, mygit.AddOptions{})
+	var ignored *mygit.IgnoredPathsError
+	if !errors.As(err, &ignored) || len(ignored.Paths) != 1 || ignored.Paths[0] != "debug.log" {
+		t.Fatalf("expected debug.log to be reported as ignored, got %v", err)
+	}
+	if result == nil || len(result.Added) != 1 || result.Added[0] != "a.txt" {
+		t.Errorf("expected a.txt to be added, got %+v", result)
+	}
+}